@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"os"
 	"strings"
 
@@ -8,6 +9,8 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/Feresey/mtest/db"
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/insert"
 	"github.com/Feresey/mtest/parse"
 )
 
@@ -22,9 +25,42 @@ type FileConfig struct {
 		Graph      string `yaml:"grahp"`
 	} `yaml:"files"`
 	Generate struct {
+		// зерно для генерации значений
+		Seed *int64 `yaml:"seed"`
+		// генерировать записи, которые база данных должна отклонить
+		Negative bool `yaml:"negative"`
+		// заполнение колонок внешних ключей
+		ForeignKeys struct {
+			// не допускать значений, которых нет в родительской таблице
+			OrphanFree bool `yaml:"orphan-free"`
+			// на каждую родительскую запись должна ссылаться хотя бы одна дочерняя
+			CoverParents bool `yaml:"cover-parents"`
+			// минимальное количество дочерних записей на каждую родительскую
+			FanOut int `yaml:"fan-out"`
+		} `yaml:"foreign-keys"`
+		// генерация большого количества записей
+		Volume struct {
+			// количество записей таблиц, включая граничные
+			Rows map[string]float64 `yaml:"rows"`
+			// распределение значений колонок
+			Distribution string `yaml:"distribution"`
+			// распределение ссылок внешних ключей на родительские записи
+			ForeignKeys string `yaml:"foreign-keys"`
+			// настройки колонок, где ключ - <схема>.<таблица>.<колонка>
+			Columns map[string]struct {
+				Distribution string  `yaml:"distribution"`
+				NullRatio    float64 `yaml:"null-ratio"`
+			} `yaml:"columns"`
+		} `yaml:"volume"`
 		Data struct {
 			Insert bool `yaml:"insert"`
-			Dump   struct {
+			// откатить вставленные записи после завершения вставки
+			Rollback bool `yaml:"rollback"`
+			// загружать записи с помощью COPY
+			Copy bool `yaml:"copy"`
+			// количество таблиц, которые вставляются одновременно
+			Parallel int `yaml:"parallel"`
+			Dump     struct {
 				Dir    string `yaml:"dir"`
 				Format string `yaml:"format"`
 			} `yaml:"dump"`
@@ -33,8 +69,55 @@ type FileConfig struct {
 }
 
 type AppConfig struct {
-	DB     db.Config
-	Parser parse.Config
+	DB       db.Config
+	Parser   parse.Config
+	Generate generate.Config
+	Insert   insert.Config
+	Dump     DumpConfig
+}
+
+type DumpConfig struct {
+	// Директория для сгенерированных записей, если не задан флаг --output. Пустая - stdout
+	Dir    string
+	Format DumpFormat
+}
+
+// OutputDir возвращает директорию для сгенерированных записей.
+// Флаг output имеет приоритет над Dir, директория из конфига создается, если ее нет.
+func (c DumpConfig) OutputDir(output string) (string, error) {
+	if output != "" || c.Dir == "" {
+		return output, nil
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return "", xerrors.Errorf("create dump dir: %w", err)
+	}
+	return c.Dir, nil
+}
+
+type DumpFormat string
+
+const (
+	DumpFormatCSV       DumpFormat = "csv"
+	DumpFormatJSON      DumpFormat = "json"
+	DumpFormatSQLInsert DumpFormat = "sql-insert"
+	DumpFormatSQLCopy   DumpFormat = "sql-copy-to"
+)
+
+var dumpFormats = []string{
+	string(DumpFormatCSV),
+	string(DumpFormatJSON),
+	string(DumpFormatSQLInsert),
+	string(DumpFormatSQLCopy),
+}
+
+func ParseDumpFormat(format string) (DumpFormat, error) {
+	for _, f := range dumpFormats {
+		if f == format {
+			return DumpFormat(f), nil
+		}
+	}
+	return "", xerrors.Errorf("unknown dump format %q, expected one of: %s",
+		format, strings.Join(dumpFormats, ", "))
 }
 
 func (fc FileConfig) Build() (*AppConfig, error) {
@@ -42,6 +125,17 @@ func (fc FileConfig) Build() (*AppConfig, error) {
 	if err != nil {
 		return nil, xerrors.Errorf("parse patterns failed: %w", err)
 	}
+	volume, err := fc.buildVolume()
+	if err != nil {
+		return nil, xerrors.Errorf("parse volume config: %w", err)
+	}
+	format := DumpFormatCSV
+	if fc.Generate.Data.Dump.Format != "" {
+		format, err = ParseDumpFormat(fc.Generate.Data.Dump.Format)
+		if err != nil {
+			return nil, xerrors.Errorf("parse dump format: %w", err)
+		}
+	}
 	return &AppConfig{
 		DB: db.Config{
 			Conn: fc.DBConn,
@@ -49,9 +143,71 @@ func (fc FileConfig) Build() (*AppConfig, error) {
 		Parser: parse.Config{
 			Patterns: patterns,
 		},
+		Generate: generate.Config{
+			Seed:     fc.Generate.Seed,
+			Negative: fc.Generate.Negative,
+			ForeignKeys: generate.ForeignKeyConfig{
+				OrphanFree:   fc.Generate.ForeignKeys.OrphanFree,
+				CoverParents: fc.Generate.ForeignKeys.CoverParents,
+				FanOut:       fc.Generate.ForeignKeys.FanOut,
+			},
+			Volume: volume,
+		},
+		Insert: insert.Config{
+			Enabled:  fc.Generate.Data.Insert,
+			Rollback: fc.Generate.Data.Rollback,
+			Copy:     fc.Generate.Data.Copy,
+			Parallel: fc.Generate.Data.Parallel,
+		},
+		Dump: DumpConfig{
+			Dir:    fc.Generate.Data.Dump.Dir,
+			Format: format,
+		},
 	}, nil
 }
 
+func (fc FileConfig) buildVolume() (generate.VolumeConfig, error) {
+	conf := fc.Generate.Volume
+	var (
+		res generate.VolumeConfig
+		err error
+	)
+	if len(conf.Rows) != 0 {
+		res.Rows = make(map[string]int64, len(conf.Rows))
+		for table, count := range conf.Rows {
+			if count < 0 || count != math.Trunc(count) {
+				return res, xerrors.Errorf("rows count of table %q must be a non-negative integer: %g", table, count)
+			}
+			res.Rows[table] = int64(count)
+		}
+	}
+	if conf.Distribution != "" {
+		if res.Values, err = generate.ParseDistribution(conf.Distribution); err != nil {
+			return res, err
+		}
+	}
+	if conf.ForeignKeys != "" {
+		if res.ForeignKeys, err = generate.ParseDistribution(conf.ForeignKeys); err != nil {
+			return res, err
+		}
+	}
+	if len(conf.Columns) != 0 {
+		res.Columns = make(map[string]generate.ColumnVolume, len(conf.Columns))
+	}
+	for colName, col := range conf.Columns {
+		colConf := generate.ColumnVolume{NullRatio: col.NullRatio}
+		if col.Distribution != "" {
+			dist, err := generate.ParseDistribution(col.Distribution)
+			if err != nil {
+				return res, xerrors.Errorf("column %q: %w", colName, err)
+			}
+			colConf.Distribution = &dist
+		}
+		res.Columns[colName] = colConf
+	}
+	return res, nil
+}
+
 func ReadConfig(confPath string) (*AppConfig, error) {
 	var fc FileConfig
 	file, err := os.ReadFile(confPath)
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpConfigOutputDir(t *testing.T) {
+	r := require.New(t)
+	dir := filepath.Join(t.TempDir(), "generated")
+
+	// флаг --output имеет приоритет над конфигом
+	out, err := DumpConfig{Dir: dir}.OutputDir("out")
+	r.NoError(err)
+	r.Equal("out", out)
+	r.NoDirExists(dir)
+
+	out, err = DumpConfig{Dir: dir}.OutputDir("")
+	r.NoError(err)
+	r.Equal(dir, out)
+	r.DirExists(dir)
+
+	out, err = DumpConfig{}.OutputDir("")
+	r.NoError(err)
+	r.Empty(out)
+
+	file := filepath.Join(t.TempDir(), "file")
+	r.NoError(os.WriteFile(file, nil, 0o600))
+	_, err = DumpConfig{Dir: filepath.Join(file, "dir")}.OutputDir("")
+	r.ErrorContains(err, "create dump dir")
+}
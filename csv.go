@@ -7,13 +7,17 @@ import (
 	"github.com/Feresey/mtest/schema"
 	"golang.org/x/exp/constraints"
 	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
 )
 
 type CSVConverter struct{}
 
 func (w *CSVConverter) ConvertRecords(table schema.Table, pr generate.Records) [][]string {
 	res := make([][]string, 0, len(pr.Records)+1)
-	res = append(res, maps.Keys(table.Columns))
+	header := maps.Keys(table.Columns)
+	sort.Strings(header)
+	res = append(res, header)
 
 	for _, precord := range pr.Records {
 		record := w.partialToFullMap(precord, table.Columns)
@@ -38,18 +42,52 @@ func (w *CSVConverter) partialToFullMap(
 	return res
 }
 
-// func (w *CSVConverter) partialFromMap(cols map[int]string) generate.PartialRecord {
-// 	var res generate.PartialRecord
-// 	for colNum, value := range cols {
-// 		if value == "" {
-// 			continue
-// 		}
-// 		res.Columns = append(res.Columns, colNum)
-// 		res.Values = append(res.Values, value)
-// 	}
+// ParseRecords преобразует строки CSV файла (первая строка - заголовок с именами колонок) в частичные записи.
+// Пустые ячейки означают, что значение колонки должен заполнить генератор.
+func (w *CSVConverter) ParseRecords(table schema.Table, rows [][]string) (generate.Records, error) {
+	var res generate.Records
+	if len(rows) == 0 {
+		return res, nil
+	}
+
+	header := rows[0]
+	for idx, colName := range header {
+		if _, ok := table.Columns[colName]; !ok {
+			return res, xerrors.Errorf("column %q not found in table %q", colName, table)
+		}
+		if slices.Contains(header[:idx], colName) {
+			return res, xerrors.Errorf("column %q is duplicated in header", colName)
+		}
+	}
 
-// 	return res
-// }
+	for rowNum, row := range rows[1:] {
+		if len(row) != len(header) {
+			return res, xerrors.Errorf("row %d has %d values, but header has %d columns",
+				rowNum+1, len(row), len(header))
+		}
+		cols := make(map[string]string, len(header))
+		for idx, colName := range header {
+			cols[colName] = row[idx]
+		}
+		res.Records = append(res.Records, w.partialFromMap(cols))
+	}
+
+	return res, nil
+}
+
+func (w *CSVConverter) partialFromMap(cols map[string]string) generate.Record {
+	var res generate.Record
+	for colName, value := range cols {
+		if value == "" {
+			continue
+		}
+		res.Columns = append(res.Columns, colName)
+		res.Values = append(res.Values, value)
+	}
+	sort.Sort(res)
+
+	return res
+}
 
 func sortByKey[K constraints.Ordered, V any](m map[K]V) []V {
 	keys := make([]K, 0, len(m))
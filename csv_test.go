@@ -0,0 +1,163 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+func csvTestSchema() *schema.Schema {
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	users := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "users"},
+		Columns: map[string]schema.Column{
+			"id":   {ColNum: 1, Name: "id", Type: int4},
+			"name": {ColNum: 2, Name: "name", Type: int4},
+			"age":  {ColNum: 3, Name: "age", Type: int4},
+		},
+	}
+	return &schema.Schema{Tables: map[string]schema.Table{users.String(): users}}
+}
+
+func TestCSVConverterParseRecords(t *testing.T) {
+	users := csvTestSchema().Tables["test.users"]
+	tests := []struct {
+		name    string
+		rows    [][]string
+		want    generate.Records
+		wantErr string
+	}{
+		{name: "empty file"},
+		{name: "header only", rows: [][]string{{"id", "name"}}},
+		{
+			name: "empty cells are filled by generator",
+			rows: [][]string{
+				{"name", "id", "age"},
+				{"'alice'", "1", ""},
+				{"", "", ""},
+				{"NULL", "", "18"},
+			},
+			want: generate.Records{Records: []generate.Record{
+				{Columns: []string{"id", "name"}, Values: []string{"1", "'alice'"}},
+				{},
+				{Columns: []string{"age", "name"}, Values: []string{"18", "NULL"}},
+			}},
+		},
+		{
+			name:    "unknown column",
+			rows:    [][]string{{"id", "email"}, {"1", "'a@b.c'"}},
+			wantErr: `column "email" not found in table "test.users"`,
+		},
+		{
+			name:    "duplicate column",
+			rows:    [][]string{{"id", "name", "id"}, {"1", "'a'", "2"}},
+			wantErr: `column "id" is duplicated in header`,
+		},
+		{
+			name:    "short row",
+			rows:    [][]string{{"id", "name"}, {"1", "'a'"}, {"2"}},
+			wantErr: "row 2 has 1 values, but header has 2 columns",
+		},
+		{
+			name:    "long row",
+			rows:    [][]string{{"id"}, {"1", "2"}},
+			wantErr: "row 1 has 2 values, but header has 1 columns",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			var conv CSVConverter
+			records, err := conv.ParseRecords(users, tt.rows)
+			if tt.wantErr != "" {
+				r.ErrorContains(err, tt.wantErr)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tt.want, records)
+		})
+	}
+}
+
+func TestLoadPartialRecords(t *testing.T) {
+	s := csvTestSchema()
+	writeFiles := func(t *testing.T, files map[string]string) string {
+		dir := t.TempDir()
+		for name, content := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+		}
+		return dir
+	}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    map[string]generate.Records
+		wantErr string
+	}{
+		{
+			name: "table records",
+			files: map[string]string{
+				"test.users.csv": "id,name,age\n1,'bob',\n,,30\n",
+			},
+			want: map[string]generate.Records{
+				"test.users": {Records: []generate.Record{
+					{Columns: []string{"id", "name"}, Values: []string{"1", "'bob'"}},
+					{Columns: []string{"age"}, Values: []string{"30"}},
+				}},
+			},
+		},
+		{
+			name: "files without table are skipped",
+			files: map[string]string{
+				"test.orders.csv": "id\n1\n",
+				"users.csv":       "id\n1\n",
+				"test.users.json": "{}",
+			},
+			want: map[string]generate.Records{},
+		},
+		{
+			name:    "unknown column",
+			files:   map[string]string{"test.users.csv": "id,email\n1,'a'\n"},
+			wantErr: `column "email" not found in table "test.users"`,
+		},
+		{
+			name:    "wrong row length",
+			files:   map[string]string{"test.users.csv": "id,name\n1,'a',2\n"},
+			wantErr: "wrong number of fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			p := &GenerateCommand{BaseCommand: BaseCommand{log: zap.NewNop()}}
+			partial, err := p.LoadPartialRecords(s, writeFiles(t, tt.files))
+			if tt.wantErr != "" {
+				r.ErrorContains(err, tt.wantErr)
+				r.ErrorContains(err, `read partial records for table "test.users"`)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tt.want, partial.Records)
+		})
+	}
+
+	p := &GenerateCommand{BaseCommand: BaseCommand{log: zap.NewNop()}}
+	partial, err := p.LoadPartialRecords(s, "")
+	require.NoError(t, err)
+	require.Empty(t, partial.Records)
+
+	_, err = p.LoadPartialRecords(s, filepath.Join(t.TempDir(), "missing"))
+	require.ErrorContains(t, err, "read partial records dir")
+}
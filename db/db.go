@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/tracelog"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -41,6 +42,37 @@ func NewDB(
 	return c, nil
 }
 
+// NewPool создает пул из maxConns соединений с базой данных.
+func NewPool(
+	ctx context.Context,
+	logger *zap.Logger,
+	cfg Config,
+	maxConns int32,
+) (*pgxpool.Pool, error) {
+	cnf, err := pgxpool.ParseConfig(cfg.Conn)
+	if err != nil {
+		return nil, xerrors.Errorf("parse config: %w", err)
+	}
+	cnf.MaxConns = maxConns
+
+	if cfg.debug {
+		cnf.ConnConfig.Tracer = &tracelog.TraceLog{
+			Logger:   tracelog.LoggerFunc(queryMessageLog(logger)),
+			LogLevel: tracelog.LogLevelInfo,
+		}
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, cnf)
+	if err != nil {
+		return nil, xerrors.Errorf("create connection pool: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, xerrors.Errorf("connect to database: %w", err)
+	}
+	return pool, nil
+}
+
 func queryMessageLog(log *zap.Logger) func(
 	ctx context.Context,
 	level tracelog.LogLevel,
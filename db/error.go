@@ -18,6 +18,8 @@ func (e Error) Error() string {
 	return fmt.Sprintf("%s: %v", e.Message, e.Err)
 }
 
+func (e Error) Unwrap() error { return e.Err }
+
 func (e Error) Pretty() string {
 	return fmt.Sprintf("%s: %v:\nquery:\n%s\n\n===\nargs: %s", e.Message, e.Err, e.Query, spew.Sdump(e.Args...))
 }
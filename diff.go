@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+const (
+	diffFormatText = "text"
+	diffFormatJSON = "json"
+)
+
+type diffFlags struct {
+	flags
+	format     *cli.StringFlag
+	outputPath *cli.StringFlag
+	exitCode   *cli.BoolFlag
+}
+
+func (f diffFlags) Set() []cli.Flag {
+	return append(
+		f.flags.Set(),
+		f.format,
+		f.outputPath,
+		f.exitCode,
+	)
+}
+
+type DiffCommand struct {
+	flags diffFlags
+	BaseCommand
+}
+
+func NewDiffCommand(f flags) *DiffCommand {
+	return &DiffCommand{
+		flags: diffFlags{
+			flags: f,
+			format: &cli.StringFlag{
+				Name:  "format",
+				Value: diffFormatText,
+				Usage: "--format " + diffFormatText + "|" + diffFormatJSON,
+				Action: func(ctx *cli.Context, format string) error {
+					if format != diffFormatText && format != diffFormatJSON {
+						return xerrors.Errorf("diff format %q is not supported", format)
+					}
+					return nil
+				},
+			},
+			outputPath: &cli.StringFlag{
+				Name:      "output",
+				Usage:     "-o diff.json, stdout by default",
+				TakesFile: true,
+				Aliases:   []string{"o"},
+			},
+			exitCode: &cli.BoolFlag{
+				Name:  "exit-code",
+				Usage: "exit with code 1 if schemas differ",
+			},
+		},
+		// set up by init
+		BaseCommand: BaseCommand{},
+	}
+}
+
+func (d *DiffCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "diff",
+		Description: "compare two schema dumps",
+		ArgsUsage:   "old.json new.json",
+		Flags:       d.flags.Set(),
+		Before:      d.Init,
+		Action:      d.Run,
+	}
+}
+
+// Init создаёт только логгер: для сравнения дампов не нужны ни конфиг, ни база данных.
+func (d *DiffCommand) Init(ctx *cli.Context) error {
+	log, err := newLogger(d.flags.debug.Get(ctx))
+	if err != nil {
+		return cli.Exit(xerrors.Errorf("create logger: %w", err), 2)
+	}
+	d.BaseCommand = BaseCommand{log: log}
+	return nil
+}
+
+func (d *DiffCommand) Run(ctx *cli.Context) (err error) {
+	if ctx.NArg() != 2 {
+		return cli.Exit("expected two schema dumps: old.json new.json", 2)
+	}
+	oldSchema, err := d.getSchemaFromFile(ctx.Args().Get(0))
+	if err != nil {
+		return xerrors.Errorf("load old schema: %w", err)
+	}
+	newSchema, err := d.getSchemaFromFile(ctx.Args().Get(1))
+	if err != nil {
+		return xerrors.Errorf("load new schema: %w", err)
+	}
+
+	diff := schema.Compare(oldSchema, newSchema)
+
+	var out io.Writer = os.Stdout
+	if path := d.flags.outputPath.Get(ctx); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return xerrors.Errorf("create output file: %w", err)
+		}
+		defer func() {
+			// ошибка cli.Exit не оборачивается, чтобы сохранить код возврата
+			if closeErr := file.Close(); closeErr != nil {
+				err = errors.Join(err, xerrors.Errorf("close output file: %w", closeErr))
+			}
+		}()
+		out = file
+	}
+
+	switch d.flags.format.Get(ctx) {
+	case diffFormatJSON:
+		err = diff.WriteJSON(out)
+	default:
+		err = diff.WriteText(out)
+	}
+	if err != nil {
+		return xerrors.Errorf("write diff: %w", err)
+	}
+
+	if d.flags.exitCode.Get(ctx) && !diff.Empty() {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
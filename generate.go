@@ -12,16 +12,40 @@ import (
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
 	"golang.org/x/xerrors"
 
+	"github.com/Feresey/mtest/db"
 	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/insert"
 	"github.com/Feresey/mtest/schema"
 )
 
+const (
+	csvExt    = ".csv"
+	sqlExt    = ".sql"
+	ndjsonExt = ".ndjson"
+	// имя файла для форматов, в которых записи всех таблиц сохраняются в один файл
+	recordsDumpName = "records"
+)
+
 type generateFlags struct {
 	flags
 	schema     SchemaLoaderFlags
 	outputPath *cli.StringFlag
+	partialDir *cli.StringFlag
+	format     *cli.StringFlag
+	insert     *cli.BoolFlag
+	rollback   *cli.BoolFlag
+	copy       *cli.BoolFlag
+	parallel   *cli.IntFlag
+	negative   *cli.BoolFlag
+	orphanFree *cli.BoolFlag
+	cover      *cli.BoolFlag
+	fanOut     *cli.IntFlag
+	rows       *cli.StringFlag
+	valuesDist *cli.StringFlag
+	fkDist     *cli.StringFlag
 }
 
 func (f generateFlags) Set() []cli.Flag {
@@ -29,6 +53,7 @@ func (f generateFlags) Set() []cli.Flag {
 		f.flags.Set(),
 		f.outputPath,
 		f.schema.dumpPath,
+		f.schema.fromSQL,
 	)
 }
 
@@ -58,9 +83,88 @@ func NewGenerateCommand(f flags) *GenerateCommand {
 					}
 					return nil
 				},
-				Usage:   "-o outdir",
+				Usage:   "-o outdir, default is generate.data.dump.dir from config",
 				Aliases: []string{"o"},
 			},
+			partialDir: &cli.StringFlag{
+				Name:      "partial-dir",
+				TakesFile: true,
+				Action: func(ctx *cli.Context, dirname string) error {
+					info, err := os.Stat(dirname)
+					if err != nil {
+						return xerrors.Errorf("partial records path does not exist: %q", dirname)
+					}
+					if !info.IsDir() {
+						return xerrors.Errorf("partial records path is not a directory: %q", dirname)
+					}
+					return nil
+				},
+				Usage: "--partial-dir dir with <schema>.<table>.csv files",
+			},
+			format: &cli.StringFlag{
+				Name: "format",
+				Action: func(ctx *cli.Context, format string) error {
+					_, err := ParseDumpFormat(format)
+					return err
+				},
+				Usage: "--format " + strings.Join(dumpFormats, "|"),
+			},
+			insert: &cli.BoolFlag{
+				Name:  "insert",
+				Usage: "insert generated records into the database",
+			},
+			rollback: &cli.BoolFlag{
+				Name:  "rollback",
+				Usage: "rollback inserted records at the end",
+			},
+			copy: &cli.BoolFlag{
+				Name:  "copy",
+				Usage: "load generated records with COPY instead of INSERT",
+			},
+			parallel: &cli.IntFlag{
+				Name:  "parallel",
+				Usage: "insert up to N independent tables at the same time, every table in its own transaction",
+			},
+			negative: &cli.BoolFlag{
+				Name:  "negative",
+				Usage: "generate records that must be rejected by the database, they are checked only on insert",
+			},
+			orphanFree: &cli.BoolFlag{
+				Name:  "orphan-free",
+				Usage: "fill foreign keys only with values present in referenced tables, skip records that can not be filled",
+			},
+			cover: &cli.BoolFlag{
+				Name:  "cover-parents",
+				Usage: "generate at least one child record for every record of referenced tables",
+			},
+			fanOut: &cli.IntFlag{
+				Name:  "fan-out",
+				Usage: "minimal number of child records for every record of referenced tables",
+			},
+			rows: &cli.StringFlag{
+				Name:  "rows",
+				Usage: "--rows users=1e6,public.orders=5e6 generate records up to the given count, records are streamed",
+				Action: func(ctx *cli.Context, rows string) error {
+					_, err := generate.ParseRows(rows)
+					return err
+				},
+			},
+			valuesDist: &cli.StringFlag{
+				Name:  "distribution",
+				Usage: "--distribution uniform|normal[:stddev]|zipf[:s] distribution of column values of --rows records",
+				Action: func(ctx *cli.Context, dist string) error {
+					_, err := generate.ParseDistribution(dist)
+					return err
+				},
+			},
+			fkDist: &cli.StringFlag{
+				Name:  "fk-distribution",
+				Usage: "--fk-distribution uniform|normal[:stddev]|zipf[:s] distribution of foreign key references of --rows records",
+				Action: func(ctx *cli.Context, dist string) error {
+					_, err := generate.ParseDistribution(dist)
+					return err
+				},
+			},
 			schema: NewSchemaLoaderFlags(),
 		},
 	}
@@ -70,9 +174,24 @@ func (p *GenerateCommand) Command() *cli.Command {
 	return &cli.Command{
 		Name:        "generate",
 		Description: "generate records",
-		Flags:       p.flags.Set(),
-		Before:      p.Init,
-		Action:      p.GenerateRecords,
+		Flags: append(p.flags.Set(),
+			p.flags.partialDir,
+			p.flags.format,
+			p.flags.insert,
+			p.flags.rollback,
+			p.flags.copy,
+			p.flags.parallel,
+			p.flags.negative,
+			p.flags.orphanFree,
+			p.flags.cover,
+			p.flags.fanOut,
+			p.flags.rows,
+			p.flags.valuesDist,
+			p.flags.fkDist,
+		),
+		Before: p.Init,
+		Action: p.GenerateRecords,
+		After:  p.schemaLoader.Cleanup,
 		Subcommands: []*cli.Command{
 			p.DefaultsCommand(),
 		},
@@ -100,37 +219,198 @@ func (p *GenerateCommand) GenerateRecords(ctx *cli.Context) error {
 		return err
 	}
 
-	gen, err := generate.New(p.log, s)
+	genConf := p.cnf.Generate
+	if ctx.IsSet(p.flags.negative.Name) {
+		genConf.Negative = p.flags.negative.Get(ctx)
+	}
+	if ctx.IsSet(p.flags.orphanFree.Name) {
+		genConf.ForeignKeys.OrphanFree = p.flags.orphanFree.Get(ctx)
+	}
+	if ctx.IsSet(p.flags.cover.Name) {
+		genConf.ForeignKeys.CoverParents = p.flags.cover.Get(ctx)
+	}
+	if ctx.IsSet(p.flags.fanOut.Name) {
+		genConf.ForeignKeys.FanOut = p.flags.fanOut.Get(ctx)
+	}
+	// значения флагов проверены при разборе
+	if ctx.IsSet(p.flags.rows.Name) {
+		genConf.Volume.Rows, _ = generate.ParseRows(p.flags.rows.Get(ctx))
+	}
+	if ctx.IsSet(p.flags.valuesDist.Name) {
+		genConf.Volume.Values, _ = generate.ParseDistribution(p.flags.valuesDist.Get(ctx))
+	}
+	if ctx.IsSet(p.flags.fkDist.Name) {
+		genConf.Volume.ForeignKeys, _ = generate.ParseDistribution(p.flags.fkDist.Get(ctx))
+	}
+	gen, err := generate.New(p.log, s, genConf)
 	if err != nil {
-		return err
+		return xerrors.Errorf("create generator: %w", err)
+	}
+
+	partial, err := p.LoadPartialRecords(s, p.flags.partialDir.Get(ctx))
+	if err != nil {
+		return xerrors.Errorf("load partial records: %w", err)
+	}
+
+	format := p.cnf.Dump.Format
+	if ctx.IsSet(p.flags.format.Name) {
+		format, err = ParseDumpFormat(p.flags.format.Get(ctx))
+		if err != nil {
+			return err
+		}
+	}
+	conf := p.cnf.Insert
+	if ctx.IsSet(p.flags.insert.Name) {
+		conf.Enabled = p.flags.insert.Get(ctx)
+	}
+	if ctx.IsSet(p.flags.rollback.Name) {
+		conf.Rollback = p.flags.rollback.Get(ctx)
+	}
+	if ctx.IsSet(p.flags.copy.Name) {
+		conf.Copy = p.flags.copy.Get(ctx)
+	}
+	if ctx.IsSet(p.flags.parallel.Name) {
+		conf.Parallel = p.flags.parallel.Get(ctx)
 	}
 
-	_ = gen
+	if len(genConf.Volume.Rows) != 0 {
+		return p.GenerateVolume(ctx, gen, partial, format, conf)
+	}
 
-	// TODO load partial
 	// TODO load domains
-	// records, warnings := gen.GenerateRecords(nil, nil)
-	// if len(warnings) > 0 {
-	// 	p.log.Warn("generate records", zap.Errors("warnings", warnings))
-	// }
-	// for tableOID, records := range records {
-	// 	table, ok := s.Tables[tableOID]
-	// 	if !ok {
-	// 		err := xerrors.Errorf("internal error: table with oid %d not found for generated records", tableOID)
-	// 		p.log.Error(err.Error())
-	// 		return err
-	// 	}
-	// 	err := p.DumpRecords(&table, records, p.flags.outputPath.Get(ctx))
-	// 	if err != nil {
-	// 		err = xerrors.Errorf("dump generated records: %w", err)
-	// 		p.log.Error(err.Error())
-	// 		return err
-	// 	}
-	// }
+	records, warnings := gen.GenerateRecords(partial, nil)
+	if len(warnings) > 0 {
+		p.log.Warn("generate records", zap.Errors("warnings", warnings))
+	}
+	// негативные записи нельзя выполнить как скрипт, они проверяются только при вставке
+	positive := make(map[string]generate.Records, len(records))
+	for tableName, tableRecords := range records {
+		positive[tableName] = tableRecords.Positive()
+	}
+	dumpdir, err := p.cnf.Dump.OutputDir(p.flags.outputPath.Get(ctx))
+	if err != nil {
+		return err
+	}
+	err = p.DumpGeneratedRecords(gen.Order(), positive, format, dumpdir)
+	if err != nil {
+		return xerrors.Errorf("dump generated records: %w", err)
+	}
+
+	if !conf.Enabled {
+		if genConf.Negative {
+			p.log.Warn("negative records are checked only on insert")
+		}
+		return nil
+	}
+	return p.InsertRecords(ctx, gen.Levels(), records, conf)
+}
+
+// InsertRecords вставляет сгенерированные записи в базу данных по уровням заполнения таблиц.
+// Для одновременной вставки таблиц создается пул соединений.
+func (p *GenerateCommand) InsertRecords(
+	ctx *cli.Context,
+	levels [][]schema.Table,
+	records map[string]generate.Records,
+	conf insert.Config,
+) error {
+	var conn insert.TxBeginner
+	if conf.Parallel > 1 {
+		pool, err := p.connectPool(ctx, p.flags.debug.Get(ctx), conf.Parallel)
+		if err != nil {
+			return cli.Exit(err, 3)
+		}
+		defer pool.Close()
+		conn = pool
+	} else {
+		single, err := p.schemaLoader.Connect(ctx, p.flags.flags)
+		if err != nil {
+			return cli.Exit(err, 3)
+		}
+		conn = single
+	}
 
+	report, err := insert.NewInserter(conn, p.log).InsertLevels(ctx.Context, levels, records, conf)
+	if err != nil {
+		var pErr db.Error
+		if errors.As(err, &pErr) {
+			p.log.Error(pErr.Pretty())
+		}
+		return xerrors.Errorf("insert records: %w", err)
+	}
+
+	errs := report.Errors()
+	for _, err := range errs {
+		p.log.Warn("insert record failed", zap.Error(err))
+	}
+	if len(errs) != 0 {
+		return xerrors.Errorf("failed to insert %d records", len(errs))
+	}
 	return nil
 }
 
+// LoadPartialRecords загружает частичные записи из CSV файлов директории dir.
+// Имя файла должно совпадать с именем таблицы: <schema>.<table>.csv.
+func (b *BaseCommand) LoadPartialRecords(
+	s *schema.Schema,
+	dir string,
+) (partial generate.PartialRecords, err error) {
+	partial.Records = make(map[string]generate.Records)
+	if dir == "" {
+		return partial, nil
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return partial, xerrors.Errorf("read partial records dir: %w", err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != csvExt {
+			continue
+		}
+		tableName := strings.TrimSuffix(file.Name(), csvExt)
+		table, ok := s.Tables[tableName]
+		if !ok {
+			b.log.Warn("partial records file does not match any table",
+				zap.String("file", file.Name()))
+			continue
+		}
+
+		records, err := b.readPartialRecords(table, filepath.Join(dir, file.Name()))
+		if err != nil {
+			return partial, xerrors.Errorf("read partial records for table %q: %w", table, err)
+		}
+		b.log.Info("loaded partial records for table",
+			zap.String("table", tableName),
+			zap.Int("n", len(records.Records)),
+		)
+		partial.Records[tableName] = records
+	}
+
+	return partial, nil
+}
+
+func (b *BaseCommand) readPartialRecords(
+	table schema.Table,
+	filename string,
+) (records generate.Records, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return records, xerrors.Errorf("open partial records file: %w", err)
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return records, xerrors.Errorf("parse csv file %q: %w", filename, err)
+	}
+
+	var conv CSVConverter
+	return conv.ParseRecords(table, rows)
+}
+
 func (p *GenerateCommand) DefaultsCommand() *cli.Command {
 	var tableRegs []*regexp.Regexp
 	tablesPatterns := &cli.StringSliceFlag{
@@ -153,14 +433,24 @@ func (p *GenerateCommand) DefaultsCommand() *cli.Command {
 		Aliases: []string{"n"},
 		Usage:   `-n my_schema.my_table -n other_schema.other_table`,
 	}
+	withDependencies := &cli.BoolFlag{
+		Name:  "with-dependencies",
+		Usage: "also select tables referenced by foreign keys of selected tables, required for valid data",
+	}
+	withReferencedBy := &cli.BoolFlag{
+		Name:  "with-referenced-by",
+		Usage: "also select tables referencing selected tables, implies --with-dependencies",
+	}
 	return &cli.Command{
 		Name:        "default",
 		Description: "generate default partial records",
-		Flags: append(
-			p.flags.Set(),
+		// общие флаги задаются родительской команде: mtest generate -i dump.json -o dir default -n table
+		Flags: []cli.Flag{
 			tablesNames,
 			tablesPatterns,
-		),
+			withDependencies,
+			withReferencedBy,
+		},
 		Action: func(ctx *cli.Context) error {
 			s, err := p.schemaLoader.GetSchema(ctx, p.flags.schema)
 			if err != nil {
@@ -183,10 +473,20 @@ func (p *GenerateCommand) DefaultsCommand() *cli.Command {
 					}
 				}
 			}
+			if withDependencies.Get(ctx) || withReferencedBy.Get(ctx) {
+				selected := maps.Keys(tables)
+				closure := s.Closure(selected, withReferencedBy.Get(ctx))
+				for _, name := range closure {
+					tables[name] = s.Tables[name]
+				}
+				p.log.Info("tables are selected with dependencies",
+					zap.Int("selected", len(selected)),
+					zap.Strings("tables", closure))
+			}
 			p.log.Debug("got table oids",
 				zap.Strings("tables", mapset.NewThreadUnsafeSetFromMapKeys(tables).ToSlice()))
 
-			gen, err := generate.New(p.log, s)
+			gen, err := generate.New(p.log, s, p.cnf.Generate)
 			if err != nil {
 				return xerrors.Errorf("create generator: %w", err)
 			}
@@ -206,6 +506,55 @@ func (p *GenerateCommand) DefaultsCommand() *cli.Command {
 	}
 }
 
+// DumpGeneratedRecords сохраняет сгенерированные записи таблиц в указанном формате.
+func (p *GenerateCommand) DumpGeneratedRecords(
+	order []schema.Table,
+	records map[string]generate.Records,
+	format DumpFormat,
+	dumpdir string,
+) error {
+	switch format {
+	case DumpFormatSQLInsert, DumpFormatSQLCopy:
+		err := dumpToFile(
+			p.log,
+			dumpdir, recordsDumpName+sqlExt,
+			records,
+			func(w io.Writer, records map[string]generate.Records) error {
+				if format == DumpFormatSQLCopy {
+					var conv SQLCopyConverter
+					return conv.WriteRecords(w, order, records)
+				}
+				var conv SQLInsertConverter
+				return conv.WriteRecords(w, order, records)
+			})
+		if err != nil {
+			err = xerrors.Errorf("dump records as sql script: %w", err)
+			p.log.Error(err.Error())
+			return err
+		}
+		return nil
+	case DumpFormatCSV, DumpFormatJSON:
+		for _, table := range order {
+			tableRecords, ok := records[table.String()]
+			if !ok {
+				continue
+			}
+			var err error
+			if format == DumpFormatJSON {
+				err = p.DumpJSONRecords(table, tableRecords, dumpdir)
+			} else {
+				err = p.DumpRecords(table, tableRecords, dumpdir)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return xerrors.Errorf("dump format %q is not supported", format)
+	}
+}
+
 func (p *GenerateCommand) DumpRecords(
 	table schema.Table,
 	records generate.Records,
@@ -213,7 +562,7 @@ func (p *GenerateCommand) DumpRecords(
 ) error {
 	err := dumpToFile(
 		p.log,
-		dumpdir, table.String(),
+		dumpdir, table.String()+csvExt,
 		records,
 		func(w io.Writer, records generate.Records) error {
 			var conv CSVConverter
@@ -228,21 +577,43 @@ func (p *GenerateCommand) DumpRecords(
 	return nil
 }
 
+func (p *GenerateCommand) DumpJSONRecords(
+	table schema.Table,
+	records generate.Records,
+	dumpdir string,
+) error {
+	err := dumpToFile(
+		p.log,
+		dumpdir, table.String()+ndjsonExt,
+		records,
+		func(w io.Writer, records generate.Records) error {
+			var conv JSONConverter
+			return conv.WriteRecords(w, records)
+		})
+	if err != nil {
+		err = xerrors.Errorf("dump json records for table %q: %w", table, err)
+		p.log.Error(err.Error())
+		return err
+	}
+
+	return nil
+}
+
 func dumpToFile[T any](
 	log *zap.Logger,
 	dumpdir string,
-	tableName string,
+	fileName string,
 	data T,
 	dumpFunc func(w io.Writer, data T) error,
 ) (err error) {
 	log = log.WithOptions(zap.AddCallerSkip(1))
-	dumpfile := filepath.Join(dumpdir, tableName+".csv")
+	dumpfile := filepath.Join(dumpdir, fileName)
 	if dumpdir == "" {
 		dumpfile = "stdout"
 	}
 	defer func() {
-		log.Info("dumped partial records for table",
-			zap.String("table", tableName),
+		log.Info("dumped records",
+			zap.String("name", fileName),
 			zap.String("dumpfile", dumpfile),
 			zap.Error(err),
 		)
@@ -254,7 +625,7 @@ func dumpToFile[T any](
 	} else {
 		file, err := os.Create(dumpfile)
 		if err != nil {
-			err := xerrors.Errorf("create output file for default checks: %w", err)
+			err := xerrors.Errorf("create output file for records: %w", err)
 			log.Error(err.Error())
 			return cli.Exit("", 5)
 		}
@@ -0,0 +1,407 @@
+package generate
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+// максимальное количество подряд отброшенных значений домена, нарушающих CHECK ограничения.
+const maxCheckedDomainSkips = 10 * defaultTopDomainIterations
+
+// CheckConstraint описывает разобранное CHECK ограничение таблицы.
+type CheckConstraint struct {
+	Name string
+	// Колонки, которые упоминаются в выражении
+	Columns []string
+
+	expr checkExpr
+	// ограничение описывает границы партиций, а не CHECK ограничение таблицы
+	partition bool
+	// ограничение не проверяет база данных, нарушающие его значения просто не генерируются
+	sequence bool
+}
+
+// ParseCheck разбирает определение CHECK ограничения.
+func ParseCheck(c *schema.Constraint) (*CheckConstraint, error) {
+	expr, err := parseCheckExpr(c.Definition)
+	if err != nil {
+		return nil, xerrors.Errorf("parse check constraint %q: %w", c.Name, err)
+	}
+	res := &CheckConstraint{Name: c.Name, expr: expr}
+	walkCheckExpr(expr, func(e checkExpr) {
+		if col, ok := e.(checkColumn); ok && !slices.Contains(res.Columns, col.name) {
+			res.Columns = append(res.Columns, col.name)
+		}
+	})
+	return res, nil
+}
+
+func (c *CheckConstraint) String() string { return c.Name }
+
+// Check проверяет значения колонок записи, заданные SQL литералами.
+// Как и в PostgreSQL, ограничение выполнено, если выражение истинно или равно NULL.
+// Если значений колонок не хватает для вычисления, то ограничение считается выполненным.
+func (c *CheckConstraint) Check(values map[string]string) bool {
+	v, ok := c.expr.eval(values)
+	if !ok || v.null {
+		return true
+	}
+	b, ok := parseCheckBool(v.value)
+	return !ok || b
+}
+
+// satisfied проверяет, что выражение истинно, как условие WHERE.
+// В отличие от Check, значение NULL не выполняет условие.
+// known = false, если значений колонок не хватает для вычисления.
+func (c *CheckConstraint) satisfied(values map[string]string) (match, known bool) {
+	v, ok := c.expr.eval(values)
+	if !ok {
+		return false, false
+	}
+	if v.null {
+		return false, true
+	}
+	b, ok := parseCheckBool(v.value)
+	return ok && b, ok
+}
+
+// violation возвращает нарушение ограничения значением колонки.
+// Для ограничений на несколько колонок colName пустой.
+func (c *CheckConstraint) violation(colName string) Violation {
+	if c.partition {
+		// в ошибке PostgreSQL нет имени ограничения
+		return Violation{Kind: ViolationPartition, Column: colName}
+	}
+	return Violation{Kind: ViolationCheck, Column: colName, Constraint: c.Name}
+}
+
+// checkBound описывает константу, с которой сравнивается колонка в выражении.
+type checkBound struct {
+	column string
+	value  string
+	// с константой сравнивается длина значения колонки
+	length bool
+}
+
+// bounds возвращает константы, с которыми сравниваются колонки.
+func (c *CheckConstraint) bounds() (res []checkBound) {
+	walkCheckExpr(c.expr, func(e checkExpr) {
+		var (
+			operand checkExpr
+			consts  []checkExpr
+		)
+		switch e := e.(type) {
+		case checkCompare:
+			operand, consts = e.left, []checkExpr{e.right}
+			if _, ok := e.left.(checkConst); ok {
+				operand, consts = e.right, []checkExpr{e.left}
+			}
+		case checkAny:
+			operand, consts = e.left, e.items
+		default:
+			return
+		}
+
+		var bound checkBound
+		switch op := operand.(type) {
+		case checkColumn:
+			bound.column = op.name
+		case checkLength:
+			col, ok := op.arg.(checkColumn)
+			if !ok {
+				return
+			}
+			bound.column, bound.length = col.name, true
+		default:
+			return
+		}
+		for _, item := range consts {
+			if item, ok := item.(checkConst); ok && !item.value.null {
+				bound.value = item.value.value
+				res = append(res, bound)
+			}
+		}
+	})
+	return res
+}
+
+// walkCheckExpr обходит все узлы выражения.
+func walkCheckExpr(e checkExpr, fn func(checkExpr)) {
+	fn(e)
+	var children []checkExpr
+	switch e := e.(type) {
+	case checkLength:
+		children = []checkExpr{e.arg}
+	case checkCompare:
+		children = []checkExpr{e.left, e.right}
+	case checkAny:
+		children = append([]checkExpr{e.left}, e.items...)
+	case checkArray:
+		children = e.items
+	case checkIsNull:
+		children = []checkExpr{e.arg}
+	case checkIsBool:
+		children = []checkExpr{e.arg}
+	case checkNot:
+		children = []checkExpr{e.arg}
+	case checkLogic:
+		children = []checkExpr{e.left, e.right}
+	}
+	for _, child := range children {
+		walkCheckExpr(child, fn)
+	}
+}
+
+// parseTableChecks разбирает CHECK ограничения таблицы в порядке их имен.
+// В этом же порядке их проверяет PostgreSQL, поэтому первое нарушенное ограничение совпадает с ошибкой базы.
+// Ограничения, которые не удалось разобрать, не учитываются.
+func parseTableChecks(log *zap.Logger, table schema.Table) []*CheckConstraint {
+	names := maps.Keys(table.Constraints)
+	sort.Strings(names)
+	var res []*CheckConstraint
+	for _, name := range names {
+		c := table.Constraints[name]
+		if c.Type != schema.ConstraintTypeCheck {
+			continue
+		}
+		check, err := ParseCheck(c)
+		if err != nil {
+			log.Warn("check constraint is not supported and will be ignored",
+				zap.Stringer("table", table),
+				zap.String("constraint", c.Name),
+				zap.String("definition", c.Definition),
+				zap.Error(err),
+			)
+			continue
+		}
+		res = append(res, check)
+	}
+	return res
+}
+
+// failedCheck возвращает первое ограничение, которое нарушают значения, или nil.
+func failedCheck(checks []*CheckConstraint, values map[string]string) *CheckConstraint {
+	for _, check := range checks {
+		if !check.Check(values) {
+			return check
+		}
+	}
+	return nil
+}
+
+// checkColumnValue проверяет значение колонки без учета остальных колонок записи.
+func checkColumnValue(checks []*CheckConstraint, colName, value string) *CheckConstraint {
+	return failedCheck(checks, map[string]string{colName: value})
+}
+
+// columnCheckValues возвращает граничные значения колонки из CHECK ограничений.
+// Например для CHECK (age >= 18) это 17, 18 и 19.
+// valid - значения, которые проходят все ограничения, invalid - значения, нарушающие одно из них.
+// Значения, которые не помещаются в тип колонки, отбрасываются.
+func columnCheckValues(col schema.Column, checks []*CheckConstraint) (valid []string, invalid []negativeValue) {
+	seen := make(map[string]bool)
+	for _, check := range checks {
+		for _, bound := range check.bounds() {
+			if bound.column != col.Name {
+				continue
+			}
+			for _, literal := range boundLiterals(col, bound) {
+				if seen[literal] || !fitsColumn(col, literal) {
+					continue
+				}
+				seen[literal] = true
+				failed := checkColumnValue(checks, col.Name, literal)
+				if failed == nil {
+					valid = append(valid, literal)
+					continue
+				}
+				if failed.sequence {
+					continue
+				}
+				invalid = append(invalid, negativeValue{violation: failed.violation(col.Name), value: literal})
+			}
+		}
+	}
+	return valid, invalid
+}
+
+// boundLiterals возвращает SQL литералы значения на границе и по обе стороны от нее.
+func boundLiterals(col schema.Column, bound checkBound) []string {
+	if bound.length {
+		n, err := strconv.Atoi(bound.value)
+		if err != nil {
+			return nil
+		}
+		var res []string
+		for _, l := range []int{n - 1, n, n + 1} {
+			if l >= 0 {
+				res = append(res, quote(strings.Repeat("0", l)))
+			}
+		}
+		return res
+	}
+
+	typ := baseType(col.Type)
+	n, ok := new(big.Rat).SetString(bound.value)
+	if !ok || !isNumericType(typ) {
+		return []string{columnLiteral(typ, bound.value)}
+	}
+
+	// шаг - минимальное значение, которое можно сохранить в колонке
+	scale := 0
+	switch typ.TypeName.Name {
+	case "int2", "int4", "int8":
+	case "numeric":
+		if attr := columnDomainAttributes(col); attr.NumericPrecision != 0 {
+			scale = attr.NumericScale
+			break
+		}
+		fallthrough
+	default:
+		if _, frac, ok := strings.Cut(bound.value, "."); ok {
+			scale = len(frac)
+		}
+	}
+	if scale < 0 {
+		scale = 0
+	}
+	step := new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+
+	res := make([]string, 0, 3)
+	for _, delta := range []int64{-1, 0, 1} {
+		v := new(big.Rat).Mul(step, big.NewRat(delta, 1))
+		res = append(res, v.Add(v, n).FloatString(scale))
+	}
+	return res
+}
+
+func isNumericType(typ *schema.DBType) bool {
+	if typ.TypeName.Schema != pgCatalogSchema {
+		return false
+	}
+	switch typ.TypeName.Name {
+	case "int2", "int4", "int8", "float4", "float8", "numeric":
+		return true
+	}
+	return false
+}
+
+// columnLiteral возвращает SQL литерал значения в том же виде, в каком его возвращают домены.
+func columnLiteral(typ *schema.DBType, value string) string {
+	switch {
+	case typ.Type == schema.DataTypeEnum:
+		return fmt.Sprintf("%s::%s", quote(value), typ)
+	case typ.TypeName.Schema == pgCatalogSchema && typ.TypeName.Name == "bool":
+		return value
+	}
+	return quote(value)
+}
+
+// columnDomainAttributes возвращает аттрибуты колонки с учетом домена, на котором она основана.
+func columnDomainAttributes(col schema.Column) schema.DomainAttributes {
+	attr := col.Attributes.DomainAttributes
+	for typ := col.Type; typ.Type == schema.DataTypeDomain && typ.ElemType != nil; typ = typ.ElemType {
+		if typ.DomainAttributes != nil {
+			attr = *typ.DomainAttributes
+		}
+	}
+	return attr
+}
+
+// fitsColumn проверяет, что значение можно сохранить в колонке без ошибки преобразования типа.
+func fitsColumn(col schema.Column, literal string) bool {
+	value, isNull, err := ParseLiteral(literal)
+	if err != nil || isNull {
+		return true
+	}
+	attr := columnDomainAttributes(col)
+	if attr.HasCharMaxLength && utf8.RuneCountInString(value) > attr.CharMaxLength {
+		return false
+	}
+
+	typ := baseType(col.Type)
+	if typ.TypeName.Schema != pgCatalogSchema {
+		return true
+	}
+	switch typ.TypeName.Name {
+	case "int2", "int4", "int8":
+		bits := map[string]int{"int2": 16, "int4": 32, "int8": 64}[typ.TypeName.Name]
+		_, err := strconv.ParseInt(value, 10, bits)
+		return err == nil
+	case "numeric":
+		if attr.NumericPrecision == 0 {
+			return true
+		}
+		n, ok := new(big.Rat).SetString(value)
+		if !ok {
+			return true
+		}
+		limit, _ := new(big.Rat).SetString(numericOverflow(attr.NumericPrecision, attr.NumericScale))
+		return new(big.Rat).Abs(n).Cmp(limit) < 0
+	}
+	return true
+}
+
+// checkedDomain пропускает значения домена, которые нарушают CHECK ограничения колонки.
+// Перед значениями домена перебираются допустимые граничные значения из ограничений.
+type checkedDomain struct {
+	base    Domain
+	colName string
+	checks  []*CheckConstraint
+	extra   []string
+
+	index int
+	value string
+}
+
+func newCheckedDomain(base Domain, colName string, checks []*CheckConstraint, extra []string) *checkedDomain {
+	d := &checkedDomain{base: base, colName: colName, checks: checks, extra: extra}
+	d.Reset()
+	return d
+}
+
+func (d *checkedDomain) Reset() {
+	d.base.Reset()
+	d.index = -1
+}
+
+func (d *checkedDomain) Next() bool {
+	if d.index+1 < len(d.extra) {
+		d.index++
+		d.value = d.extra[d.index]
+		return true
+	}
+	for skipped := 0; skipped < maxCheckedDomainSkips; skipped++ {
+		if !d.base.Next() {
+			return false
+		}
+		if value := d.base.Value(); checkColumnValue(d.checks, d.colName, value) == nil {
+			d.value = value
+			return true
+		}
+	}
+	return false
+}
+
+func (d *checkedDomain) Value() string { return d.value }
+
+// columnChecks возвращает ограничения, в которых упоминается колонка.
+func columnChecks(checks []*CheckConstraint, colName string) (res []*CheckConstraint) {
+	for _, check := range checks {
+		if slices.Contains(check.Columns, colName) {
+			res = append(res, check)
+		}
+	}
+	return res
+}
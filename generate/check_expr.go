@@ -0,0 +1,792 @@
+package generate
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+)
+
+// checkValue описывает значение выражения CHECK ограничения.
+// Значения хранятся в текстовом представлении, логические значения - как true и false.
+type checkValue struct {
+	null  bool
+	value string
+}
+
+var checkNull = checkValue{null: true}
+
+func checkBool(b bool) checkValue { return checkValue{value: strconv.FormatBool(b)} }
+
+// checkExpr описывает узел разобранного выражения CHECK ограничения.
+type checkExpr interface {
+	// eval вычисляет выражение для значений колонок записи, заданных SQL литералами.
+	// Возвращает false, если значение неизвестно, например если не хватает значений колонок.
+	eval(values map[string]string) (checkValue, bool)
+}
+
+type (
+	checkConst  struct{ value checkValue }
+	checkColumn struct{ name string }
+	// длина строки, функции length и char_length
+	checkLength  struct{ arg checkExpr }
+	checkCompare struct {
+		op          string
+		left, right checkExpr
+	}
+	// сравнение со списком значений, x op ANY (...) или x op ALL (...). IN - частный случай
+	checkAny struct {
+		op    string
+		left  checkExpr
+		items []checkExpr
+		all   bool
+	}
+	// список значений ARRAY[...]. Вычисляется только как часть checkAny
+	checkArray  struct{ items []checkExpr }
+	checkIsNull struct {
+		arg checkExpr
+		not bool
+	}
+	// IS [NOT] TRUE и IS [NOT] FALSE
+	checkIsBool struct {
+		arg   checkExpr
+		value bool
+		not   bool
+	}
+	checkNot   struct{ arg checkExpr }
+	checkLogic struct {
+		and         bool
+		left, right checkExpr
+	}
+)
+
+func (e checkConst) eval(map[string]string) (checkValue, bool) { return e.value, true }
+
+func (e checkColumn) eval(values map[string]string) (checkValue, bool) {
+	literal, ok := values[e.name]
+	if !ok {
+		return checkValue{}, false
+	}
+	value, isNull, err := ParseLiteral(literal)
+	if err != nil {
+		return checkValue{}, false
+	}
+	if isNull {
+		return checkNull, true
+	}
+	return checkValue{value: value}, true
+}
+
+func (e checkLength) eval(values map[string]string) (checkValue, bool) {
+	v, ok := e.arg.eval(values)
+	if !ok || v.null {
+		return v, ok
+	}
+	return checkValue{value: strconv.Itoa(utf8.RuneCountInString(v.value))}, true
+}
+
+func (e checkCompare) eval(values map[string]string) (checkValue, bool) {
+	l, lok := e.left.eval(values)
+	r, rok := e.right.eval(values)
+	if !lok || !rok {
+		return checkValue{}, false
+	}
+	if l.null || r.null {
+		return checkNull, true
+	}
+	return checkBool(compareOp(e.op, compareCheckValues(l.value, r.value))), true
+}
+
+func (e checkAny) eval(values map[string]string) (checkValue, bool) {
+	null := false
+	for _, item := range e.items {
+		v, ok := checkCompare{op: e.op, left: e.left, right: item}.eval(values)
+		if !ok {
+			return checkValue{}, false
+		}
+		if v.null {
+			null = true
+			continue
+		}
+		// для ANY результат определяет первое истинное сравнение, для ALL - первое ложное
+		if (v.value == "true") != e.all {
+			return v, true
+		}
+	}
+	if null {
+		return checkNull, true
+	}
+	return checkBool(e.all), true
+}
+
+func (e checkArray) eval(map[string]string) (checkValue, bool) { return checkValue{}, false }
+
+func (e checkIsNull) eval(values map[string]string) (checkValue, bool) {
+	v, ok := e.arg.eval(values)
+	if !ok {
+		return checkValue{}, false
+	}
+	return checkBool(v.null != e.not), true
+}
+
+func (e checkIsBool) eval(values map[string]string) (checkValue, bool) {
+	v, ok := e.arg.eval(values)
+	if !ok {
+		return checkValue{}, false
+	}
+	if v.null {
+		return checkBool(e.not), true
+	}
+	b, ok := parseCheckBool(v.value)
+	if !ok {
+		return checkValue{}, false
+	}
+	return checkBool((b == e.value) != e.not), true
+}
+
+func (e checkNot) eval(values map[string]string) (checkValue, bool) {
+	v, ok := e.arg.eval(values)
+	if !ok || v.null {
+		return v, ok
+	}
+	b, ok := parseCheckBool(v.value)
+	if !ok {
+		return checkValue{}, false
+	}
+	return checkBool(!b), true
+}
+
+// eval вычисляет AND и OR по правилам трехзначной логики SQL.
+// Если одна из частей определяет результат, то вторая может быть неизвестна.
+func (e checkLogic) eval(values map[string]string) (checkValue, bool) {
+	// значение, определяющее результат: false для AND и true для OR
+	dominant := !e.and
+	var (
+		null, unknown bool
+	)
+	for _, arg := range []checkExpr{e.left, e.right} {
+		v, ok := arg.eval(values)
+		if ok && v.null {
+			null = true
+			continue
+		}
+		b, isBool := parseCheckBool(v.value)
+		switch {
+		case !ok || !isBool:
+			unknown = true
+		case b == dominant:
+			return checkBool(dominant), true
+		}
+	}
+	switch {
+	case unknown:
+		return checkValue{}, false
+	case null:
+		return checkNull, true
+	default:
+		return checkBool(!dominant), true
+	}
+}
+
+func parseCheckBool(s string) (value, ok bool) {
+	switch strings.ToLower(s) {
+	case "true", "t":
+		return true, true
+	case "false", "f":
+		return false, true
+	}
+	return false, false
+}
+
+func compareOp(op string, cmp int) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "<>", "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // ">="
+		return cmp >= 0
+	}
+}
+
+// compareCheckValues сравнивает значения как числа, логические значения или строки.
+// Строки сравниваются побайтово, правила сортировки базы данных не учитываются.
+func compareCheckValues(a, b string) int {
+	if an, ok := parseCheckNumber(a); ok {
+		if bn, ok := parseCheckNumber(b); ok {
+			return an.cmp(bn)
+		}
+	}
+	if ab, ok := parseCheckBool(a); ok {
+		if bb, ok := parseCheckBool(b); ok {
+			switch {
+			case ab == bb:
+				return 0
+			case bb:
+				return -1
+			default:
+				return 1
+			}
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// checkNumber описывает число, в том числе NaN и бесконечности.
+type checkNumber struct {
+	rat *big.Rat
+	// -1 для -Infinity, 1 для Infinity
+	inf int
+	nan bool
+}
+
+func parseCheckNumber(s string) (checkNumber, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "nan":
+		return checkNumber{nan: true}, true
+	case "infinity", "+infinity", "inf", "+inf":
+		return checkNumber{inf: 1}, true
+	case "-infinity", "-inf":
+		return checkNumber{inf: -1}, true
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return checkNumber{}, false
+	}
+	return checkNumber{rat: r}, true
+}
+
+// cmp сравнивает числа так же, как PostgreSQL: NaN больше всех остальных значений.
+func (n checkNumber) cmp(o checkNumber) int {
+	rank := func(n checkNumber) int {
+		switch {
+		case n.nan:
+			return 2
+		default:
+			return n.inf
+		}
+	}
+	if r1, r2 := rank(n), rank(o); r1 != 0 || r2 != 0 {
+		switch {
+		case r1 < r2:
+			return -1
+		case r1 > r2:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return n.rat.Cmp(o.rat)
+}
+
+type checkTokenKind int
+
+const (
+	checkTokenEOF checkTokenKind = iota
+	// идентификатор или ключевое слово. Идентификаторы без кавычек приведены к нижнему регистру.
+	checkTokenIdent
+	checkTokenQuotedIdent
+	checkTokenString
+	checkTokenNumber
+	checkTokenOp
+)
+
+type checkToken struct {
+	kind  checkTokenKind
+	value string
+}
+
+// многосимвольные операторы, которые распознает лексер
+var checkOps = []string{"::", "<=", ">=", "<>", "!="}
+
+// tokenizeCheck разбивает выражение на лексемы.
+func tokenizeCheck(src string) ([]checkToken, error) {
+	var toks []checkToken
+	for pos := 0; pos < len(src); {
+		r, size := utf8.DecodeRuneInString(src[pos:])
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+		case r == '\'':
+			var sb strings.Builder
+			end := -1
+			for i := pos + 1; i < len(src); i++ {
+				if src[i] != '\'' {
+					sb.WriteByte(src[i])
+					continue
+				}
+				if i+1 < len(src) && src[i+1] == '\'' {
+					sb.WriteByte('\'')
+					i++
+					continue
+				}
+				end = i + 1
+				break
+			}
+			if end < 0 {
+				return nil, xerrors.New("unterminated string literal")
+			}
+			toks = append(toks, checkToken{kind: checkTokenString, value: sb.String()})
+			pos = end
+		case r == '"':
+			end := strings.IndexByte(src[pos+1:], '"')
+			if end < 0 {
+				return nil, xerrors.New("unterminated quoted identifier")
+			}
+			toks = append(toks, checkToken{kind: checkTokenQuotedIdent, value: src[pos+1 : pos+1+end]})
+			pos += end + 2
+		case unicode.IsDigit(r) || r == '.' && pos+1 < len(src) && unicode.IsDigit(rune(src[pos+1])):
+			end := pos
+			for end < len(src) && (unicode.IsDigit(rune(src[end])) || src[end] == '.') {
+				end++
+			}
+			if end < len(src) && (src[end] == 'e' || src[end] == 'E') {
+				exp := end + 1
+				if exp < len(src) && (src[exp] == '+' || src[exp] == '-') {
+					exp++
+				}
+				if exp < len(src) && unicode.IsDigit(rune(src[exp])) {
+					for end = exp; end < len(src) && unicode.IsDigit(rune(src[end])); end++ {
+					}
+				}
+			}
+			toks = append(toks, checkToken{kind: checkTokenNumber, value: src[pos:end]})
+			pos = end
+		case unicode.IsLetter(r) || r == '_':
+			end := pos
+			for end < len(src) {
+				r, size := utf8.DecodeRuneInString(src[end:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '$' {
+					break
+				}
+				end += size
+			}
+			toks = append(toks, checkToken{kind: checkTokenIdent, value: strings.ToLower(src[pos:end])})
+			pos = end
+		default:
+			op := string(r)
+			for _, candidate := range checkOps {
+				if strings.HasPrefix(src[pos:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			toks = append(toks, checkToken{kind: checkTokenOp, value: op})
+			pos += len(op)
+		}
+	}
+	return append(toks, checkToken{kind: checkTokenEOF}), nil
+}
+
+// ключевые слова, которые не могут быть частью имени типа после ::
+var checkKeywords = []string{
+	"and", "or", "not", "between", "symmetric", "in", "is", "isnull", "notnull",
+	"any", "all", "some", "like", "ilike", "similar", "escape",
+}
+
+// checkParser разбирает выражение CHECK ограничения.
+// Поддерживаются сравнения, BETWEEN, IN, ANY и ALL со списком значений, IS NULL, IS TRUE и IS FALSE,
+// функции length и char_length, логические операторы AND, OR и NOT.
+// Приведения типов разбираются, но не учитываются при вычислении.
+type checkParser struct {
+	toks []checkToken
+	pos  int
+}
+
+func (p *checkParser) peek() checkToken { return p.toks[p.pos] }
+
+func (p *checkParser) next() checkToken {
+	tok := p.toks[p.pos]
+	if tok.kind != checkTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *checkParser) isKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == checkTokenIdent && tok.value == keyword
+}
+
+func (p *checkParser) acceptKeyword(keyword string) bool {
+	if p.isKeyword(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *checkParser) isOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == checkTokenOp && tok.value == op
+}
+
+func (p *checkParser) acceptOp(op string) bool {
+	if p.isOp(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *checkParser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.unexpected("%q", op)
+	}
+	return nil
+}
+
+func (p *checkParser) unexpected(format string, args ...any) error {
+	tok := p.peek()
+	got := strconv.Quote(tok.value)
+	if tok.kind == checkTokenEOF {
+		got = "end of expression"
+	}
+	return xerrors.Errorf("expected "+format+", got %s", append(args, got)...)
+}
+
+func (p *checkParser) parseOr() (checkExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = checkLogic{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *checkParser) parseAnd() (checkExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = checkLogic{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *checkParser) parseNot() (checkExpr, error) {
+	if p.acceptKeyword("not") {
+		arg, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return checkNot{arg: arg}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *checkParser) parsePredicate() (checkExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind == checkTokenOp {
+		switch tok.value {
+		case "=", "<>", "!=", "<", "<=", ">", ">=":
+			p.next()
+			return p.parseComparison(tok.value, left)
+		}
+	}
+
+	switch {
+	case p.acceptKeyword("is"):
+		not := p.acceptKeyword("not")
+		switch {
+		case p.acceptKeyword("null"):
+			return checkIsNull{arg: left, not: not}, nil
+		case p.acceptKeyword("true"):
+			return checkIsBool{arg: left, value: true, not: not}, nil
+		case p.acceptKeyword("false"):
+			return checkIsBool{arg: left, value: false, not: not}, nil
+		}
+		return nil, p.unexpected("NULL, TRUE or FALSE")
+	case p.acceptKeyword("isnull"):
+		return checkIsNull{arg: left}, nil
+	case p.acceptKeyword("notnull"):
+		return checkIsNull{arg: left, not: true}, nil
+	}
+
+	// NOT после операнда относится к BETWEEN или IN, иначе это атрибут NOT VALID
+	not := false
+	if p.isKeyword("not") {
+		// после NOT всегда есть хотя бы лексема конца выражения
+		if next := p.toks[p.pos+1]; next.kind == checkTokenIdent && (next.value == "between" || next.value == "in") {
+			p.next()
+			not = true
+		}
+	}
+	var res checkExpr
+	switch {
+	case p.acceptKeyword("between"):
+		symmetric := p.acceptKeyword("symmetric")
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptKeyword("and") {
+			return nil, p.unexpected("AND")
+		}
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		res = checkLogic{
+			and:   true,
+			left:  checkCompare{op: ">=", left: left, right: low},
+			right: checkCompare{op: "<=", left: left, right: high},
+		}
+		if symmetric {
+			res = checkLogic{left: res, right: checkLogic{
+				and:   true,
+				left:  checkCompare{op: ">=", left: left, right: high},
+				right: checkCompare{op: "<=", left: left, right: low},
+			}}
+		}
+	case p.acceptKeyword("in"):
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		items, err := p.parseList(")")
+		if err != nil {
+			return nil, err
+		}
+		res = checkAny{op: "=", left: left, items: items}
+	default:
+		return left, nil
+	}
+	if not {
+		res = checkNot{arg: res}
+	}
+	return res, nil
+}
+
+// parseComparison разбирает правую часть сравнения, в том числе op ANY (ARRAY[...]).
+func (p *checkParser) parseComparison(op string, left checkExpr) (checkExpr, error) {
+	all := p.isKeyword("all")
+	if p.isKeyword("any") || p.isKeyword("some") || all {
+		p.next()
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		arr, ok := arg.(checkArray)
+		if !ok {
+			return nil, xerrors.New("only ARRAY[...] is supported in ANY and ALL")
+		}
+		return checkAny{op: op, left: left, items: arr.items, all: all}, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return checkCompare{op: op, left: left, right: right}, nil
+}
+
+// parseOperand разбирает значение с необязательными приведениями типа.
+func (p *checkParser) parseOperand() (checkExpr, error) {
+	res, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("::") {
+		if err := p.skipTypeName(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// skipTypeName пропускает имя типа, например character varying(10)[] или timestamp without time zone.
+func (p *checkParser) skipTypeName() error {
+	words := 0
+	for tok := p.peek(); tok.kind == checkTokenIdent && !slices.Contains(checkKeywords, tok.value) ||
+		tok.kind == checkTokenQuotedIdent; tok = p.peek() {
+		p.next()
+		words++
+		// схема типа
+		if p.acceptOp(".") {
+			words = 0
+		}
+	}
+	if words == 0 {
+		return p.unexpected("type name")
+	}
+	if p.acceptOp("(") {
+		if _, err := p.parseList(")"); err != nil {
+			return err
+		}
+	}
+	for p.acceptOp("[") {
+		if err := p.expectOp("]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *checkParser) parsePrimary() (checkExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case checkTokenNumber:
+		return checkConst{value: checkValue{value: tok.value}}, nil
+	case checkTokenString:
+		return checkConst{value: checkValue{value: tok.value}}, nil
+	case checkTokenQuotedIdent:
+		return p.parseColumnRef(tok.value), nil
+	case checkTokenOp:
+		switch tok.value {
+		case "(":
+			expr, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return expr, p.expectOp(")")
+		case "-", "+":
+			num := p.next()
+			if num.kind != checkTokenNumber {
+				p.pos--
+				return nil, p.unexpected("number")
+			}
+			value := num.value
+			if tok.value == "-" {
+				value = "-" + value
+			}
+			return checkConst{value: checkValue{value: value}}, nil
+		}
+	case checkTokenIdent:
+		switch tok.value {
+		case "true", "false":
+			return checkConst{value: checkValue{value: tok.value}}, nil
+		case "null":
+			return checkConst{value: checkNull}, nil
+		case "array":
+			if err := p.expectOp("["); err != nil {
+				return nil, err
+			}
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return checkArray{items: items}, nil
+		}
+		switch {
+		case p.isOp("("):
+			return p.parseFunction(tok.value)
+		case p.peek().kind == checkTokenString:
+			// константа с типом, например date '2020-01-01'
+			return checkConst{value: checkValue{value: p.next().value}}, nil
+		case slices.Contains(checkKeywords, tok.value):
+			p.pos--
+			return nil, p.unexpected("operand")
+		}
+		return p.parseColumnRef(tok.value), nil
+	case checkTokenEOF:
+	}
+	p.pos--
+	return nil, p.unexpected("operand")
+}
+
+// parseColumnRef разбирает ссылку на колонку, возможно с именем таблицы.
+func (p *checkParser) parseColumnRef(name string) checkExpr {
+	for p.isOp(".") {
+		tok := p.toks[p.pos+1]
+		if tok.kind != checkTokenIdent && tok.kind != checkTokenQuotedIdent {
+			break
+		}
+		p.pos += 2
+		name = tok.value
+	}
+	return checkColumn{name: name}
+}
+
+func (p *checkParser) parseFunction(name string) (checkExpr, error) {
+	p.next() // (
+	args, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "length", "char_length", "character_length":
+		if len(args) != 1 {
+			return nil, xerrors.Errorf("function %s expects one argument", name)
+		}
+		return checkLength{arg: args[0]}, nil
+	default:
+		return nil, xerrors.Errorf("unsupported function %s", name)
+	}
+}
+
+// parseList разбирает список выражений через запятую до закрывающей скобки.
+func (p *checkParser) parseList(closing string) ([]checkExpr, error) {
+	var items []checkExpr
+	if p.acceptOp(closing) {
+		return items, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.acceptOp(closing) {
+			return items, nil
+		}
+		if err := p.expectOp(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseCheckExpr разбирает определение CHECK ограничения в виде CHECK (expr) или просто expr.
+// Атрибуты после выражения, например NOT VALID или NO INHERIT, пропускаются.
+func parseCheckExpr(def string) (checkExpr, error) {
+	toks, err := tokenizeCheck(def)
+	if err != nil {
+		return nil, err
+	}
+	p := &checkParser{toks: toks}
+	if p.acceptKeyword("check") {
+		if !p.isOp("(") {
+			return nil, p.unexpected("%q", "(")
+		}
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for _, attr := range []string{"not", "valid", "no", "inherit"} {
+		p.acceptKeyword(attr)
+	}
+	if tok := p.peek(); tok.kind != checkTokenEOF {
+		return nil, p.unexpected("end of expression")
+	}
+	return expr, nil
+}
@@ -0,0 +1,270 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestCheckConstraint(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     string
+		columns []string
+		pass    []map[string]string
+		fail    []map[string]string
+	}{
+		{
+			name:    "comparison",
+			def:     "CHECK ((age >= 18))",
+			columns: []string{"age"},
+			pass:    []map[string]string{{"age": "18"}, {"age": "NULL"}, {}},
+			fail:    []map[string]string{{"age": "17"}, {"age": "-1"}},
+		},
+		{
+			name:    "between from ddl",
+			def:     "CHECK (price BETWEEN 0.5 AND 10)",
+			columns: []string{"price"},
+			pass:    []map[string]string{{"price": "0.5"}, {"price": "10.00"}},
+			fail:    []map[string]string{{"price": "0.4"}, {"price": "'NaN'::NUMERIC"}},
+		},
+		{
+			name:    "in list from ddl",
+			def:     "CHECK (status NOT IN ('deleted', 'banned'))",
+			columns: []string{"status"},
+			pass:    []map[string]string{{"status": "'active'"}},
+			fail:    []map[string]string{{"status": "'banned'"}},
+		},
+		{
+			name:    "any array from pg_get_constraintdef",
+			def:     "CHECK (((status)::text = ANY ((ARRAY['new'::character varying, 'done'::character varying])::text[])))",
+			columns: []string{"status"},
+			pass:    []map[string]string{{"status": "'new'"}, {"status": "'done'::test.status"}},
+			fail:    []map[string]string{{"status": "'old'"}},
+		},
+		{
+			name:    "length",
+			def:     "CHECK ((char_length((name)::text) > 2)) NOT VALID",
+			columns: []string{"name"},
+			pass:    []map[string]string{{"name": "'abc'"}},
+			fail:    []map[string]string{{"name": "'ab'"}, {"name": "''"}},
+		},
+		{
+			name:    "several columns",
+			def:     `CHECK (((start < finish) OR ("Finish" IS NULL AND (start >= (0)::numeric))))`,
+			columns: []string{"start", "finish", "Finish"},
+			pass: []map[string]string{
+				{"start": "1", "finish": "2"},
+				{"start": "1", "finish": "0", "Finish": "NULL"},
+				// значение finish неизвестно
+				{"start": "1"},
+			},
+			fail: []map[string]string{
+				{"start": "2", "finish": "1", "Finish": "1"},
+				{"start": "-1", "finish": "-2", "Finish": "NULL"},
+			},
+		},
+		{
+			name:    "boolean column",
+			def:     "CHECK ((NOT is_deleted OR deleted_at IS NOT NULL))",
+			columns: []string{"is_deleted", "deleted_at"},
+			pass:    []map[string]string{{"is_deleted": "False"}, {"is_deleted": "True", "deleted_at": "'epoch'"}},
+			fail:    []map[string]string{{"is_deleted": "True", "deleted_at": "NULL"}},
+		},
+		{
+			name:    "is not false",
+			def:     "CHECK ((is_active IS NOT FALSE))",
+			columns: []string{"is_active"},
+			pass:    []map[string]string{{"is_active": "True"}, {"is_active": "NULL"}},
+			fail:    []map[string]string{{"is_active": "False"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			check, err := ParseCheck(&schema.Constraint{Name: "c", Type: schema.ConstraintTypeCheck, Definition: tt.def})
+			r.NoError(err)
+			r.Equal(tt.columns, check.Columns)
+			for _, values := range tt.pass {
+				r.True(check.Check(values), "%v", values)
+			}
+			for _, values := range tt.fail {
+				r.False(check.Check(values), "%v", values)
+			}
+		})
+	}
+}
+
+func TestParseCheckErrors(t *testing.T) {
+	for _, def := range []string{
+		"CHECK ((name ~~ 'a%'::text))",
+		"CHECK ((lower(name) = name))",
+		"CHECK ((a > ))",
+		"CHECK ((a = ANY (b)))",
+		"CHECK ((a > 1)) garbage",
+	} {
+		_, err := ParseCheck(&schema.Constraint{Name: "c", Definition: def})
+		require.Error(t, err, def)
+	}
+}
+
+func TestColumnCheckValues(t *testing.T) {
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	numeric := &schema.DBType{
+		TypeName: schema.Identifier{Schema: "pg_catalog", Name: "numeric"},
+		Type:     schema.DataTypeBase,
+	}
+	varchar := &schema.DBType{
+		TypeName: schema.Identifier{Schema: "pg_catalog", Name: "varchar"},
+		Type:     schema.DataTypeBase,
+	}
+	status := &schema.DBType{
+		TypeName:   schema.Identifier{Schema: "test", Name: "status"},
+		Type:       schema.DataTypeEnum,
+		EnumValues: []string{"new", "done", "deleted"},
+	}
+	parse := func(name, def string) *CheckConstraint {
+		check, err := ParseCheck(&schema.Constraint{Name: name, Definition: def})
+		require.NoError(t, err)
+		return check
+	}
+
+	tests := []struct {
+		name        string
+		col         schema.Column
+		checks      []*CheckConstraint
+		wantValid   []string
+		wantInvalid map[string]string
+	}{
+		{
+			name:        "integer",
+			col:         schema.Column{Name: "age", Type: int4},
+			checks:      []*CheckConstraint{parse("age_check", "CHECK ((age >= 18))")},
+			wantValid:   []string{"18", "19"},
+			wantInvalid: map[string]string{"17": "age_check"},
+		},
+		{
+			name: "integer type bounds",
+			col:  schema.Column{Name: "id", Type: int4},
+			checks: []*CheckConstraint{
+				parse("a_check", "CHECK ((id < 2147483647))"),
+				parse("b_check", "CHECK ((id > 0))"),
+			},
+			wantValid:   []string{"2147483646", "1"},
+			wantInvalid: map[string]string{"2147483647": "a_check", "-1": "b_check", "0": "b_check"},
+		},
+		{
+			name: "numeric scale",
+			col: schema.Column{Name: "price", Type: numeric, Attributes: schema.ColumnAttributes{
+				DomainAttributes: schema.DomainAttributes{IsNumeric: true, NumericPrecision: 4, NumericScale: 2},
+			}},
+			checks:      []*CheckConstraint{parse("price_check", "CHECK (price BETWEEN 0 AND 99.99)")},
+			wantValid:   []string{"0.00", "0.01", "99.98", "99.99"},
+			wantInvalid: map[string]string{"-0.01": "price_check"},
+		},
+		{
+			name: "length",
+			col: schema.Column{Name: "code", Type: varchar, Attributes: schema.ColumnAttributes{
+				DomainAttributes: schema.DomainAttributes{HasCharMaxLength: true, CharMaxLength: 3},
+			}},
+			checks:      []*CheckConstraint{parse("code_check", "CHECK ((length((code)::text) = 3))")},
+			wantValid:   []string{"'000'"},
+			wantInvalid: map[string]string{"'00'": "code_check"},
+		},
+		{
+			name:        "enum",
+			col:         schema.Column{Name: "status", Type: status},
+			checks:      []*CheckConstraint{parse("status_check", "CHECK ((status <> 'deleted'::test.status))")},
+			wantInvalid: map[string]string{"'deleted'::test.status": "status_check"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, invalid := columnCheckValues(tt.col, tt.checks)
+			require.Equal(t, tt.wantValid, valid)
+			gotInvalid := make(map[string]string, len(invalid))
+			for _, neg := range invalid {
+				require.Equal(t, ViolationCheck, neg.violation.Kind)
+				require.Equal(t, tt.col.Name, neg.violation.Column)
+				gotInvalid[neg.value] = neg.violation.Constraint
+			}
+			if tt.wantInvalid == nil {
+				tt.wantInvalid = map[string]string{}
+			}
+			require.Equal(t, tt.wantInvalid, gotInvalid)
+		})
+	}
+}
+
+func TestGenerateRecordsChecks(t *testing.T) {
+	r := require.New(t)
+
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	constraints := map[string]*schema.Constraint{
+		"users_age_check": {
+			Name: "users_age_check", Type: schema.ConstraintTypeCheck,
+			Definition: "CHECK ((age >= 500))", Columns: []string{"age"},
+		},
+		"users_check": {
+			Name: "users_check", Type: schema.ConstraintTypeCheck,
+			Definition: "CHECK ((min_age < age))", Columns: []string{"min_age", "age"},
+		},
+		"users_unsupported_check": {
+			Name: "users_unsupported_check", Type: schema.ConstraintTypeCheck,
+			Definition: "CHECK ((age % 2) = 0)", Columns: []string{"age"},
+		},
+	}
+	users := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "users"},
+		Columns: map[string]schema.Column{
+			"age":     {ColNum: 1, Name: "age", Type: int4},
+			"min_age": {ColNum: 2, Name: "min_age", Type: int4},
+		},
+		Constraints: constraints,
+	}
+	s := &schema.Schema{
+		Types:  map[string]*schema.DBType{int4.String(): int4},
+		Tables: map[string]schema.Table{users.String(): users},
+	}
+
+	gen, err := New(zap.NewNop(), s, Config{Negative: true})
+	r.NoError(err)
+	res, warnings := gen.GenerateRecords(PartialRecords{}, nil)
+	r.Empty(warnings)
+
+	checks := gen.checks[users.String()]
+	r.Len(checks, 2, "unsupported constraint must be ignored")
+
+	records := res[users.String()]
+	ages := make(map[string]bool)
+	var negatives []Violation
+	for _, record := range records.Records {
+		values := recordValues(record)
+		if record.Violates != nil {
+			negatives = append(negatives, *record.Violates)
+			if record.Violates.Kind == ViolationCheck {
+				r.Equal(record.Violates.Constraint, failedCheck(checks, values).Name, "record %s", record)
+			}
+			continue
+		}
+		r.Nil(failedCheck(checks, values), "record %s violates check", record)
+		ages[values["age"]] = true
+	}
+	r.True(ages["500"])
+	r.True(ages["501"])
+	r.False(ages["0"])
+	r.Contains(negatives, Violation{Kind: ViolationCheck, Column: "age", Constraint: "users_age_check"})
+	// у ограничения на несколько колонок нет граничных значений, нарушающие его значения подбираются перебором
+	r.Contains(negatives, Violation{Kind: ViolationCheck, Constraint: "users_check"})
+}
@@ -3,14 +3,17 @@ package generate
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 
+	"golang.org/x/exp/maps"
+
 	"github.com/Feresey/mtest/schema"
 	mapset "github.com/deckarep/golang-set/v2"
 )
 
-const pgCatalogPrefix = "pg_catalog."
+const pgCatalogSchema = "pg_catalog"
 
 // var BaseTypes = map[string]struct{}{
 // 	"bool": {},
@@ -114,24 +117,35 @@ func (g *Generator) getDefaultTableChecks(table schema.Table) map[string]ColumnC
 		foreignColumns.Append(fk.Constraint.Columns...)
 	}
 
+	tableChecks := g.checks[table.String()]
 	for _, col := range table.Columns {
-		checks[col.Name] = g.makeChecks(col, foreignColumns)
+		// значения генерируемых колонок вычисляет сама база данных
+		if col.Attributes.IsGeneratedAlways() {
+			continue
+		}
+		colConstraints := columnChecks(tableChecks, col.Name)
+		colChecks := g.makeChecks(col, foreignColumns, colConstraints)
+		// значения на границах партиций, чтобы записи попали в каждую партицию
+		if parts, ok := g.partitions[table.String()]; ok && !foreignColumns.Contains(col.Name) {
+			colChecks.AddValues(parts.values(col)...)
+			colChecks.Values = filterCheckValues(col.Name, colConstraints, colChecks.Values)
+		}
+		checks[col.Name] = colChecks
 	}
 
 	return checks
 }
 
-func (g *Generator) makeChecks(col schema.Column, foreignCols mapset.Set[string]) ColumnChecks {
+func (g *Generator) makeChecks(
+	col schema.Column,
+	foreignCols mapset.Set[string],
+	constraints []*CheckConstraint,
+) ColumnChecks {
 	var check ColumnChecks
 	attr := col.Attributes
 
 	if !attr.NotNullable {
-		check.AddValues("NULL")
-	}
-
-	// Если тип не является встроенным в postgresql, то я его не обрабатываю.
-	if !strings.HasPrefix(col.Type.String(), pgCatalogPrefix) {
-		return check
+		check.AddValues(nullLiteral)
 	}
 
 	// Для FK колонок нельзя делать обычные проверки на значения, т.к. они зависят от других таблиц.
@@ -155,16 +169,38 @@ func (g *Generator) makeChecks(col schema.Column, foreignCols mapset.Set[string]
 		)
 	}
 
+	// граничные значения CHECK ограничений и отбрасывание значений, которые их нарушают
+	valid, _ := columnCheckValues(col, constraints)
+	check.AddValues(valid...)
+	check.Values = filterCheckValues(col.Name, constraints, check.Values)
+
 	return check
 }
 
+// filterCheckValues удаляет повторы и значения, нарушающие CHECK ограничения колонки.
+func filterCheckValues(colName string, constraints []*CheckConstraint, values []string) []string {
+	res := values[:0]
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		if seen[value] || checkColumnValue(constraints, colName, value) != nil {
+			continue
+		}
+		seen[value] = true
+		res = append(res, value)
+	}
+	return res
+}
+
 func (g *Generator) transformChecks(
 	checks map[string]ColumnChecks,
 	mergeChecks bool,
 ) (res Records) {
 	// Объединение проверок отдельных колонок в частичные записи.
-	for colName, columnChecks := range checks {
-		for _, value := range columnChecks.Values {
+	// Колонки перебираются в отсортированном порядке, чтобы результат не зависел от порядка обхода мапы.
+	colNames := maps.Keys(checks)
+	sort.Strings(colNames)
+	for _, colName := range colNames {
+		for _, value := range checks[colName].Values {
 			pr := Record{
 				Columns: []string{colName},
 				Values:  []string{value},
@@ -183,7 +219,11 @@ func (g *Generator) transformChecks(
 func (g *Generator) getTypeChecks(check *ColumnChecks, typ *schema.DBType) {
 	switch typ.TypType() {
 	case schema.DataTypeBase:
-		g.baseTypesChecks(check, strings.TrimPrefix(typ.String(), pgCatalogPrefix))
+		// Если тип не является встроенным в postgresql, то я его не обрабатываю.
+		if typ.TypeName.Schema != pgCatalogSchema {
+			return
+		}
+		g.baseTypesChecks(check, typ.TypeName.Name)
 	case schema.DataTypeArray:
 		// TODO нужно добавить кучу проверок на разные массивы, например для INT[][]:
 		// [None], [None, None], [[None]], [], [[1],[2]], [[1],[None]], [[None], [None]]
@@ -195,8 +235,9 @@ func (g *Generator) getTypeChecks(check *ColumnChecks, typ *schema.DBType) {
 	case schema.DataTypeDomain:
 		// TODO domain attributes checks
 		g.getTypeChecks(check, typ.ElemType)
-	case schema.DataTypeComposite,
-		schema.DataTypeRange,
+	case schema.DataTypeComposite:
+		g.compositeChecks(check, typ)
+	case schema.DataTypeRange,
 		schema.DataTypeMultiRange,
 		schema.DataTypePseudo:
 	default:
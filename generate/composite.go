@@ -0,0 +1,134 @@
+package generate
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+// compositeChecks добавляет проверочные значения составного типа.
+// Сначала все поля принимают первое из своих значений, затем каждое поле по очереди перебирает остальные.
+// Так проверяется каждое значение каждого поля без перебора всех сочетаний.
+func (g *Generator) compositeChecks(check *ColumnChecks, typ *schema.DBType) {
+	values := make([][]string, len(typ.Attributes))
+	row := make([]string, len(typ.Attributes))
+	for idx, attr := range typ.Attributes {
+		values[idx] = g.attributeCheckValues(attr)
+		row[idx] = values[idx][0]
+	}
+
+	check.AddValues(compositeLiteral(typ.String(), row))
+	for idx, attrValues := range values {
+		first := row[idx]
+		for _, value := range attrValues[1:] {
+			row[idx] = value
+			check.AddValues(compositeLiteral(typ.String(), row))
+		}
+		row[idx] = first
+	}
+}
+
+// attributeCheckValues возвращает проверочные значения поля составного типа без повторов.
+// NULL добавляется последним, чтобы остальные поля по возможности проверялись с непустым значением.
+func (g *Generator) attributeCheckValues(attr schema.Column) []string {
+	var check ColumnChecks
+	g.getTypeChecks(&check, attr.Type)
+	if domainAttr := columnDomainAttributes(attr); domainAttr.HasCharMaxLength {
+		check.AddValuesProcess(quote,
+			strings.Repeat(" ", domainAttr.CharMaxLength),
+			strings.Repeat("0", domainAttr.CharMaxLength),
+		)
+	}
+	if !attr.IsNotNull() {
+		check.AddValues(nullLiteral)
+	}
+
+	var values []string
+	for _, value := range filterCheckValues(attr.Name, nil, check.Values) {
+		if fitsColumn(attr, value) {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return []string{nullLiteral}
+	}
+	return values
+}
+
+// compositeLiteral возвращает значение составного типа из значений его полей.
+func compositeLiteral(typeName string, values []string) string {
+	return fmt.Sprintf("ROW(%s)::%s", strings.Join(values, ", "), typeName)
+}
+
+// compositeDomain возвращает домен составного типа из доменов по умолчанию его полей.
+func (g *Generator) compositeDomain(typ *schema.DBType) (Domain, error) {
+	fields := make([]Domain, 0, len(typ.Attributes))
+	for _, attr := range typ.Attributes {
+		field, err := g.defaultTypeDomain(attr.Type, attr.Attributes.DomainAttributes)
+		if err != nil {
+			return nil, xerrors.Errorf("attribute %q of composite type %q: %w", attr.Name, typ, err)
+		}
+		fields = append(fields, field)
+	}
+	return NewCompositeDomain(typ.String(), fields), nil
+}
+
+// CompositeDomain перебирает значения составного типа ROW(...)::type.
+// Домены полей перебираются одновременно, исчерпанный домен поля начинается заново.
+// Домен исчерпан, когда каждый домен полей был исчерпан хотя бы один раз.
+type CompositeDomain struct {
+	typeName string
+	fields   []Domain
+	// домен поля уже был исчерпан
+	exhausted []bool
+	index     int
+}
+
+func NewCompositeDomain(typeName string, fields []Domain) *CompositeDomain {
+	d := &CompositeDomain{
+		typeName:  typeName,
+		fields:    fields,
+		exhausted: make([]bool, len(fields)),
+	}
+	d.Reset()
+	return d
+}
+
+func (d *CompositeDomain) Reset() {
+	d.index = -1
+	for idx, field := range d.fields {
+		field.Reset()
+		d.exhausted[idx] = false
+	}
+}
+
+func (d *CompositeDomain) Next() bool {
+	d.index++
+	if len(d.fields) == 0 {
+		return d.index == 0
+	}
+	done := true
+	for idx, field := range d.fields {
+		if !field.Next() {
+			d.exhausted[idx] = true
+			field.Reset()
+			if !field.Next() {
+				// домен поля пуст
+				return false
+			}
+		}
+		done = done && d.exhausted[idx]
+	}
+	return !done
+}
+
+func (d *CompositeDomain) Value() string {
+	values := make([]string, 0, len(d.fields))
+	for _, field := range d.fields {
+		values = append(values, field.Value())
+	}
+	return compositeLiteral(d.typeName, values)
+}
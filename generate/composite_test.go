@@ -0,0 +1,151 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func compositeTestTypes() (status, fullName *schema.DBType) {
+	boolType := &schema.DBType{
+		TypeName: schema.Identifier{OID: 16, Schema: "pg_catalog", Name: "bool"},
+		Type:     schema.DataTypeBase,
+	}
+	varchar := &schema.DBType{
+		TypeName: schema.Identifier{OID: 1043, Schema: "pg_catalog", Name: "varchar"},
+		Type:     schema.DataTypeBase,
+	}
+	nameDomain := &schema.DBType{
+		TypeName:         schema.Identifier{OID: 101, Schema: "test", Name: "name_domain"},
+		Type:             schema.DataTypeDomain,
+		ElemType:         varchar,
+		DomainAttributes: &schema.DomainAttributes{NotNullable: true, HasCharMaxLength: true, CharMaxLength: 2},
+	}
+	status = &schema.DBType{
+		TypeName: schema.Identifier{OID: 102, Schema: "test", Name: "status"},
+		Type:     schema.DataTypeComposite,
+		Attributes: []schema.Column{
+			{ColNum: 1, Name: "active", Type: boolType},
+		},
+	}
+	fullName = &schema.DBType{
+		TypeName: schema.Identifier{OID: 103, Schema: "test", Name: "full_name"},
+		Type:     schema.DataTypeComposite,
+		Attributes: []schema.Column{
+			{ColNum: 1, Name: "name", Type: nameDomain},
+			{ColNum: 2, Name: "status", Type: status},
+		},
+	}
+	return status, fullName
+}
+
+func TestCompositeChecks(t *testing.T) {
+	status, fullName := compositeTestTypes()
+	gen := &Generator{}
+
+	var check ColumnChecks
+	gen.getTypeChecks(&check, status)
+	require.Equal(t, []string{
+		"ROW(True)::test.status",
+		"ROW(False)::test.status",
+		"ROW(NULL)::test.status",
+	}, check.Values)
+
+	check = ColumnChecks{}
+	gen.getTypeChecks(&check, fullName)
+	require.Equal(t, []string{
+		"ROW('', ROW(True)::test.status)::test.full_name",
+		// значения длиннее 2 символов не помещаются в домен, NULL запрещен доменом
+		"ROW(' ', ROW(True)::test.status)::test.full_name",
+		"ROW('0', ROW(True)::test.status)::test.full_name",
+		"ROW('  ', ROW(True)::test.status)::test.full_name",
+		"ROW('00', ROW(True)::test.status)::test.full_name",
+		"ROW('', ROW(False)::test.status)::test.full_name",
+		"ROW('', ROW(NULL)::test.status)::test.full_name",
+		"ROW('', NULL)::test.full_name",
+	}, check.Values)
+}
+
+func TestCompositeDomain(t *testing.T) {
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	status, _ := compositeTestTypes()
+	pair := &schema.DBType{
+		TypeName: schema.Identifier{OID: 104, Schema: "test", Name: "pair"},
+		Type:     schema.DataTypeComposite,
+		Attributes: []schema.Column{
+			{ColNum: 1, Name: "status", Type: status},
+			{ColNum: 2, Name: "num", Type: int4},
+		},
+	}
+	gen := &Generator{}
+
+	d, err := gen.defaultTypeDomain(pair, schema.DomainAttributes{})
+	require.NoError(t, err)
+	// домен исчерпан, когда исчерпан самый длинный домен поля
+	require.Equal(t, []string{
+		"ROW(ROW(True)::test.status, 0)::test.pair",
+		"ROW(ROW(False)::test.status, 1)::test.pair",
+		"ROW(ROW(True)::test.status, -1)::test.pair",
+	}, domainValues(t, NewCompositeDomain("test.pair", []Domain{
+		NewCompositeDomain("test.status", []Domain{BoolDomain()}),
+		NewEnumDomain([]string{"0", "1", "-1"}),
+	})))
+	values := domainValues(t, d)
+	require.Len(t, values, 2*defaultTopDomainIterations+1)
+	require.Equal(t, "ROW(ROW(True)::test.status, 0)::test.pair", values[0])
+
+	empty := NewCompositeDomain("test.empty", nil)
+	require.Equal(t, []string{"ROW()::test.empty"}, domainValues(t, empty))
+
+	_, err = gen.defaultTypeDomain(&schema.DBType{
+		TypeName: schema.Identifier{Schema: "test", Name: "bad"},
+		Type:     schema.DataTypeComposite,
+		Attributes: []schema.Column{{Name: "c", Type: &schema.DBType{
+			TypeName: schema.Identifier{Schema: "pg_catalog", Name: "circle"},
+			Type:     schema.DataTypeBase,
+		}}},
+	}, schema.DomainAttributes{})
+	require.ErrorContains(t, err, `attribute "c" of composite type "test.bad"`)
+}
+
+func TestGenerateRecordsComposite(t *testing.T) {
+	r := require.New(t)
+	_, fullName := compositeTestTypes()
+	users := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "users"},
+		Columns: map[string]schema.Column{
+			"full_name": {ColNum: 1, Name: "full_name", Type: fullName},
+		},
+	}
+	s := &schema.Schema{
+		Types:  map[string]*schema.DBType{fullName.String(): fullName},
+		Tables: map[string]schema.Table{users.String(): users},
+	}
+
+	gen, err := New(zap.NewNop(), s, Config{})
+	r.NoError(err)
+	res, warnings := gen.GenerateRecords(PartialRecords{}, nil)
+	r.Empty(warnings)
+
+	values := make(map[string]bool)
+	for _, record := range res["test.users"].Records {
+		literal := recordValues(record)["full_name"]
+		values[literal] = true
+		// значения должны преобразовываться в текст для COPY и JSON
+		_, _, err := ParseLiteral(literal)
+		r.NoError(err, literal)
+	}
+	r.True(values["NULL"])
+	r.True(values["ROW('', NULL)::test.full_name"])
+	r.True(values["ROW('00', ROW(True)::test.status)::test.full_name"])
+
+	value, _, err := ParseLiteral("ROW(' ', ROW(True)::test.status)::test.full_name")
+	r.NoError(err)
+	r.Equal(`(" ","(True)")`, value)
+}
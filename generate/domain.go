@@ -0,0 +1,183 @@
+package generate
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Domain описывает множество значений, которые можно перебирать для колонки.
+// Значения возвращаются в виде SQL литералов, так же как и проверки из Checks.
+type Domain interface {
+	// Reset возвращает домен в начальное состояние
+	Reset()
+	// Next переходит к следующему значению. Возвращает false, если домен исчерпан.
+	Next() bool
+	// Value возвращает текущее значение домена
+	Value() string
+}
+
+// IntDomain перебирает целые числа 0, 1, -1, 2, -2, ... пока модуль числа не превысит top.
+type IntDomain struct {
+	top   int64
+	index int64
+	value int64
+}
+
+func (d *IntDomain) Init(top int64) {
+	d.top = top
+	d.Reset()
+}
+
+func (d *IntDomain) Reset() {
+	d.index = -1
+	d.value = 0
+}
+
+func (d *IntDomain) Next() bool {
+	d.index++
+	d.value = alternateSign(d.index)
+	return abs(d.value) <= d.top
+}
+
+func (d *IntDomain) Value() string { return strconv.FormatInt(d.value, 10) }
+
+// FloatDomain перебирает числа 0, step, -step, 2*step, -2*step, ... пока модуль числа не превысит top.
+type FloatDomain struct {
+	top       float64
+	step      float64
+	precision int
+	index     int64
+	value     float64
+}
+
+func (d *FloatDomain) Init(top, step float64) {
+	d.top = top
+	d.step = step
+	// количество знаков после запятой, чтобы не выводить ошибки округления
+	d.precision = int(math.Max(0, math.Ceil(-math.Log10(step))))
+	d.Reset()
+}
+
+func (d *FloatDomain) Reset() {
+	d.index = -1
+	d.value = 0
+}
+
+func (d *FloatDomain) Next() bool {
+	d.index++
+	d.value = float64(alternateSign(d.index)) * d.step
+	// половина шага компенсирует ошибки округления при сравнении с top
+	return math.Abs(d.value) <= d.top+d.step/2
+}
+
+func (d *FloatDomain) Value() string {
+	return strconv.FormatFloat(d.value, 'f', d.precision, 64)
+}
+
+// TimeDomain перебирает моменты времени now, now+step, now-step, now+2*step, ...
+// Всего перебирается top значений.
+type TimeDomain struct {
+	now   time.Time
+	step  time.Duration
+	top   int64
+	index int64
+	value time.Time
+}
+
+// шаг больше суток, чтобы значения различались и для DATE, и для TIME.
+const defaultStepTimeDomain = 24*time.Hour + time.Second
+
+func (d *TimeDomain) Init(now time.Time, top int64) {
+	d.now = now.UTC().Truncate(time.Second)
+	d.step = defaultStepTimeDomain
+	d.top = top
+	d.Reset()
+}
+
+func (d *TimeDomain) Reset() {
+	d.index = -1
+	d.value = d.now
+}
+
+func (d *TimeDomain) Next() bool {
+	d.index++
+	d.value = d.now.Add(time.Duration(alternateSign(d.index)) * d.step)
+	return d.index < d.top
+}
+
+func (d *TimeDomain) Value() string { return quote(d.value.Format(time.RFC3339)) }
+
+// EnumDomain перебирает заранее известные значения.
+type EnumDomain struct {
+	values []string
+	index  int
+}
+
+func NewEnumDomain(values []string) *EnumDomain {
+	d := &EnumDomain{values: values}
+	d.Reset()
+	return d
+}
+
+func BoolDomain() *EnumDomain {
+	return NewEnumDomain([]string{"True", "False"})
+}
+
+func (d *EnumDomain) Reset() { d.index = -1 }
+
+func (d *EnumDomain) Next() bool {
+	d.index++
+	return d.index < len(d.values)
+}
+
+func (d *EnumDomain) Value() string { return d.values[d.index] }
+
+// UUIDDomain генерирует бесконечное количество случайных строк.
+type UUIDDomain struct {
+	// Максимальная длина строки. 0 - без ограничений
+	MaxLength int
+	// Источник случайных байт. Если nil, то используется crypto/rand
+	Rand  io.Reader
+	value string
+}
+
+func (d *UUIDDomain) Reset() {}
+
+func (d *UUIDDomain) Next() bool {
+	if d.Rand == nil {
+		d.value = uuid.NewString()
+	} else {
+		// чтение из math/rand не возвращает ошибок
+		d.value = uuid.Must(uuid.NewRandomFromReader(d.Rand)).String()
+	}
+	if d.MaxLength > 0 && len(d.value) > d.MaxLength {
+		d.value = d.value[:d.MaxLength]
+	}
+	return true
+}
+
+func (d *UUIDDomain) Value() string { return quote(d.value) }
+
+// alternateSign возвращает index-ый элемент последовательности 0, 1, -1, 2, -2, ...
+func alternateSign(index int64) int64 {
+	if index%2 == 1 {
+		return (index + 1) / 2
+	}
+	return -index / 2
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
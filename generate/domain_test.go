@@ -0,0 +1,91 @@
+package generate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func domainValues(t *testing.T, d Domain) (values []string) {
+	t.Helper()
+	d.Reset()
+	for d.Next() {
+		values = append(values, d.Value())
+	}
+	return values
+}
+
+func TestDomains(t *testing.T) {
+	intDomain := func(top int64) Domain {
+		var d IntDomain
+		d.Init(top)
+		return &d
+	}
+	floatDomain := func(top, step float64) Domain {
+		var d FloatDomain
+		d.Init(top, step)
+		return &d
+	}
+	timeDomain := func(now time.Time, top int64) Domain {
+		var d TimeDomain
+		d.Init(now, top)
+		return &d
+	}
+
+	tests := []struct {
+		name   string
+		domain Domain
+		want   []string
+	}{
+		{
+			name:   "int",
+			domain: intDomain(2),
+			want:   []string{"0", "1", "-1", "2", "-2"},
+		},
+		{
+			name:   "float",
+			domain: floatDomain(0.3, 0.1),
+			want:   []string{"0.0", "0.1", "-0.1", "0.2", "-0.2", "0.3", "-0.3"},
+		},
+		{
+			name:   "numeric without scale",
+			domain: floatDomain(numericToFloatDomainParams(1, 0)),
+			want:   []string{"0", "1", "-1", "2", "-2", "3", "-3", "4", "-4", "5", "-5", "6", "-6", "7", "-7", "8", "-8", "9", "-9"},
+		},
+		{
+			name:   "time",
+			domain: timeDomain(time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC), 3),
+			want: []string{
+				"'2023-01-03T00:00:00Z'",
+				"'2023-01-04T00:00:01Z'",
+				"'2023-01-01T23:59:59Z'",
+			},
+		},
+		{
+			name:   "bool",
+			domain: BoolDomain(),
+			want:   []string{"True", "False"},
+		},
+		{
+			name:   "enum",
+			domain: NewEnumDomain([]string{"'a'::test.enum", "'b'::test.enum"}),
+			want:   []string{"'a'::test.enum", "'b'::test.enum"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			r.Equal(tt.want, domainValues(t, tt.domain))
+			r.Equal(tt.want, domainValues(t, tt.domain), "after reset")
+		})
+	}
+}
+
+func TestUUIDDomainMaxLength(t *testing.T) {
+	d := UUIDDomain{MaxLength: 5}
+	d.Reset()
+	require.True(t, d.Next())
+	require.Len(t, d.Value(), 5+2)
+}
@@ -2,6 +2,9 @@ package domains
 
 import (
 	_ "embed"
+	"math/rand"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	lua "github.com/yuin/gopher-lua"
@@ -103,15 +106,47 @@ func (l *LuaDomain) Next() (value string, ok bool, err error) {
 //go:embed lua/domains.lua
 var domainsFile string
 
-func RegisterModule(l *lua.LState) {
+// Config задает источники случайных значений и времени для lua доменов.
+// Одинаковые Rand и Now дают одинаковые значения доменов.
+// Если Rand не задан, то используется генератор, инициализированный текущим временем,
+// если не задан Now - текущее время.
+type Config struct {
+	Rand *rand.Rand
+	Now  time.Time
+}
+
+// RegisterModule регистрирует модули go_uuid, go_time и domains в lua машине.
+func RegisterModule(l *lua.LState, conf Config) {
+	if conf.Rand == nil {
+		//nolint:gosec // криптографическая стойкость не нужна
+		conf.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if conf.Now.IsZero() {
+		conf.Now = time.Now()
+	}
 	l.RegisterModule("go_uuid", map[string]lua.LGFunction{
 		"new": func(l *lua.LState) int {
-			l.Push(lua.LString(uuid.NewString()))
+			id, err := uuid.NewRandomFromReader(conf.Rand)
+			if err != nil {
+				l.RaiseError("generate uuid: %s", err)
+			}
+			l.Push(lua.LString(id.String()))
+			return 1
+		},
+	})
+	l.RegisterModule("go_time", map[string]lua.LGFunction{
+		"now": func(l *lua.LState) int {
+			l.Push(lua.LNumber(conf.Now.Unix()))
 			return 1
 		},
 	})
-	l.Register("domains", func(l *lua.LState) int {
-		if err := l.DoString(domainsFile); err != nil {
+	l.PreloadModule("domains", func(l *lua.LState) int {
+		fn, err := l.Load(strings.NewReader(domainsFile), "domains.lua")
+		if err != nil {
+			l.Error(lua.LString(err.Error()), 9)
+		}
+		l.Push(fn)
+		if err := l.PCall(0, lua.MultRet, nil); err != nil {
 			l.Error(lua.LString(err.Error()), 9)
 		}
 		return 1
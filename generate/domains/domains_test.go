@@ -2,7 +2,9 @@ package domains
 
 import (
 	_ "embed"
+	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	lua "github.com/yuin/gopher-lua"
@@ -24,7 +26,10 @@ func getValues(t *testing.T, domain *LuaDomain) (values []string) {
 func TestIntLuaDomain(t *testing.T) {
 	l := lua.NewState()
 	t.Cleanup(l.Close)
-	RegisterModule(l)
+	RegisterModule(l, Config{
+		Rand: rand.New(rand.NewSource(1)),
+		Now:  time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC),
+	})
 
 	err := l.DoString(`domains = require("domains")`)
 	require.NoError(t, err)
@@ -148,3 +153,19 @@ func TestIntLuaDomain(t *testing.T) {
 		})
 	}
 }
+
+func TestRegisterModuleDefaults(t *testing.T) {
+	l := lua.NewState()
+	t.Cleanup(l.Close)
+	RegisterModule(l, Config{})
+
+	err := l.DoString(`
+	local uuid = require("go_uuid")
+	local time = require("go_time")
+	id = uuid.new()
+	now = time.now()
+	`)
+	require.NoError(t, err)
+	require.Len(t, l.GetGlobal("id").String(), 36)
+	require.Greater(t, float64(l.GetGlobal("now").(lua.LNumber)), float64(0))
+}
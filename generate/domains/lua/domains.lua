@@ -31,11 +31,12 @@ function Int:next()
     return self.value
 end
 
+local go_time = require("go_time")
 local Time = {}
 Time.__index = Time
 
 function Time:new(params)
-    if not params.now then params.now = os.time() end
+    if not params.now then params.now = go_time.now() end
     if not params.step then params.step = 1 end
     if not params.top then params.top = 1000 end
     -- default format is RFC3339
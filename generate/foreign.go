@@ -0,0 +1,404 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+// ForeignKeyConfig задает заполнение колонок внешних ключей значениями из родительских таблиц.
+type ForeignKeyConfig struct {
+	// Не допускать значений внешних ключей, которых нет в родительской таблице.
+	// Записи, для которых не нашлось допустимых значений, пропускаются.
+	// Иначе в таких записях колонки ключа заполняются значениями из доменов.
+	OrphanFree bool
+	// На каждую запись родительской таблицы должна ссылаться хотя бы одна дочерняя запись.
+	CoverParents bool
+	// Минимальное количество дочерних записей для каждой записи родительской таблицы. 0 - не задано.
+	// Недостающие дочерние записи догенерируются, лишние не удаляются.
+	FanOut int
+}
+
+// minChildren возвращает минимальное количество дочерних записей для каждой родительской записи.
+func (c ForeignKeyConfig) minChildren() int {
+	if c.FanOut == 0 && c.CoverParents {
+		return 1
+	}
+	return c.FanOut
+}
+
+// tableForeignKey описывает внешний ключ таблицы и наборы значений, на которые он может ссылаться.
+type tableForeignKey struct {
+	fk schema.ForeignKey
+	// ключ ссылается на эту же таблицу. Наборы значений пополняются по мере генерации записей
+	self bool
+	// колонка ключа, допускающая NULL. Пустая, если все колонки NOT NULL
+	nullColumn string
+	// ключ разрывает цикл между таблицами, родительская таблица генерируется позже.
+	// Если ключ допускает NULL, то он вставляется как NULL и обновляется после вставки всех таблиц,
+	// иначе проверка ключа откладывается до конца транзакции.
+	deferred bool
+
+	// наборы значений колонок ключа из записей родительской таблицы в порядке fk.Constraint.Columns
+	tuples [][]string
+	// индекс набора в tuples по составному значению
+	tupleIndex map[string]int
+	// количество дочерних записей, ссылающихся на каждый набор
+	refs []int
+	// набор, с которого начинается поиск, чтобы ссылки распределялись равномерно
+	next int
+}
+
+func (fk *tableForeignKey) String() string { return fk.fk.String() }
+
+// addTuple добавляет набор значений колонок ключа.
+// values содержит значения колонок родительской записи, на которые ссылается ключ.
+func (fk *tableForeignKey) addTuple(values map[string]string) {
+	tuple := make([]string, 0, len(fk.fk.ReferenceColumns))
+	for _, refCol := range fk.fk.ReferenceColumns {
+		value, ok := values[refCol]
+		if !ok {
+			return
+		}
+		tuple = append(tuple, value)
+	}
+	fk.addKeyTuple(tuple)
+}
+
+// addKeyTuple добавляет набор значений, заданных в порядке fk.ReferenceColumns.
+func (fk *tableForeignKey) addKeyTuple(tuple []string) {
+	// на записи с NULL в колонках ключа нельзя сослаться
+	if slices.Contains(tuple, nullLiteral) {
+		return
+	}
+	key := tupleKey(tuple)
+	if _, ok := fk.tupleIndex[key]; ok {
+		return
+	}
+	fk.tupleIndex[key] = len(fk.tuples)
+	fk.tuples = append(fk.tuples, tuple)
+	fk.refs = append(fk.refs, 0)
+}
+
+// tuple возвращает набор значений как запись с колонками ключа.
+func (fk *tableForeignKey) tuple(idx int) Record {
+	return Record{Columns: slices.Clip(fk.fk.Constraint.Columns), Values: slices.Clip(fk.tuples[idx])}
+}
+
+// find возвращает индекс набора значений, который совпадает со значениями колонок ключа в записи.
+func (fk *tableForeignKey) find(record map[string]string) (int, bool) {
+	values := make([]string, 0, len(fk.fk.Constraint.Columns))
+	for _, col := range fk.fk.Constraint.Columns {
+		value, ok := record[col]
+		if !ok || value == nullLiteral {
+			return 0, false
+		}
+		values = append(values, value)
+	}
+	idx, ok := fk.tupleIndex[tupleKey(values)]
+	return idx, ok
+}
+
+func tupleKey(values []string) string {
+	fields := make([]string, 0, len(values))
+	for _, value := range values {
+		fields = append(fields, strconv.Quote(value))
+	}
+	return strings.Join(fields, ",")
+}
+
+// addRecords добавляет наборы значений из позитивных записей родительской таблицы.
+func (fk *tableForeignKey) addRecords(records Records) {
+	for _, record := range records.Positive().Records {
+		fk.addTuple(recordValues(record))
+	}
+}
+
+// linkForeignKeys связывает внешние ключи таблицы с уже сгенерированными записями родительских таблиц.
+// addTuples добавляет в ключ наборы значений из записей родительской таблицы.
+func (g *Generator) linkForeignKeys(tgen *tableGenerator, addTuples func(fk *tableForeignKey)) {
+	table := tgen.table
+	tgen.fkConf = g.fkConf
+	tgen.foreignKeys = nil
+
+	fkNames := maps.Keys(table.ForeignKeys)
+	sort.Strings(fkNames)
+	for _, fkName := range fkNames {
+		fk := table.ForeignKeys[fkName]
+		if len(fk.Constraint.Columns) == 0 || len(fk.Constraint.Columns) != len(fk.ReferenceColumns) {
+			g.log.Warn("foreign key columns are not specified",
+				zap.Stringer("table", table), zap.String("foreign_key", fkName))
+			continue
+		}
+		tfk := &tableForeignKey{
+			fk:         fk,
+			self:       fk.ReferenceTable == table.String(),
+			deferred:   slices.Contains(g.deferred[table.String()], fkName),
+			tupleIndex: make(map[string]int),
+		}
+		for _, colName := range fk.Constraint.Columns {
+			if col, ok := table.Columns[colName]; ok && !col.IsNotNull() {
+				tfk.nullColumn = colName
+				break
+			}
+		}
+		if !tfk.self && !tfk.deferred {
+			addTuples(tfk)
+			if len(tfk.tuples) == 0 {
+				g.log.Warn("referenced table has no records, foreign key can not reference existing values",
+					zap.Stringer("table", table),
+					zap.String("foreign_key", fkName),
+					zap.String("reference", fk.ReferenceTable),
+					zap.Bool("orphan_free", g.fkConf.OrphanFree),
+				)
+			}
+		}
+		tgen.foreignKeys = append(tgen.foreignKeys, tfk)
+	}
+}
+
+// fillForeignKey заполняет отсутствующие в записи колонки внешнего ключа значениями из родительской таблицы.
+// Наборы значений перебираются по кругу, поэтому ссылки распределяются по родительским записям равномерно.
+// Если подходящего набора нет, то колонка ключа заполняется NULL, если это возможно.
+// Для ключа на эту же таблицу возвращается pending = true, если запись должна ссылаться сама на себя.
+func (g *tableGenerator) fillForeignKey(
+	fk *tableForeignKey,
+	record map[string]string,
+	checks []*CheckConstraint,
+) (pending bool, err error) {
+	if fk.deferred {
+		// значения ключа заполнятся после генерации родительской таблицы
+		if _, ok := record[fk.nullColumn]; fk.nullColumn != "" && !ok {
+			record[fk.nullColumn] = nullLiteral
+		}
+		return false, nil
+	}
+
+	var missing []string
+	for _, col := range fk.fk.Constraint.Columns {
+		value, ok := record[col]
+		if !ok {
+			missing = append(missing, col)
+			continue
+		}
+		// внешний ключ с NULL в любой из колонок не проверяется
+		if value == nullLiteral {
+			return false, nil
+		}
+	}
+	if len(missing) == 0 {
+		if _, ok := fk.find(record); !ok && g.fkConf.OrphanFree && !fk.self {
+			return false, &skipRecordError{reason: fmt.Sprintf(
+				"values of foreign key %q are not present in table %q", fk, fk.fk.ReferenceTable)}
+		}
+		return false, nil
+	}
+
+tuples:
+	for k := range fk.tuples {
+		idx := (fk.next + k) % len(fk.tuples)
+		tuple := fk.tuples[idx]
+		for i, col := range fk.fk.Constraint.Columns {
+			if value, ok := record[col]; ok && value != tuple[i] {
+				continue tuples
+			}
+		}
+		for _, col := range missing {
+			record[col] = tuple[slices.Index(fk.fk.Constraint.Columns, col)]
+		}
+		if failedCheck(checks, record) == nil && g.violatedUniqueIndex(record) == nil {
+			fk.next = idx + 1
+			return false, nil
+		}
+	}
+	for _, col := range missing {
+		delete(record, col)
+	}
+
+	switch {
+	case fk.nullColumn != "" && slices.Contains(missing, fk.nullColumn):
+		record[fk.nullColumn] = nullLiteral
+		return false, nil
+	case fk.self:
+		return true, nil
+	case g.fkConf.OrphanFree:
+		return false, &skipRecordError{reason: fmt.Sprintf(
+			"no record of table %q can be referenced by foreign key %q", fk.fk.ReferenceTable, fk)}
+	}
+	// значения возьмутся из доменов колонок
+	return false, nil
+}
+
+// fillDeferredForeignKeys заполняет внешние ключи, разрывающие циклы, после генерации записей всех таблиц.
+// Значения ключей, допускающих NULL, переносятся в Record.Deferred, а в самой записи заменяются на NULL.
+func (g *Generator) fillDeferredForeignKeys(tgens map[string]*tableGenerator, res map[string]Records) {
+	for _, table := range g.order {
+		tgen, ok := tgens[table.String()]
+		if !ok {
+			continue
+		}
+		for _, fk := range tgen.foreignKeys {
+			if !fk.deferred {
+				continue
+			}
+			fk.deferred = false
+			fk.addRecords(res[fk.fk.ReferenceTable])
+			if len(fk.tuples) == 0 {
+				g.log.Warn("referenced table has no records, deferred foreign key can not reference existing values",
+					zap.Stringer("table", table),
+					zap.Stringer("foreign_key", fk),
+					zap.String("reference", fk.fk.ReferenceTable),
+				)
+			}
+			records := res[table.String()].Records
+			for idx := range records {
+				if records[idx].Violates == nil {
+					tgen.fillDeferredForeignKey(fk, &records[idx])
+				}
+			}
+		}
+	}
+}
+
+// fillDeferredForeignKey заполняет колонки ключа записи значениями из родительской таблицы.
+// Значения колонок, заданные частичной записью, не меняются.
+func (g *tableGenerator) fillDeferredForeignKey(fk *tableForeignKey, record *Record) {
+	// значения остальных индексов записи уже учтены, поэтому проверяются только индексы с колонками ключа
+	uniqueIndexes := g.uniqueIndexes
+	g.uniqueIndexes = nil
+	for _, index := range uniqueIndexes {
+		if slices.ContainsFunc(index.columns, func(col string) bool {
+			return slices.Contains(fk.fk.Constraint.Columns, col)
+		}) {
+			g.uniqueIndexes = append(g.uniqueIndexes, index)
+		}
+	}
+	defer func() { g.uniqueIndexes = uniqueIndexes }()
+
+	values := recordValues(*record)
+	var partial map[string]string
+	if record.Partial != nil {
+		partial = recordValues(*record.Partial)
+	}
+	generated := make(map[string]string)
+	for _, col := range fk.fk.Constraint.Columns {
+		if _, ok := partial[col]; !ok {
+			generated[col] = values[col]
+			delete(values, col)
+		}
+	}
+
+	_, err := g.fillForeignKey(fk, values, g.checks)
+	if err != nil {
+		g.log.Warn("deferred foreign key is filled with generated values",
+			zap.Stringer("foreign_key", fk),
+			zap.Stringer("record", record),
+			zap.Error(err),
+		)
+	}
+	// колонки, для которых не нашлось значений, сохраняют сгенерированные значения
+	for col, value := range generated {
+		if _, ok := values[col]; !ok {
+			values[col] = value
+		}
+	}
+	g.addUniqueValues(values)
+
+	if fk.nullColumn != "" {
+		if _, ok := fk.find(values); ok {
+			deferred := Record{}
+			if record.Deferred != nil {
+				deferred = *record.Deferred
+			}
+			for _, col := range fk.fk.Constraint.Columns {
+				deferred.Columns = append(deferred.Columns, col)
+				deferred.Values = append(deferred.Values, values[col])
+				if col, ok := g.table.Columns[col]; ok && !col.IsNotNull() {
+					values[col.Name] = nullLiteral
+				}
+			}
+			record.Deferred = &deferred
+		}
+	}
+
+	res := g.recordFromMap(values)
+	record.Columns, record.Values = res.Columns, res.Values
+}
+
+// fillSelfReference заполняет колонки ключа на эту же таблицу значениями самой записи.
+func (g *tableGenerator) fillSelfReference(
+	fk *tableForeignKey,
+	record map[string]string,
+	checks []*CheckConstraint,
+) error {
+	for idx, col := range fk.fk.Constraint.Columns {
+		value, ok := record[fk.fk.ReferenceColumns[idx]]
+		if !ok {
+			return &skipRecordError{reason: fmt.Sprintf(
+				"column %q referenced by foreign key %q is not filled", fk.fk.ReferenceColumns[idx], fk)}
+		}
+		record[col] = value
+	}
+	if failedCheck(checks, record) != nil || g.violatedUniqueIndex(record) != nil {
+		return &skipRecordError{reason: fmt.Sprintf("record can not reference itself by foreign key %q", fk)}
+	}
+	return nil
+}
+
+// countReferences учитывает ссылки записи на родительские записи.
+// Для ключей на эту же таблицу запись становится доступной для ссылок из следующих записей.
+func (g *tableGenerator) countReferences(record map[string]string) {
+	for _, fk := range g.foreignKeys {
+		if idx, ok := fk.find(record); ok {
+			fk.refs[idx]++
+		}
+		if fk.self {
+			fk.addTuple(record)
+		}
+	}
+}
+
+// generateChildRecords догенерирует записи, чтобы на каждую запись родительской таблицы
+// ссылалось не меньше заданного количества записей.
+// Ключи на эту же таблицу не учитываются, т.к. каждая новая запись сама становится родительской.
+func (g *tableGenerator) generateChildRecords() (records Records, err error) {
+	target := g.fkConf.minChildren()
+	if target == 0 {
+		return records, nil
+	}
+	for _, fk := range g.foreignKeys {
+		if fk.self {
+			continue
+		}
+		for idx := range fk.tuples {
+			for fk.refs[idx] < target {
+				partial := fk.tuple(idx)
+				vals, err := g.generateRecordValues(partial)
+				var skipErr *skipRecordError
+				if errors.As(err, &skipErr) {
+					g.log.Warn("unable to generate child record",
+						zap.Stringer("foreign_key", fk),
+						zap.Stringer("parent", &partial),
+						zap.Error(err),
+					)
+					break
+				}
+				if err != nil {
+					return records, err
+				}
+				record := g.recordFromMap(vals)
+				record.Partial = &partial
+				records.Records = append(records.Records, record)
+			}
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,221 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func foreignTestSchema() *schema.Schema {
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	notNull := schema.ColumnAttributes{DomainAttributes: schema.DomainAttributes{NotNullable: true}}
+
+	parentsPK := schema.Index{Name: "parents_pkey", Columns: []string{"a", "b"}, IsUnique: true, IsPrimary: true}
+	parents := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "parents"},
+		Columns: map[string]schema.Column{
+			"a": {ColNum: 1, Name: "a", Type: int4, Attributes: notNull},
+			"b": {ColNum: 2, Name: "b", Type: int4, Attributes: notNull},
+		},
+		Indexes: map[string]schema.Index{parentsPK.Name: parentsPK},
+	}
+
+	childrenPK := schema.Index{Name: "children_pkey", Columns: []string{"id"}, IsUnique: true, IsPrimary: true}
+	parentFK := &schema.Constraint{
+		Name: "children_parent_fkey", Type: schema.ConstraintTypeFK, Columns: []string{"parent_a", "parent_b"},
+	}
+	selfFK := &schema.Constraint{
+		Name: "children_prev_id_fkey", Type: schema.ConstraintTypeFK, Columns: []string{"prev_id"},
+	}
+	children := schema.Table{
+		Name: schema.Identifier{OID: 2, Schema: "test", Name: "children"},
+		Columns: map[string]schema.Column{
+			"id":       {ColNum: 1, Name: "id", Type: int4, Attributes: notNull},
+			"parent_a": {ColNum: 2, Name: "parent_a", Type: int4, Attributes: notNull},
+			"parent_b": {ColNum: 3, Name: "parent_b", Type: int4, Attributes: notNull},
+			"prev_id":  {ColNum: 4, Name: "prev_id", Type: int4},
+		},
+		ForeignKeys: map[string]schema.ForeignKey{
+			parentFK.Name: {Constraint: parentFK, ReferenceTable: "test.parents", ReferenceColumns: []string{"a", "b"}},
+			selfFK.Name:   {Constraint: selfFK, ReferenceTable: "test.children", ReferenceColumns: []string{"id"}},
+		},
+		Indexes: map[string]schema.Index{childrenPK.Name: childrenPK},
+	}
+	parents.ReferencedBy = map[string]*schema.Constraint{"test.children": parentFK}
+
+	return &schema.Schema{
+		Types:  map[string]*schema.DBType{int4.String(): int4},
+		Tables: map[string]schema.Table{parents.String(): parents, children.String(): children},
+	}
+}
+
+func TestGenerateRecordsForeignKeys(t *testing.T) {
+	tests := []struct {
+		name         string
+		conf         ForeignKeyConfig
+		partial      PartialRecords
+		wantChildren int
+		wantSkipped  string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:         "cover parents",
+			conf:         ForeignKeyConfig{CoverParents: true},
+			wantChildren: 1,
+		},
+		{
+			name:         "fan out",
+			conf:         ForeignKeyConfig{FanOut: 3},
+			wantChildren: 3,
+		},
+		{
+			name: "orphan free",
+			conf: ForeignKeyConfig{OrphanFree: true},
+			partial: PartialRecords{Records: map[string]Records{
+				"test.children": {Records: []Record{
+					{Columns: []string{"id"}, Values: []string{"7"}},
+					{Columns: []string{"id", "parent_a", "parent_b"}, Values: []string{"-5", "-100", "-100"}},
+				}},
+			}},
+			wantSkipped: "-5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			gen, err := New(zap.NewNop(), foreignTestSchema(), Config{ForeignKeys: tt.conf})
+			r.NoError(err)
+			res, warnings := gen.GenerateRecords(tt.partial, nil)
+			r.Empty(warnings)
+
+			parents := make(map[[2]string]int)
+			for _, record := range res["test.parents"].Records {
+				values := recordValues(record)
+				parents[[2]string{values["a"], values["b"]}] = 0
+			}
+			r.NotEmpty(parents)
+
+			children := res["test.children"].Records
+			r.NotEmpty(children)
+			ids := make(map[string]bool)
+			for _, record := range children {
+				values := recordValues(record)
+				key := [2]string{values["parent_a"], values["parent_b"]}
+				_, ok := parents[key]
+				r.True(ok, "record %s references missing parent", record)
+				parents[key]++
+
+				// записи ссылаются только на уже сгенерированные записи этой же таблицы
+				if prev := values["prev_id"]; prev != nullLiteral {
+					r.True(ids[prev], "record %s references missing record", record)
+				}
+				ids[values["id"]] = true
+			}
+			for key, count := range parents {
+				r.GreaterOrEqual(count, tt.wantChildren, "parent %v", key)
+			}
+			if tt.wantSkipped != "" {
+				r.True(ids["7"])
+				r.False(ids[tt.wantSkipped], "orphan record must be skipped")
+			}
+		})
+	}
+}
+
+func TestGenerateRecordsForeignKeysEmptyParent(t *testing.T) {
+	r := require.New(t)
+	s := foreignTestSchema()
+	parents := s.Tables["test.parents"]
+	parents.Columns = nil
+	s.Tables["test.parents"] = parents
+
+	gen, err := New(zap.NewNop(), s, Config{ForeignKeys: ForeignKeyConfig{OrphanFree: true}})
+	r.NoError(err)
+	res, warnings := gen.GenerateRecords(PartialRecords{}, nil)
+	r.Empty(warnings)
+	r.Empty(res["test.children"].Records, "children without parents must be skipped")
+
+	_, err = New(zap.NewNop(), s, Config{ForeignKeys: ForeignKeyConfig{FanOut: -1}})
+	r.Error(err)
+}
+
+func TestGenerateRecordsDeferredForeignKeys(t *testing.T) {
+	r := require.New(t)
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	notNull := schema.ColumnAttributes{DomainAttributes: schema.DomainAttributes{NotNullable: true}}
+
+	departmentFK := &schema.Constraint{
+		Name: "users_department_id_fkey", Type: schema.ConstraintTypeFK, Columns: []string{"department_id"},
+	}
+	headFK := &schema.Constraint{
+		Name: "departments_head_id_fkey", Type: schema.ConstraintTypeFK, Columns: []string{"head_id"},
+	}
+	usersPK := schema.Index{Name: "users_pkey", Columns: []string{"id"}, IsUnique: true, IsPrimary: true}
+	users := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "users"},
+		Columns: map[string]schema.Column{
+			"id":            {ColNum: 1, Name: "id", Type: int4, Attributes: notNull},
+			"department_id": {ColNum: 2, Name: "department_id", Type: int4, Attributes: notNull},
+		},
+		ForeignKeys: map[string]schema.ForeignKey{
+			departmentFK.Name: {
+				Constraint: departmentFK, ReferenceTable: "test.departments", ReferenceColumns: []string{"id"},
+			},
+		},
+		Indexes:      map[string]schema.Index{usersPK.Name: usersPK},
+		ReferencedBy: map[string]*schema.Constraint{"test.departments": headFK},
+	}
+	departmentsPK := schema.Index{Name: "departments_pkey", Columns: []string{"id"}, IsUnique: true, IsPrimary: true}
+	departments := schema.Table{
+		Name: schema.Identifier{OID: 2, Schema: "test", Name: "departments"},
+		Columns: map[string]schema.Column{
+			"id":      {ColNum: 1, Name: "id", Type: int4, Attributes: notNull},
+			"head_id": {ColNum: 2, Name: "head_id", Type: int4},
+		},
+		ForeignKeys: map[string]schema.ForeignKey{
+			headFK.Name: {Constraint: headFK, ReferenceTable: "test.users", ReferenceColumns: []string{"id"}},
+		},
+		Indexes:      map[string]schema.Index{departmentsPK.Name: departmentsPK},
+		ReferencedBy: map[string]*schema.Constraint{"test.users": departmentFK},
+	}
+	s := &schema.Schema{
+		Types:  map[string]*schema.DBType{int4.String(): int4},
+		Tables: map[string]schema.Table{users.String(): users, departments.String(): departments},
+	}
+
+	gen, err := New(zap.NewNop(), s, Config{})
+	r.NoError(err)
+	res, warnings := gen.GenerateRecords(PartialRecords{}, nil)
+	r.Empty(warnings)
+
+	userIDs := make(map[string]bool)
+	for _, record := range res["test.users"].Records {
+		userIDs[recordValues(record)["id"]] = true
+	}
+	r.NotEmpty(userIDs)
+
+	var deferred int
+	for _, record := range res["test.departments"].Records {
+		// внешний ключ вставляется как NULL и устанавливается после вставки пользователей
+		r.Equal(nullLiteral, recordValues(record)["head_id"])
+		if record.Deferred == nil {
+			continue
+		}
+		deferred++
+		r.Equal([]string{"head_id"}, record.Deferred.Columns)
+		r.True(userIDs[record.Deferred.Values[0]], "record %s references missing user", record)
+	}
+	r.NotZero(deferred)
+}
@@ -1,13 +1,15 @@
 package generate
 
 import (
+	"errors"
+	"fmt"
 	"math"
+	"math/rand"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
 	"golang.org/x/xerrors"
 
 	"github.com/Feresey/mtest/schema"
@@ -20,93 +22,196 @@ const (
 	defaultStepFloatDomain     = 0.1
 )
 
+// начало отсчета времени для доменов при детерминированной генерации.
+var seedTimeBase = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// максимальное смещение времени от seedTimeBase при детерминированной генерации.
+const seedTimeRange = 20 * 365 * 24 * time.Hour
+
+type Config struct {
+	// Зерно генератора случайных чисел.
+	// Если указано, то одинаковые схема и зерно всегда дают одинаковые записи.
+	// Если nil, то используется случайное зерно и текущее время.
+	Seed *int64
+	// Генерировать негативные записи, которые база данных должна отклонить.
+	Negative bool
+	// Заполнение колонок внешних ключей
+	ForeignKeys ForeignKeyConfig
+	// Генерация большого количества записей
+	Volume VolumeConfig
+}
+
 type Generator struct {
 	s     *schema.Schema
 	order []schema.Table
+	// таблицы, которые можно заполнять одновременно
+	levels [][]schema.Table
+
+	// источник случайных значений для всех доменов
+	rand *rand.Rand
+	// момент времени, от которого отсчитываются домены времени
+	now time.Time
+	// генерировать негативные записи
+	negative bool
+	// разобранные CHECK ограничения, где ключ - имя таблицы
+	checks map[string][]*CheckConstraint
+	// заполнение колонок внешних ключей
+	fkConf ForeignKeyConfig
+	// внешние ключи, удаленные из графа для разрыва циклов, где ключ - имя таблицы
+	deferred map[string][]string
+	// количество записей таблиц и распределения значений
+	volume VolumeConfig
+	// партиции таблиц, где ключ - имя таблицы
+	partitions map[string]*tablePartitions
 
 	log *zap.Logger
 }
 
-func New(log *zap.Logger, s *schema.Schema) (*Generator, error) {
-	graph := s.NewGraph()
+func New(log *zap.Logger, s *schema.Schema, conf Config) (*Generator, error) {
+	if conf.ForeignKeys.FanOut < 0 {
+		return nil, xerrors.Errorf("foreign keys fan-out must not be negative: %d", conf.ForeignKeys.FanOut)
+	}
+	volume, err := resolveVolume(s, conf.Volume)
+	if err != nil {
+		return nil, xerrors.Errorf("volume config: %w", err)
+	}
+	graph, err := s.NewAcyclicGraph()
+	if err != nil {
+		return nil, err
+	}
 	order, err := graph.TopologicalSort()
 	if err != nil {
 		return nil, err
 	}
+	levelNames, err := graph.Levels()
+	if err != nil {
+		return nil, err
+	}
+	deferred := make(map[string][]string, len(graph.Deferred))
+	for _, edge := range graph.Deferred {
+		log.Info("foreign keys are filled after insertion of referenced table to break a cycle",
+			zap.Stringer("edge", edge),
+			zap.Bool("deferrable", edge.Deferrable()),
+		)
+		for _, fk := range edge.ForeignKeys {
+			deferred[edge.Table] = append(deferred[edge.Table], fk.Constraint.Name)
+		}
+	}
 
 	tablesOrdered := make([]schema.Table, 0, len(order))
+	checks := make(map[string][]*CheckConstraint, len(order))
+	partitions := make(map[string]*tablePartitions)
 	for _, tableName := range order {
-		tablesOrdered = append(tablesOrdered, s.Tables[tableName])
+		table := s.Tables[tableName]
+		tablesOrdered = append(tablesOrdered, table)
+		checks[tableName] = append(parseTableChecks(log, table), sequenceChecks(table)...)
+
+		parts, err := parseTablePartitions(table)
+		if err != nil {
+			log.Warn("partitioning is not supported and will be ignored",
+				zap.Stringer("table", table),
+				zap.Error(err),
+			)
+			continue
+		}
+		if parts == nil {
+			continue
+		}
+		partitions[tableName] = parts
+		// значения, которые не попадают ни в одну партицию, вставить нельзя
+		if check := parts.routingCheck(table); check != nil {
+			checks[tableName] = append(checks[tableName], check)
+		}
 	}
 	log.Debug("tables insert order", zap.Stringers("order", tablesOrdered))
 
+	levels := make([][]schema.Table, 0, len(levelNames))
+	for _, names := range levelNames {
+		level := make([]schema.Table, 0, len(names))
+		for _, tableName := range names {
+			level = append(level, s.Tables[tableName])
+		}
+		levels = append(levels, level)
+	}
+
+	r, now := NewRand(conf.Seed)
 	g := &Generator{
-		log:   log,
-		order: tablesOrdered,
-		s:     s,
+		log:    log,
+		order:  tablesOrdered,
+		levels: levels,
+		s:      s,
+		rand:   r,
+		now:    now,
+
+		negative:   conf.Negative,
+		checks:     checks,
+		fkConf:     conf.ForeignKeys,
+		deferred:   deferred,
+		volume:     volume,
+		partitions: partitions,
 	}
 	return g, nil
 }
 
-// type Records struct {
-// 	Table  *schema.Table
-// 	Values []map[int]string
-// }
+// NewRand возвращает генератор случайных чисел и момент времени для доменов.
+// Если зерно указано, то и генератор, и время полностью определяются им.
+func NewRand(seed *int64) (r *rand.Rand, now time.Time) {
+	if seed == nil {
+		//nolint:gosec // криптографическая стойкость не нужна
+		return rand.New(rand.NewSource(time.Now().UnixNano())), time.Now()
+	}
+	//nolint:gosec // криптографическая стойкость не нужна
+	r = rand.New(rand.NewSource(*seed))
+	now = seedTimeBase.Add(time.Duration(r.Int63n(int64(seedTimeRange/time.Second))) * time.Second)
+	return r, now
+}
+
+// Order возвращает таблицы в порядке, в котором их нужно заполнять.
+func (g *Generator) Order() []schema.Table { return g.order }
+
+// Levels возвращает таблицы, разбитые на уровни, которые можно заполнять одновременно.
+func (g *Generator) Levels() [][]schema.Table { return g.levels }
 
 type CustomTableDomain struct {
-	ColumnDomains map[int]Domain
+	// домены колонок, где ключ - имя колонки
+	ColumnDomains map[string]Domain
 }
 
 type PartialRecords struct {
+	// частичные записи, где ключ - имя таблицы
 	Records map[string]Records
 }
 
 // GenerateRecords генерирует данные по массиву проверок значений для каждой колонки.
 // если для таблицы не указаны проверки значений, то они генерируются на лету из базовых.
+// Результат - полные записи, где ключ - имя таблицы.
+// Негативные записи, если они включены, идут после обычных записей таблицы.
 func (g *Generator) GenerateRecords(
 	partial PartialRecords,
-	domains map[int]CustomTableDomain,
-) (res map[int]Records, warnings []error) {
-	res = make(map[int]Records, len(g.order))
+	domains map[string]CustomTableDomain,
+) (res map[string]Records, warnings []error) {
+	res = make(map[string]Records, len(g.order))
+	tgens := make(map[string]*tableGenerator, len(g.order))
 
 	// порядок обхода, найденный топологической сортировкой
 tables:
 	for _, table := range g.order {
-		// TODO генерить дефолтные значения нужно вне этой функции
 		// если нет проверок для текущей таблицы, то будут дефолтные проверки.
-		tablePartialRecords, ok := partialRecords[table.OID()]
+		tablePartialRecords, ok := partial.Records[table.String()]
 		if !ok {
-			g.log.Info("generate default checks for table", zap.Stringer("table", table.Name))
+			g.log.Info("generate default checks for table", zap.Stringer("table", table))
 			tablePartialRecords = g.GetDefaultChecks(table)
 		}
 
-		domain, ok := domains[table.OID()]
-		if !ok {
-			domain = CustomTableDomain{
-				ColumnDomains: make(map[int]Domain),
-			}
-		}
-
-		for _, col := range table.Columns {
-			_, ok := domain.ColumnDomains[col.ColNum]
-			if ok {
-				continue
-			}
-			defaultDomain, err := g.DefaultDomain(col)
-			if err != nil {
-				warnings = append(warnings, err)
-				g.log.Warn("get domain",
-					zap.Stringer("table", table),
-					zap.Stringer("column", col),
-					zap.Stringer("type", col.Type),
-					zap.Error(err),
-				)
-				continue tables
-			}
-			domain.ColumnDomains[col.ColNum] = defaultDomain
+		checks := g.checks[table.String()]
+		domain, err := g.tableDomains(table, domains[table.String()], checks)
+		if err != nil {
+			warnings = append(warnings, err)
+			continue tables
 		}
 
-		tgen := newTableGenerator(g.log, table, domain)
+		tgen := newTableGenerator(g.log, table, domain, checks)
+		g.linkForeignKeys(tgen, func(fk *tableForeignKey) { fk.addRecords(res[fk.fk.ReferenceTable]) })
 
 		records, err := tgen.generateTableRecords(tablePartialRecords)
 		if err != nil {
@@ -117,29 +222,100 @@ tables:
 			)
 			continue tables
 		}
-		res[table.OID()] = records
+		res[table.String()] = records
+		tgens[table.String()] = tgen
+
+		if g.negative {
+			negative, negativeWarnings := g.generateNegativeRecords(tgen, res)
+			for _, err := range negativeWarnings {
+				warnings = append(warnings, err)
+				g.log.Warn("generate negative records", zap.Stringer("table", table), zap.Error(err))
+			}
+			records.Records = append(records.Records, negative.Records...)
+			res[table.String()] = records
+		}
 	}
+	g.fillDeferredForeignKeys(tgens, res)
 
 	return res, warnings
 }
 
-func (g *Generator) DefaultDomain(col *schema.Column) (Domain, error) {
-	if col.Type.Type == schema.DataTypeEnum {
-		return &EnumDomain{
-			// TODO нужно ли делать явное приведение типов?
-			values: col.Type.EnumType.Values,
-		}, nil
+// tableDomains возвращает домены колонок таблицы, ограниченные CHECK ограничениями.
+// Для колонок, у которых не указан домен в custom, используется домен по умолчанию.
+func (g *Generator) tableDomains(
+	table schema.Table,
+	custom CustomTableDomain,
+	checks []*CheckConstraint,
+) (CustomTableDomain, error) {
+	domain := CustomTableDomain{
+		ColumnDomains: make(map[string]Domain, len(table.Columns)),
+	}
+
+	// обход в порядке объявления, чтобы предупреждения не зависели от порядка обхода map
+	for _, col := range sortedColumns(table) {
+		if col.Attributes.IsGeneratedAlways() {
+			continue
+		}
+		colDomain, ok := custom.ColumnDomains[col.Name]
+		if ok {
+			domain.ColumnDomains[col.Name] = g.checkedDomain(col, colDomain, checks)
+			continue
+		}
+		defaultDomain, err := g.DefaultDomain(col)
+		if err != nil {
+			err = xerrors.Errorf("table %q, column %q: %w", table, col.Name, err)
+			g.log.Warn("get domain",
+				zap.Stringer("table", table),
+				zap.String("column", col.Name),
+				zap.Stringer("type", col.Type),
+				zap.Error(err),
+			)
+			return domain, err
+		}
+		domain.ColumnDomains[col.Name] = g.checkedDomain(col, defaultDomain, checks)
+	}
+	return domain, nil
+}
+
+// checkedDomain ограничивает домен колонки CHECK ограничениями, в которых она упоминается.
+func (g *Generator) checkedDomain(col schema.Column, domain Domain, checks []*CheckConstraint) Domain {
+	checks = columnChecks(checks, col.Name)
+	if len(checks) == 0 {
+		return domain
+	}
+	valid, _ := columnCheckValues(col, checks)
+	return newCheckedDomain(domain, col.Name, checks, valid)
+}
+
+// DefaultDomain возвращает домен значений по умолчанию для колонки.
+func (g *Generator) DefaultDomain(col schema.Column) (Domain, error) {
+	return g.defaultTypeDomain(col.Type, col.Attributes.DomainAttributes)
+}
+
+func (g *Generator) defaultTypeDomain(typ *schema.DBType, attr schema.DomainAttributes) (Domain, error) {
+	switch typ.Type {
+	case schema.DataTypeEnum:
+		values := make([]string, 0, len(typ.EnumValues))
+		for _, value := range typ.EnumValues {
+			values = append(values, fmt.Sprintf("%s::%s", quote(value), typ))
+		}
+		return NewEnumDomain(values), nil
+	case schema.DataTypeDomain:
+		if typ.ElemType == nil {
+			return nil, xerrors.Errorf("base type of domain %q is not specified", typ)
+		}
+		if typ.DomainAttributes != nil {
+			attr = *typ.DomainAttributes
+		}
+		return g.defaultTypeDomain(typ.ElemType, attr)
+	case schema.DataTypeComposite:
+		return g.compositeDomain(typ)
 	}
 
-	typeName := col.Type.TypeName
-	if typeName.Schema != "pg_catalog" {
+	typeName := typ.TypeName
+	if typeName.Schema != pgCatalogSchema {
 		return nil, xerrors.Errorf(
-			"unable to determine default type domain for non-default postgres type %q. "+
-				"table %q, column %q",
-			typeName,
-			col.Table.Name,
-			col.Name,
-		)
+			"unable to determine default type domain for non-default postgres type %q", typeName)
 	}
 
 	switch typeName.Name {
@@ -152,8 +328,8 @@ func (g *Generator) DefaultDomain(col *schema.Column) (Domain, error) {
 	case "float4", "float8", "numeric":
 		var f FloatDomain
 		f.Init(numericToFloatDomainParams(
-			col.Attributes.NumericPrecision,
-			col.Attributes.NumericScale,
+			attr.NumericPrecision,
+			attr.NumericScale,
 		))
 		return &f, nil
 	case "uuid",
@@ -163,7 +339,11 @@ func (g *Generator) DefaultDomain(col *schema.Column) (Domain, error) {
 		"char",
 		"varchar",
 		"text":
-		return &UUIDDomain{}, nil
+		d := &UUIDDomain{Rand: g.rand}
+		if attr.HasCharMaxLength {
+			d.MaxLength = attr.CharMaxLength
+		}
+		return d, nil
 	case "date",
 		"time",
 		"timetz",
@@ -171,66 +351,67 @@ func (g *Generator) DefaultDomain(col *schema.Column) (Domain, error) {
 		"timestamptz":
 		// TODO interval
 		var t TimeDomain
-		t.Init(time.Now(), defaultTopDomainIterations)
+		t.Init(g.now, defaultTopDomainIterations)
 		return &t, nil
 	default:
 		return nil, xerrors.Errorf(
-			"unable to determine default domain for type %q. "+
-				"table %q, column %q",
-			typeName,
-			col.Table.Name,
-			col.Name,
-		)
+			"unable to determine default domain for type %q", typeName)
 	}
 }
 
 type tableGenerator struct {
 	log     *zap.Logger
-	table   *schema.Table
+	table   schema.Table
 	domains CustomTableDomain
-
-	// уникальные индексы таблицы
-	uniqueIndexes map[int]*schema.Index
+	// CHECK ограничения таблицы
+	checks []*CheckConstraint
+	// внешние ключи таблицы в порядке их имен
+	foreignKeys []*tableForeignKey
+	fkConf      ForeignKeyConfig
+
+	// колонки таблицы в порядке их объявления
+	columns []schema.Column
+	// уникальные индексы таблицы в порядке их имен
+	uniqueIndexes []*uniqueIndex
 	// множество значений отдельных колонок
 	// map[col_name]map[value]struct{}
-	colValues map[int]mapset.Set[string]
-	// для каждого уникального индекса показывает заполненные его значения
-	// map[index_name]map[composite_value]struct{}
-	uniqueIndexValues map[int]mapset.Set[string]
+	colValues map[string]mapset.Set[string]
 }
 
 func newTableGenerator(
 	log *zap.Logger,
-	table *schema.Table,
+	table schema.Table,
 	domain CustomTableDomain,
+	checks []*CheckConstraint,
 ) *tableGenerator {
-	uniqueIndexes := make(map[int]*schema.Index)
-	for indexName, index := range table.Indexes {
-		if index.IsUnique {
-			uniqueIndexes[indexName] = index
-		}
-	}
-
+	log = log.With(zap.Stringer("table", table))
 	t := &tableGenerator{
-		log:     log.With(zap.Stringer("table", table)),
+		log:     log,
 		table:   table,
 		domains: domain,
+		checks:  checks,
 
-		colValues:         make(map[int]mapset.Set[string], len(table.Columns)),
-		uniqueIndexes:     uniqueIndexes,
-		uniqueIndexValues: make(map[int]mapset.Set[string], len(uniqueIndexes)),
+		columns:       sortedColumns(table),
+		colValues:     make(map[string]mapset.Set[string], len(table.Columns)),
+		uniqueIndexes: newUniqueIndexes(log, table),
 	}
 
-	for indexName := range uniqueIndexes {
-		t.uniqueIndexValues[indexName] = mapset.NewThreadUnsafeSet[string]()
-	}
 	for _, col := range table.Columns {
-		t.colValues[col.ColNum] = mapset.NewThreadUnsafeSet[string]()
+		t.colValues[col.Name] = mapset.NewThreadUnsafeSet[string]()
 	}
 
 	return t
 }
 
+// sortedColumns возвращает колонки таблицы в порядке их объявления.
+func sortedColumns(table schema.Table) []schema.Column {
+	columns := maps.Values(table.Columns)
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i].ColNum < columns[j].ColNum
+	})
+	return columns
+}
+
 func (g *tableGenerator) generateTableRecords(
 	partialRecords Records,
 ) (records Records, err error) {
@@ -240,110 +421,126 @@ func (g *tableGenerator) generateTableRecords(
 	}()
 
 	// Для каждой полученной частичной записи надо догенерировать значения отсутствующих колонок
-	for _, precord := range partialRecords.Records {
-		vals, err := g.generateRecordValues(precord)
+	for idx := range partialRecords.Records {
+		precord := &partialRecords.Records[idx]
+		if failed := failedCheck(g.checks, recordValues(*precord)); failed != nil {
+			g.log.Warn("partial record violates check constraint and will be skipped",
+				zap.Stringer("record", precord),
+				zap.Stringer("constraint", failed),
+			)
+			continue
+		}
+		vals, err := g.generateRecordValues(*precord)
+		var skipErr *skipRecordError
+		if errors.As(err, &skipErr) {
+			g.log.Warn("partial record can not be completed and will be skipped",
+				zap.Stringer("record", precord),
+				zap.Error(err),
+			)
+			continue
+		}
 		if err != nil {
 			return records, err
 		}
-		records.Records = append(records.Records, g.recordFromMap(vals))
+		record := g.recordFromMap(vals)
+		record.Partial = precord
+		records.Records = append(records.Records, record)
 	}
 
-	// TODO для всех уникальных индексов
-	/* только для пересекающихся групп индексов
-	   для каждой колонки группы - счетчик индексов
-	   если декартово произведение уникальных значений колонок уже содержится в каком-либо индексе
-	   то исключаются все индексы, основанные на этих колонках
-	*/
+	children, err := g.generateChildRecords()
+	if err != nil {
+		return records, err
+	}
+	records.Records = append(records.Records, children.Records...)
 
 	return records, nil
 }
 
-func (g *tableGenerator) recordFromMap(m map[int]string) (res Record) {
-	for colNum, value := range m {
-		res.Columns = append(res.Columns, colNum)
+func (g *tableGenerator) recordFromMap(m map[string]string) (res Record) {
+	for colName, value := range m {
+		res.Columns = append(res.Columns, colName)
 		res.Values = append(res.Values, value)
 	}
 	sort.Sort(res)
 	return res
 }
 
-func (g *tableGenerator) generateRecordValues(precord Record) (map[int]string, error) {
+func (g *tableGenerator) generateRecordValues(precord Record) (map[string]string, error) {
 	// record соответствует полной записи
-	// TODO генерируемые колонки - их надо пропускать почти всегда. Надо это настроить
-	record := make(map[int]string, len(g.table.Columns))
-	for idx, colName := range precord.Columns {
-		record[colName] = precord.Values[idx]
+	record := recordValues(precord)
+	// ограничения, которые нарушены значениями частичной записи, не проверяются.
+	// Такие записи генерируются только как негативные.
+	var checks []*CheckConstraint
+	for _, check := range g.checks {
+		if check.Check(record) {
+			checks = append(checks, check)
+		}
 	}
 
-	for _, col := range g.table.Columns {
-		if _, ok := record[col.ColNum]; ok {
-			continue
+	// колонки внешних ключей заполняются значениями из родительских таблиц
+	var selfRefs []*tableForeignKey
+	selfRefColumns := make(map[string]bool)
+	for _, fk := range g.foreignKeys {
+		pending, err := g.fillForeignKey(fk, record, checks)
+		if err != nil {
+			return nil, err
 		}
-
-		domain, ok := g.domains.ColumnDomains[col.ColNum]
-		if !ok {
-			return nil, xerrors.Errorf(
-				"internal error: unable to find column domain for column %q for table %q",
-				col.Name, g.table.Name)
+		if pending {
+			selfRefs = append(selfRefs, fk)
+			for _, col := range fk.fk.Constraint.Columns {
+				selfRefColumns[col] = true
+			}
 		}
+	}
 
-		// TODO перебирать можно только заполненные записи
-		if ok := g.generateAndCheckValue(col, domain, record); !ok {
-			// TODO по идее по исчерпании домена надо текущую запись пропускать и продолжить
-			return nil, xerrors.Errorf(
-				"unable to generate values within expiration of domain. column %q, table %q",
-				col.Name, g.table.Name)
+	var columns []schema.Column
+	for _, col := range g.columns {
+		if _, ok := record[col.Name]; ok {
+			continue
 		}
+		// генерируемые колонки заполняет сама база данных
+		if col.Attributes.IsGeneratedAlways() || selfRefColumns[col.Name] {
+			continue
+		}
+		columns = append(columns, col)
+	}
+	if err := g.fillColumns(columns, record, checks); err != nil {
+		return nil, err
 	}
 
-	for indexName, index := range g.uniqueIndexes {
-		key := g.concatIndexColumnsFromRecord(record, index)
-		g.uniqueIndexValues[indexName].Add(key)
+	for _, fk := range selfRefs {
+		if err := g.fillSelfReference(fk, record, checks); err != nil {
+			return nil, err
+		}
 	}
+
+	g.addUniqueValues(record)
+	g.countReferences(record)
 	for colName, value := range record {
-		g.colValues[colName].Add(value)
+		if values, ok := g.colValues[colName]; ok {
+			values.Add(value)
+		}
 	}
 
 	return record, nil
 }
 
-func (g *tableGenerator) generateAndCheckValue(
-	col *schema.Column,
-	domain Domain,
-	record map[int]string,
-) bool {
-	domain.Reset()
-domainLoop:
-	for domain.Next() {
-		// TODO add explicit type cast to result only if needed
-		value := domain.Value()
-		// FIXME +/-1 ?
-		if col.Attributes.HasCharMaxLength && len(value) > col.Attributes.CharMaxLength {
-			value = value[:col.Attributes.CharMaxLength]
-		}
-		record[col.ColNum] = value
-
-		// TODO тут выделяется куча памяти
-		for indexName, index := range g.uniqueIndexes {
-			key := g.concatIndexColumnsFromRecord(record, index)
-			if g.uniqueIndexValues[indexName].Contains(key) {
-				continue domainLoop //nolint:gocritic // fp
-			}
-		}
-	}
-
-	return false
+// skipRecordError означает, что частичную запись невозможно дополнить до допустимой записи,
+// например если ни одно значение домена не выполняет CHECK ограничение.
+// Такая запись пропускается, остальные записи таблицы генерируются как обычно.
+type skipRecordError struct {
+	reason string
 }
 
-func (g *tableGenerator) concatIndexColumnsFromRecord(
-	record map[int]string,
-	index *schema.Index,
-) string {
-	fields := make([]string, 0, len(index.Columns))
-	for _, col := range index.Columns {
-		fields = append(fields, strconv.Quote(record[col.ColNum]))
+func (e *skipRecordError) Error() string { return e.reason }
+
+// recordValues возвращает значения записи, где ключ - имя колонки.
+func recordValues(record Record) map[string]string {
+	values := make(map[string]string, len(record.Columns))
+	for idx, colName := range record.Columns {
+		values[colName] = record.Values[idx]
 	}
-	return strings.Join(fields, ",")
+	return values
 }
 
 func numericToFloatDomainParams(precision, scale int) (top, step float64) {
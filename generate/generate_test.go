@@ -5,6 +5,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Feresey/mtest/schema"
 )
 
 func TestNumericToFloatDomainParams(t *testing.T) {
@@ -55,3 +59,117 @@ func TestNumericToFloatDomainParams(t *testing.T) {
 			})
 	}
 }
+
+func TestGenerateRecords(t *testing.T) {
+	r := require.New(t)
+
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	status := &schema.DBType{
+		TypeName:   schema.Identifier{OID: 100, Schema: "test", Name: "status"},
+		Type:       schema.DataTypeEnum,
+		EnumValues: []string{"active", "inactive"},
+	}
+	pkIndex := schema.Index{
+		Name:      "users_pkey",
+		Columns:   []string{"id"},
+		IsUnique:  true,
+		IsPrimary: true,
+	}
+	users := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "users"},
+		Columns: map[string]schema.Column{
+			"id": {
+				ColNum: 1, Name: "id", Type: int4,
+				Attributes: schema.ColumnAttributes{
+					DomainAttributes: schema.DomainAttributes{NotNullable: true},
+				},
+			},
+			"status": {ColNum: 2, Name: "status", Type: status},
+			"total": {
+				ColNum: 3, Name: "total", Type: int4,
+				Attributes: schema.ColumnAttributes{IsGenerated: true, HasDefault: true},
+			},
+		},
+		PrimaryKey: &schema.Constraint{
+			Name: "users_pkey", Type: schema.ConstraintTypePK,
+			Index: &pkIndex, Columns: []string{"id"},
+		},
+		Indexes: map[string]schema.Index{"users_pkey": pkIndex},
+	}
+	s := &schema.Schema{
+		Types:  map[string]*schema.DBType{int4.String(): int4, status.String(): status},
+		Tables: map[string]schema.Table{users.String(): users},
+	}
+
+	gen, err := New(zap.NewNop(), s, Config{})
+	r.NoError(err)
+
+	res, warnings := gen.GenerateRecords(PartialRecords{}, nil)
+	r.Empty(warnings)
+	r.Contains(res, "test.users")
+
+	records := res["test.users"].Records
+	r.NotEmpty(records)
+	ids := make(map[string]struct{}, len(records))
+	statuses := make(map[string]struct{})
+	for _, record := range records {
+		r.Equal([]string{"id", "status"}, record.Columns)
+		r.NotContains(ids, record.Values[0], "primary key must be unique")
+		ids[record.Values[0]] = struct{}{}
+		statuses[record.Values[1]] = struct{}{}
+	}
+	r.Contains(statuses, "NULL")
+	r.Contains(statuses, "'active'::test.status")
+	r.Contains(statuses, "'inactive'::test.status")
+}
+
+func TestGenerateRecordsSeed(t *testing.T) {
+	r := require.New(t)
+
+	uuidType := &schema.DBType{
+		TypeName: schema.Identifier{OID: 2950, Schema: "pg_catalog", Name: "uuid"},
+		Type:     schema.DataTypeBase,
+	}
+	tsType := &schema.DBType{
+		TypeName: schema.Identifier{OID: 1184, Schema: "pg_catalog", Name: "timestamptz"},
+		Type:     schema.DataTypeBase,
+	}
+	events := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "events"},
+		Columns: map[string]schema.Column{
+			"id":         {ColNum: 1, Name: "id", Type: uuidType},
+			"created_at": {ColNum: 2, Name: "created_at", Type: tsType},
+		},
+	}
+	s := &schema.Schema{
+		Types: map[string]*schema.DBType{
+			uuidType.String(): uuidType,
+			tsType.String():   tsType,
+		},
+		Tables: map[string]schema.Table{events.String(): events},
+	}
+
+	generateWithSeed := func(seed int64) []Record {
+		gen, err := New(zap.NewNop(), s, Config{Seed: &seed})
+		r.NoError(err)
+		// значения колонок берутся из доменов, если их нет в частичных записях
+		partial := PartialRecords{Records: map[string]Records{
+			events.String(): {Records: make([]Record, 3)},
+		}}
+		res, warnings := gen.GenerateRecords(partial, nil)
+		r.Empty(warnings)
+		records := res["test.events"].Records
+		for idx := range records {
+			records[idx].Partial = nil
+		}
+		return records
+	}
+
+	first := generateWithSeed(42)
+	r.NotEmpty(first)
+	r.Equal(first, generateWithSeed(42))
+	r.NotEqual(first, generateWithSeed(43))
+}
@@ -0,0 +1,126 @@
+package generate
+
+import (
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+const nullLiteral = "NULL"
+
+// ParseLiteral преобразует SQL литерал из проверок или доменов в текстовое представление значения.
+// Например 'NaN'::REAL -> NaN, 'text' -> text, 10 -> 10, ROW(1, 'a b')::test.pair -> (1,"a b").
+// Для NULL возвращается isNull = true.
+func ParseLiteral(literal string) (value string, isNull bool, err error) {
+	literal = strings.TrimSpace(literal)
+	if strings.EqualFold(literal, nullLiteral) {
+		return "", true, nil
+	}
+	if len(literal) > len(rowPrefix) && strings.EqualFold(literal[:len(rowPrefix)], rowPrefix) {
+		value, err := parseRowLiteral(literal)
+		return value, false, err
+	}
+	if !strings.HasPrefix(literal, "'") {
+		value, _, _ = strings.Cut(literal, "::")
+		if value == "" || strings.ContainsAny(value, "'() ") {
+			return "", false, xerrors.Errorf("unsupported literal: %s", literal)
+		}
+		return value, false, nil
+	}
+
+	var sb strings.Builder
+	for i := 1; i < len(literal); i++ {
+		if literal[i] != '\'' {
+			sb.WriteByte(literal[i])
+			continue
+		}
+		// экранированная кавычка
+		if i+1 < len(literal) && literal[i+1] == '\'' {
+			sb.WriteByte('\'')
+			i++
+			continue
+		}
+		// после закрывающей кавычки может быть только приведение типа
+		rest := literal[i+1:]
+		if rest != "" && !strings.HasPrefix(rest, "::") {
+			return "", false, xerrors.Errorf("unsupported literal: %s", literal)
+		}
+		return sb.String(), false, nil
+	}
+
+	return "", false, xerrors.Errorf("unterminated string literal: %s", literal)
+}
+
+const rowPrefix = "ROW("
+
+// parseRowLiteral преобразует литерал ROW(...) в текстовое представление значения составного типа.
+// Поля со специальными символами заключаются в двойные кавычки, NULL поля остаются пустыми.
+func parseRowLiteral(literal string) (string, error) {
+	fields, rest, err := splitRowFields(literal[len(rowPrefix):])
+	if err != nil {
+		return "", xerrors.Errorf("%w: %s", err, literal)
+	}
+	// после закрывающей скобки может быть только приведение типа
+	if rest != "" && !strings.HasPrefix(rest, "::") {
+		return "", xerrors.Errorf("unsupported literal: %s", literal)
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for idx, field := range fields {
+		if idx != 0 {
+			sb.WriteByte(',')
+		}
+		value, isNull, err := ParseLiteral(field)
+		if err != nil {
+			return "", xerrors.Errorf("field %d of %s: %w", idx+1, literal, err)
+		}
+		if !isNull {
+			sb.WriteString(quoteRowField(value))
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String(), nil
+}
+
+// splitRowFields разбивает перечисление полей ROW на литералы полей.
+// body начинается после открывающей скобки, rest - остаток после закрывающей скобки.
+func splitRowFields(body string) (fields []string, rest string, err error) {
+	var (
+		depth  int
+		quoted bool
+		start  int
+	)
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case c == '\'':
+			// экранированная кавычка внутри строки переключает состояние дважды
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ')':
+			if field := strings.TrimSpace(body[start:i]); field != "" || len(fields) != 0 {
+				fields = append(fields, field)
+			}
+			return fields, strings.TrimSpace(body[i+1:]), nil
+		case c == ',' && depth == 0:
+			fields = append(fields, strings.TrimSpace(body[start:i]))
+			start = i + 1
+		}
+	}
+	return nil, "", xerrors.New("unterminated row literal")
+}
+
+// quoteRowField заключает значение поля составного типа в двойные кавычки, если это необходимо.
+// Пустая строка тоже заключается в кавычки, чтобы не совпадать с NULL.
+func quoteRowField(value string) string {
+	if value != "" && !strings.ContainsAny(value, ",()\"\\ \t\n\r\v\f") {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `""`)
+	return `"` + value + `"`
+}
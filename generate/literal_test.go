@@ -0,0 +1,52 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLiteral(t *testing.T) {
+	tests := []struct {
+		literal string
+		want    string
+		isNull  bool
+		wantErr bool
+	}{
+		{literal: "NULL", isNull: true},
+		{literal: "0", want: "0"},
+		{literal: "-2147483648", want: "-2147483648"},
+		{literal: "True", want: "True"},
+		{literal: "''", want: ""},
+		{literal: "' '", want: " "},
+		{literal: "'it''s'", want: "it's"},
+		{literal: "'NaN'::REAL", want: "NaN"},
+		{literal: "'active'::test.status", want: "active"},
+		{literal: "'2023-01-03T00:00:00Z'", want: "2023-01-03T00:00:00Z"},
+		{literal: "'unterminated", wantErr: true},
+		{literal: "'a' || 'b'", wantErr: true},
+		{literal: "ROW(1, 2)", want: "(1,2)"},
+		{literal: "ROW()::test.empty", want: "()"},
+		{literal: "row(NULL, 'x')::test.pair", want: "(,x)"},
+		{literal: "ROW('', 'a b', 'it''s, \"q\"', 'c:\\d')::test.quad", want: `("","a b","it's, ""q""","c:\\d")`},
+		{literal: "ROW(ROW(1, 'a')::test.pair, 'NaN'::REAL)::test.nested", want: `("(1,a)",NaN)`},
+		{literal: "ROW('(', ')')", want: `("(",")")`},
+		{literal: "ROW(1, 2", wantErr: true},
+		{literal: "ROW(1, )", wantErr: true},
+		{literal: "ROW(1) || 'a'", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.literal, func(t *testing.T) {
+			r := require.New(t)
+			value, isNull, err := ParseLiteral(tt.literal)
+			if tt.wantErr {
+				r.Error(err)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tt.isNull, isNull)
+			r.Equal(tt.want, value)
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package generate
+
+import (
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/Feresey/mtest/generate/checks"
+	"github.com/Feresey/mtest/generate/domains"
+)
+
+// NewLuaState создает lua машину с модулями доменов и проверок.
+// Lua домены используют тот же источник случайных значений и момент времени, что и домены генератора,
+// поэтому при заданном seed они тоже возвращают одинаковые значения.
+func (g *Generator) NewLuaState() *lua.LState {
+	l := lua.NewState()
+	domains.RegisterModule(l, domains.Config{Rand: g.rand, Now: g.now})
+	checks.RegisterModule(l)
+	return l
+}
@@ -0,0 +1,31 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestNewLuaStateSeed(t *testing.T) {
+	luaValues := func(seed int64) []string {
+		gen, err := New(zap.NewNop(), &schema.Schema{}, Config{Seed: &seed})
+		require.NoError(t, err)
+		l := gen.NewLuaState()
+		t.Cleanup(l.Close)
+
+		require.NoError(t, l.DoString(`
+		local domains = require("domains")
+		local uuid = domains.UUID:new()
+		id = uuid:next()
+		now = require("go_time").now()
+		`))
+		return []string{l.GetGlobal("id").String(), l.GetGlobal("now").String()}
+	}
+
+	first := luaValues(1)
+	require.Equal(t, first, luaValues(1))
+	require.NotEqual(t, first, luaValues(2))
+}
@@ -0,0 +1,426 @@
+package generate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+// ViolationKind описывает вид ограничения, которое нарушает негативная запись.
+type ViolationKind string
+
+const (
+	ViolationNotNull      ViolationKind = "not null"
+	ViolationLength       ViolationKind = "length"
+	ViolationNumericRange ViolationKind = "numeric range"
+	ViolationUnique       ViolationKind = "unique"
+	ViolationForeignKey   ViolationKind = "foreign key"
+	ViolationEnum         ViolationKind = "enum"
+	ViolationCheck        ViolationKind = "check"
+	// значение ключа партиционирования не попадает ни в одну партицию
+	ViolationPartition ViolationKind = "partition"
+)
+
+// Violation описывает ограничение, которое должна нарушить негативная запись.
+// База данных обязана отклонить такую запись.
+type Violation struct {
+	Kind ViolationKind
+	// Колонка с недопустимым значением. Пустая для ограничений на несколько колонок.
+	Column string
+	// Имя ограничения или индекса. Пустое для ограничений без имени, например NOT NULL.
+	Constraint string
+}
+
+func (v Violation) String() string {
+	switch {
+	case v.Constraint != "":
+		return fmt.Sprintf("%s constraint %q", v.Kind, v.Constraint)
+	case v.Column != "":
+		return fmt.Sprintf("%s constraint on column %q", v.Kind, v.Column)
+	default:
+		return string(v.Kind) + " constraint"
+	}
+}
+
+// недопустимая метка перечисления
+const invalidEnumLabel = "mtest_invalid"
+
+const (
+	// количество значений домена колонки, которые перебираются при поиске нарушения CHECK ограничения
+	maxCheckCandidates = 20
+	// максимальное количество сочетаний значений при поиске нарушения CHECK ограничения
+	maxCheckSearchSteps = 10 * defaultTopDomainIterations
+)
+
+// generateNegativeRecords генерирует записи, которые должны быть отклонены базой данных.
+// Каждая запись нарушает ровно одно ограничение, остальные колонки заполняются как у обычных записей.
+// Превышение scale у numeric не проверяется, т.к. PostgreSQL молча округляет такие значения.
+func (g *Generator) generateNegativeRecords(
+	tgen *tableGenerator,
+	records map[string]Records,
+) (res Records, warnings []error) {
+	table := tgen.table
+	add := func(v Violation, partial Record) {
+		record, err := tgen.generateNegativeRecord(partial, v)
+		if err != nil {
+			warnings = append(warnings, xerrors.Errorf("table %q, negative record for %s: %w", table, v, err))
+			return
+		}
+		res.Records = append(res.Records, record)
+	}
+
+	// нарушенные ограничения без учета колонки
+	violated := make(map[Violation]bool)
+	for _, col := range tgen.columns {
+		if col.Attributes.IsGeneratedAlways() {
+			continue
+		}
+		_, invalid := columnCheckValues(col, tgen.checks)
+		for _, neg := range append(negativeColumnValues(col), invalid...) {
+			violated[Violation{Kind: neg.violation.Kind, Constraint: neg.violation.Constraint}] = true
+			add(neg.violation, Record{Columns: []string{col.Name}, Values: []string{neg.value}})
+		}
+	}
+
+	// ограничения на несколько колонок, ключ партиционирования и ограничения без граничных значений,
+	// например CHECK (start_at < end_at) или FOR VALUES IN ('a', 'b')
+	for _, check := range tgen.checks {
+		if check.sequence || violated[check.violation("")] {
+			continue
+		}
+		partial, ok := tgen.violatingCheckValues(check)
+		if !ok {
+			tgen.log.Debug("unable to find values violating check constraint", zap.Stringer("constraint", check))
+			continue
+		}
+		var colName string
+		if len(check.Columns) == 1 {
+			colName = check.Columns[0]
+		}
+		add(check.violation(colName), partial)
+	}
+
+	for _, index := range tgen.uniqueIndexes {
+		partial, ok := duplicateIndexValues(index, records[table.String()])
+		if !ok {
+			continue
+		}
+		add(Violation{Kind: ViolationUnique, Constraint: index.index.Name}, partial)
+	}
+
+	fkNames := maps.Keys(table.ForeignKeys)
+	sort.Strings(fkNames)
+	for _, fkName := range fkNames {
+		fk := table.ForeignKeys[fkName]
+		// записи родительской таблицы еще не сгенерированы, поэтому несуществующее значение не найти
+		if slices.Contains(g.deferred[table.String()], fkName) {
+			continue
+		}
+		partial, err := tgen.danglingForeignKeyValues(fk, records[fk.ReferenceTable])
+		if err != nil {
+			warnings = append(warnings, xerrors.Errorf("table %q, foreign key %q: %w", table, fkName, err))
+			continue
+		}
+		add(Violation{Kind: ViolationForeignKey, Constraint: fk.Constraint.Name}, partial)
+	}
+
+	return res, warnings
+}
+
+type negativeValue struct {
+	violation Violation
+	value     string
+}
+
+// negativeColumnValues возвращает недопустимые значения колонки.
+func negativeColumnValues(col schema.Column) (res []negativeValue) {
+	attr := col.Attributes
+	if col.IsNotNull() {
+		res = append(res, negativeValue{
+			violation: Violation{Kind: ViolationNotNull, Column: col.Name},
+			value:     nullLiteral,
+		})
+	}
+	if attr.ArrayDims != 0 {
+		return res
+	}
+
+	typ := baseType(col.Type)
+	switch {
+	case typ.Type == schema.DataTypeEnum:
+		label := invalidEnumLabel
+		for slices.Contains(typ.EnumValues, label) {
+			label += "_"
+		}
+		res = append(res, negativeValue{
+			violation: Violation{Kind: ViolationEnum, Column: col.Name},
+			value:     fmt.Sprintf("%s::%s", quote(label), typ),
+		})
+	case typ.TypeName.Schema != pgCatalogSchema:
+	case attr.HasCharMaxLength && (typ.TypeName.Name == "varchar" || typ.TypeName.Name == "bpchar"):
+		// пробелы в конце строки обрезаются без ошибки, поэтому строка из нулей
+		res = append(res, negativeValue{
+			violation: Violation{Kind: ViolationLength, Column: col.Name},
+			value:     quote(strings.Repeat("0", attr.CharMaxLength+1)),
+		})
+	case typ.TypeName.Name == "numeric" && attr.NumericPrecision != 0:
+		res = append(res, negativeValue{
+			violation: Violation{Kind: ViolationNumericRange, Column: col.Name},
+			value:     numericOverflow(attr.NumericPrecision, attr.NumericScale),
+		})
+	default:
+		if limit, ok := intOverflow[typ.TypeName.Name]; ok {
+			res = append(res, negativeValue{
+				violation: Violation{Kind: ViolationNumericRange, Column: col.Name},
+				value:     limit,
+			})
+		}
+	}
+	return res
+}
+
+// значения целочисленных типов, на единицу превышающие максимальное.
+var intOverflow = map[string]string{
+	"int2": strconv.Itoa(math.MaxInt16 + 1),
+	"int4": strconv.Itoa(math.MaxInt32 + 1),
+	"int8": "9223372036854775808",
+}
+
+// numericOverflow возвращает наименьшее по модулю значение, не помещающееся в numeric(precision, scale).
+func numericOverflow(precision, scale int) string {
+	digits := precision - scale
+	if digits >= 0 {
+		return "1" + strings.Repeat("0", digits)
+	}
+	return "0." + strings.Repeat("0", -digits-1) + "1"
+}
+
+// baseType возвращает тип, на котором основан домен.
+func baseType(typ *schema.DBType) *schema.DBType {
+	for typ.Type == schema.DataTypeDomain && typ.ElemType != nil {
+		typ = typ.ElemType
+	}
+	return typ
+}
+
+// duplicateIndexValues возвращает значения колонок индекса из уже сгенерированной записи.
+// Подходит только запись, которая попала в индекс: без NULL значений в колонках индекса
+// без NULLS NOT DISTINCT и удовлетворяющая условию частичного индекса.
+// Для частичного индекса в запись добавляются и колонки условия, чтобы дубликат тоже попал в индекс.
+func duplicateIndexValues(index *uniqueIndex, records Records) (Record, bool) {
+	for _, record := range records.Records {
+		if record.Violates != nil {
+			continue
+		}
+		values := recordValues(record)
+		if _, ok := index.key(values); !ok {
+			continue
+		}
+		if index.where != nil {
+			if match, known := index.where.satisfied(values); !known || !match {
+				continue
+			}
+		}
+		var partial Record
+		for _, colName := range index.columns {
+			value, ok := values[colName]
+			if !ok || slices.Contains(partial.Columns, colName) {
+				continue
+			}
+			partial.Columns = append(partial.Columns, colName)
+			partial.Values = append(partial.Values, value)
+		}
+		return partial, true
+	}
+	return Record{}, false
+}
+
+// danglingForeignKeyValues возвращает значения колонок внешнего ключа, которых нет во внешней таблице.
+// Все колонки ключа заполняются, т.к. при NULL в любой из них внешний ключ не проверяется.
+func (g *tableGenerator) danglingForeignKeyValues(fk schema.ForeignKey, parent Records) (Record, error) {
+	cols := fk.Constraint.Columns
+	if len(cols) == 0 || len(cols) != len(fk.ReferenceColumns) {
+		return Record{}, xerrors.New("foreign key columns are not specified")
+	}
+
+	// значения первой колонки ключа, которые уже есть во внешней таблице
+	existing := make(map[string]bool)
+	for _, record := range parent.Records {
+		if idx := slices.Index(record.Columns, fk.ReferenceColumns[0]); idx >= 0 {
+			existing[record.Values[idx]] = true
+		}
+	}
+
+	var partial Record
+	for idx, colName := range cols {
+		domain, ok := g.domains.ColumnDomains[colName]
+		if !ok {
+			return Record{}, xerrors.Errorf("unable to find domain for column %q", colName)
+		}
+		// значения, ещё не использованные в колонке, не нарушат уникальные индексы раньше внешнего ключа
+		var value string
+		found, fresh := false, false
+		for domain.Reset(); !fresh && domain.Next(); {
+			if idx == 0 && existing[domain.Value()] {
+				continue
+			}
+			fresh = !g.colValues[colName].Contains(domain.Value())
+			if !found || fresh {
+				value, found = domain.Value(), true
+			}
+		}
+		if !found {
+			return Record{}, xerrors.Errorf("domain of column %q is exhausted", colName)
+		}
+		partial.Columns = append(partial.Columns, colName)
+		partial.Values = append(partial.Values, value)
+	}
+	return partial, nil
+}
+
+// violatingCheckValues подбирает значения колонок, при которых ограничение нарушено первым из ограничений таблицы.
+// Значения доменов перебираются без учета CHECK ограничений. Чтобы нарушить сравнение колонок между собой,
+// в значения колонки добавляются и значения других колонок ограничения того же типа.
+func (g *tableGenerator) violatingCheckValues(check *CheckConstraint) (Record, bool) {
+	candidates := make([][]string, len(check.Columns))
+	for idx, colName := range check.Columns {
+		col, ok := g.table.Columns[colName]
+		if !ok || col.Attributes.IsGeneratedAlways() {
+			return Record{}, false
+		}
+		candidates[idx] = g.checkCandidates(colName)
+	}
+	for idx, colName := range check.Columns {
+		col := g.table.Columns[colName]
+		for other, otherName := range check.Columns {
+			if other == idx || baseType(g.table.Columns[otherName].Type).String() != baseType(col.Type).String() {
+				continue
+			}
+			for _, value := range candidates[other] {
+				if !slices.Contains(candidates[idx], value) && fitsColumn(col, value) {
+					candidates[idx] = append(candidates[idx], value)
+				}
+			}
+		}
+		if len(candidates[idx]) == 0 {
+			return Record{}, false
+		}
+	}
+
+	// перебор всех сочетаний значений, последняя колонка меняется чаще всего
+	pos := make([]int, len(check.Columns))
+	values := make(map[string]string, len(check.Columns))
+	for steps := 0; steps < maxCheckSearchSteps; steps++ {
+		for idx, colName := range check.Columns {
+			values[colName] = candidates[idx][pos[idx]]
+		}
+		if failedCheck(g.checks, values) == check {
+			var partial Record
+			for idx, colName := range check.Columns {
+				partial.Columns = append(partial.Columns, colName)
+				partial.Values = append(partial.Values, candidates[idx][pos[idx]])
+			}
+			return partial, true
+		}
+
+		idx := len(pos) - 1
+		for ; idx >= 0; idx-- {
+			if pos[idx]++; pos[idx] < len(candidates[idx]) {
+				break
+			}
+			pos[idx] = 0
+		}
+		if idx < 0 {
+			break
+		}
+	}
+	return Record{}, false
+}
+
+// checkCandidates возвращает значения колонки для поиска нарушения CHECK ограничения:
+// допустимые граничные значения из ограничений и первые значения домена без учета ограничений.
+func (g *tableGenerator) checkCandidates(colName string) (res []string) {
+	domain, ok := g.domains.ColumnDomains[colName]
+	if !ok {
+		return nil
+	}
+	defer domain.Reset()
+	if checked, ok := domain.(*checkedDomain); ok {
+		res = append(res, checked.extra...)
+		domain = checked.base
+	}
+	limit := len(res) + maxCheckCandidates
+	for domain.Reset(); len(res) < limit && domain.Next(); {
+		if !slices.Contains(res, domain.Value()) {
+			res = append(res, domain.Value())
+		}
+	}
+	return res
+}
+
+// generateNegativeRecord дополняет частичную запись с недопустимыми значениями до полной записи.
+// Проверяемый уникальный индекс не учитывается, т.к. запись должна его нарушить.
+func (g *tableGenerator) generateNegativeRecord(partial Record, v Violation) (Record, error) {
+	if v.Kind == ViolationUnique {
+		uniqueIndexes := g.uniqueIndexes
+		g.uniqueIndexes = nil
+		for _, index := range uniqueIndexes {
+			if index.index.Name != v.Constraint {
+				g.uniqueIndexes = append(g.uniqueIndexes, index)
+			}
+		}
+		defer func() { g.uniqueIndexes = uniqueIndexes }()
+	}
+	if v.Kind == ViolationForeignKey {
+		// значения проверяемого ключа не должны браться из родительской таблицы
+		foreignKeys := g.foreignKeys
+		g.foreignKeys = nil
+		for _, fk := range foreignKeys {
+			if fk.fk.Constraint.Name != v.Constraint {
+				g.foreignKeys = append(g.foreignKeys, fk)
+			}
+		}
+		defer func() { g.foreignKeys = foreignKeys }()
+	}
+	switch v.Kind {
+	case ViolationNotNull, ViolationLength, ViolationNumericRange, ViolationEnum:
+		// такие значения отклоняются раньше проверки CHECK ограничений, поэтому ограничения колонки не учитываются
+		checks := g.checks
+		g.checks = nil
+		for _, check := range checks {
+			if !slices.Contains(check.Columns, v.Column) {
+				g.checks = append(g.checks, check)
+			}
+		}
+		defer func() { g.checks = checks }()
+	}
+
+	values, err := g.generateRecordValues(partial)
+	if err != nil {
+		return Record{}, err
+	}
+	record := g.recordFromMap(values)
+	record.Partial = &partial
+	record.Violates = &v
+	return record, nil
+}
+
+// Positive возвращает записи без негативных записей.
+func (p Records) Positive() Records {
+	res := Records{Records: make([]Record, 0, len(p.Records))}
+	for _, record := range p.Records {
+		if record.Violates == nil {
+			res.Records = append(res.Records, record)
+		}
+	}
+	return res
+}
@@ -0,0 +1,151 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func negativeTestSchema() *schema.Schema {
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	varchar := &schema.DBType{
+		TypeName: schema.Identifier{OID: 1043, Schema: "pg_catalog", Name: "varchar"},
+		Type:     schema.DataTypeBase,
+	}
+	numeric := &schema.DBType{
+		TypeName: schema.Identifier{OID: 1700, Schema: "pg_catalog", Name: "numeric"},
+		Type:     schema.DataTypeBase,
+	}
+	status := &schema.DBType{
+		TypeName:   schema.Identifier{OID: 100, Schema: "test", Name: "status"},
+		Type:       schema.DataTypeEnum,
+		EnumValues: []string{"active", "mtest_invalid"},
+	}
+	notNull := schema.ColumnAttributes{DomainAttributes: schema.DomainAttributes{NotNullable: true}}
+
+	usersPK := schema.Index{Name: "users_pkey", Columns: []string{"id"}, IsUnique: true, IsPrimary: true}
+	usersName := schema.Index{Name: "users_name_key", Columns: []string{"name"}, IsUnique: true}
+	users := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "users"},
+		Columns: map[string]schema.Column{
+			"id": {ColNum: 1, Name: "id", Type: int4, Attributes: notNull},
+			"name": {ColNum: 2, Name: "name", Type: varchar, Attributes: schema.ColumnAttributes{
+				DomainAttributes: schema.DomainAttributes{HasCharMaxLength: true, CharMaxLength: 10},
+			}},
+			"status": {ColNum: 3, Name: "status", Type: status},
+			"amount": {ColNum: 4, Name: "amount", Type: numeric, Attributes: schema.ColumnAttributes{
+				DomainAttributes: schema.DomainAttributes{IsNumeric: true, NumericPrecision: 5, NumericScale: 2},
+			}},
+		},
+		Indexes: map[string]schema.Index{"users_pkey": usersPK, "users_name_key": usersName},
+	}
+
+	ordersPK := schema.Index{Name: "orders_pkey", Columns: []string{"id"}, IsUnique: true, IsPrimary: true}
+	fk := &schema.Constraint{Name: "orders_user_id_fkey", Type: schema.ConstraintTypeFK, Columns: []string{"user_id"}}
+	orders := schema.Table{
+		Name: schema.Identifier{OID: 2, Schema: "test", Name: "orders"},
+		Columns: map[string]schema.Column{
+			"id":      {ColNum: 1, Name: "id", Type: int4, Attributes: notNull},
+			"user_id": {ColNum: 2, Name: "user_id", Type: int4},
+		},
+		ForeignKeys: map[string]schema.ForeignKey{
+			fk.Name: {Constraint: fk, ReferenceTable: "test.users", ReferenceColumns: []string{"id"}},
+		},
+		Indexes: map[string]schema.Index{"orders_pkey": ordersPK},
+	}
+	users.ReferencedBy = map[string]*schema.Constraint{"test.orders": fk}
+
+	return &schema.Schema{
+		Types: map[string]*schema.DBType{
+			int4.String(): int4, varchar.String(): varchar, numeric.String(): numeric, status.String(): status,
+		},
+		Tables: map[string]schema.Table{users.String(): users, orders.String(): orders},
+	}
+}
+
+func TestGenerateNegativeRecords(t *testing.T) {
+	r := require.New(t)
+	s := negativeTestSchema()
+
+	gen, err := New(zap.NewNop(), s, Config{Negative: true})
+	r.NoError(err)
+	res, warnings := gen.GenerateRecords(PartialRecords{}, nil)
+	r.Empty(warnings)
+
+	value := func(record Record, col string) string {
+		idx := slices.Index(record.Columns, col)
+		r.GreaterOrEqual(idx, 0, "column %q not found in record %s", col, record)
+		return record.Values[idx]
+	}
+	users := res["test.users"]
+	r.NotEmpty(users.Positive().Records)
+	userNegatives := make(map[Violation]Record)
+	for idx, record := range users.Records {
+		if record.Violates == nil {
+			r.Less(idx, len(users.Positive().Records), "negative records must follow positive ones")
+			continue
+		}
+		userNegatives[*record.Violates] = record
+	}
+	r.Len(userNegatives, 7)
+
+	r.Equal("NULL", value(userNegatives[Violation{Kind: ViolationNotNull, Column: "id"}], "id"))
+	r.Equal("'00000000000'", value(userNegatives[Violation{Kind: ViolationLength, Column: "name"}], "name"))
+	// mtest_invalid - допустимая метка, поэтому выбирается другая
+	r.Equal("'mtest_invalid_'::test.status", value(userNegatives[Violation{Kind: ViolationEnum, Column: "status"}], "status"))
+	r.Equal("1000", value(userNegatives[Violation{Kind: ViolationNumericRange, Column: "amount"}], "amount"))
+	r.Equal("2147483648", value(userNegatives[Violation{Kind: ViolationNumericRange, Column: "id"}], "id"))
+
+	firstUser := users.Records[0]
+	pkDup := userNegatives[Violation{Kind: ViolationUnique, Constraint: "users_pkey"}]
+	r.Equal(value(firstUser, "id"), value(pkDup, "id"))
+	nameDup := userNegatives[Violation{Kind: ViolationUnique, Constraint: "users_name_key"}]
+	r.NotEqual(value(firstUser, "id"), value(nameDup, "id"), "only the checked index must be violated")
+	r.NotEqual("NULL", value(nameDup, "name"))
+
+	userIDs := make(map[string]bool)
+	for _, record := range users.Positive().Records {
+		userIDs[value(record, "id")] = true
+	}
+	var dangling *Record
+	for _, record := range res["test.orders"].Records {
+		if record.Violates != nil && record.Violates.Kind == ViolationForeignKey {
+			record := record
+			dangling = &record
+		}
+	}
+	r.NotNil(dangling)
+	r.Equal("orders_user_id_fkey", dangling.Violates.Constraint)
+	r.NotEqual("NULL", value(*dangling, "user_id"))
+	r.False(userIDs[value(*dangling, "user_id")], "foreign key value must not exist in referenced table")
+
+	gen, err = New(zap.NewNop(), s, Config{})
+	r.NoError(err)
+	res, _ = gen.GenerateRecords(PartialRecords{}, nil)
+	for _, records := range res {
+		r.Equal(records, records.Positive())
+	}
+}
+
+func TestNumericOverflow(t *testing.T) {
+	tests := []struct {
+		precision, scale int
+		want             string
+	}{
+		{5, 2, "1000"},
+		{3, 0, "1000"},
+		{3, 3, "1"},
+		{3, 5, "0.01"},
+		{2, -2, "10000"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, numericOverflow(tt.precision, tt.scale))
+	}
+}
@@ -0,0 +1,265 @@
+package generate
+
+import (
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+// tablePartitions описывает партиции таблицы в виде ограничений на значения ключа партиционирования.
+type tablePartitions struct {
+	// колонки ключа партиционирования
+	columns []string
+	// ограничения партиций, кроме DEFAULT, в порядке имен партиций
+	bounds     []*CheckConstraint
+	hasDefault bool
+}
+
+// parseTablePartitions разбирает границы партиций таблицы.
+// Для партиционирования по хешу возвращается nil: распределение значений по партициям
+// зависит от хеш-функций PostgreSQL, а при полном наборе остатков любое значение попадает в партицию.
+func parseTablePartitions(table schema.Table) (*tablePartitions, error) {
+	p := table.Partitioning
+	if p == nil || p.Strategy == schema.PartitionStrategyHash {
+		return nil, nil
+	}
+	if slices.Contains(p.Columns, "") {
+		return nil, xerrors.Errorf("partition key %q contains expressions", p.Definition)
+	}
+
+	res := &tablePartitions{columns: p.Columns, hasDefault: p.HasDefault()}
+	for _, partition := range p.Partitions {
+		if partition.IsDefault {
+			continue
+		}
+		expr, err := parsePartitionBound(p, partition.Bound)
+		if err != nil {
+			return nil, xerrors.Errorf("partition %q: %w", partition, err)
+		}
+		res.bounds = append(res.bounds, &CheckConstraint{
+			Name:      partition.String(),
+			Columns:   p.Columns,
+			expr:      expr,
+			partition: true,
+		})
+	}
+	return res, nil
+}
+
+// routingCheck возвращает ограничение, которое выполняется для значений ключа,
+// попадающих хотя бы в одну партицию. Если есть DEFAULT партиция, то ограничение не нужно.
+func (p *tablePartitions) routingCheck(table schema.Table) *CheckConstraint {
+	if p.hasDefault {
+		return nil
+	}
+	var expr checkExpr = checkConst{value: checkBool(false)}
+	for idx, bound := range p.bounds {
+		if idx == 0 {
+			expr = bound.expr
+			continue
+		}
+		expr = checkLogic{left: expr, right: bound.expr}
+	}
+	return &CheckConstraint{
+		Name:      "partitions of " + table.String(),
+		Columns:   p.columns,
+		expr:      expr,
+		partition: true,
+	}
+}
+
+// values возвращает значения колонки на границах партиций: значения внутри каждой партиции,
+// а если есть DEFAULT партиция, то и значения, которые не попадают ни в одну другую партицию.
+func (p *tablePartitions) values(col schema.Column) (res []string) {
+	if !slices.Contains(p.columns, col.Name) {
+		return nil
+	}
+	seen := make(map[string]bool)
+	add := func(value string) {
+		if !seen[value] {
+			seen[value] = true
+			res = append(res, value)
+		}
+	}
+	for _, bound := range p.bounds {
+		valid, invalid := columnCheckValues(col, []*CheckConstraint{bound})
+		for _, value := range valid {
+			add(value)
+		}
+		if !p.hasDefault {
+			continue
+		}
+		for _, neg := range invalid {
+			if !slices.ContainsFunc(p.bounds, func(bound *CheckConstraint) bool {
+				return bound.Check(map[string]string{col.Name: neg.value})
+			}) {
+				add(neg.value)
+			}
+		}
+	}
+	return res
+}
+
+// parsePartitionBound переводит границы партиции в выражение над колонками ключа.
+// Выражение никогда не равно NULL, поэтому его можно проверять как CHECK ограничение.
+// Поддерживаются FOR VALUES FROM (...) TO (...) и FOR VALUES IN (...).
+func parsePartitionBound(p *schema.Partitioning, bound string) (checkExpr, error) {
+	toks, err := tokenizeCheck(bound)
+	if err != nil {
+		return nil, err
+	}
+	parser := &checkParser{toks: toks}
+	if !parser.acceptKeyword("for") || !parser.acceptKeyword("values") {
+		return nil, parser.unexpected("FOR VALUES")
+	}
+
+	var expr checkExpr
+	switch {
+	case parser.acceptKeyword("from"):
+		lower, err := parsePartitionBoundList(parser, len(p.Columns))
+		if err != nil {
+			return nil, xerrors.Errorf("lower bound: %w", err)
+		}
+		if !parser.acceptKeyword("to") {
+			return nil, parser.unexpected("TO")
+		}
+		upper, err := parsePartitionBoundList(parser, len(p.Columns))
+		if err != nil {
+			return nil, xerrors.Errorf("upper bound: %w", err)
+		}
+		expr, err = rangePartitionExpr(p.Columns, lower, upper)
+		if err != nil {
+			return nil, err
+		}
+	case parser.acceptKeyword("in"):
+		items, err := parsePartitionBoundList(parser, -1)
+		if err != nil {
+			return nil, err
+		}
+		if len(p.Columns) != 1 {
+			return nil, xerrors.Errorf("list partition key must have one column, got %d", len(p.Columns))
+		}
+		expr = listPartitionExpr(p.Columns[0], items)
+	default:
+		return nil, parser.unexpected("FROM or IN")
+	}
+
+	if tok := parser.peek(); tok.kind != checkTokenEOF {
+		return nil, parser.unexpected("end of partition bound")
+	}
+	return expr, nil
+}
+
+// parsePartitionBoundList разбирает список значений в скобках. Если n >= 0, то значений должно быть n.
+func parsePartitionBoundList(parser *checkParser, n int) ([]checkExpr, error) {
+	if err := parser.expectOp("("); err != nil {
+		return nil, err
+	}
+	items, err := parser.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+	if n >= 0 && len(items) != n {
+		return nil, xerrors.Errorf("expected %d values, got %d", n, len(items))
+	}
+	return items, nil
+}
+
+// isBoundKeyword проверяет, что значение границы - MINVALUE или MAXVALUE.
+func isBoundKeyword(e checkExpr, keyword string) bool {
+	col, ok := e.(checkColumn)
+	return ok && col.name == keyword
+}
+
+// rangePartitionExpr возвращает условие попадания ключа в диапазон [lower, upper).
+// Ключи из нескольких колонок сравниваются лексикографически, как это делает PostgreSQL.
+// NULL в колонках ключа попадает только в DEFAULT партицию.
+func rangePartitionExpr(columns []string, lower, upper []checkExpr) (checkExpr, error) {
+	for idx := range columns {
+		if isBoundKeyword(lower[idx], "maxvalue") || isBoundKeyword(upper[idx], "minvalue") {
+			return nil, xerrors.New("empty partition range is not supported")
+		}
+	}
+
+	var expr checkExpr
+	for _, colName := range columns {
+		expr = andExpr(expr, checkIsNull{arg: checkColumn{name: colName}, not: true})
+	}
+	expr = andExpr(expr, rangeBoundExpr(columns, lower, "minvalue", ">"))
+	expr = andExpr(expr, rangeBoundExpr(columns, upper, "maxvalue", "<"))
+	return expr, nil
+}
+
+// rangeBoundExpr возвращает лексикографическое сравнение ключа с границей.
+// Для нижней границы op = ">", для верхней op = "<". Нижняя граница включается в диапазон, верхняя - нет.
+// Возвращает nil, если граница не ограничивает значения (MINVALUE или MAXVALUE).
+func rangeBoundExpr(columns []string, bound []checkExpr, unbounded, op string) checkExpr {
+	if len(columns) == 0 || isBoundKeyword(bound[0], unbounded) {
+		return nil
+	}
+	col := checkColumn{name: columns[0]}
+	rest := rangeBoundExpr(columns[1:], bound[1:], unbounded, op)
+	if rest == nil {
+		if op == ">" || len(columns) > 1 {
+			// остальные колонки не ограничены или значение равно нижней границе
+			return checkCompare{op: op + "=", left: col, right: bound[0]}
+		}
+		return checkCompare{op: op, left: col, right: bound[0]}
+	}
+	return checkLogic{
+		left: checkCompare{op: op, left: col, right: bound[0]},
+		right: checkLogic{
+			and:   true,
+			left:  checkCompare{op: "=", left: col, right: bound[0]},
+			right: rest,
+		},
+	}
+}
+
+// listPartitionExpr возвращает условие попадания значения колонки в список.
+// NULL попадает в партицию, только если он есть в списке.
+func listPartitionExpr(colName string, items []checkExpr) checkExpr {
+	col := checkColumn{name: colName}
+	var (
+		values  []checkExpr
+		hasNull bool
+	)
+	for _, item := range items {
+		if c, ok := item.(checkConst); ok && c.value.null {
+			hasNull = true
+			continue
+		}
+		values = append(values, item)
+	}
+
+	var expr checkExpr
+	if len(values) != 0 {
+		expr = andExpr(
+			checkIsNull{arg: col, not: true},
+			checkAny{op: "=", left: col, items: values},
+		)
+	}
+	if hasNull {
+		isNull := checkIsNull{arg: col}
+		if expr == nil {
+			return isNull
+		}
+		return checkLogic{left: expr, right: isNull}
+	}
+	if expr == nil {
+		return checkConst{value: checkBool(false)}
+	}
+	return expr
+}
+
+// andExpr объединяет выражения через AND, пропуская nil.
+func andExpr(left, right checkExpr) checkExpr {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	}
+	return checkLogic{and: true, left: left, right: right}
+}
@@ -0,0 +1,226 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestParsePartitionBound(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		bound   string
+		pass    []map[string]string
+		fail    []map[string]string
+	}{
+		{
+			name:    "range",
+			columns: []string{"id"},
+			bound:   "FOR VALUES FROM (1) TO (10)",
+			pass:    []map[string]string{{"id": "1"}, {"id": "9"}},
+			fail:    []map[string]string{{"id": "0"}, {"id": "10"}, {"id": "NULL"}},
+		},
+		{
+			name:    "range of several columns",
+			columns: []string{"a", "b"},
+			bound:   "FOR VALUES FROM (1, MINVALUE) TO (2, 5)",
+			pass: []map[string]string{
+				{"a": "1", "b": "-100"},
+				{"a": "1", "b": "100"},
+				{"a": "2", "b": "4"},
+			},
+			fail: []map[string]string{
+				{"a": "0", "b": "0"},
+				{"a": "2", "b": "5"},
+				{"a": "3", "b": "0"},
+				{"a": "1", "b": "NULL"},
+			},
+		},
+		{
+			name:    "range to maxvalue",
+			columns: []string{"created"},
+			bound:   "FOR VALUES FROM ('2023-01-01') TO (MAXVALUE)",
+			pass:    []map[string]string{{"created": "'2023-01-01'"}, {"created": "'2030-01-01'"}},
+			fail:    []map[string]string{{"created": "'2022-12-31'"}},
+		},
+		{
+			name:    "list",
+			columns: []string{"status"},
+			bound:   "FOR VALUES IN ('new', 'done')",
+			pass:    []map[string]string{{"status": "'new'"}, {"status": "'done'"}},
+			fail:    []map[string]string{{"status": "'old'"}, {"status": "NULL"}},
+		},
+		{
+			name:    "list with null",
+			columns: []string{"status"},
+			bound:   "FOR VALUES IN (NULL, 'new')",
+			pass:    []map[string]string{{"status": "NULL"}, {"status": "'new'"}},
+			fail:    []map[string]string{{"status": "'old'"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			p := &schema.Partitioning{Columns: tt.columns}
+			expr, err := parsePartitionBound(p, tt.bound)
+			r.NoError(err)
+			check := &CheckConstraint{Name: "p", Columns: tt.columns, expr: expr}
+			for _, values := range tt.pass {
+				r.True(check.Check(values), "%v", values)
+			}
+			for _, values := range tt.fail {
+				r.False(check.Check(values), "%v", values)
+			}
+		})
+	}
+}
+
+func TestParsePartitionBoundErrors(t *testing.T) {
+	p := &schema.Partitioning{Columns: []string{"id"}}
+	for _, bound := range []string{
+		"FOR VALUES WITH (modulus 2, remainder 0)",
+		"FOR VALUES FROM (1, 2) TO (3, 4)",
+		"FOR VALUES FROM (MAXVALUE) TO (10)",
+		"FOR VALUES IN ('a') garbage",
+		"DEFAULT",
+	} {
+		_, err := parsePartitionBound(p, bound)
+		require.Error(t, err, bound)
+	}
+}
+
+func partitionTestSchema(partitions ...schema.Partition) *schema.Schema {
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	events := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "events"},
+		Columns: map[string]schema.Column{
+			"id": {ColNum: 1, Name: "id", Type: int4, Attributes: schema.ColumnAttributes{
+				DomainAttributes: schema.DomainAttributes{NotNullable: true},
+			}},
+		},
+		Partitioning: &schema.Partitioning{
+			Strategy:   schema.PartitionStrategyRange,
+			Columns:    []string{"id"},
+			Definition: "RANGE (id)",
+			Partitions: partitions,
+		},
+	}
+	return &schema.Schema{
+		Types:  map[string]*schema.DBType{int4.String(): int4},
+		Tables: map[string]schema.Table{events.String(): events},
+	}
+}
+
+func TestGenerateRecordsPartitions(t *testing.T) {
+	r := require.New(t)
+	first := schema.Partition{
+		Name:  schema.Identifier{Schema: "test", Name: "events_1"},
+		Bound: "FOR VALUES FROM (1) TO (10)",
+	}
+	second := schema.Partition{
+		Name:  schema.Identifier{Schema: "test", Name: "events_2"},
+		Bound: "FOR VALUES FROM (10) TO (20)",
+	}
+	s := partitionTestSchema(first, second)
+
+	gen, err := New(zap.NewNop(), s, Config{Negative: true})
+	r.NoError(err)
+	res, warnings := gen.GenerateRecords(PartialRecords{}, nil)
+	r.Empty(warnings)
+
+	parts := gen.partitions["test.events"]
+	r.NotNil(parts)
+	r.Len(parts.bounds, 2)
+
+	ids := make(map[string]bool)
+	var negatives []Violation
+	for _, record := range res["test.events"].Records {
+		values := recordValues(record)
+		if record.Violates != nil {
+			negatives = append(negatives, *record.Violates)
+			continue
+		}
+		r.Nil(failedCheck(gen.checks["test.events"], values), "record %s must land in a partition", record)
+		ids[values["id"]] = true
+	}
+	// значения на границах каждой партиции
+	for _, id := range []string{"1", "9", "10", "19"} {
+		r.True(ids[id], "id %s", id)
+	}
+	r.Contains(negatives, Violation{Kind: ViolationPartition, Column: "id"})
+
+	// с DEFAULT партицией допустимы значения вне диапазонов других партиций
+	s = partitionTestSchema(first, second, schema.Partition{
+		Name:      schema.Identifier{Schema: "test", Name: "events_default"},
+		Bound:     "DEFAULT",
+		IsDefault: true,
+	})
+	gen, err = New(zap.NewNop(), s, Config{})
+	r.NoError(err)
+	res, warnings = gen.GenerateRecords(PartialRecords{}, nil)
+	r.Empty(warnings)
+	r.Empty(gen.checks["test.events"])
+
+	ids = make(map[string]bool)
+	for _, record := range res["test.events"].Records {
+		ids[recordValues(record)["id"]] = true
+	}
+	for _, id := range []string{"0", "1", "10", "20"} {
+		r.True(ids[id], "id %s", id)
+	}
+}
+
+func TestGenerateRecordsListPartitionNegative(t *testing.T) {
+	r := require.New(t)
+	text := &schema.DBType{
+		TypeName: schema.Identifier{OID: 25, Schema: "pg_catalog", Name: "text"},
+		Type:     schema.DataTypeBase,
+	}
+	events := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "events"},
+		Columns: map[string]schema.Column{
+			"kind": {ColNum: 1, Name: "kind", Type: text},
+		},
+		Partitioning: &schema.Partitioning{
+			Strategy:   schema.PartitionStrategyList,
+			Columns:    []string{"kind"},
+			Definition: "LIST (kind)",
+			Partitions: []schema.Partition{{
+				Name:  schema.Identifier{Schema: "test", Name: "events_ab"},
+				Bound: "FOR VALUES IN ('a', 'b')",
+			}},
+		},
+	}
+	s := &schema.Schema{
+		Types:  map[string]*schema.DBType{text.String(): text},
+		Tables: map[string]schema.Table{events.String(): events},
+	}
+
+	gen, err := New(zap.NewNop(), s, Config{Negative: true})
+	r.NoError(err)
+	res, warnings := gen.GenerateRecords(PartialRecords{}, nil)
+	r.Empty(warnings)
+
+	// у строкового ключа нет значений по соседству с границами, недопустимое значение берется из домена
+	var negative *Record
+	for _, record := range res["test.events"].Records {
+		values := recordValues(record)
+		if record.Violates == nil {
+			r.Contains([]string{"'a'", "'b'"}, values["kind"])
+			continue
+		}
+		record := record
+		negative = &record
+	}
+	r.NotNil(negative)
+	r.Equal(Violation{Kind: ViolationPartition, Column: "kind"}, *negative.Violates)
+	r.NotContains([]string{"'a'", "'b'", "NULL"}, recordValues(*negative)["kind"])
+}
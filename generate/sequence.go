@@ -0,0 +1,36 @@
+package generate
+
+import (
+	"strconv"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+// sequenceChecks возвращает ограничения на значения колонок, которые заполняются из последовательностей.
+// После вставки последовательность сдвигается за вставленные значения, поэтому значение должно
+// оставлять последовательности следующее значение: для возрастающей последовательности оно меньше MAXVALUE,
+// для убывающей - больше MINVALUE. Значения с другой стороны на последовательность не влияют.
+func sequenceChecks(table schema.Table) []*CheckConstraint {
+	var res []*CheckConstraint
+	for _, col := range sortedColumns(table) {
+		seq := col.Attributes.Sequence
+		if seq == nil || col.Attributes.IsGeneratedAlways() {
+			continue
+		}
+		op, bound := "<", seq.MaxValue
+		if seq.Increment < 0 {
+			op, bound = ">", seq.MinValue
+		}
+		res = append(res, &CheckConstraint{
+			Name:    "sequence " + seq.String(),
+			Columns: []string{col.Name},
+			expr: checkCompare{
+				op:    op,
+				left:  checkColumn{name: col.Name},
+				right: checkConst{value: checkValue{value: strconv.FormatInt(bound, 10)}},
+			},
+			sequence: true,
+		})
+	}
+	return res
+}
@@ -0,0 +1,83 @@
+package generate
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestGenerateRecordsSequences(t *testing.T) {
+	r := require.New(t)
+
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	notNull := schema.DomainAttributes{NotNullable: true}
+	sequence := func(name string, increment, minValue, maxValue int64) *schema.Sequence {
+		return &schema.Sequence{
+			Name:      schema.Identifier{Schema: "test", Name: name},
+			Increment: increment,
+			MinValue:  minValue,
+			MaxValue:  maxValue,
+		}
+	}
+	items := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "items"},
+		Columns: map[string]schema.Column{
+			"id": {ColNum: 1, Name: "id", Type: int4, Attributes: schema.ColumnAttributes{
+				DomainAttributes: notNull,
+				Identity:         schema.IdentityAlways,
+				Sequence:         sequence("items_id_seq", 1, 1, math.MaxInt32),
+			}},
+			"num": {ColNum: 2, Name: "num", Type: int4, Attributes: schema.ColumnAttributes{
+				DomainAttributes: notNull,
+				HasDefault:       true,
+				Default:          "nextval('test.items_num_seq'::regclass)",
+				Sequence:         sequence("items_num_seq", 1, 1, 100),
+			}},
+			"down": {ColNum: 3, Name: "down", Type: int4, Attributes: schema.ColumnAttributes{
+				DomainAttributes: notNull,
+				Identity:         schema.IdentityByDefault,
+				Sequence:         sequence("items_down_seq", -1, -50, -1),
+			}},
+		},
+	}
+	s := &schema.Schema{
+		Types:  map[string]*schema.DBType{int4.String(): int4},
+		Tables: map[string]schema.Table{items.String(): items},
+	}
+
+	gen, err := New(zap.NewNop(), s, Config{Negative: true})
+	r.NoError(err)
+	res, warnings := gen.GenerateRecords(PartialRecords{}, nil)
+	r.Empty(warnings)
+
+	records := res[items.String()]
+	r.NotEmpty(records.Positive().Records)
+	nums := make(map[int64]bool)
+	for _, record := range records.Records {
+		r.False(slices.Contains(record.Columns, "id"), "identity column must be filled by database: %s", record)
+		if record.Violates != nil {
+			r.NotEqual(ViolationCheck, record.Violates.Kind, "sequence range must not produce negative records")
+			continue
+		}
+		values := recordValues(record)
+		num, err := strconv.ParseInt(values["num"], 10, 64)
+		r.NoError(err)
+		r.Less(num, int64(100))
+		nums[num] = true
+		down, err := strconv.ParseInt(values["down"], 10, 64)
+		r.NoError(err)
+		r.Greater(down, int64(-50))
+	}
+	r.True(nums[99], "value before sequence maximum must be generated")
+	// значения с другой стороны последовательности допустимы
+	r.True(nums[-1])
+}
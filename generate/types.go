@@ -2,6 +2,7 @@ package generate
 
 import (
 	"sort"
+	"strings"
 
 	"golang.org/x/exp/constraints"
 )
@@ -14,10 +15,26 @@ type Record struct {
 	Columns []string
 	// Значения колонок
 	Values []string
+	// Частичная запись, из которой была сгенерирована полная запись (может быть nil)
+	Partial *Record
+	// Ограничение, которое нарушает негативная запись. nil для обычных записей.
+	Violates *Violation
+	// Значения колонок внешних ключей, которые устанавливаются после вставки записей всех таблиц.
+	// Такие ключи разрывают циклы между таблицами и вставляются как NULL. nil, если таких ключей нет.
+	Deferred *Record
 	// // если это частичная запись, может ли она вливаться в другие записи
 	// CanBeMerged bool
 }
 
+// String возвращает запись в виде "col1=value1, col2=value2".
+func (p Record) String() string {
+	pairs := make([]string, 0, len(p.Columns))
+	for idx, col := range p.Columns {
+		pairs = append(pairs, col+"="+p.Values[idx])
+	}
+	return strings.Join(pairs, ", ")
+}
+
 var _ sort.Interface = (*Record)(nil)
 
 func (p Record) Len() int           { return len(p.Columns) }
@@ -44,11 +61,29 @@ func (p *Records) MergeAdd(r Record) {
 	*record = p.merge(*record, r)
 }
 
+// merge объединяет две записи с отсортированными колонками, сохраняя соответствие колонок и значений.
 func (p *Records) merge(out, curr Record) Record {
-	return Record{
-		Columns: mergeTwoSortedArrays(out.Columns, curr.Columns),
-		Values:  mergeTwoSortedArrays(out.Values, curr.Values),
+	res := Record{
+		Columns: make([]string, 0, len(out.Columns)+len(curr.Columns)),
+		Values:  make([]string, 0, len(out.Values)+len(curr.Values)),
+	}
+	i, j := 0, 0
+	for i < len(out.Columns) && j < len(curr.Columns) {
+		if out.Columns[i] < curr.Columns[j] {
+			res.Columns = append(res.Columns, out.Columns[i])
+			res.Values = append(res.Values, out.Values[i])
+			i++
+		} else {
+			res.Columns = append(res.Columns, curr.Columns[j])
+			res.Values = append(res.Values, curr.Values[j])
+			j++
+		}
 	}
+	res.Columns = append(res.Columns, out.Columns[i:]...)
+	res.Values = append(res.Values, out.Values[i:]...)
+	res.Columns = append(res.Columns, curr.Columns[j:]...)
+	res.Values = append(res.Values, curr.Values[j:]...)
+	return res
 }
 
 func (p *Records) searchNoOverlapRecord(cols []string) *Record {
@@ -75,3 +75,15 @@ func TestMergeTwoSortedStringArrays(t *testing.T) {
 		})
 	}
 }
+
+func TestRecordsMergeAdd(t *testing.T) {
+	var records Records
+	records.MergeAdd(Record{Columns: []string{"b"}, Values: []string{"1"}})
+	records.MergeAdd(Record{Columns: []string{"a"}, Values: []string{"2"}})
+	records.MergeAdd(Record{Columns: []string{"c", "a"}, Values: []string{"3", "4"}})
+
+	assert.Equal(t, []Record{
+		{Columns: []string{"a", "b"}, Values: []string{"2", "1"}},
+		{Columns: []string{"a", "c"}, Values: []string{"4", "3"}},
+	}, records.Records)
+}
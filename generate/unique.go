@@ -0,0 +1,232 @@
+package generate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+// максимальное количество значений доменов, которые перебираются для одной записи.
+// Ограничивает перебор бесконечных доменов и доменов, значения которых почти исчерпаны.
+const maxRecordSearchSteps = 100 * defaultTopDomainIterations
+
+// uniqueIndex описывает уникальный индекс или первичный ключ таблицы
+// и составные значения его колонок в уже сгенерированных записях.
+type uniqueIndex struct {
+	index schema.Index
+	// условие частичного индекса. nil, если индекс не частичный
+	where *CheckConstraint
+	// колонки, от которых зависит попадание записи в индекс
+	columns []string
+	values  mapset.Set[string]
+}
+
+func (u *uniqueIndex) String() string { return u.index.Name }
+
+// newUniqueIndexes возвращает уникальные индексы таблицы в порядке их имен.
+// Индексы только на выражениях не проверяются, т.к. значения выражений неизвестны.
+// Индексы на колонках и выражениях проверяются только по колонкам, что строже, чем в базе данных.
+func newUniqueIndexes(log *zap.Logger, table schema.Table) []*uniqueIndex {
+	var res []*uniqueIndex
+	for _, index := range table.Indexes {
+		if !index.IsUnique {
+			continue
+		}
+		// по колонкам без выражений уникальность проверить нельзя, например UNIQUE (a, lower(b))
+		if index.HasExpressions || len(index.Columns) == 0 {
+			log.Warn("unique index on expressions is not checked", zap.String("index", index.Name))
+			continue
+		}
+		u := &uniqueIndex{
+			index:   index,
+			columns: index.Columns,
+			values:  mapset.NewThreadUnsafeSet[string](),
+		}
+		where, err := parseIndexPredicate(index)
+		if err != nil {
+			log.Warn("predicate of partial unique index is not supported, index is checked for all records",
+				zap.String("index", index.Name), zap.Error(err))
+		}
+		if where != nil {
+			u.where = where
+			u.columns = append(append([]string(nil), index.Columns...), where.Columns...)
+		}
+		res = append(res, u)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].index.Name < res[j].index.Name })
+	return res
+}
+
+// parseIndexPredicate разбирает условие WHERE частичного индекса из его определения.
+func parseIndexPredicate(index schema.Index) (*CheckConstraint, error) {
+	const where = " WHERE "
+	pos := strings.Index(strings.ToUpper(index.Definition), where)
+	if pos < 0 {
+		return nil, nil
+	}
+	return ParseCheck(&schema.Constraint{Name: index.Name, Definition: index.Definition[pos+len(where):]})
+}
+
+// key возвращает составное значение колонок индекса.
+// Возвращает false, если запись не может нарушить индекс:
+// не все колонки заполнены, одна из колонок NULL без NULLS NOT DISTINCT
+// или запись не удовлетворяет условию частичного индекса.
+// Если условие частичного индекса нельзя вычислить, то запись считается попадающей в индекс.
+func (u *uniqueIndex) key(record map[string]string) (string, bool) {
+	fields := make([]string, 0, len(u.index.Columns))
+	for _, colName := range u.index.Columns {
+		value, ok := record[colName]
+		if !ok {
+			return "", false
+		}
+		if value == nullLiteral && !u.index.IsNullsNotDistinct {
+			return "", false
+		}
+		fields = append(fields, strconv.Quote(value))
+	}
+	if u.where != nil {
+		if match, known := u.where.satisfied(record); known && !match {
+			return "", false
+		}
+	}
+	return strings.Join(fields, ","), true
+}
+
+// violatedUniqueIndex возвращает первый уникальный индекс, в котором уже есть значения записи.
+func (g *tableGenerator) violatedUniqueIndex(record map[string]string) *uniqueIndex {
+	for _, index := range g.uniqueIndexes {
+		if key, ok := index.key(record); ok && index.values.Contains(key) {
+			return index
+		}
+	}
+	return nil
+}
+
+// addUniqueValues запоминает значения записи во всех уникальных индексах.
+func (g *tableGenerator) addUniqueValues(record map[string]string) {
+	for _, index := range g.uniqueIndexes {
+		if key, ok := index.key(record); ok {
+			index.values.Add(key)
+		}
+	}
+}
+
+// fillColumns заполняет колонки значениями их доменов так,
+// чтобы запись не нарушала уникальные индексы и CHECK ограничения.
+// Если значения колонки исчерпаны, то перебор возвращается к последней заполненной колонке,
+// из-за значения которой отбрасывались значения, и продолжает перебор её домена.
+func (g *tableGenerator) fillColumns(
+	columns []schema.Column,
+	record map[string]string,
+	checks []*CheckConstraint,
+) error {
+	domains := make([]Domain, len(columns))
+	for idx, col := range columns {
+		domain, ok := g.domains.ColumnDomains[col.Name]
+		if !ok {
+			return xerrors.Errorf(
+				"internal error: unable to find column domain for column %q for table %q",
+				col.Name, g.table)
+		}
+		domains[idx] = domain
+	}
+
+	// колонки, из-за значений которых отбрасывались значения каждой из колонок
+	conflicts := make([]map[string]bool, len(columns))
+	// последние индекс и ограничение, отбросившие значение, для сообщения об ошибке
+	var (
+		rejectedIndex *uniqueIndex
+		rejectedCheck *CheckConstraint
+	)
+	steps := 0
+	for idx, fresh := 0, true; idx < len(columns); {
+		col, domain := columns[idx], domains[idx]
+		if fresh {
+			domain.Reset()
+			conflicts[idx] = make(map[string]bool)
+		}
+
+		found := false
+		for steps < maxRecordSearchSteps && domain.Next() {
+			steps++
+			record[col.Name] = domain.Value()
+			if check := failedCheck(checks, record); check != nil {
+				rejectedCheck = check
+				addConflicts(conflicts[idx], check.Columns)
+				continue
+			}
+			if index := g.violatedUniqueIndex(record); index != nil {
+				rejectedIndex = index
+				addConflicts(conflicts[idx], index.columns)
+				continue
+			}
+			found = true
+			break
+		}
+		if found {
+			idx, fresh = idx+1, true
+			continue
+		}
+		delete(record, col.Name)
+
+		back := -1
+		for prev := idx - 1; prev >= 0; prev-- {
+			if conflicts[idx][columns[prev].Name] {
+				back = prev
+				break
+			}
+		}
+		if back < 0 || steps >= maxRecordSearchSteps {
+			return g.exhaustedError(col, rejectedIndex, rejectedCheck, steps)
+		}
+		for prev := back + 1; prev < idx; prev++ {
+			delete(record, columns[prev].Name)
+		}
+		backCol := columns[back].Name
+		for colName := range conflicts[idx] {
+			if colName != backCol {
+				conflicts[back][colName] = true
+			}
+		}
+		idx, fresh = back, false
+	}
+	return nil
+}
+
+func addConflicts(conflicts map[string]bool, columns []string) {
+	for _, colName := range columns {
+		conflicts[colName] = true
+	}
+}
+
+// exhaustedError возвращает ошибку перебора значений колонки.
+// Если значения отбрасывались уникальным индексом или CHECK ограничением, то запись пропускается.
+func (g *tableGenerator) exhaustedError(
+	col schema.Column,
+	index *uniqueIndex,
+	check *CheckConstraint,
+	steps int,
+) error {
+	var limit string
+	if steps >= maxRecordSearchSteps {
+		limit = fmt.Sprintf(" within %d values", maxRecordSearchSteps)
+	}
+	switch {
+	case index != nil:
+		return &skipRecordError{reason: fmt.Sprintf(
+			"unique index %q is exhausted: no unique value of column %q found%s", index, col.Name, limit)}
+	case check != nil:
+		return &skipRecordError{reason: fmt.Sprintf(
+			"no value of column %q satisfies check constraint %q%s", col.Name, check, limit)}
+	}
+	return xerrors.Errorf(
+		"unable to generate values within expiration of domain. column %q, table %q",
+		col.Name, g.table)
+}
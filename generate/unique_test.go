@@ -0,0 +1,164 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func uniqueTestTable(indexes ...schema.Index) schema.Table {
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	boolType := &schema.DBType{
+		TypeName: schema.Identifier{OID: 16, Schema: "pg_catalog", Name: "bool"},
+		Type:     schema.DataTypeBase,
+	}
+	notNull := schema.ColumnAttributes{DomainAttributes: schema.DomainAttributes{NotNullable: true}}
+	table := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "items"},
+		Columns: map[string]schema.Column{
+			"a":    {ColNum: 1, Name: "a", Type: int4},
+			"b":    {ColNum: 2, Name: "b", Type: boolType, Attributes: notNull},
+			"flag": {ColNum: 3, Name: "flag", Type: boolType},
+		},
+		Indexes: make(map[string]schema.Index),
+	}
+	for _, index := range indexes {
+		table.Indexes[index.Name] = index
+	}
+	return table
+}
+
+func TestGenerateRecordsUnique(t *testing.T) {
+	emptyRecords := func(n int) []Record { return make([]Record, n) }
+	tests := []struct {
+		name    string
+		index   schema.Index
+		records []Record
+		want    []string
+	}{
+		{
+			// значения b исчерпываются, поэтому перебор возвращается к колонке a
+			name:    "composite",
+			index:   schema.Index{Name: "items_a_b_key", Columns: []string{"a", "b"}, IsUnique: true},
+			records: emptyRecords(5),
+			want: []string{
+				"a=0, b=True, flag=True",
+				"a=0, b=False, flag=True",
+				"a=1, b=True, flag=True",
+				"a=1, b=False, flag=True",
+				"a=-1, b=True, flag=True",
+			},
+		},
+		{
+			name:  "nulls distinct",
+			index: schema.Index{Name: "items_a_b_key", Columns: []string{"a", "b"}, IsUnique: true},
+			records: []Record{
+				{Columns: []string{"a", "b"}, Values: []string{"NULL", "True"}},
+				{Columns: []string{"a", "b"}, Values: []string{"NULL", "True"}},
+			},
+			want: []string{"a=NULL, b=True, flag=True", "a=NULL, b=True, flag=True"},
+		},
+		{
+			name: "nulls not distinct",
+			index: schema.Index{
+				Name: "items_a_b_key", Columns: []string{"a", "b"}, IsUnique: true, IsNullsNotDistinct: true,
+			},
+			records: []Record{
+				{Columns: []string{"a", "b"}, Values: []string{"NULL", "True"}},
+				{Columns: []string{"a", "b"}, Values: []string{"NULL", "True"}},
+				{Columns: []string{"a"}, Values: []string{"NULL"}},
+			},
+			want: []string{"a=NULL, b=True, flag=True", "a=NULL, b=False, flag=True"},
+		},
+		{
+			name: "partial",
+			index: schema.Index{
+				Name: "items_a_key", Columns: []string{"a"}, IsUnique: true,
+				Definition: "CREATE UNIQUE INDEX items_a_key ON test.items USING btree (a) WHERE (flag IS TRUE)",
+			},
+			records: []Record{
+				{Columns: []string{"a", "flag"}, Values: []string{"1", "False"}},
+				{Columns: []string{"a", "flag"}, Values: []string{"1", "False"}},
+				{Columns: []string{"a", "flag"}, Values: []string{"1", "True"}},
+				{Columns: []string{"a", "flag"}, Values: []string{"1", "True"}},
+			},
+			// записи с flag = false не попадают в индекс
+			want: []string{"a=1, b=True, flag=False", "a=1, b=True, flag=False", "a=1, b=True, flag=True"},
+		},
+		{
+			// колонки INCLUDE не входят в ключ, поэтому a не помогает получить новое значение ключа
+			name: "include",
+			index: schema.Index{
+				Name: "items_b_key", Columns: []string{"b"}, IsUnique: true,
+				Definition: "CREATE UNIQUE INDEX items_b_key ON test.items USING btree (b) INCLUDE (a)",
+			},
+			records: emptyRecords(3),
+			want:    []string{"a=0, b=True, flag=True", "a=0, b=False, flag=True"},
+		},
+		{
+			name: "expression",
+			index: schema.Index{
+				Name: "items_expr_key", IsUnique: true,
+				Definition: "CREATE UNIQUE INDEX items_expr_key ON test.items USING btree (lower(a))",
+			},
+			records: emptyRecords(2),
+			want:    []string{"a=0, b=True, flag=True", "a=0, b=True, flag=True"},
+		},
+		{
+			// индекс не сужается до колонки a, поэтому ее значения могут повторяться
+			name: "column and expression",
+			index: schema.Index{
+				Name: "items_a_expr_key", Columns: []string{"a"}, HasExpressions: true, IsUnique: true,
+				Definition: "CREATE UNIQUE INDEX items_a_expr_key ON test.items USING btree (a, lower(b::text))",
+			},
+			records: emptyRecords(2),
+			want:    []string{"a=0, b=True, flag=True", "a=0, b=True, flag=True"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			table := uniqueTestTable(tt.index)
+			s := &schema.Schema{Tables: map[string]schema.Table{table.String(): table}}
+			gen, err := New(zap.NewNop(), s, Config{})
+			r.NoError(err)
+			res, warnings := gen.GenerateRecords(PartialRecords{Records: map[string]Records{
+				table.String(): {Records: tt.records},
+			}}, nil)
+			r.Empty(warnings)
+
+			got := make([]string, 0, len(res[table.String()].Records))
+			for _, record := range res[table.String()].Records {
+				got = append(got, record.String())
+			}
+			r.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestGenerateRecordValuesExhausted(t *testing.T) {
+	r := require.New(t)
+	table := uniqueTestTable(schema.Index{Name: "items_b_flag_key", Columns: []string{"b", "flag"}, IsUnique: true})
+	table.Columns["flag"] = schema.Column{ColNum: 3, Name: "flag", Type: table.Columns["b"].Type,
+		Attributes: table.Columns["b"].Attributes}
+	domain := CustomTableDomain{ColumnDomains: map[string]Domain{
+		"a": NewEnumDomain([]string{"1"}), "b": BoolDomain(), "flag": BoolDomain(),
+	}}
+	tgen := newTableGenerator(zap.NewNop(), table, domain, nil)
+
+	for i := 0; i < 4; i++ {
+		_, err := tgen.generateRecordValues(Record{})
+		r.NoError(err)
+	}
+	_, err := tgen.generateRecordValues(Record{})
+	var skipErr *skipRecordError
+	r.ErrorAs(err, &skipErr)
+	r.ErrorContains(err, `unique index "items_b_flag_key" is exhausted`)
+}
@@ -0,0 +1,603 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+const (
+	// параметр s распределения Ципфа по умолчанию
+	defaultZipfS = 1.2
+	// стандартное отклонение нормального распределения по умолчанию в долях от количества значений
+	defaultNormalStdDev = 1.0 / 3
+	// количество попыток сгенерировать одну запись объема
+	maxVolumeAttempts = 100
+	// количество значений домена, из которых выбираются значения неуникальных колонок
+	volumePoolSize = defaultTopDomainIterations
+)
+
+type DistributionKind string
+
+const (
+	DistributionUniform DistributionKind = "uniform"
+	DistributionNormal  DistributionKind = "normal"
+	DistributionZipf    DistributionKind = "zipf"
+)
+
+// Distribution описывает распределение номеров значений, из которых выбирается значение.
+// Домены перебирают значения от центра к краям (0, 1, -1, 2, ...), поэтому нормальное распределение
+// номеров дает значения, сосредоточенные около центра домена, а распределение Ципфа делает
+// первые значения (или первые родительские записи для внешних ключей) самыми частыми.
+type Distribution struct {
+	Kind DistributionKind
+	// Параметр s распределения Ципфа, должен быть больше 1. 0 - значение по умолчанию
+	ZipfS float64
+	// Стандартное отклонение нормального распределения в долях от количества значений.
+	// 0 - значение по умолчанию
+	StdDev float64
+}
+
+func (d Distribution) String() string {
+	switch d.Kind {
+	case DistributionNormal:
+		return fmt.Sprintf("%s:%g", d.Kind, d.StdDev)
+	case DistributionZipf:
+		return fmt.Sprintf("%s:%g", d.Kind, d.ZipfS)
+	default:
+		return string(d.Kind)
+	}
+}
+
+// ParseDistribution разбирает распределение в формате kind[:param]:
+// uniform, normal[:stddev], zipf[:s].
+func ParseDistribution(s string) (Distribution, error) {
+	kind, param, hasParam := strings.Cut(s, ":")
+	d := Distribution{Kind: DistributionKind(kind)}
+	var value float64
+	if hasParam {
+		var err error
+		value, err = strconv.ParseFloat(param, 64)
+		if err != nil {
+			return d, xerrors.Errorf("parse parameter of distribution %q: %w", s, err)
+		}
+	}
+	switch d.Kind {
+	case DistributionUniform:
+		if hasParam {
+			return d, xerrors.Errorf("uniform distribution has no parameters: %q", s)
+		}
+	case DistributionNormal:
+		d.StdDev = value
+	case DistributionZipf:
+		d.ZipfS = value
+	default:
+		return d, xerrors.Errorf("unknown distribution %q, expected one of: %s, %s, %s",
+			s, DistributionUniform, DistributionNormal, DistributionZipf)
+	}
+	return d, d.validate()
+}
+
+func (d Distribution) validate() error {
+	switch {
+	case d.Kind == DistributionNormal && d.StdDev < 0:
+		return xerrors.Errorf("standard deviation of normal distribution must be positive: %g", d.StdDev)
+	case d.Kind == DistributionZipf && d.ZipfS != 0 && d.ZipfS <= 1:
+		return xerrors.Errorf("parameter s of zipf distribution must be greater than 1: %g", d.ZipfS)
+	}
+	return nil
+}
+
+// withDefaults возвращает распределение с заполненными параметрами.
+// Если распределение не задано, то возвращается def.
+func (d Distribution) withDefaults(def DistributionKind) Distribution {
+	if d.Kind == "" {
+		d.Kind = def
+	}
+	if d.StdDev == 0 {
+		d.StdDev = defaultNormalStdDev
+	}
+	if d.ZipfS == 0 {
+		d.ZipfS = defaultZipfS
+	}
+	return d
+}
+
+// ColumnVolume задает заполнение колонки в режиме объема.
+type ColumnVolume struct {
+	// Распределение значений колонки. nil - распределение значений по умолчанию
+	Distribution *Distribution
+	// Доля NULL значений в колонке, от 0 до 1. Учитывается только для колонок, допускающих NULL
+	NullRatio float64
+}
+
+// VolumeConfig задает генерацию большого количества записей, например для проверки
+// производительности миграций. Недостающие записи заполняются значениями доменов
+// с заданными распределениями после граничных записей.
+type VolumeConfig struct {
+	// Количество записей таблицы, включая граничные, где ключ - имя таблицы.
+	// Схему можно не указывать, если имя таблицы однозначно.
+	Rows map[string]int64
+	// Распределение значений колонок. По умолчанию равномерное
+	Values Distribution
+	// Распределение ссылок внешних ключей на родительские записи. По умолчанию распределение Ципфа
+	ForeignKeys Distribution
+	// Настройки колонок, где ключ - <схема>.<таблица>.<колонка>
+	Columns map[string]ColumnVolume
+}
+
+// ParseRows разбирает количество записей таблиц в формате table=count,other.table=count.
+// Количество можно указать в экспоненциальной записи, например 1e6.
+func ParseRows(s string) (map[string]int64, error) {
+	rows := make(map[string]int64)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		table, count, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, xerrors.Errorf("rows count of table is not specified: %q", item)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+		if err != nil {
+			return nil, xerrors.Errorf("parse rows count of table %q: %w", table, err)
+		}
+		if value < 0 || value != math.Trunc(value) || value > math.MaxInt64 {
+			return nil, xerrors.Errorf("rows count of table %q must be a non-negative integer: %q", table, count)
+		}
+		rows[strings.TrimSpace(table)] = int64(value)
+	}
+	return rows, nil
+}
+
+// resolveVolume проверяет настройки объема и заменяет имена таблиц полными именами.
+func resolveVolume(s *schema.Schema, conf VolumeConfig) (VolumeConfig, error) {
+	if err := conf.Values.validate(); err != nil {
+		return conf, xerrors.Errorf("values distribution: %w", err)
+	}
+	if err := conf.ForeignKeys.validate(); err != nil {
+		return conf, xerrors.Errorf("foreign keys distribution: %w", err)
+	}
+	for name, col := range conf.Columns {
+		if col.NullRatio < 0 || col.NullRatio > 1 {
+			return conf, xerrors.Errorf("null ratio of column %q must be between 0 and 1: %g", name, col.NullRatio)
+		}
+		if col.Distribution != nil {
+			if err := col.Distribution.validate(); err != nil {
+				return conf, xerrors.Errorf("distribution of column %q: %w", name, err)
+			}
+		}
+	}
+
+	rows := make(map[string]int64, len(conf.Rows))
+	for name, count := range conf.Rows {
+		if _, ok := s.Tables[name]; ok {
+			rows[name] = count
+			continue
+		}
+		var found []string
+		for fullName, table := range s.Tables {
+			if table.Name.Name == name {
+				found = append(found, fullName)
+			}
+		}
+		sort.Strings(found)
+		switch len(found) {
+		case 0:
+			return conf, xerrors.Errorf("table %q not found", name)
+		case 1:
+			rows[found[0]] = count
+		default:
+			return conf, xerrors.Errorf("table name %q is ambiguous: %s", name, strings.Join(found, ", "))
+		}
+	}
+	conf.Rows = rows
+	return conf, nil
+}
+
+// RecordWriter принимает записи таблиц по мере генерации.
+// Таблицы передаются в порядке заполнения, записи таблицы передаются между BeginTable и EndTable.
+type RecordWriter interface {
+	BeginTable(table schema.Table) error
+	WriteRecord(table schema.Table, record Record) error
+	EndTable(table schema.Table) error
+}
+
+// GenerateVolume генерирует записи всех таблиц и передает их в w, не накапливая в памяти.
+// Сначала генерируются граничные записи, так же как в GenerateRecords, затем записи
+// до количества, заданного в VolumeConfig.Rows, заполняются значениями доменов.
+//
+// В памяти остаются только значения колонок, на которые ссылаются внешние ключи,
+// и значения уникальных индексов, уникальность которых нельзя обеспечить последовательным
+// перебором домена одной из колонок. Внешние ключи, разрывающие циклы, не обновляются
+// после вставки родительской таблицы, поэтому остаются NULL.
+// Негативные записи в этом режиме не генерируются.
+// Ошибка возвращается только если записи не удалось передать в w.
+func (g *Generator) GenerateVolume(
+	partial PartialRecords,
+	w RecordWriter,
+) (warnings []error, err error) {
+	if g.negative {
+		g.log.Warn("negative records are not generated in volume mode")
+	}
+	// значения колонок, на которые ссылаются внешние ключи, где ключ - имя таблицы
+	keys := make(map[string]*referencedKeys)
+	keyColumns := g.referencedColumns()
+
+tables:
+	for _, table := range g.order {
+		tablePartialRecords, ok := partial.Records[table.String()]
+		if !ok {
+			tablePartialRecords = g.GetDefaultChecks(table)
+		}
+		checks := g.checks[table.String()]
+		domain, err := g.tableDomains(table, CustomTableDomain{}, checks)
+		if err != nil {
+			warnings = append(warnings, err)
+			continue tables
+		}
+
+		tgen := newTableGenerator(g.log, table, domain, checks)
+		g.linkForeignKeys(tgen, func(fk *tableForeignKey) { keys[fk.fk.ReferenceTable].addTuples(fk) })
+		for _, fk := range tgen.foreignKeys {
+			if fk.deferred {
+				g.log.Warn("deferred foreign key is not filled in volume mode",
+					zap.Stringer("table", table), zap.Stringer("foreign_key", fk))
+			}
+		}
+
+		records, err := tgen.generateTableRecords(tablePartialRecords)
+		if err != nil {
+			warnings = append(warnings, err)
+			g.log.Warn("generate", zap.Stringer("table", table), zap.Error(err))
+			continue tables
+		}
+
+		if err := w.BeginTable(table); err != nil {
+			return warnings, xerrors.Errorf("begin table %q: %w", table, err)
+		}
+		var tableKeys *referencedKeys
+		if columns := keyColumns[table.String()]; len(columns) != 0 {
+			tableKeys = &referencedKeys{columns: columns}
+			keys[table.String()] = tableKeys
+		}
+		write := func(record Record) error {
+			if err := w.WriteRecord(table, record); err != nil {
+				return xerrors.Errorf("write record of table %q: %w", table, err)
+			}
+			tableKeys.add(record)
+			return nil
+		}
+		for _, record := range records.Records {
+			if err := write(record); err != nil {
+				return warnings, err
+			}
+		}
+
+		if count := g.volume.Rows[table.String()] - int64(len(records.Records)); count > 0 {
+			vgen, err := g.newVolumeTable(tgen, g.volume.Rows[table.String()])
+			if err != nil {
+				warnings = append(warnings, err)
+				g.log.Warn("generate volume records", zap.Stringer("table", table), zap.Error(err))
+				count = 0
+			}
+			var generated int64
+			for ; generated < count; generated++ {
+				vals, err := vgen.next()
+				if err != nil {
+					err = xerrors.Errorf("table %q, generated %d of %d volume records: %w",
+						table, generated, count, err)
+					warnings = append(warnings, err)
+					g.log.Warn("generate volume records", zap.Stringer("table", table), zap.Error(err))
+					break
+				}
+				if err := write(tgen.recordFromMap(vals)); err != nil {
+					return warnings, err
+				}
+			}
+			g.log.Info("volume records generated", zap.Stringer("table", table), zap.Int64("records", generated))
+		}
+
+		if err := w.EndTable(table); err != nil {
+			return warnings, xerrors.Errorf("end table %q: %w", table, err)
+		}
+	}
+	return warnings, nil
+}
+
+// referencedColumns возвращает колонки таблиц, на которые ссылаются внешние ключи, где ключ - имя таблицы.
+func (g *Generator) referencedColumns() map[string][]string {
+	res := make(map[string][]string)
+	for _, table := range g.order {
+		for _, fk := range table.ForeignKeys {
+			for _, col := range fk.ReferenceColumns {
+				if !slices.Contains(res[fk.ReferenceTable], col) {
+					res[fk.ReferenceTable] = append(res[fk.ReferenceTable], col)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// referencedKeys хранит значения колонок таблицы, на которые ссылаются внешние ключи.
+// Для каждой записи сохраняются только значения этих колонок, без имен и остальных колонок.
+type referencedKeys struct {
+	columns []string
+	// значения колонок записей в порядке columns. Отсутствующие в записи колонки заполнены NULL
+	values [][]string
+}
+
+// add сохраняет значения колонок записи. Для nil ничего не делает.
+func (k *referencedKeys) add(record Record) {
+	if k == nil {
+		return
+	}
+	values := make([]string, len(k.columns))
+	for idx, col := range k.columns {
+		values[idx] = nullLiteral
+		if pos := slices.Index(record.Columns, col); pos != -1 {
+			values[idx] = record.Values[pos]
+		}
+	}
+	k.values = append(k.values, values)
+}
+
+// addTuples добавляет во внешний ключ наборы значений сохраненных записей.
+func (k *referencedKeys) addTuples(fk *tableForeignKey) {
+	if k == nil {
+		return
+	}
+	positions := make([]int, 0, len(fk.fk.ReferenceColumns))
+	for _, col := range fk.fk.ReferenceColumns {
+		pos := slices.Index(k.columns, col)
+		if pos == -1 {
+			return
+		}
+		positions = append(positions, pos)
+	}
+	for _, values := range k.values {
+		tuple := make([]string, 0, len(positions))
+		for _, pos := range positions {
+			tuple = append(tuple, values[pos])
+		}
+		fk.addKeyTuple(tuple)
+	}
+}
+
+// sampler выбирает номер значения по распределению.
+type sampler struct {
+	dist Distribution
+	rand *rand.Rand
+	// распределение Ципфа создается для конкретного количества значений
+	zipf  *rand.Zipf
+	zipfN int
+}
+
+// sample возвращает номер значения от 0 до n-1.
+func (s *sampler) sample(n int) int {
+	switch s.dist.Kind {
+	case DistributionNormal:
+		// модуль нормального распределения, т.к. значения доменов перебираются от центра
+		for i := 0; i < maxVolumeAttempts; i++ {
+			idx := int(math.Abs(s.rand.NormFloat64()) * s.dist.StdDev * float64(n))
+			if idx < n {
+				return idx
+			}
+		}
+		return s.rand.Intn(n)
+	case DistributionZipf:
+		if n == 1 {
+			return 0
+		}
+		if s.zipf == nil || s.zipfN != n {
+			s.zipf = rand.NewZipf(s.rand, s.dist.ZipfS, 1, uint64(n-1))
+			s.zipfN = n
+		}
+		return int(s.zipf.Uint64())
+	default:
+		return s.rand.Intn(n)
+	}
+}
+
+// volumeTable генерирует записи объема одной таблицы.
+type volumeTable struct {
+	tgen *tableGenerator
+	rand *rand.Rand
+
+	// последовательно перебираемые домены колонок, значения которых не повторяются
+	sequences map[string]Domain
+	// значения остальных колонок и распределения, по которым они выбираются
+	pools    map[string][]string
+	samplers map[string]*sampler
+	// доля NULL значений колонок
+	nullRatio map[string]float64
+	// распределение ссылок внешних ключей
+	foreignKeys *sampler
+	// уникальные индексы без последовательных колонок, значения которых нужно запоминать
+	tracked []*uniqueIndex
+}
+
+func (g *Generator) newVolumeTable(tgen *tableGenerator, rows int64) (*volumeTable, error) {
+	table := tgen.table
+	v := &volumeTable{
+		tgen:        tgen,
+		rand:        g.rand,
+		sequences:   make(map[string]Domain),
+		pools:       make(map[string][]string),
+		samplers:    make(map[string]*sampler),
+		nullRatio:   make(map[string]float64),
+		foreignKeys: &sampler{dist: g.volume.ForeignKeys.withDefaults(DistributionZipf), rand: g.rand},
+	}
+
+	fkColumns := make(map[string]bool)
+	for _, fk := range tgen.foreignKeys {
+		for _, col := range fk.fk.Constraint.Columns {
+			fkColumns[col] = true
+		}
+	}
+	// уникальность индекса обеспечивается, если значения хотя бы одной его колонки не повторяются
+	for _, index := range tgen.uniqueIndexes {
+		if slices.ContainsFunc(index.columns, func(col string) bool { return v.sequences[col] != nil }) {
+			continue
+		}
+		idx := slices.IndexFunc(index.columns, func(colName string) bool {
+			col, ok := table.Columns[colName]
+			return ok && !fkColumns[colName] && !col.Attributes.IsGeneratedAlways()
+		})
+		if idx < 0 {
+			v.tracked = append(v.tracked, index)
+			continue
+		}
+		col := table.Columns[index.columns[idx]]
+		domain, err := g.sequenceDomain(col, rows, tgen.checks)
+		if err != nil {
+			return nil, err
+		}
+		v.sequences[col.Name] = domain
+	}
+
+	for _, col := range tgen.columns {
+		if col.Attributes.IsGeneratedAlways() {
+			continue
+		}
+		conf := g.volume.Columns[table.String()+"."+col.Name]
+		if !col.IsNotNull() && v.sequences[col.Name] == nil && conf.NullRatio > 0 {
+			v.nullRatio[col.Name] = conf.NullRatio
+		}
+		if v.sequences[col.Name] != nil || fkColumns[col.Name] {
+			continue
+		}
+		dist := g.volume.Values
+		if conf.Distribution != nil {
+			dist = *conf.Distribution
+		}
+		v.samplers[col.Name] = &sampler{dist: dist.withDefaults(DistributionUniform), rand: g.rand}
+
+		domain := tgen.domains.ColumnDomains[col.Name]
+		pool := make([]string, 0, volumePoolSize)
+		domain.Reset()
+		for len(pool) < volumePoolSize && domain.Next() {
+			pool = append(pool, domain.Value())
+		}
+		domain.Reset()
+		v.pools[col.Name] = pool
+	}
+	return v, nil
+}
+
+// sequenceDomain возвращает домен колонки, которого хватит на rows значений.
+func (g *Generator) sequenceDomain(col schema.Column, rows int64, checks []*CheckConstraint) (Domain, error) {
+	domain, err := g.DefaultDomain(col)
+	if err != nil {
+		return nil, xerrors.Errorf("column %q: %w", col.Name, err)
+	}
+	switch d := domain.(type) {
+	case *IntDomain:
+		if rows > d.top {
+			d.Init(rows)
+		}
+	case *TimeDomain:
+		if rows > d.top {
+			d.Init(g.now, rows)
+		}
+	}
+	domain = g.checkedDomain(col, domain, checks)
+	domain.Reset()
+	return domain, nil
+}
+
+// next генерирует следующую запись объема.
+func (v *volumeTable) next() (map[string]string, error) {
+	g := v.tgen
+	var lastErr error
+	for attempt := 0; attempt < maxVolumeAttempts; attempt++ {
+		record := make(map[string]string, len(g.columns))
+		// колонки перебираются в порядке объявления, чтобы при одном seed результат не менялся
+		for _, col := range g.columns {
+			if ratio, ok := v.nullRatio[col.Name]; ok && v.rand.Float64() < ratio {
+				record[col.Name] = nullLiteral
+			}
+		}
+
+		var selfRefs []*tableForeignKey
+		selfRefColumns := make(map[string]bool)
+		skipped := false
+		for _, fk := range g.foreignKeys {
+			if len(fk.tuples) != 0 {
+				fk.next = v.foreignKeys.sample(len(fk.tuples))
+			}
+			pending, err := g.fillForeignKey(fk, record, g.checks)
+			var skipErr *skipRecordError
+			if errors.As(err, &skipErr) {
+				lastErr, skipped = err, true
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if pending {
+				selfRefs = append(selfRefs, fk)
+				for _, col := range fk.fk.Constraint.Columns {
+					selfRefColumns[col] = true
+				}
+			}
+		}
+		if skipped {
+			continue
+		}
+
+		for _, col := range g.columns {
+			if _, ok := record[col.Name]; ok || col.Attributes.IsGeneratedAlways() || selfRefColumns[col.Name] {
+				continue
+			}
+			if domain, ok := v.sequences[col.Name]; ok {
+				if !domain.Next() {
+					return nil, xerrors.Errorf("values of column %q are exhausted", col.Name)
+				}
+				record[col.Name] = domain.Value()
+				continue
+			}
+			if pool := v.pools[col.Name]; len(pool) != 0 {
+				record[col.Name] = pool[v.samplers[col.Name].sample(len(pool))]
+			}
+		}
+
+		for _, fk := range selfRefs {
+			if err := g.fillSelfReference(fk, record, g.checks); err != nil {
+				lastErr, skipped = err, true
+				break
+			}
+		}
+		if skipped {
+			continue
+		}
+
+		if failed := failedCheck(g.checks, record); failed != nil {
+			lastErr = xerrors.Errorf("check constraint %q is violated", failed)
+			continue
+		}
+		if index := g.violatedUniqueIndex(record); index != nil {
+			lastErr = xerrors.Errorf("unique index %q is violated", index)
+			continue
+		}
+		for _, index := range v.tracked {
+			if key, ok := index.key(record); ok {
+				index.values.Add(key)
+			}
+		}
+		return record, nil
+	}
+	return nil, xerrors.Errorf("no valid record found in %d attempts: %w", maxVolumeAttempts, lastErr)
+}
@@ -0,0 +1,256 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+// memoryWriter запоминает количество записей и значения колонок сгенерированных записей.
+type memoryWriter struct {
+	tables []string
+	values map[string][]map[string]string
+}
+
+func (w *memoryWriter) BeginTable(table schema.Table) error {
+	w.tables = append(w.tables, table.String())
+	return nil
+}
+
+func (w *memoryWriter) WriteRecord(table schema.Table, record Record) error {
+	w.values[table.String()] = append(w.values[table.String()], recordValues(record))
+	return nil
+}
+
+func (w *memoryWriter) EndTable(schema.Table) error { return nil }
+
+func volumeTestSchema() *schema.Schema {
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	text := &schema.DBType{
+		TypeName: schema.Identifier{OID: 25, Schema: "pg_catalog", Name: "text"},
+		Type:     schema.DataTypeBase,
+	}
+	notNull := schema.ColumnAttributes{DomainAttributes: schema.DomainAttributes{NotNullable: true}}
+
+	usersPK := schema.Index{Name: "users_pkey", Columns: []string{"id"}, IsUnique: true, IsPrimary: true}
+	usersEmail := schema.Index{Name: "users_email_key", Columns: []string{"email"}, IsUnique: true}
+	users := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "users"},
+		Columns: map[string]schema.Column{
+			"id":    {ColNum: 1, Name: "id", Type: int4, Attributes: notNull},
+			"email": {ColNum: 2, Name: "email", Type: text, Attributes: notNull},
+			"age":   {ColNum: 3, Name: "age", Type: int4},
+		},
+		Indexes: map[string]schema.Index{usersPK.Name: usersPK, usersEmail.Name: usersEmail},
+	}
+
+	ordersPK := schema.Index{Name: "orders_pkey", Columns: []string{"id"}, IsUnique: true, IsPrimary: true}
+	userFK := &schema.Constraint{
+		Name: "orders_user_id_fkey", Type: schema.ConstraintTypeFK, Columns: []string{"user_id"},
+	}
+	orders := schema.Table{
+		Name: schema.Identifier{OID: 2, Schema: "test", Name: "orders"},
+		Columns: map[string]schema.Column{
+			"id":      {ColNum: 1, Name: "id", Type: int4, Attributes: notNull},
+			"user_id": {ColNum: 2, Name: "user_id", Type: int4, Attributes: notNull},
+		},
+		ForeignKeys: map[string]schema.ForeignKey{
+			userFK.Name: {Constraint: userFK, ReferenceTable: "test.users", ReferenceColumns: []string{"id"}},
+		},
+		Indexes: map[string]schema.Index{ordersPK.Name: ordersPK},
+	}
+	users.ReferencedBy = map[string]*schema.Constraint{"test.orders": userFK}
+
+	return &schema.Schema{
+		Types:  map[string]*schema.DBType{int4.String(): int4, text.String(): text},
+		Tables: map[string]schema.Table{users.String(): users, orders.String(): orders},
+	}
+}
+
+func TestGenerateVolume(t *testing.T) {
+	r := require.New(t)
+	seed := int64(1)
+	gen, err := New(zap.NewNop(), volumeTestSchema(), Config{
+		Seed: &seed,
+		Volume: VolumeConfig{
+			Rows:   map[string]int64{"users": 2000, "test.orders": 5000},
+			Values: Distribution{Kind: DistributionNormal},
+			Columns: map[string]ColumnVolume{
+				"test.users.age": {NullRatio: 0.5},
+			},
+		},
+	})
+	r.NoError(err)
+
+	w := &memoryWriter{values: make(map[string][]map[string]string)}
+	warnings, err := gen.GenerateVolume(PartialRecords{}, w)
+	r.NoError(err)
+	r.Empty(warnings)
+	r.Equal([]string{"test.users", "test.orders"}, w.tables)
+
+	users := w.values["test.users"]
+	r.Len(users, 2000)
+	ids := make(map[string]bool)
+	emails := make(map[string]bool)
+	var nulls int
+	for _, values := range users {
+		r.False(ids[values["id"]], "duplicate id %s", values["id"])
+		r.False(emails[values["email"]], "duplicate email %s", values["email"])
+		ids[values["id"]] = true
+		emails[values["email"]] = true
+		if values["age"] == nullLiteral {
+			nulls++
+		}
+	}
+	r.InDelta(1000, nulls, 100)
+
+	orders := w.values["test.orders"]
+	r.Len(orders, 5000)
+	refs := make(map[string]int)
+	orderIDs := make(map[string]bool)
+	for _, values := range orders {
+		r.False(orderIDs[values["id"]], "duplicate id %s", values["id"])
+		orderIDs[values["id"]] = true
+		r.True(ids[values["user_id"]], "order references missing user %s", values["user_id"])
+		refs[values["user_id"]]++
+	}
+	// распределение Ципфа: на первого пользователя ссылается больше всего заказов
+	first := users[0]["id"]
+	for id, count := range refs {
+		r.LessOrEqual(count, refs[first], "user %s", id)
+	}
+}
+
+func TestGenerateVolumeSeed(t *testing.T) {
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	pk := schema.Index{Name: "items_pkey", Columns: []string{"id"}, IsUnique: true, IsPrimary: true}
+	items := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "items"},
+		Columns: map[string]schema.Column{
+			"id": {ColNum: 1, Name: "id", Type: int4, Attributes: schema.ColumnAttributes{
+				DomainAttributes: schema.DomainAttributes{NotNullable: true},
+			}},
+		},
+		Indexes: map[string]schema.Index{pk.Name: pk},
+	}
+	columns := make(map[string]ColumnVolume)
+	for idx, colName := range []string{"a", "b", "c", "d"} {
+		items.Columns[colName] = schema.Column{ColNum: idx + 2, Name: colName, Type: int4}
+		columns["test.items."+colName] = ColumnVolume{NullRatio: 0.3}
+	}
+	s := &schema.Schema{
+		Types:  map[string]*schema.DBType{int4.String(): int4},
+		Tables: map[string]schema.Table{items.String(): items},
+	}
+
+	generate := func() []map[string]string {
+		seed := int64(1)
+		gen, err := New(zap.NewNop(), s, Config{
+			Seed:   &seed,
+			Volume: VolumeConfig{Rows: map[string]int64{"test.items": 200}, Columns: columns},
+		})
+		require.NoError(t, err)
+		w := &memoryWriter{values: make(map[string][]map[string]string)}
+		warnings, err := gen.GenerateVolume(PartialRecords{}, w)
+		require.NoError(t, err)
+		require.Empty(t, warnings)
+		return w.values["test.items"]
+	}
+
+	first := generate()
+	require.Len(t, first, 200)
+	for run := 0; run < 5; run++ {
+		require.Equal(t, first, generate(), "run %d", run)
+	}
+}
+
+func TestReferencedKeys(t *testing.T) {
+	r := require.New(t)
+	fkConstraint := &schema.Constraint{Name: "fk", Type: schema.ConstraintTypeFK, Columns: []string{"ref_b", "ref_a"}}
+	fk := &tableForeignKey{
+		fk:         schema.ForeignKey{Constraint: fkConstraint, ReferenceColumns: []string{"b", "a"}},
+		tupleIndex: make(map[string]int),
+	}
+
+	var empty *referencedKeys
+	empty.add(Record{Columns: []string{"a"}, Values: []string{"1"}})
+	empty.addTuples(fk)
+	r.Empty(fk.tuples)
+
+	keys := &referencedKeys{columns: []string{"a", "b"}}
+	keys.add(Record{Columns: []string{"id", "a", "b"}, Values: []string{"1", "10", "'x'"}})
+	keys.add(Record{Columns: []string{"b", "a"}, Values: []string{"'y'", "20"}})
+	// запись без колонки ключа и повторный набор пропускаются
+	keys.add(Record{Columns: []string{"a"}, Values: []string{"30"}})
+	keys.add(Record{Columns: []string{"a", "b"}, Values: []string{"10", "'x'"}})
+	r.Len(keys.values, 4)
+
+	keys.addTuples(fk)
+	r.Equal([][]string{{"'x'", "10"}, {"'y'", "20"}}, fk.tuples)
+	r.Equal(Record{Columns: []string{"ref_b", "ref_a"}, Values: []string{"'y'", "20"}}, fk.tuple(1))
+}
+
+func TestGenerateVolumeConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		volume VolumeConfig
+	}{
+		{name: "unknown table", volume: VolumeConfig{Rows: map[string]int64{"unknown": 1}}},
+		{name: "null ratio", volume: VolumeConfig{Columns: map[string]ColumnVolume{"test.users.age": {NullRatio: 2}}}},
+		{name: "zipf", volume: VolumeConfig{ForeignKeys: Distribution{Kind: DistributionZipf, ZipfS: 0.5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(zap.NewNop(), volumeTestSchema(), Config{Volume: tt.volume})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParseRows(t *testing.T) {
+	r := require.New(t)
+	rows, err := ParseRows("users=1e6, test.orders=5000,")
+	r.NoError(err)
+	r.Equal(map[string]int64{"users": 1000000, "test.orders": 5000}, rows)
+
+	for _, s := range []string{"users", "users=many", "users=1.5", "users=-1"} {
+		_, err := ParseRows(s)
+		r.Error(err, s)
+	}
+}
+
+func TestParseDistribution(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Distribution
+		wantErr bool
+	}{
+		{in: "uniform", want: Distribution{Kind: DistributionUniform}},
+		{in: "normal:0.1", want: Distribution{Kind: DistributionNormal, StdDev: 0.1}},
+		{in: "zipf", want: Distribution{Kind: DistributionZipf}},
+		{in: "zipf:2", want: Distribution{Kind: DistributionZipf, ZipfS: 2}},
+		{in: "zipf:1", wantErr: true},
+		{in: "uniform:1", wantErr: true},
+		{in: "poisson", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDistribution(tt.in)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
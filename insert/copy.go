@@ -0,0 +1,111 @@
+package insert
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+// copyEscaper экранирует значения для текстового формата COPY.
+var copyEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\t", `\t`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+const copyNull = `\N`
+
+// CopyQuery возвращает запрос COPY FROM STDIN для указанных колонок таблицы.
+func CopyQuery(table schema.Table, columns []string) string {
+	cols := make([]string, 0, len(columns))
+	for _, col := range columns {
+		cols = append(cols, pgx.Identifier{col}.Sanitize())
+	}
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN",
+		pgx.Identifier{table.Name.Schema, table.Name.Name}.Sanitize(),
+		strings.Join(cols, ", "),
+	)
+}
+
+// GroupByColumns разбивает записи на идущие подряд группы с одинаковым набором колонок.
+// Для каждой группы нужен отдельный COPY.
+func GroupByColumns(records []generate.Record) [][]generate.Record {
+	var groups [][]generate.Record
+	for idx, record := range records {
+		if idx == 0 || !slices.Equal(records[idx-1].Columns, record.Columns) {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], record)
+	}
+	return groups
+}
+
+// WriteCopyData записывает записи в текстовом формате COPY.
+// Значения записей должны быть SQL литералами, они преобразуются функцией generate.ParseLiteral.
+func WriteCopyData(w io.Writer, records []generate.Record) error {
+	bw := bufio.NewWriter(w)
+	for rowNum, record := range records {
+		if err := WriteCopyRecord(bw, record); err != nil {
+			return xerrors.Errorf("record %d: %w", rowNum, err)
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteCopyRecord записывает одну запись в текстовом формате COPY.
+func WriteCopyRecord(bw *bufio.Writer, record generate.Record) error {
+	for idx, literal := range record.Values {
+		if idx != 0 {
+			if err := bw.WriteByte('\t'); err != nil {
+				return err
+			}
+		}
+		value, isNull, err := generate.ParseLiteral(literal)
+		if err != nil {
+			return xerrors.Errorf("column %q: %w", record.Columns[idx], err)
+		}
+		if isNull {
+			value = copyNull
+		} else {
+			value = copyEscaper.Replace(value)
+		}
+		if _, err := bw.WriteString(value); err != nil {
+			return err
+		}
+	}
+	return bw.WriteByte('\n')
+}
+
+// copyRecords загружает записи таблицы с помощью COPY FROM STDIN.
+func (i *Inserter) copyRecords(
+	ctx context.Context,
+	tx pgx.Tx,
+	table schema.Table,
+	records []generate.Record,
+) error {
+	for _, group := range GroupByColumns(records) {
+		if len(group[0].Columns) == 0 {
+			return xerrors.New("unable to copy records without columns")
+		}
+		var buf bytes.Buffer
+		if err := WriteCopyData(&buf, group); err != nil {
+			return xerrors.Errorf("encode records: %w", err)
+		}
+		query := CopyQuery(table, group[0].Columns)
+		if _, err := tx.Conn().PgConn().CopyFrom(ctx, &buf, query); err != nil {
+			return xerrors.Errorf("copy records: %w", err)
+		}
+	}
+	return nil
+}
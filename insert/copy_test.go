@@ -0,0 +1,62 @@
+package insert
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestWriteCopyData(t *testing.T) {
+	r := require.New(t)
+	records := []generate.Record{
+		{Columns: []string{"a", "b", "c"}, Values: []string{"1", "NULL", "'NaN'::REAL"}},
+		{Columns: []string{"a", "b", "c"}, Values: []string{"-1", "'tab\there'", "'back\\slash\nnew''line'"}},
+		{Columns: []string{"a", "b", "c"}, Values: []string{"0", "'\\N'", "''"}},
+	}
+
+	var buf bytes.Buffer
+	r.NoError(WriteCopyData(&buf, records))
+	r.Equal("1\t\\N\tNaN\n"+
+		"-1\ttab\\there\tback\\\\slash\\nnew'line\n"+
+		"0\t\\\\N\t\n", buf.String())
+
+	err := WriteCopyData(&buf, []generate.Record{
+		{Columns: []string{"a"}, Values: []string{"'a' || 'b'"}},
+	})
+	r.Error(err)
+}
+
+func TestWriteCopyDataComposite(t *testing.T) {
+	r := require.New(t)
+	records := []generate.Record{
+		{Columns: []string{"id", "address"}, Values: []string{"1", "ROW('Main St, 1', NULL, 'tab\there')::test.address"}},
+		{Columns: []string{"id", "address"}, Values: []string{"2", "ROW('say \"hi\"', 'c:\\dir', 10)::test.address"}},
+	}
+
+	var buf bytes.Buffer
+	r.NoError(WriteCopyData(&buf, records))
+	r.Equal("1\t(\"Main St, 1\",,\"tab\\there\")\n"+
+		"2\t(\"say \"\"hi\"\"\",\"c:\\\\\\\\dir\",10)\n", buf.String())
+}
+
+func TestGroupByColumns(t *testing.T) {
+	ab1 := generate.Record{Columns: []string{"a", "b"}, Values: []string{"1", "2"}}
+	ab2 := generate.Record{Columns: []string{"a", "b"}, Values: []string{"3", "4"}}
+	a := generate.Record{Columns: []string{"a"}, Values: []string{"5"}}
+
+	require.Equal(t,
+		[][]generate.Record{{ab1, ab2}, {a}, {ab1}},
+		GroupByColumns([]generate.Record{ab1, ab2, a, ab1}))
+	require.Empty(t, GroupByColumns(nil))
+}
+
+func TestCopyQuery(t *testing.T) {
+	table := schema.Table{Name: schema.Identifier{Schema: "test", Name: "users"}}
+	require.Equal(t,
+		`COPY "test"."users" ("id", "name") FROM STDIN`,
+		CopyQuery(table, []string{"id", "name"}))
+}
@@ -0,0 +1,123 @@
+package insert
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/Feresey/mtest/db"
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+// DeferredConstraints возвращает имена отложенных (DEFERRABLE) внешних ключей,
+// которые ссылаются на таблицы, вставляемые позже. Такие ключи разрывают циклы между таблицами,
+// поэтому их проверка откладывается, пока не будут вставлены все таблицы.
+func DeferredConstraints(order []schema.Table) []string {
+	position := make(map[string]int, len(order))
+	for idx, table := range order {
+		position[table.String()] = idx
+	}
+
+	var res []string
+	for idx, table := range order {
+		fkNames := maps.Keys(table.ForeignKeys)
+		sort.Strings(fkNames)
+		for _, fkName := range fkNames {
+			fk := table.ForeignKeys[fkName]
+			refPos, ok := position[fk.ReferenceTable]
+			if !ok || refPos <= idx || !fk.Constraint.IsDeferrable() {
+				continue
+			}
+			res = append(res, pgx.Identifier{table.Name.Schema, fk.Constraint.Name}.Sanitize())
+		}
+	}
+	return res
+}
+
+// SetConstraintsQuery возвращает запрос, меняющий режим проверки ограничений (DEFERRED или IMMEDIATE).
+func SetConstraintsQuery(constraints []string, mode string) string {
+	return fmt.Sprintf("SET CONSTRAINTS %s %s", strings.Join(constraints, ", "), mode)
+}
+
+// UpdateQuery возвращает запрос, устанавливающий значения отложенных внешних ключей записи.
+// Запись находится по первичному ключу, а если его нет, то по значениям всех остальных колонок.
+func UpdateQuery(table schema.Table, record generate.Record) string {
+	deferred := record.Deferred
+	sets := make([]string, 0, len(deferred.Columns))
+	for idx, col := range deferred.Columns {
+		sets = append(sets, fmt.Sprintf("%s = %s", pgx.Identifier{col}.Sanitize(), deferred.Values[idx]))
+	}
+
+	keyColumns := record.Columns
+	if pk := table.PrimaryKey; pk != nil && !slices.ContainsFunc(pk.Columns, func(col string) bool {
+		return !slices.Contains(record.Columns, col) || slices.Contains(deferred.Columns, col)
+	}) {
+		keyColumns = pk.Columns
+	}
+	conds := make([]string, 0, len(keyColumns))
+	for _, col := range keyColumns {
+		if slices.Contains(deferred.Columns, col) {
+			continue
+		}
+		value := record.Values[slices.Index(record.Columns, col)]
+		conds = append(conds, fmt.Sprintf("%s IS NOT DISTINCT FROM %s", pgx.Identifier{col}.Sanitize(), value))
+	}
+
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+		pgx.Identifier{table.Name.Schema, table.Name.Name}.Sanitize(),
+		strings.Join(sets, ", "),
+		strings.Join(conds, " AND "),
+	)
+}
+
+// updateDeferred устанавливает значения отложенных внешних ключей после вставки записей всех таблиц.
+// Ошибки обновления записей добавляются в отчет таблицы.
+func (i *Inserter) updateDeferred(
+	ctx context.Context,
+	tx pgx.Tx,
+	order []schema.Table,
+	records map[string]generate.Records,
+	report *Report,
+) error {
+	for _, table := range order {
+		reportIdx := slices.IndexFunc(report.Tables, func(tr TableReport) bool { return tr.Table == table.String() })
+		if reportIdx < 0 {
+			continue
+		}
+		for idx, record := range records[table.String()].Positive().Records {
+			if record.Deferred == nil {
+				continue
+			}
+			query := UpdateQuery(table, record)
+			if err := i.exec(ctx, tx, "SAVEPOINT "+rowSavepoint); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, query); err != nil {
+				report.Tables[reportIdx].Errors = append(report.Tables[reportIdx].Errors, RowError{
+					Table:  table.String(),
+					RowNum: idx,
+					Record: record,
+					Err: db.Error{
+						Err:     err,
+						Message: "update deferred foreign keys",
+						Query:   query,
+					},
+				})
+				if err := i.exec(ctx, tx, "ROLLBACK TO SAVEPOINT "+rowSavepoint); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := i.exec(ctx, tx, "RELEASE SAVEPOINT "+rowSavepoint); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
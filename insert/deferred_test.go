@@ -0,0 +1,82 @@
+package insert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestUpdateQuery(t *testing.T) {
+	deferred := generate.Record{Columns: []string{"head_id"}, Values: []string{"3"}}
+	record := generate.Record{
+		Columns:  []string{"id", "name", "head_id"},
+		Values:   []string{"1", "'a'", "NULL"},
+		Deferred: &deferred,
+	}
+
+	tests := []struct {
+		name       string
+		primaryKey *schema.Constraint
+		want       string
+	}{
+		{
+			name:       "primary key",
+			primaryKey: &schema.Constraint{Name: "departments_pkey", Columns: []string{"id"}},
+			want:       `UPDATE "test"."departments" SET "head_id" = 3 WHERE "id" IS NOT DISTINCT FROM 1`,
+		},
+		{
+			name: "no primary key",
+			want: `UPDATE "test"."departments" SET "head_id" = 3 ` +
+				`WHERE "id" IS NOT DISTINCT FROM 1 AND "name" IS NOT DISTINCT FROM 'a'`,
+		},
+		{
+			name:       "deferred primary key",
+			primaryKey: &schema.Constraint{Name: "departments_pkey", Columns: []string{"id", "head_id"}},
+			want: `UPDATE "test"."departments" SET "head_id" = 3 ` +
+				`WHERE "id" IS NOT DISTINCT FROM 1 AND "name" IS NOT DISTINCT FROM 'a'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := schema.Table{
+				Name:       schema.Identifier{Schema: "test", Name: "departments"},
+				PrimaryKey: tt.primaryKey,
+			}
+			require.Equal(t, tt.want, UpdateQuery(table, record))
+		})
+	}
+}
+
+func TestDeferredConstraints(t *testing.T) {
+	fk := func(name, ref, definition string) schema.ForeignKey {
+		return schema.ForeignKey{
+			Constraint:     &schema.Constraint{Name: name, Type: schema.ConstraintTypeFK, Definition: definition},
+			ReferenceTable: ref,
+		}
+	}
+	departments := schema.Table{
+		Name: schema.Identifier{Schema: "test", Name: "departments"},
+		ForeignKeys: map[string]schema.ForeignKey{
+			"departments_head_id_fkey": fk("departments_head_id_fkey", "test.users",
+				"FOREIGN KEY (head_id) REFERENCES test.users(id) DEFERRABLE INITIALLY IMMEDIATE"),
+			"departments_owner_id_fkey": fk("departments_owner_id_fkey", "test.users",
+				"FOREIGN KEY (owner_id) REFERENCES test.users(id) NOT DEFERRABLE"),
+		},
+	}
+	users := schema.Table{
+		Name: schema.Identifier{Schema: "test", Name: "users"},
+		ForeignKeys: map[string]schema.ForeignKey{
+			"users_department_id_fkey": fk("users_department_id_fkey", "test.departments",
+				"FOREIGN KEY (department_id) REFERENCES test.departments(id) DEFERRABLE"),
+		},
+	}
+
+	constraints := DeferredConstraints([]schema.Table{departments, users})
+	require.Equal(t, []string{`"test"."departments_head_id_fkey"`}, constraints)
+	require.Equal(t, `SET CONSTRAINTS "test"."departments_head_id_fkey" DEFERRED`,
+		SetConstraintsQuery(constraints, "DEFERRED"))
+}
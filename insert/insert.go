@@ -0,0 +1,316 @@
+package insert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/db"
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+const (
+	tableSavepoint = "mtest_table"
+	rowSavepoint   = "mtest_row"
+)
+
+type Config struct {
+	// Вставлять сгенерированные записи в базу данных
+	Enabled bool
+	// Откатить все вставленные записи после завершения вставки
+	Rollback bool
+	// Загружать записи с помощью COPY вместо INSERT
+	Copy bool
+	// Количество таблиц одного уровня, которые вставляются одновременно
+	Parallel int
+}
+
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Inserter вставляет сгенерированные записи в базу данных.
+type Inserter struct {
+	conn TxBeginner
+	log  *zap.Logger
+}
+
+func NewInserter(conn TxBeginner, log *zap.Logger) *Inserter {
+	return &Inserter{
+		conn: conn,
+		log:  log.Named("insert"),
+	}
+}
+
+// RowError описывает ошибку вставки одной записи.
+type RowError struct {
+	Table string
+	// Порядковый номер записи таблицы
+	RowNum int
+	Record generate.Record
+	Err    db.Error
+}
+
+func (e RowError) Error() string {
+	partial := e.Record
+	if e.Record.Partial != nil {
+		partial = *e.Record.Partial
+	}
+	return fmt.Sprintf("table %q, record %d, partial record (%s): %v",
+		e.Table, e.RowNum, partial, e.Err)
+}
+
+func (e RowError) Unwrap() error { return e.Err }
+
+// TableReport описывает результат вставки записей одной таблицы.
+type TableReport struct {
+	Table    string
+	Inserted int
+	Errors   []RowError
+	// Количество негативных записей, отклонённых базой данных из-за ожидаемого нарушения
+	Rejected int
+	// Негативные записи, которые база данных приняла или отклонила по другой причине
+	NegativeErrors []RowError
+	// Включенные триггеры таблицы на событие INSERT, если записи были вставлены.
+	// Срабатывание триггеров не проверяется
+	EnabledInsertTriggers []string
+}
+
+type Report struct {
+	Tables []TableReport
+}
+
+// Errors возвращает ошибки вставки записей всех таблиц.
+func (r Report) Errors() []error {
+	var errs []error
+	for _, table := range r.Tables {
+		for _, err := range table.Errors {
+			errs = append(errs, err)
+		}
+		for _, err := range table.NegativeErrors {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Insert вставляет записи таблиц в указанном порядке в рамках одной транзакции.
+// Записи каждой таблицы вставляются одним батчем или одним COPY.
+// Если записи не удалось вставить, то записи вставляются по одной, чтобы найти все ошибочные записи.
+// После вставки последовательности колонок сдвигаются за вставленные значения.
+func (i *Inserter) Insert(
+	ctx context.Context,
+	order []schema.Table,
+	records map[string]generate.Records,
+	conf Config,
+) (report Report, err error) {
+	tx, err := i.conn.Begin(ctx)
+	if err != nil {
+		return report, xerrors.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil || conf.Rollback {
+			i.log.Info("rollback inserted records")
+			if rerr := tx.Rollback(ctx); rerr != nil {
+				err = xerrors.Errorf("rollback transaction: %w", rerr)
+			}
+			return
+		}
+		if cerr := tx.Commit(ctx); cerr != nil {
+			err = xerrors.Errorf("commit transaction: %w", cerr)
+		}
+	}()
+
+	report, err = i.InsertTx(ctx, tx, order, records, conf.Copy)
+	if err != nil || conf.Rollback {
+		return report, err
+	}
+	return report, i.UpdateSequences(ctx, tx, order)
+}
+
+// InsertTx вставляет записи таблиц в указанном порядке в уже открытой транзакции.
+// Транзакция не завершается, поэтому после вставки в ней можно выполнять другие запросы.
+// Внешние ключи, разрывающие циклы между таблицами, заполняются после вставки всех таблиц.
+func (i *Inserter) InsertTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	order []schema.Table,
+	records map[string]generate.Records,
+	useCopy bool,
+) (report Report, err error) {
+	deferred := DeferredConstraints(order)
+	if len(deferred) != 0 {
+		if err := i.exec(ctx, tx, SetConstraintsQuery(deferred, "DEFERRED")); err != nil {
+			return report, err
+		}
+	}
+
+	for _, table := range order {
+		tableRecords, ok := records[table.String()]
+		if !ok {
+			continue
+		}
+		tr, err := i.insertTable(ctx, tx, table, tableRecords, useCopy)
+		if err != nil {
+			return report, xerrors.Errorf("insert records to table %q: %w", table, err)
+		}
+		i.log.Info("records inserted",
+			zap.Stringer("table", table),
+			zap.Int("inserted", tr.Inserted),
+			zap.Int("failed", len(tr.Errors)),
+			zap.Int("rejected", tr.Rejected),
+			zap.Strings("enabled_insert_triggers", tr.EnabledInsertTriggers),
+		)
+		report.Tables = append(report.Tables, tr)
+	}
+
+	if err := i.updateDeferred(ctx, tx, order, records, &report); err != nil {
+		return report, xerrors.Errorf("update deferred foreign keys: %w", err)
+	}
+	if len(deferred) != 0 {
+		// отложенные ключи проверяются сразу, чтобы ошибка не возникла только при COMMIT
+		if err := i.exec(ctx, tx, SetConstraintsQuery(deferred, "IMMEDIATE")); err != nil {
+			return report, xerrors.Errorf("check deferred foreign keys: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+func (i *Inserter) insertTable(
+	ctx context.Context,
+	tx pgx.Tx,
+	table schema.Table,
+	records generate.Records,
+	useCopy bool,
+) (report TableReport, err error) {
+	report.Table = table.String()
+	defer func() {
+		if err == nil && report.Inserted != 0 {
+			report.EnabledInsertTriggers = EnabledInsertTriggers(table)
+		}
+	}()
+
+	var negative []generate.Record
+	for _, record := range records.Records {
+		if record.Violates != nil {
+			negative = append(negative, record)
+		}
+	}
+	records = records.Positive()
+	// негативные записи проверяются после вставки обычных, т.к. могут дублировать их значения
+	defer func() {
+		if err == nil && len(negative) != 0 {
+			err = i.insertNegative(ctx, tx, table, negative, &report)
+		}
+	}()
+
+	queries := make([]string, 0, len(records.Records))
+	for _, record := range records.Records {
+		queries = append(queries, Query(table, record))
+	}
+
+	if err := i.exec(ctx, tx, "SAVEPOINT "+tableSavepoint); err != nil {
+		return report, err
+	}
+
+	if useCopy {
+		err = i.copyRecords(ctx, tx, table, records.Records)
+	} else {
+		err = i.insertBatch(ctx, tx, queries)
+	}
+	if err == nil {
+		report.Inserted = len(queries)
+		return report, i.exec(ctx, tx, "RELEASE SAVEPOINT "+tableSavepoint)
+	}
+	i.log.Debug("bulk insert failed, insert records one by one",
+		zap.Stringer("table", table), zap.Error(err))
+
+	if err := i.exec(ctx, tx, "ROLLBACK TO SAVEPOINT "+tableSavepoint); err != nil {
+		return report, err
+	}
+
+	for idx, query := range queries {
+		if err := i.exec(ctx, tx, "SAVEPOINT "+rowSavepoint); err != nil {
+			return report, err
+		}
+		if _, err := tx.Exec(ctx, query); err != nil {
+			report.Errors = append(report.Errors, RowError{
+				Table:  table.String(),
+				RowNum: idx,
+				Record: records.Records[idx],
+				Err: db.Error{
+					Err:     err,
+					Message: "insert record",
+					Query:   query,
+				},
+			})
+			if err := i.exec(ctx, tx, "ROLLBACK TO SAVEPOINT "+rowSavepoint); err != nil {
+				return report, err
+			}
+			continue
+		}
+		report.Inserted++
+		if err := i.exec(ctx, tx, "RELEASE SAVEPOINT "+rowSavepoint); err != nil {
+			return report, err
+		}
+	}
+
+	return report, i.exec(ctx, tx, "RELEASE SAVEPOINT "+tableSavepoint)
+}
+
+func (i *Inserter) insertBatch(ctx context.Context, tx pgx.Tx, queries []string) (err error) {
+	var batch pgx.Batch
+	for _, query := range queries {
+		batch.Queue(query)
+	}
+
+	br := tx.SendBatch(ctx, &batch)
+	defer func() {
+		if cerr := br.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	for range queries {
+		if _, err := br.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Inserter) exec(ctx context.Context, tx pgx.Tx, query string) error {
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return db.Error{
+			Err:     err,
+			Message: "exec",
+			Query:   query,
+		}
+	}
+	return nil
+}
+
+// Query возвращает запрос вставки записи в таблицу.
+// Значения записи должны быть SQL литералами.
+func Query(table schema.Table, record generate.Record) string {
+	tableName := pgx.Identifier{table.Name.Schema, table.Name.Name}.Sanitize()
+	if len(record.Columns) == 0 {
+		return fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", tableName)
+	}
+
+	cols := make([]string, 0, len(record.Columns))
+	for _, col := range record.Columns {
+		cols = append(cols, pgx.Identifier{col}.Sanitize())
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		tableName,
+		strings.Join(cols, ", "),
+		strings.Join(record.Values, ", "),
+	)
+}
@@ -0,0 +1,61 @@
+package insert
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Feresey/mtest/db"
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestQuery(t *testing.T) {
+	table := schema.Table{
+		Name: schema.Identifier{Schema: "test", Name: "users"},
+	}
+
+	tests := []struct {
+		name   string
+		record generate.Record
+		want   string
+	}{
+		{
+			name: "values",
+			record: generate.Record{
+				Columns: []string{"id", "name"},
+				Values:  []string{"1", "'NaN'::REAL"},
+			},
+			want: `INSERT INTO "test"."users" ("id", "name") VALUES (1, 'NaN'::REAL)`,
+		},
+		{
+			name:   "empty",
+			record: generate.Record{},
+			want:   `INSERT INTO "test"."users" DEFAULT VALUES`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Query(table, tt.record))
+		})
+	}
+}
+
+func TestRowErrorPartial(t *testing.T) {
+	partial := generate.Record{Columns: []string{"id"}, Values: []string{"NULL"}}
+	err := RowError{
+		Table:  "test.users",
+		RowNum: 3,
+		Record: generate.Record{
+			Columns: []string{"id", "name"},
+			Values:  []string{"NULL", "''"},
+			Partial: &partial,
+		},
+		Err: db.Error{Err: errors.New("not null violation"), Message: "insert record"},
+	}
+	require.Equal(t,
+		`table "test.users", record 3, partial record (id=NULL): insert record: not null violation`,
+		err.Error())
+}
@@ -0,0 +1,83 @@
+package insert
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/db"
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+const negativeSavepoint = "mtest_negative"
+
+// violationCodes содержит SQLSTATE ошибок для каждого вида нарушения.
+var violationCodes = map[generate.ViolationKind]string{
+	generate.ViolationNotNull:      "23502", // not_null_violation
+	generate.ViolationLength:       "22001", // string_data_right_truncation
+	generate.ViolationNumericRange: "22003", // numeric_value_out_of_range
+	generate.ViolationUnique:       "23505", // unique_violation
+	generate.ViolationForeignKey:   "23503", // foreign_key_violation
+	generate.ViolationEnum:         "22P02", // invalid_text_representation
+	generate.ViolationCheck:        "23514", // check_violation
+	generate.ViolationPartition:    "23514", // check_violation, no partition of relation found for row
+}
+
+// CheckViolation проверяет, что запись отклонена базой данных из-за ожидаемого нарушения.
+// Для нарушений именованных ограничений имя ограничения из ошибки должно совпадать с ожидаемым.
+func CheckViolation(v generate.Violation, err error) error {
+	if err == nil {
+		return xerrors.Errorf("record is not rejected, expected %s violation", v)
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return xerrors.Errorf("expected %s violation, got: %w", v, err)
+	}
+	if code, ok := violationCodes[v.Kind]; ok && pgErr.Code != code {
+		return xerrors.Errorf("expected %s violation, got: %w", v, err)
+	}
+	if v.Constraint != "" && pgErr.ConstraintName != v.Constraint {
+		return xerrors.Errorf("expected %s violation, got violation of %q: %w", v, pgErr.ConstraintName, err)
+	}
+	return nil
+}
+
+// insertNegative вставляет негативные записи по одной и проверяет, что база данных их отклоняет.
+// Изменения каждой записи откатываются, даже если запись была вставлена.
+func (i *Inserter) insertNegative(
+	ctx context.Context,
+	tx pgx.Tx,
+	table schema.Table,
+	records []generate.Record,
+	report *TableReport,
+) error {
+	for idx, record := range records {
+		query := Query(table, record)
+		if err := i.exec(ctx, tx, "SAVEPOINT "+negativeSavepoint); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, query)
+		if err := i.exec(ctx, tx, "ROLLBACK TO SAVEPOINT "+negativeSavepoint); err != nil {
+			return err
+		}
+		if err := CheckViolation(*record.Violates, err); err != nil {
+			report.NegativeErrors = append(report.NegativeErrors, RowError{
+				Table:  table.String(),
+				RowNum: idx,
+				Record: record,
+				Err: db.Error{
+					Err:     err,
+					Message: "negative record",
+					Query:   query,
+				},
+			})
+			continue
+		}
+		report.Rejected++
+	}
+	return nil
+}
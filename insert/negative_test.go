@@ -0,0 +1,74 @@
+package insert
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/generate"
+)
+
+func TestCheckViolation(t *testing.T) {
+	unique := generate.Violation{Kind: generate.ViolationUnique, Constraint: "users_pkey"}
+	notNull := generate.Violation{Kind: generate.ViolationNotNull, Column: "id"}
+
+	tests := []struct {
+		name    string
+		v       generate.Violation
+		err     error
+		wantErr bool
+	}{
+		{
+			name: "expected violation",
+			v:    unique,
+			err:  xerrors.Errorf("exec: %w", &pgconn.PgError{Code: "23505", ConstraintName: "users_pkey"}),
+		},
+		{
+			name: "unnamed constraint",
+			v:    notNull,
+			err:  &pgconn.PgError{Code: "23502"},
+		},
+		{
+			name: "check constraint",
+			v:    generate.Violation{Kind: generate.ViolationCheck, Column: "age", Constraint: "users_age_check"},
+			err:  &pgconn.PgError{Code: "23514", ConstraintName: "users_age_check"},
+		},
+		{
+			name:    "not rejected",
+			v:       unique,
+			wantErr: true,
+		},
+		{
+			name:    "other constraint",
+			v:       unique,
+			err:     &pgconn.PgError{Code: "23505", ConstraintName: "users_name_key"},
+			wantErr: true,
+		},
+		{
+			name:    "other code",
+			v:       notNull,
+			err:     &pgconn.PgError{Code: "23503", ConstraintName: "orders_user_id_fkey"},
+			wantErr: true,
+		},
+		{
+			name:    "not a database error",
+			v:       notNull,
+			err:     errors.New("connection reset"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckViolation(tt.v, tt.err)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package insert
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+// InsertLevels вставляет записи таблиц по уровням графа зависимостей.
+// Таблицы одного уровня вставляются одновременно, не более conf.Parallel таблиц сразу,
+// каждая таблица в отдельной транзакции. Поэтому соединение Inserter должно допускать
+// одновременное использование, например быть пулом соединений.
+// Внешние ключи, разрывающие циклы между таблицами, и последовательности колонок
+// обновляются после вставки всех уровней.
+//
+// Откатить записи, вставленные в разных транзакциях, нельзя, как и отложить проверку
+// DEFERRABLE внешних ключей до вставки таблиц следующих уровней.
+// В этих случаях записи вставляются последовательно в одной транзакции.
+func (i *Inserter) InsertLevels(
+	ctx context.Context,
+	levels [][]schema.Table,
+	records map[string]generate.Records,
+	conf Config,
+) (report Report, err error) {
+	var order []schema.Table
+	for _, level := range levels {
+		order = append(order, level...)
+	}
+
+	switch {
+	case conf.Parallel <= 1:
+		return i.Insert(ctx, order, records, conf)
+	case conf.Rollback:
+		i.log.Warn("records are inserted sequentially, because parallel insert can not be rolled back")
+		return i.Insert(ctx, order, records, conf)
+	case len(DeferredConstraints(order)) != 0:
+		i.log.Warn("records are inserted sequentially, because deferrable foreign keys break a cycle",
+			zap.Strings("constraints", DeferredConstraints(order)))
+		return i.Insert(ctx, order, records, conf)
+	}
+
+	for levelNum, level := range levels {
+		reports := make([]*TableReport, len(level))
+		group, groupCtx := errgroup.WithContext(ctx)
+		group.SetLimit(conf.Parallel)
+		for idx, table := range level {
+			idx, table := idx, table
+			tableRecords, ok := records[table.String()]
+			if !ok {
+				continue
+			}
+			group.Go(func() error {
+				tr, err := i.insertTableTx(groupCtx, table, tableRecords, conf.Copy)
+				if err != nil {
+					return xerrors.Errorf("insert records to table %q: %w", table, err)
+				}
+				i.log.Info("records inserted",
+					zap.Stringer("table", table),
+					zap.Int("level", levelNum),
+					zap.Int("inserted", tr.Inserted),
+					zap.Int("failed", len(tr.Errors)),
+					zap.Int("rejected", tr.Rejected),
+					zap.Strings("enabled_insert_triggers", tr.EnabledInsertTriggers),
+				)
+				reports[idx] = &tr
+				return nil
+			})
+		}
+		if err := group.Wait(); err != nil {
+			return report, err
+		}
+		for _, tr := range reports {
+			if tr != nil {
+				report.Tables = append(report.Tables, *tr)
+			}
+		}
+	}
+
+	err = i.inTx(ctx, func(tx pgx.Tx) error {
+		if err := i.updateDeferred(ctx, tx, order, records, &report); err != nil {
+			return xerrors.Errorf("update deferred foreign keys: %w", err)
+		}
+		return i.UpdateSequences(ctx, tx, order)
+	})
+	return report, err
+}
+
+// insertTableTx вставляет записи таблицы в отдельной транзакции.
+func (i *Inserter) insertTableTx(
+	ctx context.Context,
+	table schema.Table,
+	records generate.Records,
+	useCopy bool,
+) (report TableReport, err error) {
+	err = i.inTx(ctx, func(tx pgx.Tx) error {
+		report, err = i.insertTable(ctx, tx, table, records, useCopy)
+		return err
+	})
+	return report, err
+}
+
+// inTx выполняет функцию в транзакции. Транзакция фиксируется, если функция завершилась без ошибки.
+func (i *Inserter) inTx(ctx context.Context, f func(tx pgx.Tx) error) (err error) {
+	tx, err := i.conn.Begin(ctx)
+	if err != nil {
+		return xerrors.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rerr := tx.Rollback(ctx); rerr != nil {
+				err = xerrors.Errorf("rollback transaction: %w", rerr)
+			}
+			return
+		}
+		if cerr := tx.Commit(ctx); cerr != nil {
+			err = xerrors.Errorf("commit transaction: %w", cerr)
+		}
+	}()
+	return f(tx)
+}
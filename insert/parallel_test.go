@@ -0,0 +1,293 @@
+package insert
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+// fakeConn открывает транзакции, которые только запоминают выполненные запросы.
+type fakeConn struct {
+	mu  sync.Mutex
+	txs []*fakeTx
+	// начала и завершения транзакций в порядке выполнения
+	events []fakeEvent
+	// запросы, содержащие эту подстроку, завершаются ошибкой
+	fail string
+}
+
+type fakeEvent struct {
+	tx   *fakeTx
+	kind string
+}
+
+func (c *fakeConn) Begin(context.Context) (pgx.Tx, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx := &fakeTx{conn: c}
+	c.txs = append(c.txs, tx)
+	c.events = append(c.events, fakeEvent{tx: tx, kind: "begin"})
+	return tx, nil
+}
+
+// eventIndex возвращает номер события транзакции.
+func (c *fakeConn) eventIndex(tx *fakeTx, kind string) int {
+	return slices.IndexFunc(c.events, func(e fakeEvent) bool { return e.tx == tx && e.kind == kind })
+}
+
+// tableTx возвращает транзакцию, в которой вставлялись записи таблицы.
+func (c *fakeConn) tableTx(table schema.Table) *fakeTx {
+	prefix := "INSERT INTO " + pgx.Identifier{table.Name.Schema, table.Name.Name}.Sanitize()
+	for _, tx := range c.txs {
+		if slices.ContainsFunc(tx.queries, func(q string) bool { return strings.HasPrefix(q, prefix) }) {
+			return tx
+		}
+	}
+	return nil
+}
+
+// fakeTx реализует только методы pgx.Tx, которые использует Inserter.
+type fakeTx struct {
+	pgx.Tx
+	conn    *fakeConn
+	queries []string
+	// commit или rollback
+	status string
+}
+
+func (tx *fakeTx) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	tx.conn.mu.Lock()
+	defer tx.conn.mu.Unlock()
+	tx.queries = append(tx.queries, sql)
+	if tx.conn.fail != "" && strings.Contains(sql, tx.conn.fail) {
+		return pgconn.CommandTag{}, errors.New("fake error")
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+// SendBatch завершается ошибкой, т.к. запросы пакета недоступны снаружи pgx.
+// Поэтому записи вставляются по одной и видны в запросах транзакции.
+func (tx *fakeTx) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	return fakeBatchResults{}
+}
+
+func (tx *fakeTx) Commit(context.Context) error { return tx.finish("commit") }
+
+func (tx *fakeTx) Rollback(context.Context) error { return tx.finish("rollback") }
+
+func (tx *fakeTx) finish(kind string) error {
+	tx.conn.mu.Lock()
+	defer tx.conn.mu.Unlock()
+	tx.status = kind
+	tx.conn.events = append(tx.conn.events, fakeEvent{tx: tx, kind: kind})
+	return nil
+}
+
+type fakeBatchResults struct {
+	pgx.BatchResults
+}
+
+func (fakeBatchResults) Exec() (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, errors.New("batch is not supported")
+}
+
+func (fakeBatchResults) Close() error { return nil }
+
+// parallelTestTables возвращает таблицы a и b первого уровня и таблицу c второго уровня.
+// Внешний ключ c.a_id заполняется после вставки всех уровней.
+func parallelTestTables() (a, b, c schema.Table, records map[string]generate.Records) {
+	table := func(name string) schema.Table {
+		return schema.Table{Name: schema.Identifier{Schema: "test", Name: name}}
+	}
+	a, b, c = table("a"), table("b"), table("c")
+	a.Columns = map[string]schema.Column{
+		"id": {Name: "id", Attributes: schema.ColumnAttributes{
+			Identity: schema.IdentityByDefault,
+			Sequence: &schema.Sequence{Name: schema.Identifier{Schema: "test", Name: "a_id_seq"}, Increment: 1},
+		}},
+	}
+
+	record := func(values ...string) generate.Record {
+		return generate.Record{Columns: []string{"id"}, Values: values}
+	}
+	deferred := generate.Record{
+		Columns:  []string{"id", "a_id"},
+		Values:   []string{"1", "NULL"},
+		Deferred: &generate.Record{Columns: []string{"a_id"}, Values: []string{"1"}},
+	}
+	records = map[string]generate.Records{
+		a.String(): {Records: []generate.Record{record("1"), record("2")}},
+		b.String(): {Records: []generate.Record{record("1")}},
+		c.String(): {Records: []generate.Record{deferred}},
+	}
+	return a, b, c, records
+}
+
+func TestInsertLevels(t *testing.T) {
+	r := require.New(t)
+	a, b, c, records := parallelTestTables()
+	// у таблицы нет записей, поэтому она не вставляется и не попадает в отчет
+	empty := schema.Table{Name: schema.Identifier{Schema: "test", Name: "empty"}}
+	levels := [][]schema.Table{{a, b, empty}, {c}}
+
+	conn := &fakeConn{}
+	report, err := NewInserter(conn, zap.NewNop()).InsertLevels(context.Background(), levels, records, Config{Parallel: 2})
+	r.NoError(err)
+
+	// отчеты таблиц идут в порядке уровней, а не в порядке завершения транзакций
+	r.Len(report.Tables, 3)
+	for idx, want := range []struct {
+		table    schema.Table
+		inserted int
+	}{{a, 2}, {b, 1}, {c, 1}} {
+		r.Equal(want.table.String(), report.Tables[idx].Table)
+		r.Equal(want.inserted, report.Tables[idx].Inserted)
+		r.Empty(report.Tables[idx].Errors)
+	}
+
+	// по транзакции на таблицу и одна для отложенных ключей и последовательностей
+	r.Len(conn.txs, 4)
+	txA, txB, txC := conn.tableTx(a), conn.tableTx(b), conn.tableTx(c)
+	r.NotNil(txA)
+	r.NotNil(txB)
+	r.NotNil(txC)
+	for _, tx := range conn.txs {
+		r.Equal("commit", tx.status)
+	}
+	// таблицы следующего уровня вставляются после фиксации предыдущего
+	r.Greater(conn.eventIndex(txC, "begin"), conn.eventIndex(txA, "commit"))
+	r.Greater(conn.eventIndex(txC, "begin"), conn.eventIndex(txB, "commit"))
+
+	final := conn.txs[3]
+	r.Greater(conn.eventIndex(final, "begin"), conn.eventIndex(txC, "commit"))
+	r.Contains(final.queries, UpdateQuery(c, records[c.String()].Records[0]))
+	for _, query := range SequenceQueries([]schema.Table{a}) {
+		r.Contains(final.queries, query)
+	}
+	r.Less(
+		slices.Index(final.queries, UpdateQuery(c, records[c.String()].Records[0])),
+		slices.Index(final.queries, SequenceQueries([]schema.Table{a})[0]),
+		"sequences are updated after deferred foreign keys",
+	)
+}
+
+func TestInsertLevelsErrors(t *testing.T) {
+	r := require.New(t)
+	a, b, c, records := parallelTestTables()
+	levels := [][]schema.Table{{a, b}, {c}}
+
+	// ошибка обновления отложенного ключа попадает в отчет таблицы, а транзакция фиксируется
+	conn := &fakeConn{fail: "UPDATE"}
+	report, err := NewInserter(conn, zap.NewNop()).InsertLevels(context.Background(), levels, records, Config{Parallel: 2})
+	r.NoError(err)
+	r.Len(report.Tables, 3)
+	r.Equal(c.String(), report.Tables[2].Table)
+	r.Len(report.Tables[2].Errors, 1)
+	r.Equal("update deferred foreign keys", report.Tables[2].Errors[0].Err.Message)
+	r.Len(report.Errors(), 1)
+	r.Equal("commit", conn.txs[len(conn.txs)-1].status)
+
+	// ошибки записей попадают в отчет своей таблицы и не останавливают вставку
+	conn = &fakeConn{fail: `INSERT INTO "test"."b"`}
+	report, err = NewInserter(conn, zap.NewNop()).InsertLevels(context.Background(), levels, records, Config{Parallel: 2})
+	r.NoError(err)
+	r.Len(report.Tables, 3)
+	r.Empty(report.Tables[0].Errors)
+	r.Len(report.Tables[1].Errors, 1)
+	r.Equal(b.String(), report.Tables[1].Errors[0].Table)
+	r.Equal(0, report.Tables[1].Inserted)
+
+	// ошибка вставки таблицы откатывает транзакции уровня, следующие уровни не вставляются
+	conn = &fakeConn{fail: "RELEASE SAVEPOINT " + tableSavepoint}
+	_, err = NewInserter(conn, zap.NewNop()).InsertLevels(context.Background(), levels, records, Config{Parallel: 2})
+	r.Error(err)
+	r.Len(conn.txs, 2)
+	for _, tx := range conn.txs {
+		r.Equal("rollback", tx.status)
+	}
+	r.Nil(conn.tableTx(c))
+}
+
+func TestInsertLevelsSequential(t *testing.T) {
+	a, b, c, records := parallelTestTables()
+	deferrable := c
+	deferrable.ForeignKeys = map[string]schema.ForeignKey{
+		"c_b_id_fkey": {
+			Constraint: &schema.Constraint{
+				Name: "c_b_id_fkey", Type: schema.ConstraintTypeFK,
+				Definition: "FOREIGN KEY (b_id) REFERENCES test.b(id) DEFERRABLE",
+			},
+			ReferenceTable: b.String(),
+		},
+	}
+
+	tests := []struct {
+		name   string
+		levels [][]schema.Table
+		conf   Config
+		status string
+		// запрос, с которого начинается транзакция
+		first string
+	}{
+		{
+			name:   "single table at once",
+			levels: [][]schema.Table{{a, b}, {c}},
+			conf:   Config{Parallel: 1},
+			status: "commit",
+			first:  "SAVEPOINT " + tableSavepoint,
+		},
+		{
+			name:   "rollback",
+			levels: [][]schema.Table{{a, b}, {c}},
+			conf:   Config{Parallel: 2, Rollback: true},
+			status: "rollback",
+			first:  "SAVEPOINT " + tableSavepoint,
+		},
+		{
+			// c вставляется раньше b, поэтому проверка ключа откладывается до конца транзакции
+			name:   "deferrable cycle",
+			levels: [][]schema.Table{{a, deferrable}, {b}},
+			conf:   Config{Parallel: 2},
+			status: "commit",
+			first:  `SET CONSTRAINTS "test"."c_b_id_fkey" DEFERRED`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			conn := &fakeConn{}
+			report, err := NewInserter(conn, zap.NewNop()).InsertLevels(context.Background(), tt.levels, records, tt.conf)
+			r.NoError(err)
+			r.Len(report.Tables, 3)
+
+			// все таблицы вставляются в одной транзакции в порядке уровней
+			r.Len(conn.txs, 1)
+			tx := conn.txs[0]
+			r.Equal(tt.status, tx.status)
+			r.Equal(tt.first, tx.queries[0])
+			var order []string
+			for _, level := range tt.levels {
+				for _, table := range level {
+					order = append(order, table.String())
+				}
+			}
+			var got []string
+			for _, tr := range report.Tables {
+				got = append(got, tr.Table)
+			}
+			r.Equal(order, got)
+		})
+	}
+}
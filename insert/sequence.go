@@ -0,0 +1,63 @@
+package insert
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+// SequenceQueries возвращает запросы, которые сдвигают последовательности колонок за вставленные значения,
+// чтобы следующие значения последовательностей не совпали со вставленными записями.
+// Последовательность не сдвигается назад, если ее текущее значение уже дальше вставленных.
+func SequenceQueries(order []schema.Table) []string {
+	var res []string
+	for _, table := range order {
+		colNames := maps.Keys(table.Columns)
+		sort.Strings(colNames)
+		for _, colName := range colNames {
+			col := table.Columns[colName]
+			seq := col.Attributes.Sequence
+			// значения GENERATED ALWAYS колонок берутся только из последовательности
+			if seq == nil || col.Attributes.IsGeneratedAlways() {
+				continue
+			}
+			agg, cmp := "max", ">="
+			if seq.Increment < 0 {
+				agg, cmp = "min", "<="
+			}
+			seqName := pgx.Identifier{seq.Name.Schema, seq.Name.Name}.Sanitize()
+			value := fmt.Sprintf("%s(%s)", agg, pgx.Identifier{col.Name}.Sanitize())
+			res = append(res, fmt.Sprintf(
+				"SELECT setval('%s'::REGCLASS, %s) FROM %s HAVING %s %s (SELECT last_value FROM %s)",
+				strings.ReplaceAll(seqName, "'", "''"), value,
+				pgx.Identifier{table.Name.Schema, table.Name.Name}.Sanitize(),
+				value, cmp, seqName,
+			))
+		}
+	}
+	return res
+}
+
+// UpdateSequences сдвигает последовательности колонок таблиц за вставленные значения.
+// Изменения последовательностей не откатываются вместе с транзакцией,
+// поэтому при откате вставленных записей последовательности обновлять не нужно.
+func (i *Inserter) UpdateSequences(ctx context.Context, tx pgx.Tx, order []schema.Table) error {
+	queries := SequenceQueries(order)
+	for _, query := range queries {
+		if err := i.exec(ctx, tx, query); err != nil {
+			return xerrors.Errorf("update sequence: %w", err)
+		}
+	}
+	if len(queries) != 0 {
+		i.log.Info("sequences updated", zap.Int("sequences", len(queries)))
+	}
+	return nil
+}
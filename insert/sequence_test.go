@@ -0,0 +1,39 @@
+package insert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestSequenceQueries(t *testing.T) {
+	sequence := func(name string, increment int64) *schema.Sequence {
+		return &schema.Sequence{Name: schema.Identifier{Schema: "test", Name: name}, Increment: increment}
+	}
+	items := schema.Table{
+		Name: schema.Identifier{Schema: "test", Name: "items"},
+		Columns: map[string]schema.Column{
+			"id": {Name: "id", Attributes: schema.ColumnAttributes{
+				Identity: schema.IdentityAlways,
+				Sequence: sequence("items_id_seq", 1),
+			}},
+			"num": {Name: "num", Attributes: schema.ColumnAttributes{
+				Identity: schema.IdentityByDefault,
+				Sequence: sequence("items_num_seq", 1),
+			}},
+			"Down": {Name: "Down", Attributes: schema.ColumnAttributes{
+				Sequence: sequence("it's_seq", -1),
+			}},
+			"title": {Name: "title"},
+		},
+	}
+
+	require.Equal(t, []string{
+		`SELECT setval('"test"."it''s_seq"'::REGCLASS, min("Down")) FROM "test"."items" ` +
+			`HAVING min("Down") <= (SELECT last_value FROM "test"."it's_seq")`,
+		`SELECT setval('"test"."items_num_seq"'::REGCLASS, max("num")) FROM "test"."items" ` +
+			`HAVING max("num") >= (SELECT last_value FROM "test"."items_num_seq")`,
+	}, SequenceQueries([]schema.Table{items}))
+}
@@ -0,0 +1,173 @@
+package insert
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/db"
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+// Stream загружает записи в базу данных по мере генерации в рамках одной транзакции.
+// Функция write получает generate.RecordWriter, который загружает записи с помощью COPY.
+// В отличие от Insert, ошибочные записи не ищутся: первая ошибка прерывает загрузку.
+func (i *Inserter) Stream(
+	ctx context.Context,
+	order []schema.Table,
+	conf Config,
+	write func(w generate.RecordWriter) error,
+) (err error) {
+	tx, err := i.conn.Begin(ctx)
+	if err != nil {
+		return xerrors.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil || conf.Rollback {
+			i.log.Info("rollback inserted records")
+			if rerr := tx.Rollback(ctx); rerr != nil {
+				err = xerrors.Errorf("rollback transaction: %w", rerr)
+			}
+			return
+		}
+		if cerr := tx.Commit(ctx); cerr != nil {
+			err = xerrors.Errorf("commit transaction: %w", cerr)
+		}
+	}()
+
+	deferred := DeferredConstraints(order)
+	if len(deferred) != 0 {
+		if err := i.exec(ctx, tx, SetConstraintsQuery(deferred, "DEFERRED")); err != nil {
+			return err
+		}
+	}
+
+	w := &StreamWriter{ctx: ctx, tx: tx, log: i.log}
+	if err := write(w); err != nil {
+		return errors.Join(err, w.finish())
+	}
+	if err := w.finish(); err != nil {
+		return err
+	}
+
+	if len(deferred) != 0 {
+		if err := i.exec(ctx, tx, SetConstraintsQuery(deferred, "IMMEDIATE")); err != nil {
+			return xerrors.Errorf("check deferred foreign keys: %w", err)
+		}
+	}
+	if conf.Rollback {
+		return nil
+	}
+	return i.UpdateSequences(ctx, tx, order)
+}
+
+// StreamWriter загружает записи таблиц с помощью COPY, не накапливая их в памяти.
+// Записи подряд с одинаковым набором колонок загружаются одним COPY.
+type StreamWriter struct {
+	ctx context.Context
+	tx  pgx.Tx
+	log *zap.Logger
+
+	// текущий COPY
+	copy *copyStream
+	// количество загруженных записей текущей таблицы
+	inserted int
+}
+
+// copyStream передает данные в COPY FROM STDIN, выполняемый в отдельной горутине.
+type copyStream struct {
+	query   string
+	columns []string
+	pw      *io.PipeWriter
+	bw      *bufio.Writer
+	done    chan error
+}
+
+func (w *StreamWriter) BeginTable(schema.Table) error {
+	w.inserted = 0
+	return nil
+}
+
+func (w *StreamWriter) WriteRecord(table schema.Table, record generate.Record) error {
+	if len(record.Columns) == 0 {
+		if err := w.finish(); err != nil {
+			return err
+		}
+		query := Query(table, record)
+		if _, err := w.tx.Exec(w.ctx, query); err != nil {
+			return db.Error{Err: err, Message: "insert record", Query: query}
+		}
+		w.inserted++
+		return nil
+	}
+
+	if w.copy != nil && !slices.Equal(w.copy.columns, record.Columns) {
+		if err := w.finish(); err != nil {
+			return err
+		}
+	}
+	if w.copy == nil {
+		w.start(table, record.Columns)
+	}
+	if err := WriteCopyRecord(w.copy.bw, record); err != nil {
+		return xerrors.Errorf("write record %d: %w", w.inserted, errors.Join(err, w.finish()))
+	}
+	w.inserted++
+	return nil
+}
+
+func (w *StreamWriter) EndTable(table schema.Table) error {
+	if err := w.finish(); err != nil {
+		return xerrors.Errorf("copy records to table %q: %w", table, err)
+	}
+	var triggers []string
+	if w.inserted != 0 {
+		triggers = EnabledInsertTriggers(table)
+	}
+	w.log.Info("records inserted",
+		zap.Stringer("table", table),
+		zap.Int("inserted", w.inserted),
+		zap.Strings("enabled_insert_triggers", triggers),
+	)
+	return nil
+}
+
+func (w *StreamWriter) start(table schema.Table, columns []string) {
+	pr, pw := io.Pipe()
+	stream := &copyStream{
+		query:   CopyQuery(table, columns),
+		columns: slices.Clone(columns),
+		pw:      pw,
+		bw:      bufio.NewWriter(pw),
+		done:    make(chan error, 1),
+	}
+	go func() {
+		_, err := w.tx.Conn().PgConn().CopyFrom(w.ctx, pr, stream.query)
+		// если COPY завершился с ошибкой, то запись в канал тоже должна завершиться
+		pr.CloseWithError(err)
+		stream.done <- err
+	}()
+	w.copy = stream
+}
+
+// finish завершает текущий COPY и возвращает его ошибку.
+func (w *StreamWriter) finish() error {
+	stream := w.copy
+	if stream == nil {
+		return nil
+	}
+	w.copy = nil
+	flushErr := stream.bw.Flush()
+	stream.pw.Close()
+	if err := <-stream.done; err != nil {
+		return db.Error{Err: err, Message: "copy records", Query: stream.query}
+	}
+	return flushErr
+}
@@ -0,0 +1,21 @@
+package insert
+
+import (
+	"sort"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+// EnabledInsertTriggers возвращает имена включенных триггеров таблицы на событие INSERT.
+// Это только список из схемы: условия WHEN и триггеры секций не учитываются,
+// поэтому триггер из списка мог не сработать ни на одной записи.
+func EnabledInsertTriggers(table schema.Table) []string {
+	var res []string
+	for name, trigger := range table.Triggers {
+		if trigger.FiresOn(schema.TriggerEventInsert) {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,29 @@
+package insert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestEnabledInsertTriggers(t *testing.T) {
+	events := func(events ...schema.TriggerEvent) []schema.TriggerEvent { return events }
+	items := schema.Table{
+		Name: schema.Identifier{Schema: "test", Name: "items"},
+		Triggers: map[string]schema.Trigger{
+			"b_audit": {Name: "b_audit", Timing: schema.TriggerTimingAfter,
+				Events: events(schema.TriggerEventInsert, schema.TriggerEventUpdate)},
+			"a_check": {Name: "a_check", Timing: schema.TriggerTimingBefore, ForEachRow: true,
+				Events: events(schema.TriggerEventInsert), Condition: "new.id > 0"},
+			"on_delete": {Name: "on_delete", Timing: schema.TriggerTimingAfter,
+				Events: events(schema.TriggerEventDelete)},
+			"disabled": {Name: "disabled", Timing: schema.TriggerTimingAfter,
+				Events: events(schema.TriggerEventInsert), Disabled: true},
+		},
+	}
+
+	require.Equal(t, []string{"a_check", "b_audit"}, EnabledInsertTriggers(items))
+	require.Empty(t, EnabledInsertTriggers(schema.Table{}))
+}
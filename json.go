@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/generate"
+)
+
+// JSONConverter сохраняет записи в формате NDJSON: один JSON объект на запись.
+// Ключи объекта - имена колонок. Колонки, отсутствующие в записи, не попадают в объект,
+// NULL значения сохраняются как null.
+// Все остальные значения, в том числе числа и boolean, сохраняются JSON строками
+// в текстовом представлении PostgreSQL, как в COPY: {"id":"1","active":"True"}.
+type JSONConverter struct{}
+
+func (w *JSONConverter) WriteRecords(out io.Writer, records generate.Records) error {
+	bw := bufio.NewWriter(out)
+	enc := json.NewEncoder(bw)
+	for rowNum, record := range records.Records {
+		obj, err := w.recordToMap(record)
+		if err != nil {
+			return xerrors.Errorf("record %d: %w", rowNum, err)
+		}
+		if err := enc.Encode(obj); err != nil {
+			return xerrors.Errorf("encode record %d: %w", rowNum, err)
+		}
+	}
+	return bw.Flush()
+}
+
+func (w *JSONConverter) recordToMap(record generate.Record) (map[string]*string, error) {
+	res := make(map[string]*string, len(record.Columns))
+	for idx, colName := range record.Columns {
+		value, isNull, err := generate.ParseLiteral(record.Values[idx])
+		if err != nil {
+			return nil, xerrors.Errorf("column %q: %w", colName, err)
+		}
+		if isNull {
+			res[colName] = nil
+			continue
+		}
+		res[colName] = &value
+	}
+	return res, nil
+}
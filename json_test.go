@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Feresey/mtest/generate"
+)
+
+func TestJSONConverter(t *testing.T) {
+	r := require.New(t)
+	records := generate.Records{Records: []generate.Record{
+		// колонка name не задана в записи и не попадает в объект
+		{Columns: []string{"active", "id"}, Values: []string{"True", "1"}},
+		{Columns: []string{"active", "id", "name"}, Values: []string{"NULL", "2", "'it''s'"}},
+		{Columns: []string{"id", "score"}, Values: []string{"3", "'NaN'::REAL"}},
+	}}
+
+	var conv JSONConverter
+	var buf bytes.Buffer
+	r.NoError(conv.WriteRecords(&buf, records))
+	r.Equal(`{"active":"True","id":"1"}`+"\n"+
+		`{"active":null,"id":"2","name":"it's"}`+"\n"+
+		`{"id":"3","score":"NaN"}`+"\n", buf.String())
+
+	obj, err := conv.recordToMap(records.Records[0])
+	r.NoError(err)
+	r.NotContains(obj, "name")
+
+	obj, err = conv.recordToMap(records.Records[1])
+	r.NoError(err)
+	r.Contains(obj, "active")
+	r.Nil(obj["active"])
+
+	err = conv.WriteRecords(&buf, generate.Records{Records: []generate.Record{
+		{Columns: []string{"id"}, Values: []string{"'a' || 'b'"}},
+	}})
+	r.ErrorContains(err, `record 0: column "id"`)
+}
+
+func TestJSONConverterComposite(t *testing.T) {
+	r := require.New(t)
+	records := generate.Records{Records: []generate.Record{
+		{Columns: []string{"id", "address"}, Values: []string{"1", "ROW('Main St, 1', NULL, 10)::test.address"}},
+		{Columns: []string{"id", "address"}, Values: []string{"2", "ROW('say \"hi\"', '', 'x')::test.address"}},
+	}}
+
+	var conv JSONConverter
+	var buf bytes.Buffer
+	r.NoError(conv.WriteRecords(&buf, records))
+	r.Equal(`{"address":"(\"Main St, 1\",,10)","id":"1"}`+"\n"+
+		`{"address":"(\"say \"\"hi\"\"\",\"\",x)","id":"2"}`+"\n", buf.String())
+}
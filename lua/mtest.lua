@@ -1,4 +1,4 @@
-local domains = require("domains.lua")
+local domains = require("domains")
 
 local defaultTopElements = 1000
 local defaultStepFloatDomain = 0.1
@@ -21,11 +21,11 @@ local module = {
             char = domains.UUID:new(),
             varchar = domains.UUID:new(),
             text = domains.UUID:new(),
-            date = domains.Time:new(os.time(), defaultTopElements),
-            time = domains.Time:new(os.time(), defaultTopElements),
-            timetz = domains.Time:new(os.time(), defaultTopElements),
-            timestamp = domains.Time:new(os.time(), defaultTopElements),
-            timestamptz = domains.Time:new(os.time(), defaultTopElements),
+            date = domains.Time:new{ top = defaultTopElements },
+            time = domains.Time:new{ top = defaultTopElements },
+            timetz = domains.Time:new{ top = defaultTopElements },
+            timestamp = domains.Time:new{ top = defaultTopElements },
+            timestamptz = domains.Time:new{ top = defaultTopElements },
         }
     },
     -- переопределение доменов для конкретных колонок
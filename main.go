@@ -10,6 +10,7 @@ import (
 
 	"github.com/Feresey/mtest/db"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/urfave/cli/v2"
 )
 
@@ -28,12 +29,14 @@ func newLogger(debug bool) (*zap.Logger, error) {
 type flags struct {
 	configPath *cli.StringFlag
 	debug      *cli.BoolFlag
+	seed       *cli.Int64Flag
 }
 
 func (f *flags) Set() []cli.Flag {
 	return []cli.Flag{
 		f.configPath,
 		f.debug,
+		f.seed,
 	}
 }
 
@@ -52,6 +55,10 @@ func main() {
 			Usage:  "show debug information",
 			Hidden: true,
 		},
+		seed: &cli.Int64Flag{
+			Name:  "seed",
+			Usage: "seed for generated values, the same seed gives the same records",
+		},
 	}
 
 	app := &cli.App{
@@ -61,6 +68,8 @@ func main() {
 		Commands: []*cli.Command{
 			NewParseCommand(f).Command(),
 			NewGenerateCommand(f).Command(),
+			NewDiffCommand(f).Command(),
+			NewMigrateTestCommand(f).Command(),
 		},
 		ExitErrHandler: func(ctx *cli.Context, err error) {
 			if err == nil {
@@ -98,6 +107,9 @@ func NewBase(ctx *cli.Context, f flags) (BaseCommand, error) {
 		return empty, xerrors.Errorf("get config: %w", err)
 	}
 	log.Debug("config readed")
+	if seed, ok := lookupInt64Flag(ctx, f.seed); ok {
+		cnf.Generate.Seed = &seed
+	}
 
 	return BaseCommand{
 		log: log,
@@ -105,6 +117,17 @@ func NewBase(ctx *cli.Context, f flags) (BaseCommand, error) {
 	}, nil
 }
 
+// lookupInt64Flag возвращает значение флага, указанного для команды или для любой из родительских команд.
+// Глобальные флаги объявлены и у приложения, и у команд, поэтому ctx.IsSet видит только флаги команды.
+func lookupInt64Flag(ctx *cli.Context, flag *cli.Int64Flag) (int64, bool) {
+	for _, c := range ctx.Lineage() {
+		if c.IsSet(flag.Name) {
+			return flag.Get(c), true
+		}
+	}
+	return 0, false
+}
+
 func (b *BaseCommand) connectDB(ctx *cli.Context, debug bool) (*pgx.Conn, error) {
 	if debug {
 		b.cnf.DB.SetDebug(true)
@@ -117,3 +140,16 @@ func (b *BaseCommand) connectDB(ctx *cli.Context, debug bool) (*pgx.Conn, error)
 
 	return conn, nil
 }
+
+func (b *BaseCommand) connectPool(ctx *cli.Context, debug bool, maxConns int) (*pgxpool.Pool, error) {
+	if debug {
+		b.cnf.DB.SetDebug(true)
+	}
+	pool, err := db.NewPool(ctx.Context, b.log, b.cnf.DB, int32(maxConns))
+	if err != nil {
+		return nil, xerrors.Errorf("create database connection pool: %w", err)
+	}
+	b.log.Debug("connected to database", zap.Int("max_conns", maxConns))
+
+	return pool, nil
+}
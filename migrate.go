@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/db"
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/migrate"
+	"github.com/Feresey/mtest/schema"
+)
+
+type migrateTestFlags struct {
+	flags
+	schema     SchemaLoaderFlags
+	migration  *cli.StringFlag
+	down       *cli.StringFlag
+	dir        *cli.StringFlag
+	partialDir *cli.StringFlag
+	copy       *cli.BoolFlag
+}
+
+func (f migrateTestFlags) Set() []cli.Flag {
+	return append(
+		f.flags.Set(),
+		f.schema.dumpPath,
+		f.schema.fromSQL,
+		f.migration,
+		f.down,
+		f.dir,
+		f.partialDir,
+		f.copy,
+	)
+}
+
+type MigrateTestCommand struct {
+	flags migrateTestFlags
+	BaseCommand
+
+	schemaLoader SchemaLoader
+}
+
+func NewMigrateTestCommand(f flags) *MigrateTestCommand {
+	return &MigrateTestCommand{
+		flags: migrateTestFlags{
+			flags:  f,
+			schema: NewSchemaLoaderFlags(),
+			migration: &cli.StringFlag{
+				Name:      "migration",
+				Aliases:   []string{"m"},
+				Usage:     "--migration up.sql",
+				TakesFile: true,
+			},
+			down: &cli.StringFlag{
+				Name:      "down",
+				Usage:     "--down down.sql check that down migration reverts schema and records",
+				TakesFile: true,
+			},
+			dir: &cli.StringFlag{
+				Name:      "dir",
+				Usage:     "--dir migrations apply golang-migrate or goose migrations one by one, use a scratch database",
+				TakesFile: true,
+			},
+			partialDir: &cli.StringFlag{
+				Name:      "partial-dir",
+				TakesFile: true,
+				Usage:     "--partial-dir dir with <schema>.<table>.csv files",
+			},
+			copy: &cli.BoolFlag{
+				Name:  "copy",
+				Usage: "load generated records with COPY instead of INSERT",
+			},
+		},
+	}
+}
+
+func (p *MigrateTestCommand) Command() *cli.Command {
+	return &cli.Command{
+		Name:        "migrate-test",
+		Description: "insert generated records, apply migration and rollback all changes",
+		Flags:       p.flags.Set(),
+		Before:      p.Init,
+		Action:      p.Run,
+		After:       p.schemaLoader.Cleanup,
+	}
+}
+
+func (p *MigrateTestCommand) Init(ctx *cli.Context) error {
+	base, err := NewBase(ctx, p.flags.flags)
+	if err != nil {
+		return cli.Exit(err, 2)
+	}
+	p.BaseCommand = base
+	loader, err := NewSchemaLoader(ctx, base, p.flags.flags, p.flags.schema)
+	if err != nil {
+		return err
+	}
+	p.schemaLoader = loader
+	return nil
+}
+
+func (p *MigrateTestCommand) Run(ctx *cli.Context) error {
+	dir := p.flags.dir.Get(ctx)
+	switch {
+	case dir == "" && p.flags.migration.Get(ctx) == "":
+		return cli.Exit("one of --migration or --dir is required", 2)
+	case dir == "":
+		return p.testMigration(ctx)
+	case p.flags.migration.Get(ctx) != "" || p.flags.down.Get(ctx) != "":
+		return cli.Exit("--dir can not be used with --migration or --down", 2)
+	case p.flags.schema.dumpPath.Get(ctx) != "" || p.flags.schema.fromSQL.Get(ctx) != "":
+		return cli.Exit("--dir parses schema from the database before each migration, schema flags are not supported", 2)
+	default:
+		return p.testDir(ctx, dir)
+	}
+}
+
+// testMigration проверяет одну миграцию на схеме, загруженной из базы данных или из файла.
+func (p *MigrateTestCommand) testMigration(ctx *cli.Context) error {
+	migration, err := readMigration(p.flags.migration.Get(ctx))
+	if err != nil {
+		return err
+	}
+	var down *migrate.Migration
+	if path := p.flags.down.Get(ctx); path != "" {
+		m, err := readMigration(path)
+		if err != nil {
+			return err
+		}
+		down = &m
+	}
+
+	s, err := p.schemaLoader.GetSchema(ctx, p.flags.schema)
+	if err != nil {
+		return err
+	}
+	report, err := p.test(ctx, s, migration, down)
+	if err != nil {
+		return err
+	}
+	if report.Failed() {
+		return cli.Exit(xerrors.Errorf("migration %s failed", migration.Name), 1)
+	}
+	return nil
+}
+
+// testDir проверяет миграции директории по очереди.
+// Перед каждой миграцией схема загружается из базы данных заново и для неё генерируются записи,
+// после проверки миграция применяется к базе данных.
+func (p *MigrateTestCommand) testDir(ctx *cli.Context, dir string) error {
+	steps, err := migrate.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	conn, err := p.schemaLoader.Connect(ctx, p.flags.flags)
+	if err != nil {
+		return cli.Exit(err, 3)
+	}
+
+	for _, step := range steps {
+		p.log.Info("test migration", zap.Int64("version", step.Version), zap.String("name", step.Name))
+		fmt.Printf("=== %s\n", step)
+		s, err := p.schemaLoader.parseDB(ctx)
+		if err != nil {
+			return err
+		}
+		report, err := p.test(ctx, s, step.Up, step.Down)
+		if err != nil {
+			return xerrors.Errorf("test migration %s: %w", step, err)
+		}
+		if report.Failed() {
+			return cli.Exit(xerrors.Errorf("migration %s (version %d) failed", step, step.Version), 1)
+		}
+		if err := migrate.NewTester(conn, p.log).Apply(ctx.Context, step.Up); err != nil {
+			return xerrors.Errorf("apply migration %s: %w", step, err)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// test генерирует записи для схемы, проверяет миграцию и выводит отчёт.
+func (p *MigrateTestCommand) test(
+	ctx *cli.Context,
+	s *schema.Schema,
+	migration migrate.Migration,
+	down *migrate.Migration,
+) (migrate.Report, error) {
+	var empty migrate.Report
+	gen, err := generate.New(p.log, s, p.cnf.Generate)
+	if err != nil {
+		return empty, xerrors.Errorf("create generator: %w", err)
+	}
+	partial, err := p.LoadPartialRecords(s, p.flags.partialDir.Get(ctx))
+	if err != nil {
+		return empty, xerrors.Errorf("load partial records: %w", err)
+	}
+	records, warnings := gen.GenerateRecords(partial, nil)
+	if len(warnings) > 0 {
+		p.log.Warn("generate records", zap.Errors("warnings", warnings))
+	}
+
+	conn, err := p.schemaLoader.Connect(ctx, p.flags.flags)
+	if err != nil {
+		return empty, cli.Exit(err, 3)
+	}
+
+	conf := migrate.Config{
+		Copy:   p.cnf.Insert.Copy,
+		Parser: p.cnf.Parser,
+	}
+	if ctx.IsSet(p.flags.copy.Name) {
+		conf.Copy = p.flags.copy.Get(ctx)
+	}
+	report, err := migrate.NewTester(conn, p.log).Test(ctx.Context, gen.Order(), records, migration, down, conf)
+	if err != nil {
+		var pErr db.Error
+		if errors.As(err, &pErr) {
+			p.log.Error(pErr.Pretty())
+		}
+		return empty, xerrors.Errorf("test migration: %w", err)
+	}
+
+	for _, table := range report.Tables {
+		for _, err := range table.InsertErrors {
+			p.log.Warn("insert record failed", zap.Error(err))
+		}
+	}
+	if err := report.WriteText(os.Stdout); err != nil {
+		return empty, err
+	}
+	for _, migrationErr := range []*migrate.Error{report.Error, report.DownError} {
+		if migrationErr != nil {
+			p.log.Debug(migrationErr.Err.Pretty())
+		}
+	}
+	return report, nil
+}
+
+func readMigration(path string) (migrate.Migration, error) {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return migrate.Migration{}, xerrors.Errorf("read migration: %w", err)
+	}
+	return migrate.Migration{Name: path, SQL: string(script)}, nil
+}
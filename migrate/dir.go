@@ -0,0 +1,192 @@
+package migrate
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+)
+
+const (
+	upSuffix   = ".up.sql"
+	downSuffix = ".down.sql"
+	sqlSuffix  = ".sql"
+
+	goosePrefix = "+goose"
+	gooseUp     = "up"
+	gooseDown   = "down"
+)
+
+// Step описывает одну версию миграции из директории.
+type Step struct {
+	Version int64
+	// Имя миграции без номера версии и расширения
+	Name string
+	Up   Migration
+	// Миграция отката, nil если её нет
+	Down *Migration
+}
+
+func (s Step) String() string { return filepath.Base(s.Up.Name) }
+
+// ReadDir читает миграции из директории и сортирует их по версии.
+// Поддерживаются файлы golang-migrate (NNNN_name.up.sql и NNNN_name.down.sql)
+// и файлы goose (NNNN_name.sql с аннотациями -- +goose Up и -- +goose Down).
+func ReadDir(dir string) ([]Step, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, xerrors.Errorf("read migrations dir: %w", err)
+	}
+
+	steps := make(map[int64]*Step)
+	getStep := func(version int64, name string) (*Step, error) {
+		step, ok := steps[version]
+		if !ok {
+			step = &Step{Version: version, Name: name}
+			steps[version] = step
+		}
+		if step.Name != name {
+			return nil, xerrors.Errorf("migrations %q and %q have the same version %d", step.Name, name, version)
+		}
+		return step, nil
+	}
+
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, sqlSuffix) {
+			continue
+		}
+		path := filepath.Join(dir, fileName)
+
+		var base string
+		switch {
+		case strings.HasSuffix(fileName, upSuffix):
+			base = strings.TrimSuffix(fileName, upSuffix)
+		case strings.HasSuffix(fileName, downSuffix):
+			base = strings.TrimSuffix(fileName, downSuffix)
+		default:
+			base = strings.TrimSuffix(fileName, sqlSuffix)
+		}
+		version, name, err := parseVersion(base)
+		if err != nil {
+			return nil, xerrors.Errorf("migration %q: %w", path, err)
+		}
+		step, err := getStep(version, name)
+		if err != nil {
+			return nil, err
+		}
+
+		script, err := os.ReadFile(path)
+		if err != nil {
+			return nil, xerrors.Errorf("read migration: %w", err)
+		}
+
+		switch {
+		case strings.HasSuffix(fileName, upSuffix):
+			if step.Up.Name != "" {
+				return nil, xerrors.Errorf("duplicate up migration for version %d: %q", version, path)
+			}
+			step.Up = Migration{Name: path, SQL: string(script)}
+		case strings.HasSuffix(fileName, downSuffix):
+			if step.Down != nil {
+				return nil, xerrors.Errorf("duplicate down migration for version %d: %q", version, path)
+			}
+			step.Down = &Migration{Name: path, SQL: string(script)}
+		default:
+			if step.Up.Name != "" || step.Down != nil {
+				return nil, xerrors.Errorf("duplicate migration for version %d: %q", version, path)
+			}
+			up, down, err := splitGoose(string(script))
+			if err != nil {
+				return nil, xerrors.Errorf("migration %q: %w", path, err)
+			}
+			step.Up = Migration{Name: path, SQL: up}
+			if strings.TrimSpace(down) != "" {
+				step.Down = &Migration{Name: path, SQL: down}
+			}
+		}
+	}
+
+	res := make([]Step, 0, len(steps))
+	for _, step := range steps {
+		if step.Up.Name == "" {
+			return nil, xerrors.Errorf("up migration for version %d (%s) not found", step.Version, step.Name)
+		}
+		res = append(res, *step)
+	}
+	slices.SortFunc(res, func(a, b Step) bool { return a.Version < b.Version })
+	return res, nil
+}
+
+// parseVersion разбирает имя файла вида NNNN_name.
+func parseVersion(base string) (int64, string, error) {
+	rawVersion, name, _ := strings.Cut(base, "_")
+	version, err := strconv.ParseInt(rawVersion, 10, 64)
+	if err != nil {
+		return 0, "", xerrors.Errorf("file name must start with a version number: %w", err)
+	}
+	return version, name, nil
+}
+
+// splitGoose разделяет файл goose на миграции up и down.
+// Аннотации goose, как и в goose, распознаются без учета регистра и заменяются пустыми строками,
+// поэтому пустая секция Down дает пустую миграцию.
+// Строки до первой аннотации не попадают ни в одну из миграций, как и в goose.
+func splitGoose(script string) (up, down string, err error) {
+	var (
+		upSB, downSB strings.Builder
+		current      *strings.Builder
+		seenUp       bool
+	)
+	sc := bufio.NewScanner(strings.NewReader(script))
+	sc.Buffer(nil, len(script)+1)
+	for sc.Scan() {
+		line := sc.Text()
+		annotation, ok := gooseAnnotation(line)
+		switch {
+		case !ok:
+		case annotation == gooseUp:
+			if seenUp {
+				return "", "", xerrors.New("duplicate '-- +goose Up' annotation")
+			}
+			seenUp = true
+			current = &upSB
+		case annotation == gooseDown:
+			current = &downSB
+		}
+		if ok {
+			line = ""
+		}
+		// строки другой миграции заменяются пустыми, чтобы номера строк ошибок совпадали с файлом
+		for _, sb := range []*strings.Builder{&upSB, &downSB} {
+			if sb == current {
+				sb.WriteString(line)
+			}
+			sb.WriteByte('\n')
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", "", xerrors.Errorf("read goose migration: %w", err)
+	}
+	if !seenUp {
+		return "", "", xerrors.New("'-- +goose Up' annotation not found")
+	}
+	return upSB.String(), downSB.String(), nil
+}
+
+// gooseAnnotation возвращает аннотацию goose в строке в нижнем регистре, например "up" или "statementbegin".
+func gooseAnnotation(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "--") {
+		return "", false
+	}
+	cmd := strings.TrimSpace(strings.TrimPrefix(line, "--"))
+	if len(cmd) < len(goosePrefix) || !strings.EqualFold(cmd[:len(goosePrefix)], goosePrefix) {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(cmd[len(goosePrefix):])), true
+}
@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	return dir
+}
+
+func TestReadDirGolangMigrate(t *testing.T) {
+	r := require.New(t)
+	dir := writeFiles(t, map[string]string{
+		"0010_add_age.up.sql":    "ALTER TABLE users ADD age int;",
+		"0010_add_age.down.sql":  "ALTER TABLE users DROP age;",
+		"0002_users.up.sql":      "CREATE TABLE users (id int);",
+		"0002_users.down.sql":    "DROP TABLE users;",
+		"0003_no_down.up.sql":    "CREATE INDEX ON users (id);",
+		"README.md":              "not a migration",
+		"0001_schema.up.sql":     "CREATE SCHEMA test;",
+		"0001_schema.down.sql":   "DROP SCHEMA test;",
+		"0001_schema.up.sql.bak": "ignored",
+	})
+
+	steps, err := ReadDir(dir)
+	r.NoError(err)
+	r.Len(steps, 4)
+
+	versions := make([]int64, 0, len(steps))
+	for _, step := range steps {
+		versions = append(versions, step.Version)
+	}
+	r.Equal([]int64{1, 2, 3, 10}, versions)
+
+	r.Equal("add_age", steps[3].Name)
+	r.Equal("0010_add_age.up.sql", steps[3].String())
+	r.Equal("ALTER TABLE users ADD age int;", steps[3].Up.SQL)
+	r.Equal("ALTER TABLE users DROP age;", steps[3].Down.SQL)
+	r.Equal(filepath.Join(dir, "0010_add_age.down.sql"), steps[3].Down.Name)
+	r.Nil(steps[2].Down)
+}
+
+func TestReadDirGoose(t *testing.T) {
+	r := require.New(t)
+	dir := writeFiles(t, map[string]string{
+		"20230101120000_users.sql": strings.Join([]string{
+			"-- header comment",
+			"-- +goose Up",
+			"-- +goose StatementBegin",
+			"CREATE TABLE users (id int);",
+			"-- +goose StatementEnd",
+			"",
+			"-- +goose Down",
+			"DROP TABLE users;",
+		}, "\n"),
+		"20230102120000_no_down.sql": "-- +goose Up\nALTER TABLE users ADD age int;\n",
+		// аннотации без учета регистра, секция Down пустая
+		"20230103120000_empty_down.sql": "--+goose up\nALTER TABLE users ADD name text;\n\n-- +GOOSE DOWN\n\n",
+	})
+
+	steps, err := ReadDir(dir)
+	r.NoError(err)
+	r.Len(steps, 3)
+
+	users := steps[0]
+	r.Equal(int64(20230101120000), users.Version)
+	r.NotContains(users.Up.SQL, "DROP TABLE")
+	r.NotContains(users.Up.SQL, "header comment")
+	r.NotContains(users.Down.SQL, "CREATE TABLE")
+	// номера строк миграций совпадают с номерами строк файла
+	r.Equal("CREATE TABLE users (id int);", strings.Split(users.Up.SQL, "\n")[3])
+	r.Equal("DROP TABLE users;", strings.Split(users.Down.SQL, "\n")[7])
+
+	r.Nil(steps[1].Down)
+
+	r.Equal("\nALTER TABLE users ADD name text;\n\n\n\n", steps[2].Up.SQL)
+	r.Nil(steps[2].Down)
+}
+
+func TestReadDirErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "no version",
+			files: map[string]string{"init.up.sql": ""},
+			want:  "file name must start with a version number",
+		},
+		{
+			name:  "down without up",
+			files: map[string]string{"0001_init.down.sql": ""},
+			want:  "up migration for version 1 (init) not found",
+		},
+		{
+			name: "same version",
+			files: map[string]string{
+				"0001_init.up.sql":  "",
+				"0001_other.up.sql": "",
+			},
+			want: "have the same version 1",
+		},
+		{
+			name:  "goose without up",
+			files: map[string]string{"0001_init.sql": "CREATE TABLE t (id int);"},
+			want:  "annotation not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadDir(writeFiles(t, tt.files))
+			require.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package migrate
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/parse/query"
+	"github.com/Feresey/mtest/schema"
+)
+
+// Материализованные представления с данными и материализованные представления,
+// от которых они зависят напрямую.
+const queryMaterializedViewsSQL = `-- list materialized views
+SELECT
+	ns.nspname AS schema_name,
+	c.relname AS view_name,
+	ARRAY(
+		SELECT DISTINCT format('%s.%s', dns.nspname, dc.relname)
+		FROM
+			pg_rewrite r
+			JOIN pg_depend d ON d.classid = 'pg_rewrite'::REGCLASS AND d.objid = r.oid
+			JOIN pg_class dc ON dc.oid = d.refobjid
+			JOIN pg_namespace dns ON dns.oid = dc.relnamespace
+		WHERE
+			r.ev_class = c.oid
+			AND d.refclassid = 'pg_class'::REGCLASS
+			AND d.refobjid <> c.oid
+			AND dc.relkind = 'm'
+	) AS depends_on
+FROM
+	pg_class c
+	JOIN pg_namespace ns ON ns.oid = c.relnamespace
+WHERE
+	c.relkind = 'm'
+	AND c.relispopulated
+ORDER BY c.oid ASC`
+
+// materializedView описывает материализованное представление, которое нужно обновить.
+type materializedView struct {
+	Name schema.Identifier
+	// Материализованные представления, которые нужно обновить раньше
+	DependsOn []string
+}
+
+// refreshMaterializedViews обновляет данные всех материализованных представлений,
+// чтобы миграция проверялась и на запросах представлений.
+// Ошибка обновления считается ошибкой применения миграции.
+func (t *Tester) refreshMaterializedViews(ctx context.Context, tx pgx.Tx, migration Migration) (*Error, error) {
+	views, err := query.QueryAll(ctx, tx,
+		func(scan pgx.Rows, v *materializedView) error {
+			return scan.Scan(&v.Name.Schema, &v.Name.Name, &v.DependsOn)
+		},
+		queryMaterializedViewsSQL)
+	if err != nil {
+		return nil, xerrors.Errorf("query materialized views: %w", err)
+	}
+	order, err := materializedViewsOrder(views)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, view := range order {
+		refresh := "REFRESH MATERIALIZED VIEW " + pgx.Identifier{view.Name.Schema, view.Name.Name}.Sanitize()
+		t.log.Info("refresh materialized view", zap.Stringer("view", view.Name))
+		if _, err := tx.Exec(ctx, refresh); err != nil {
+			migrationErr := NewError(migration, err)
+			// позиция ошибки относится к запросу обновления, а не к скрипту миграции
+			migrationErr.Line = 0
+			migrationErr.Err.Message = "refresh materialized view"
+			migrationErr.Err.Query = refresh
+			if migrationErr.Table == "" {
+				migrationErr.Table = view.Name.String()
+			}
+			return &migrationErr, nil
+		}
+	}
+	return nil, nil
+}
+
+// materializedViewsOrder возвращает представления в порядке обновления:
+// представление обновляется после представлений, от которых оно зависит.
+func materializedViewsOrder(views []materializedView) ([]materializedView, error) {
+	byName := make(map[string]materializedView, len(views))
+	graph := &schema.Graph{Graph: make(map[string][]string, len(views))}
+	for _, view := range views {
+		name := view.Name.String()
+		byName[name] = view
+		if _, ok := graph.Graph[name]; !ok {
+			graph.Graph[name] = nil
+		}
+		for _, dep := range view.DependsOn {
+			graph.Graph[dep] = append(graph.Graph[dep], name)
+		}
+	}
+
+	names, err := graph.TopologicalSort()
+	if err != nil {
+		return nil, xerrors.Errorf("order materialized views: %w", err)
+	}
+	order := make([]materializedView, 0, len(views))
+	for _, name := range names {
+		// представления без данных не обновляются, но могут быть в зависимостях
+		if view, ok := byName[name]; ok {
+			order = append(order, view)
+		}
+	}
+	return order, nil
+}
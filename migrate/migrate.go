@@ -0,0 +1,487 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/db"
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/insert"
+	"github.com/Feresey/mtest/parse"
+	"github.com/Feresey/mtest/schema"
+)
+
+const (
+	migrationSavepoint = "mtest_migration"
+	countSavepoint     = "mtest_count"
+
+	// SQLSTATE undefined_table
+	undefinedTableCode = "42P01"
+	// статус соединения вне транзакции
+	txStatusIdle = 'I'
+)
+
+type Config struct {
+	// Загружать записи с помощью COPY вместо INSERT
+	Copy bool
+	// Шаблоны таблиц для сравнения схемы до и после отката миграции
+	Parser parse.Config
+}
+
+// Migration описывает скрипт миграции.
+type Migration struct {
+	// Имя файла миграции
+	Name string
+	SQL  string
+}
+
+// Error описывает ошибку применения миграции.
+type Error struct {
+	Migration string
+	// Строка скрипта миграции, на которой произошла ошибка. 0, если строка неизвестна.
+	Line int
+	// Таблица, колонка и ограничение, из-за которых миграция не применилась. Могут быть пустыми.
+	Table      string
+	Column     string
+	Constraint string
+	// Подробности ошибки, например значения ключа, нарушившего ограничение
+	Detail string
+	Err    db.Error
+}
+
+func (e Error) Error() string {
+	var sb strings.Builder
+	sb.WriteString(e.Migration)
+	if e.Line != 0 {
+		fmt.Fprintf(&sb, ", line %d", e.Line)
+	}
+	if e.Table != "" {
+		fmt.Fprintf(&sb, ", table %q", e.Table)
+	}
+	if e.Column != "" {
+		fmt.Fprintf(&sb, ", column %q", e.Column)
+	}
+	if e.Constraint != "" {
+		fmt.Fprintf(&sb, ", constraint %q", e.Constraint)
+	}
+	fmt.Fprintf(&sb, ": %v", e.Err)
+	if e.Detail != "" {
+		fmt.Fprintf(&sb, " (%s)", e.Detail)
+	}
+	return sb.String()
+}
+
+func (e Error) Unwrap() error { return e.Err }
+
+// TableReport описывает состояние таблицы до и после применения миграции.
+type TableReport struct {
+	Table string
+	// Количество вставленных и не вставленных сгенерированных записей.
+	// Ошибки включают негативные записи, которые не были отклонены как ожидалось.
+	Inserted     int
+	InsertErrors []insert.RowError
+	// Количество записей в таблице до и после применения миграции.
+	RowsBefore int
+	RowsAfter  int
+	// Таблица удалена миграцией
+	Dropped bool
+	// Миграция не применилась из-за данных этой таблицы
+	MigrationFailed bool
+
+	// Количество записей после отката миграции
+	RowsAfterDown int
+	// Записи таблицы после отката миграции отличаются от записей до миграции
+	DataChanged bool
+}
+
+// Report описывает результат проверки миграции.
+type Report struct {
+	Migration string
+	Tables    []TableReport
+	// Ошибка применения миграции, nil если миграция применилась
+	Error *Error
+
+	// Миграция отката. Пустая, если откат не проверялся.
+	Down string
+	// Ошибка применения миграции отката
+	DownError *Error
+	// Отличия схемы после отката от схемы до миграции
+	SchemaDiff *schema.Diff
+}
+
+// Failed возвращает true, если миграция не применилась или откат миграции не вернул схему и данные.
+func (r Report) Failed() bool {
+	if r.Error != nil || r.DownError != nil {
+		return true
+	}
+	if r.SchemaDiff != nil && !r.SchemaDiff.Empty() {
+		return true
+	}
+	for _, table := range r.Tables {
+		if table.DataChanged {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText выводит отчёт в виде таблицы.
+func (r Report) WriteText(w io.Writer) error {
+	checkDown := r.Down != "" && r.Error == nil
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "TABLE\tINSERTED\tINSERT ERRORS\tBEFORE\tAFTER"
+	if checkDown {
+		header += "\tAFTER DOWN"
+	}
+	fmt.Fprintln(tw, header+"\tSTATUS")
+	for _, table := range r.Tables {
+		after := fmt.Sprint(table.RowsAfter)
+		afterDown := fmt.Sprint(table.RowsAfterDown)
+		var status []string
+		switch {
+		case table.MigrationFailed:
+			status = append(status, "failed")
+			after, afterDown = "-", "-"
+		case r.Error != nil:
+			after = "-"
+		case table.Dropped:
+			status = append(status, "dropped")
+			after = "-"
+		case table.RowsAfter != table.RowsBefore:
+			status = append(status, "rows changed")
+		}
+		if r.DownError != nil {
+			afterDown = "-"
+		}
+		switch {
+		case !checkDown || r.DownError != nil || table.MigrationFailed:
+		case table.RowsAfterDown < table.RowsBefore:
+			status = append(status, "rows lost")
+		case table.DataChanged:
+			status = append(status, "data changed")
+		}
+		if len(status) == 0 {
+			status = append(status, "ok")
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s",
+			table.Table, table.Inserted, len(table.InsertErrors), table.RowsBefore, after)
+		if checkDown {
+			fmt.Fprintf(tw, "\t%s", afterDown)
+		}
+		fmt.Fprintf(tw, "\t%s\n", strings.Join(status, ", "))
+	}
+	if err := tw.Flush(); err != nil {
+		return xerrors.Errorf("write report: %w", err)
+	}
+
+	if r.Error != nil {
+		_, err := fmt.Fprintf(w, "\nmigration failed: %v\n", r.Error)
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "\nmigration %s applied\n", r.Migration); err != nil {
+		return err
+	}
+	switch {
+	case !checkDown:
+		return nil
+	case r.DownError != nil:
+		_, err := fmt.Fprintf(w, "down migration failed: %v\n", r.DownError)
+		return err
+	case r.SchemaDiff != nil && !r.SchemaDiff.Empty():
+		if _, err := fmt.Fprintf(w, "schema differs after down migration %s:\n", r.Down); err != nil {
+			return err
+		}
+		return r.SchemaDiff.WriteText(w)
+	default:
+		_, err := fmt.Fprintf(w, "down migration %s reverted schema\n", r.Down)
+		return err
+	}
+}
+
+// markFailed отмечает таблицу, из-за которой не применилась миграция.
+func (r *Report) markFailed(table string) {
+	for idx := range r.Tables {
+		if r.Tables[idx].Table == table {
+			r.Tables[idx].MigrationFailed = true
+		}
+	}
+}
+
+// Tester применяет миграцию к базе данных со сгенерированными записями.
+type Tester struct {
+	conn insert.TxBeginner
+	log  *zap.Logger
+}
+
+func NewTester(conn insert.TxBeginner, log *zap.Logger) *Tester {
+	return &Tester{
+		conn: conn,
+		log:  log.Named("migrate"),
+	}
+}
+
+// tableState описывает записи таблицы.
+type tableState struct {
+	Rows int
+	// md5 от отсортированных записей таблицы
+	Checksum string
+	Exists   bool
+}
+
+// Test вставляет записи таблиц в указанном порядке, применяет миграцию и откатывает все изменения.
+// Если указана миграция отката, то она применяется после миграции up,
+// после чего схема и записи таблиц сравниваются с состоянием до миграции.
+// Ошибки применения миграций возвращаются в отчёте, а не как ошибка функции.
+func (t *Tester) Test(
+	ctx context.Context,
+	order []schema.Table,
+	records map[string]generate.Records,
+	up Migration,
+	down *Migration,
+	conf Config,
+) (report Report, err error) {
+	report.Migration = up.Name
+
+	tx, err := t.conn.Begin(ctx)
+	if err != nil {
+		return report, xerrors.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		t.log.Info("rollback migration and inserted records")
+		if rerr := tx.Rollback(ctx); rerr != nil && !errors.Is(rerr, pgx.ErrTxClosed) {
+			err = errors.Join(err, xerrors.Errorf("rollback transaction: %w", rerr))
+		}
+	}()
+
+	inserted, err := insert.NewInserter(t.conn, t.log).InsertTx(ctx, tx, order, records, conf.Copy)
+	if err != nil {
+		return report, xerrors.Errorf("insert records: %w", err)
+	}
+	byTable := make(map[string]insert.TableReport, len(inserted.Tables))
+	for _, tr := range inserted.Tables {
+		byTable[tr.Table] = tr
+	}
+
+	before, err := t.tableStates(ctx, tx, order)
+	if err != nil {
+		return report, err
+	}
+	for idx, table := range order {
+		tr := byTable[table.String()]
+		report.Tables = append(report.Tables, TableReport{
+			Table:        table.String(),
+			Inserted:     tr.Inserted,
+			InsertErrors: append(tr.Errors, tr.NegativeErrors...),
+			RowsBefore:   before[idx].Rows,
+		})
+	}
+
+	var schemaBefore *schema.Schema
+	if down != nil {
+		report.Down = down.Name
+		schemaBefore, err = t.loadSchema(ctx, tx, conf.Parser)
+		if err != nil {
+			return report, xerrors.Errorf("parse schema before migration: %w", err)
+		}
+	}
+
+	report.Error, err = t.apply(ctx, tx, up)
+	if err != nil || report.Error != nil {
+		if report.Error != nil {
+			report.markFailed(report.Error.Table)
+		}
+		return report, err
+	}
+
+	after, err := t.tableStates(ctx, tx, order)
+	if err != nil {
+		return report, err
+	}
+	for idx := range order {
+		report.Tables[idx].RowsAfter = after[idx].Rows
+		report.Tables[idx].Dropped = !after[idx].Exists
+	}
+
+	if down == nil {
+		return report, nil
+	}
+
+	report.DownError, err = t.apply(ctx, tx, *down)
+	if err != nil || report.DownError != nil {
+		if report.DownError != nil {
+			report.markFailed(report.DownError.Table)
+		}
+		return report, err
+	}
+
+	schemaAfter, err := t.loadSchema(ctx, tx, conf.Parser)
+	if err != nil {
+		return report, xerrors.Errorf("parse schema after down migration: %w", err)
+	}
+	report.SchemaDiff = schema.Compare(schemaBefore, schemaAfter)
+
+	afterDown, err := t.tableStates(ctx, tx, order)
+	if err != nil {
+		return report, err
+	}
+	for idx := range order {
+		report.Tables[idx].RowsAfterDown = afterDown[idx].Rows
+		report.Tables[idx].DataChanged = afterDown[idx] != before[idx]
+	}
+
+	return report, nil
+}
+
+// Apply применяет миграцию и сохраняет изменения.
+func (t *Tester) Apply(ctx context.Context, migration Migration) (err error) {
+	tx, err := t.conn.Begin(ctx)
+	if err != nil {
+		return xerrors.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rerr := tx.Rollback(ctx); rerr != nil && !errors.Is(rerr, pgx.ErrTxClosed) {
+			err = errors.Join(err, xerrors.Errorf("rollback transaction: %w", rerr))
+		}
+	}()
+
+	migrationErr, err := t.apply(ctx, tx, migration)
+	if err != nil {
+		return err
+	}
+	if migrationErr != nil {
+		return migrationErr
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return xerrors.Errorf("commit migration: %w", err)
+	}
+	return nil
+}
+
+// apply применяет миграцию и обновляет материализованные представления.
+// Если миграция не применилась, то её изменения откатываются.
+func (t *Tester) apply(ctx context.Context, tx pgx.Tx, migration Migration) (*Error, error) {
+	if err := t.exec(ctx, tx, "SAVEPOINT "+migrationSavepoint); err != nil {
+		return nil, err
+	}
+	t.log.Info("apply migration", zap.String("migration", migration.Name))
+	if _, err := tx.Exec(ctx, migration.SQL); err != nil {
+		migrationErr := NewError(migration, err)
+		return &migrationErr, t.exec(ctx, tx, "ROLLBACK TO SAVEPOINT "+migrationSavepoint)
+	}
+	if tx.Conn().PgConn().TxStatus() == txStatusIdle {
+		return nil, xerrors.Errorf("migration %s finished the transaction, remove COMMIT or ROLLBACK from it", migration.Name)
+	}
+	migrationErr, err := t.refreshMaterializedViews(ctx, tx, migration)
+	if err != nil {
+		return nil, err
+	}
+	if migrationErr != nil {
+		return migrationErr, t.exec(ctx, tx, "ROLLBACK TO SAVEPOINT "+migrationSavepoint)
+	}
+	return nil, t.exec(ctx, tx, "RELEASE SAVEPOINT "+migrationSavepoint)
+}
+
+func (t *Tester) loadSchema(ctx context.Context, tx pgx.Tx, conf parse.Config) (*schema.Schema, error) {
+	// парсер хранит загруженные данные, поэтому для каждой загрузки нужен новый
+	return parse.NewParser(tx, t.log).LoadSchema(ctx, conf)
+}
+
+func (t *Tester) tableStates(ctx context.Context, tx pgx.Tx, order []schema.Table) ([]tableState, error) {
+	states := make([]tableState, 0, len(order))
+	for _, table := range order {
+		state, err := t.tableState(ctx, tx, table)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// tableState возвращает количество и контрольную сумму записей таблицы.
+// Если таблица не существует, то Exists == false.
+func (t *Tester) tableState(ctx context.Context, tx pgx.Tx, table schema.Table) (state tableState, err error) {
+	if err := t.exec(ctx, tx, "SAVEPOINT "+countSavepoint); err != nil {
+		return state, err
+	}
+	query := fmt.Sprintf(
+		"SELECT count(*), coalesce(md5(string_agg(t::text, E'\\n' ORDER BY t::text)), '') FROM %s AS t",
+		pgx.Identifier{table.Name.Schema, table.Name.Name}.Sanitize())
+	err = tx.QueryRow(ctx, query).Scan(&state.Rows, &state.Checksum)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == undefinedTableCode:
+		return state, t.exec(ctx, tx, "ROLLBACK TO SAVEPOINT "+countSavepoint)
+	case err != nil:
+		return state, db.Error{Err: err, Message: "count table rows", Query: query}
+	}
+	state.Exists = true
+	return state, t.exec(ctx, tx, "RELEASE SAVEPOINT "+countSavepoint)
+}
+
+func (t *Tester) exec(ctx context.Context, tx pgx.Tx, query string) error {
+	if _, err := tx.Exec(ctx, query); err != nil {
+		return db.Error{
+			Err:     err,
+			Message: "exec",
+			Query:   query,
+		}
+	}
+	return nil
+}
+
+// NewError описывает ошибку применения миграции по ошибке базы данных.
+func NewError(migration Migration, err error) Error {
+	res := Error{
+		Migration: migration.Name,
+		Err: db.Error{
+			Err:     err,
+			Message: "apply migration",
+			Query:   migration.SQL,
+		},
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return res
+	}
+	res.Line = errorLine(migration.SQL, int(pgErr.Position))
+	res.Column = pgErr.ColumnName
+	res.Constraint = pgErr.ConstraintName
+	res.Detail = pgErr.Detail
+	if pgErr.TableName != "" {
+		res.Table = schema.Identifier{Schema: pgErr.SchemaName, Name: pgErr.TableName}.String()
+	}
+	return res
+}
+
+// errorLine возвращает номер строки по позиции ошибки PostgreSQL.
+// Позиция отсчитывается с 1 в символах, а не в байтах. 0 означает, что позиция неизвестна.
+func errorLine(script string, position int) int {
+	if position <= 0 {
+		return 0
+	}
+	line := 1
+	for _, r := range script {
+		position--
+		if position == 0 {
+			break
+		}
+		if r == '\n' {
+			line++
+		}
+	}
+	return line
+}
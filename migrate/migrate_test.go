@@ -0,0 +1,137 @@
+package migrate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestErrorLine(t *testing.T) {
+	script := "ALTER TABLE a ADD b int;\n-- комментарий\nALTER TABLE c ADD d int;"
+	tests := []struct {
+		position int
+		want     int
+	}{
+		{position: 0, want: 0},
+		{position: 1, want: 1},
+		{position: 24, want: 1},
+		{position: 25, want: 1},
+		{position: 26, want: 2},
+		// позиция считается в символах, кириллица занимает два байта
+		{position: 41, want: 3},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, errorLine(script, tt.position), "position %d", tt.position)
+	}
+}
+
+func TestNewError(t *testing.T) {
+	migration := Migration{
+		Name: "up.sql",
+		SQL:  "UPDATE test.users SET age = 0;\nALTER TABLE test.users\n  ADD CONSTRAINT users_age_check CHECK (age > 0);",
+	}
+	pgErr := &pgconn.PgError{
+		Message:        `check constraint "users_age_check" of relation "users" is violated by some row`,
+		Code:           "23514",
+		SchemaName:     "test",
+		TableName:      "users",
+		ConstraintName: "users_age_check",
+		Position:       32,
+	}
+
+	err := NewError(migration, xerrors.Errorf("exec: %w", pgErr))
+	require.Equal(t, 2, err.Line)
+	require.Equal(t, "test.users", err.Table)
+	require.Equal(t, "users_age_check", err.Constraint)
+	require.ErrorIs(t, err, pgErr)
+	require.Contains(t, err.Error(), `up.sql, line 2, table "test.users", constraint "users_age_check": apply migration:`)
+
+	plain := NewError(migration, xerrors.New("conn closed"))
+	require.Equal(t, "up.sql: apply migration: conn closed", plain.Error())
+}
+
+func TestReportWriteText(t *testing.T) {
+	report := Report{
+		Migration: "up.sql",
+		Tables: []TableReport{
+			{Table: "test.roles", Inserted: 3, RowsBefore: 3, RowsAfter: 3},
+			{Table: "test.users", Inserted: 5, RowsBefore: 5, RowsAfter: 4},
+			{Table: "test.logs", Inserted: 1, RowsBefore: 1, Dropped: true},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteText(&buf))
+	require.Equal(t, ""+
+		"TABLE       INSERTED  INSERT ERRORS  BEFORE  AFTER  STATUS\n"+
+		"test.roles  3         0              3       3      ok\n"+
+		"test.users  5         0              5       4      rows changed\n"+
+		"test.logs   1         0              1       -      dropped\n"+
+		"\nmigration up.sql applied\n", buf.String())
+
+	report.Tables[1].MigrationFailed = true
+	report.Error = &Error{Migration: "up.sql", Table: "test.users", Err: NewError(Migration{}, xerrors.New("boom")).Err}
+	buf.Reset()
+	require.NoError(t, report.WriteText(&buf))
+	require.Contains(t, buf.String(), "test.users  5         0              5       -      failed\n")
+	require.Contains(t, buf.String(), "test.roles  3         0              3       -      ok\n")
+	require.Contains(t, buf.String(), `migration failed: up.sql, table "test.users": apply migration: boom`)
+}
+
+func TestReportWriteTextDown(t *testing.T) {
+	report := Report{
+		Migration: "up.sql",
+		Down:      "down.sql",
+		Tables: []TableReport{
+			{Table: "test.roles", Inserted: 3, RowsBefore: 3, RowsAfter: 3, RowsAfterDown: 3},
+			{Table: "test.users", Inserted: 5, RowsBefore: 5, RowsAfter: 5, RowsAfterDown: 5, DataChanged: true},
+			{Table: "test.logs", Inserted: 2, RowsBefore: 2, Dropped: true, RowsAfterDown: 0, DataChanged: true},
+		},
+		SchemaDiff: &schema.Diff{Changes: []schema.Change{
+			{Kind: schema.ChangeRemoved, Object: schema.ObjectIndex, Parent: "test.logs", Name: "logs_idx", Old: "CREATE INDEX logs_idx ON test.logs USING btree (id)"},
+		}},
+	}
+	require.True(t, report.Failed())
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteText(&buf))
+	require.Equal(t, ""+
+		"TABLE       INSERTED  INSERT ERRORS  BEFORE  AFTER  AFTER DOWN  STATUS\n"+
+		"test.roles  3         0              3       3      3           ok\n"+
+		"test.users  5         0              5       5      5           data changed\n"+
+		"test.logs   2         0              2       -      0           dropped, rows lost\n"+
+		"\nmigration up.sql applied\n"+
+		"schema differs after down migration down.sql:\n"+
+		"- index test.logs.logs_idx: CREATE INDEX logs_idx ON test.logs USING btree (id)\n",
+		buf.String())
+
+	report.Tables[1].DataChanged, report.Tables[2].DataChanged = false, false
+	report.Tables[2].RowsAfterDown = 2
+	report.SchemaDiff = &schema.Diff{}
+	require.False(t, report.Failed())
+	buf.Reset()
+	require.NoError(t, report.WriteText(&buf))
+	require.Contains(t, buf.String(), "down migration down.sql reverted schema\n")
+}
+
+func TestMaterializedViewsOrder(t *testing.T) {
+	view := func(name string, deps ...string) materializedView {
+		return materializedView{Name: schema.Identifier{Schema: "test", Name: name}, DependsOn: deps}
+	}
+	order, err := materializedViewsOrder([]materializedView{
+		view("report", "test.user_stats", "test.order_stats"),
+		view("user_stats"),
+		// представление без данных не обновляется
+		view("order_stats", "test.unpopulated"),
+	})
+	require.NoError(t, err)
+	var names []string
+	for _, v := range order {
+		names = append(names, v.Name.String())
+	}
+	require.Equal(t, []string{"test.user_stats", "test.order_stats", "test.report"}, names)
+}
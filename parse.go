@@ -9,6 +9,7 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
 	"golang.org/x/xerrors"
 
 	"github.com/Feresey/mtest/db"
@@ -16,8 +17,22 @@ import (
 	"github.com/Feresey/mtest/schema"
 )
 
+type parseFlags struct {
+	flags
+	outputPath *cli.StringFlag
+	fromSQL    *cli.StringFlag
+}
+
+func (f parseFlags) Set() []cli.Flag {
+	return append(
+		f.flags.Set(),
+		f.outputPath,
+		f.fromSQL,
+	)
+}
+
 type ParseCommand struct {
-	flags flags
+	flags parseFlags
 	BaseCommand
 
 	conn *pgx.Conn
@@ -25,7 +40,16 @@ type ParseCommand struct {
 
 func NewParseCommand(f flags) *ParseCommand {
 	return &ParseCommand{
-		flags: f,
+		flags: parseFlags{
+			flags: f,
+			outputPath: &cli.StringFlag{
+				Name:    "output",
+				Value:   ".",
+				Usage:   "-o outdir",
+				Aliases: []string{"o"},
+			},
+			fromSQL: newFromSQLFlag(),
+		},
 		// set up by init
 		conn:        nil,
 		BaseCommand: BaseCommand{},
@@ -44,15 +68,18 @@ func (p *ParseCommand) Command() *cli.Command {
 }
 
 func (p *ParseCommand) Init(ctx *cli.Context) error {
-	base, err := NewBase(ctx, p.flags)
+	base, err := NewBase(ctx, p.flags.flags)
 	if err != nil {
 		return cli.Exit(err, 2)
 	}
+	p.BaseCommand = base
+	if p.flags.fromSQL.Get(ctx) != "" {
+		return nil
+	}
 	conn, err := base.connectDB(ctx, p.flags.debug.Get(ctx))
 	if err != nil {
 		return cli.Exit(err, 3)
 	}
-	p.BaseCommand = base
 	p.conn = conn
 	return nil
 }
@@ -68,6 +95,38 @@ func (p *ParseCommand) Cleanup(ctx *cli.Context) error {
 }
 
 func (p *ParseCommand) Run(ctx *cli.Context) error {
+	s, err := p.loadSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	graph, err := s.NewAcyclicGraph()
+	if err != nil {
+		return xerrors.Errorf("try to determine tables order: %w", err)
+	}
+	for _, edge := range graph.Deferred {
+		p.log.Info("foreign keys are deferred to break a cycle",
+			zap.Stringer("edge", edge),
+			zap.Bool("deferrable", edge.Deferrable()),
+		)
+	}
+	levels, err := graph.Levels()
+	if err != nil {
+		return xerrors.Errorf("split tables into levels: %w", err)
+	}
+	// таблицы одного уровня не зависят друг от друга и вставляются одновременно
+	for idx, level := range levels {
+		p.log.Info("tables level", zap.Int("level", idx), zap.Strings("tables", level))
+	}
+
+	return p.dump(s, p.flags.outputPath.Get(ctx))
+}
+
+func (p *ParseCommand) loadSchema(ctx *cli.Context) (*schema.Schema, error) {
+	if filename := p.flags.fromSQL.Get(ctx); filename != "" {
+		return p.loadSchemaFromSQL(filename)
+	}
+
 	parser := parse.NewParser(p.conn, p.log)
 	s, err := parser.LoadSchema(ctx.Context, p.cnf.Parser)
 	if err != nil {
@@ -75,16 +134,10 @@ func (p *ParseCommand) Run(ctx *cli.Context) error {
 		if errors.As(err, &pErr) {
 			println(pErr.Pretty())
 		}
-		return xerrors.Errorf("parse schema: %w", err)
+		return nil, xerrors.Errorf("parse schema: %w", err)
 	}
 	p.log.Info("schema parsed")
-
-	graph := s.NewGraph()
-	if _, err := graph.TopologicalSort(); err != nil {
-		return xerrors.Errorf("try to determine tables order: %w", err)
-	}
-
-	return p.dump(s, outputPath)
+	return s, nil
 }
 
 func (p *ParseCommand) dump(s *schema.Schema, dumpPath string) error {
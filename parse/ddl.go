@@ -0,0 +1,1840 @@
+package parse
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/parse/query"
+	"github.com/Feresey/mtest/schema"
+)
+
+// SQLParser строит схему по SQL скрипту с DDL выражениями без подключения к базе данных.
+// Поддерживаются CREATE TABLE, CREATE TYPE, CREATE DOMAIN, CREATE INDEX, CREATE SEQUENCE,
+// CREATE FUNCTION, CREATE TRIGGER и ALTER TABLE, остальные выражения пропускаются.
+type SQLParser struct {
+	log *zap.Logger
+
+	src  string
+	toks []token
+	pos  int
+	// первая лексема текущего выражения
+	stmt int
+
+	catalog *ddlCatalog
+}
+
+func NewSQLParser(log *zap.Logger) *SQLParser {
+	return &SQLParser{
+		log: log.Named("sql-parser"),
+	}
+}
+
+// LoadSchema разбирает SQL скрипт и возвращает схему таблиц, подходящих под шаблоны из конфига.
+// Если шаблоны не указаны, то в схему попадают все таблицы.
+func (p *SQLParser) LoadSchema(r io.Reader, conf Config) (*schema.Schema, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, xerrors.Errorf("read sql script: %w", err)
+	}
+	p.src = string(src)
+	p.pos = 0
+	p.catalog = newDDLCatalog()
+
+	p.toks, err = tokenize(p.src)
+	if err != nil {
+		return nil, xerrors.Errorf("tokenize sql script: %w", err)
+	}
+
+	for p.peek().kind != tokenEOF {
+		if p.acceptOp(";") {
+			continue
+		}
+		if err := p.parseStatement(); err != nil {
+			return nil, err
+		}
+	}
+
+	ps, err := p.catalog.buildParseSchema(conf.Patterns)
+	if err != nil {
+		return nil, xerrors.Errorf("resolve schema objects: %w", err)
+	}
+	return ps.convertToSchema()
+}
+
+func (p *SQLParser) parseStatement() error {
+	head := p.statementHead()
+	p.stmt = p.pos
+	var err error
+	switch {
+	case p.acceptKeyword("create"):
+		err = p.parseCreate()
+	case p.acceptKeyword("alter", "table"):
+		err = p.parseAlterTable()
+	default:
+		p.log.Debug("skip statement", zap.String("statement", p.statementHead()))
+		p.skipStatement()
+	}
+	if err != nil {
+		return xerrors.Errorf("statement %q: %w", head, err)
+	}
+	if !p.acceptOp(";") && p.peek().kind != tokenEOF {
+		return p.errorf("expected end of statement, got %q", p.peek().value)
+	}
+	return nil
+}
+
+func (p *SQLParser) parseCreate() error {
+	p.acceptKeyword("or", "replace")
+	if p.acceptKeyword("temp") || p.acceptKeyword("temporary") {
+		p.log.Debug("skip temporary object")
+		p.skipStatement()
+		return nil
+	}
+	p.acceptKeyword("unlogged")
+
+	switch {
+	case p.acceptKeyword("table"):
+		return p.parseCreateTable()
+	case p.acceptKeyword("type"):
+		return p.parseCreateType()
+	case p.acceptKeyword("domain"):
+		return p.parseCreateDomain()
+	case p.acceptKeyword("sequence"):
+		return p.parseCreateSequence()
+	case p.acceptKeyword("unique", "index"):
+		return p.parseCreateIndex(true)
+	case p.acceptKeyword("index"):
+		return p.parseCreateIndex(false)
+	case p.acceptKeyword("function"):
+		return p.parseCreateFunction()
+	case p.acceptKeyword("trigger"), p.acceptKeyword("constraint", "trigger"):
+		return p.parseCreateTrigger()
+	}
+	p.log.Debug("skip statement", zap.String("statement", p.statementHead()))
+	p.skipStatement()
+	return nil
+}
+
+func (p *SQLParser) parseCreateTable() error {
+	ifNotExists := p.acceptKeyword("if", "not", "exists")
+	name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	if _, ok := p.catalog.getTable(name); ok {
+		if ifNotExists {
+			p.skipStatement()
+			return nil
+		}
+		return xerrors.Errorf("table %q already exists", qualifiedName(name))
+	}
+	if !p.isOp("(") {
+		p.log.Warn("unsupported table definition, skip it", zap.String("table", qualifiedName(name)))
+		p.skipStatement()
+		return nil
+	}
+
+	t := &ddlTable{
+		table: query.Table{
+			OID:    p.catalog.newOID(),
+			Schema: name.Schema,
+			Table:  name.Name,
+		},
+	}
+	p.catalog.addTable(t)
+
+	if err := p.parseList(func() error {
+		if p.isOp(")") {
+			// таблица без колонок
+			return nil
+		}
+		if p.isTableConstraintStart() {
+			return p.parseTableConstraint(t)
+		}
+		if p.isKeyword(p.peek(), "like") {
+			return p.errorf("LIKE in table definition is not supported")
+		}
+		return p.parseColumnDef(t)
+	}); err != nil {
+		return xerrors.Errorf("table %q: %w", qualifiedName(name), err)
+	}
+
+	// параметры хранения, наследование и секционирование не влияют на схему
+	p.skipStatement()
+	return nil
+}
+
+func (p *SQLParser) isTableConstraintStart() bool {
+	return p.isKeyword(p.peek(), "constraint", "check", "unique", "primary", "foreign", "exclude")
+}
+
+// columnType описывает тип колонки или домена.
+type columnType struct {
+	typ query.Type
+	// модификаторы типа, например длина VARCHAR(100)
+	typmods   []int
+	arrayDims int
+	// SERIAL создает последовательность для значения по умолчанию
+	serial bool
+}
+
+func (p *SQLParser) parseColumnDef(t *ddlTable) error {
+	colName, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if _, ok := t.column(colName); ok {
+		return xerrors.Errorf("column %q specified more than once", colName)
+	}
+	ct, err := p.parseType()
+	if err != nil {
+		return xerrors.Errorf("column %q: %w", colName, err)
+	}
+	col := p.newColumn(colName, ct)
+	if ct.serial {
+		seq := p.newColumnSequence(t, colName, ct.typ)
+		p.catalog.addSequence(seq)
+		seqName := seq.name.Name
+		if t.table.Schema != defaultSchema {
+			seqName = t.table.Schema + "." + seqName
+		}
+		col.IsNullable = true
+		col.HasDefault = true
+		col.DefaultExpr = sql.NullString{String: fmt.Sprintf("nextval('%s'::regclass)", seqName), Valid: true}
+	}
+
+	for !p.isOp(",") && !p.isOp(")") && !p.isStatementEnd() {
+		if err := p.parseColumnConstraint(t, &col); err != nil {
+			return xerrors.Errorf("column %q: %w", colName, err)
+		}
+	}
+	t.addColumn(col)
+	return nil
+}
+
+// newColumn заполняет аттрибуты колонки так же, как их возвращает запрос columns.sql.
+func (p *SQLParser) newColumn(name string, ct columnType) query.Column {
+	col := query.Column{
+		ColumnName: name,
+		TypeOID:    ct.typ.TypeOID,
+		ArrayDims:  ct.arrayDims,
+		IsNumeric:  ct.typ.TypeName == "numeric" && ct.typ.TypeSchema == pgCatalog && ct.arrayDims == 0,
+	}
+	base := p.catalog.baseType(ct.typ)
+	if ct.arrayDims > 0 {
+		base = p.catalog.types[int(ct.typ.ElemTypeOID.Int32)]
+	}
+	typmods := ct.typmods
+	if ct.typ.TypeType == "d" {
+		// модификаторы типа колонки берутся из домена
+		typmods = domainTypmods(ct.typ)
+	}
+	col.CharacterMaxLength, col.NumericPriecision, col.NumericScale = typeModifiers(base, typmods)
+	return col
+}
+
+// typeModifiers возвращает длину строки и точность числа так же, как функции information_schema.
+func typeModifiers(base query.Type, typmods []int) (charLen, precision, scale sql.NullInt32) {
+	if base.TypeSchema != pgCatalog {
+		return charLen, precision, scale
+	}
+	switch base.TypeName {
+	case "bpchar", "bit":
+		if len(typmods) == 0 {
+			return validInt32(1), precision, scale
+		}
+		return validInt32(typmods[0]), precision, scale
+	case "varchar", "varbit":
+		if len(typmods) != 0 {
+			charLen = validInt32(typmods[0])
+		}
+		return charLen, precision, scale
+	case "numeric":
+		if len(typmods) != 0 {
+			precision = validInt32(typmods[0])
+			scale = validInt32(0)
+		}
+		if len(typmods) > 1 {
+			scale = validInt32(typmods[1])
+		}
+		return charLen, precision, scale
+	case "int2", "int4", "int8":
+		return charLen, validInt32(builtinTypesByName[base.TypeName].precision), validInt32(0)
+	case "float4", "float8":
+		return charLen, validInt32(builtinTypesByName[base.TypeName].precision), scale
+	}
+	return charLen, precision, scale
+}
+
+// domainTypmods восстанавливает модификаторы базового типа домена.
+func domainTypmods(dom query.Type) []int {
+	switch {
+	case dom.DomainCharacterMaxSize.Valid:
+		return []int{int(dom.DomainCharacterMaxSize.Int32)}
+	case dom.DomainIsNumeric && dom.DomainNumericPrecision.Valid:
+		return []int{int(dom.DomainNumericPrecision.Int32), int(dom.DomainNumericScale.Int32)}
+	}
+	return nil
+}
+
+func (p *SQLParser) parseColumnConstraint(t *ddlTable, col *query.Column) error {
+	var conName string
+	if p.acceptKeyword("constraint") {
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		conName = name
+	}
+
+	switch {
+	case p.acceptKeyword("not", "null"):
+		col.IsNullable = true
+	case p.acceptKeyword("null"):
+		col.IsNullable = false
+	case p.acceptKeyword("default"):
+		expr, err := p.columnExpr()
+		if err != nil {
+			return err
+		}
+		col.HasDefault = true
+		col.DefaultExpr = sql.NullString{String: expr, Valid: true}
+	case p.acceptKeyword("generated"):
+		return p.parseGenerated(t, col)
+	case p.acceptKeyword("collate"):
+		if _, err := p.qualifiedName(); err != nil {
+			return err
+		}
+	case p.isKeyword(p.peek(), "check"):
+		return p.parseCheck(t, conName, []string{col.ColumnName})
+	case p.acceptKeyword("unique"):
+		return p.addUniqueConstraint(t, conName, "u", []string{col.ColumnName}, p.parseNullsDistinct())
+	case p.acceptKeyword("primary", "key"):
+		return p.addUniqueConstraint(t, conName, "p", []string{col.ColumnName}, false)
+	case p.acceptKeyword("references"):
+		return p.parseReferences(t, conName, []string{col.ColumnName})
+	case p.acceptConstraintAttributes():
+	default:
+		return p.errorf("unexpected %q in column definition", p.peek().value)
+	}
+	return nil
+}
+
+// parseGenerated разбирает GENERATED ALWAYS AS (expr) STORED и GENERATED ... AS IDENTITY.
+func (p *SQLParser) parseGenerated(t *ddlTable, col *query.Column) error {
+	identity := "a"
+	if !p.acceptKeyword("always") {
+		if !p.acceptKeyword("by", "default") {
+			return p.errorf("expected ALWAYS or BY DEFAULT")
+		}
+		identity = "d"
+	}
+	if err := p.expectKeyword("as"); err != nil {
+		return err
+	}
+	if p.acceptKeyword("identity") {
+		return p.parseIdentity(t, col, identity)
+	}
+	from, to, err := p.skipParens()
+	if err != nil {
+		return err
+	}
+	if err := p.expectKeyword("stored"); err != nil {
+		return err
+	}
+	col.HasDefault = true
+	col.IsGenerated = true
+	col.DefaultExpr = sql.NullString{String: p.tokensText(from, to), Valid: true}
+	return nil
+}
+
+// parseIdentity разбирает параметры последовательности IDENTITY колонки после AS IDENTITY.
+func (p *SQLParser) parseIdentity(t *ddlTable, col *query.Column, identity string) error {
+	seq := p.newColumnSequence(t, col.ColumnName, p.catalog.types[col.TypeOID])
+	if p.acceptOp("(") {
+		if err := p.parseSequenceOptions(seq, func() bool { return p.isOp(")") }); err != nil {
+			return err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return err
+		}
+	}
+	p.catalog.addSequence(seq)
+
+	col.IsNullable = true
+	col.Identity = identity
+	col.SequenceSchema = sql.NullString{String: seq.name.Schema, Valid: true}
+	col.SequenceName = sql.NullString{String: seq.name.Name, Valid: true}
+	return nil
+}
+
+// newColumnSequence возвращает последовательность SERIAL или IDENTITY колонки с именем по умолчанию.
+func (p *SQLParser) newColumnSequence(t *ddlTable, colName string, typ query.Type) *ddlSequence {
+	return &ddlSequence{
+		name: schema.Identifier{
+			Schema: t.table.Schema,
+			Name:   chooseName(t.table.Table, colName, "seq", p.relNameTaken(t)),
+		},
+		typeName: p.catalog.baseType(typ).TypeName,
+	}
+}
+
+// columnExpr возвращает выражение значения по умолчанию до следующего ограничения колонки.
+func (p *SQLParser) columnExpr() (string, error) {
+	from := p.pos
+	for {
+		tok := p.peek()
+		if p.isOp(",") || p.isOp(")") || p.isStatementEnd() {
+			break
+		}
+		if p.pos > from && p.isKeyword(tok,
+			"constraint", "not", "null", "check", "unique", "primary",
+			"references", "generated", "collate", "deferrable", "initially",
+		) {
+			break
+		}
+		if p.isOp("(") {
+			if _, _, err := p.skipParens(); err != nil {
+				return "", err
+			}
+			continue
+		}
+		p.next()
+	}
+	if p.pos == from {
+		return "", p.errorf("expected expression")
+	}
+	return p.tokensText(from, p.pos), nil
+}
+
+func (p *SQLParser) parseTableConstraint(t *ddlTable) error {
+	var conName string
+	if p.acceptKeyword("constraint") {
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		conName = name
+	}
+
+	switch {
+	case p.isKeyword(p.peek(), "check"):
+		return p.parseCheck(t, conName, nil)
+	case p.acceptKeyword("unique"):
+		nullsNotDistinct := p.parseNullsDistinct()
+		cols, err := p.identList()
+		if err != nil {
+			return err
+		}
+		if err := p.addUniqueConstraint(t, conName, "u", cols, nullsNotDistinct); err != nil {
+			return err
+		}
+	case p.acceptKeyword("primary", "key"):
+		cols, err := p.identList()
+		if err != nil {
+			return err
+		}
+		if err := p.addUniqueConstraint(t, conName, "p", cols, false); err != nil {
+			return err
+		}
+	case p.acceptKeyword("foreign", "key"):
+		cols, err := p.identList()
+		if err != nil {
+			return err
+		}
+		if err := p.expectKeyword("references"); err != nil {
+			return err
+		}
+		return p.parseReferences(t, conName, cols)
+	case p.acceptKeyword("exclude"):
+		return p.parseExclude(t, conName)
+	default:
+		return p.errorf("unexpected %q in table constraint", p.peek().value)
+	}
+	return p.skipIndexParameters()
+}
+
+// parseNullsDistinct разбирает NULLS [NOT] DISTINCT. Возвращает true для NULLS NOT DISTINCT.
+func (p *SQLParser) parseNullsDistinct() bool {
+	if p.acceptKeyword("nulls", "not", "distinct") {
+		return true
+	}
+	p.acceptKeyword("nulls", "distinct")
+	return false
+}
+
+// skipIndexParameters пропускает INCLUDE, WITH и USING INDEX TABLESPACE у ограничений с индексом.
+func (p *SQLParser) skipIndexParameters() error {
+	for {
+		switch {
+		case p.acceptKeyword("include"), p.acceptKeyword("with"):
+			if _, _, err := p.skipParens(); err != nil {
+				return err
+			}
+		case p.acceptKeyword("using", "index", "tablespace"):
+			if _, err := p.ident(); err != nil {
+				return err
+			}
+		case p.acceptConstraintAttributes():
+		default:
+			return nil
+		}
+	}
+}
+
+// acceptConstraintAttributes пропускает DEFERRABLE, INITIALLY DEFERRED, NOT VALID и NO INHERIT.
+func (p *SQLParser) acceptConstraintAttributes() bool {
+	switch {
+	case p.acceptKeyword("deferrable"),
+		p.acceptKeyword("not", "deferrable"),
+		p.acceptKeyword("initially", "deferred"),
+		p.acceptKeyword("initially", "immediate"),
+		p.acceptKeyword("not", "valid"),
+		p.acceptKeyword("no", "inherit"):
+		return true
+	}
+	return false
+}
+
+func (p *SQLParser) parseCheck(t *ddlTable, conName string, columns []string) error {
+	from := p.pos
+	p.next() // CHECK
+	exprFrom, exprTo, err := p.skipParens()
+	if err != nil {
+		return err
+	}
+	def := p.tokensText(from, p.pos)
+	for p.acceptConstraintAttributes() {
+	}
+
+	if conName == "" {
+		var colName string
+		if len(columns) == 1 {
+			colName = columns[0]
+		}
+		conName = chooseName(t.table.Table, colName, "check", p.constraintNameTaken(t))
+	}
+	if columns == nil {
+		columns = p.exprColumns(t, exprFrom, exprTo)
+	}
+	return p.addConstraint(t, &ddlConstraint{
+		con: query.Constraint{
+			ConstraintName: conName,
+			SchemaName:     t.table.Schema,
+			ConstraintType: "c",
+			ConstraintDef:  def,
+		},
+		columns: columns,
+	})
+}
+
+// exprColumns возвращает колонки таблицы, которые упоминаются в выражении.
+func (p *SQLParser) exprColumns(t *ddlTable, from, to int) []string {
+	var cols []string
+	seen := make(map[string]bool)
+	for _, tok := range p.toks[from:to] {
+		if tok.kind != tokenIdent && tok.kind != tokenQuotedIdent {
+			continue
+		}
+		if _, ok := t.column(tok.value); ok && !seen[tok.value] {
+			seen[tok.value] = true
+			cols = append(cols, tok.value)
+		}
+	}
+	return cols
+}
+
+func (p *SQLParser) addUniqueConstraint(
+	t *ddlTable,
+	conName, conType string,
+	columns []string,
+	nullsNotDistinct bool,
+) error {
+	if conType == "p" {
+		for _, c := range t.constraints {
+			if c.con.ConstraintType == "p" {
+				return xerrors.Errorf("multiple primary keys for table %q are not allowed", t.table.Table)
+			}
+		}
+	}
+	if conName == "" {
+		if conType == "p" {
+			conName = chooseName(t.table.Table, "", "pkey", p.relNameTaken(t))
+		} else {
+			conName = chooseName(t.table.Table, strings.Join(columns, "_"), "key", p.relNameTaken(t))
+		}
+	}
+
+	def := "UNIQUE"
+	indexDef := "UNIQUE INDEX"
+	if conType == "p" {
+		def = "PRIMARY KEY"
+	}
+	if nullsNotDistinct {
+		def += " NULLS NOT DISTINCT"
+	}
+	colsDef := quoteIdents(columns)
+
+	dc := &ddlConstraint{
+		con: query.Constraint{
+			ConstraintName: conName,
+			SchemaName:     t.table.Schema,
+			ConstraintType: conType,
+			ConstraintDef:  fmt.Sprintf("%s (%s)", def, colsDef),
+		},
+		columns: columns,
+	}
+	if err := p.addConstraint(t, dc); err != nil {
+		return err
+	}
+	nullsDef := ""
+	if nullsNotDistinct {
+		nullsDef = " NULLS NOT DISTINCT"
+	}
+	return p.addIndex(t, &ddlIndex{
+		idx: query.Index{
+			IndexName:          conName,
+			IsUnique:           true,
+			IsPrimary:          conType == "p",
+			IsNullsNotDistinct: nullsNotDistinct,
+			IndexDefinition: fmt.Sprintf("CREATE %s %s ON %s USING btree (%s)%s",
+				indexDef, quoteIdent(conName), p.tableName(t), colsDef, nullsDef),
+		},
+		columns:    columns,
+		constraint: dc,
+	})
+}
+
+func (p *SQLParser) parseReferences(t *ddlTable, conName string, columns []string) error {
+	refTable, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	var refColumns []string
+	if p.isOp("(") {
+		refColumns, err = p.identList()
+		if err != nil {
+			return err
+		}
+	}
+
+	var actions []string
+	for {
+		from := p.pos
+		switch {
+		case p.acceptKeyword("match", "full"),
+			p.acceptKeyword("match", "partial"),
+			p.acceptKeyword("match", "simple"):
+		case p.acceptKeyword("on", "delete"), p.acceptKeyword("on", "update"):
+			if err := p.parseReferentialAction(); err != nil {
+				return err
+			}
+		case p.acceptConstraintAttributes():
+		default:
+			if conName == "" {
+				conName = chooseName(t.table.Table, strings.Join(columns, "_"), "fkey", p.constraintNameTaken(t))
+			}
+			def := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", quoteIdents(columns), p.identifierText(refTable))
+			if len(refColumns) != 0 {
+				def += fmt.Sprintf("(%s)", quoteIdents(refColumns))
+			}
+			if len(actions) != 0 {
+				def += " " + strings.Join(actions, " ")
+			}
+			return p.addConstraint(t, &ddlConstraint{
+				con: query.Constraint{
+					ConstraintName: conName,
+					SchemaName:     t.table.Schema,
+					ConstraintType: "f",
+					ConstraintDef:  def,
+				},
+				columns:    columns,
+				refTable:   refTable,
+				refColumns: refColumns,
+			})
+		}
+		actions = append(actions, strings.ToUpper(p.tokensText(from, p.pos)))
+	}
+}
+
+func (p *SQLParser) parseReferentialAction() error {
+	switch {
+	case p.acceptKeyword("no", "action"),
+		p.acceptKeyword("restrict"),
+		p.acceptKeyword("cascade"):
+		return nil
+	case p.acceptKeyword("set", "null"), p.acceptKeyword("set", "default"):
+		if p.isOp("(") {
+			_, err := p.identList()
+			return err
+		}
+		return nil
+	}
+	return p.errorf("unexpected referential action %q", p.peek().value)
+}
+
+func (p *SQLParser) parseExclude(t *ddlTable, conName string) error {
+	method := "gist"
+	if p.acceptKeyword("using") {
+		m, err := p.ident()
+		if err != nil {
+			return err
+		}
+		method = m
+	}
+
+	var columns []string
+	listFrom := p.pos
+	if err := p.parseList(func() error {
+		// элемент ограничения - колонка или выражение в скобках
+		if p.isOp("(") {
+			if _, _, err := p.skipParens(); err != nil {
+				return err
+			}
+		} else {
+			col, err := p.ident()
+			if err != nil {
+				return err
+			}
+			columns = append(columns, col)
+		}
+		for !p.isKeyword(p.peek(), "with") && !p.isStatementEnd() {
+			p.next()
+		}
+		if err := p.expectKeyword("with"); err != nil {
+			return err
+		}
+		for !p.isOp(",") && !p.isOp(")") && !p.isStatementEnd() {
+			p.next()
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	def := fmt.Sprintf("EXCLUDE USING %s (%s)", method, p.tokensText(listFrom+1, p.pos-1))
+	if err := p.skipIndexParameters(); err != nil {
+		return err
+	}
+	if p.acceptKeyword("where") {
+		from, to, err := p.skipParens()
+		if err != nil {
+			return err
+		}
+		def += fmt.Sprintf(" WHERE (%s)", p.tokensText(from, to))
+	}
+
+	if conName == "" {
+		conName = chooseName(t.table.Table, strings.Join(columns, "_"), "excl", p.relNameTaken(t))
+	}
+	dc := &ddlConstraint{
+		con: query.Constraint{
+			ConstraintName: conName,
+			SchemaName:     t.table.Schema,
+			ConstraintType: "x",
+			ConstraintDef:  def,
+		},
+		columns: columns,
+	}
+	if err := p.addConstraint(t, dc); err != nil {
+		return err
+	}
+	return p.addIndex(t, &ddlIndex{
+		idx: query.Index{
+			IndexName: conName,
+			IndexDefinition: fmt.Sprintf("CREATE INDEX %s ON %s USING %s (%s)",
+				quoteIdent(conName), p.tableName(t), method, quoteIdents(columns)),
+		},
+		columns:    columns,
+		constraint: dc,
+	})
+}
+
+func (p *SQLParser) addConstraint(t *ddlTable, dc *ddlConstraint) error {
+	for _, c := range t.constraints {
+		if c.con.ConstraintName == dc.con.ConstraintName {
+			return xerrors.Errorf("constraint %q for table %q already exists", dc.con.ConstraintName, t.table.Table)
+		}
+	}
+	dc.con.ConstraintOID = p.catalog.newOID()
+	t.constraints = append(t.constraints, dc)
+	p.catalog.constraintNames[qualifiedName(schema.Identifier{Schema: t.table.Schema, Name: dc.con.ConstraintName})] = true
+	return nil
+}
+
+func (p *SQLParser) addIndex(t *ddlTable, di *ddlIndex) error {
+	name := qualifiedName(schema.Identifier{Schema: t.table.Schema, Name: di.idx.IndexName})
+	if p.catalog.relNames[name] {
+		return xerrors.Errorf("relation %q already exists", name)
+	}
+	p.catalog.relNames[name] = true
+	di.idx.IndexOID = p.catalog.newOID()
+	di.idx.IndexSchema = t.table.Schema
+	t.indexes = append(t.indexes, di)
+	if di.constraint != nil {
+		di.constraint.index = di
+	}
+	return nil
+}
+
+func (p *SQLParser) relNameTaken(t *ddlTable) func(string) bool {
+	return func(name string) bool {
+		return p.catalog.relNames[qualifiedName(schema.Identifier{Schema: t.table.Schema, Name: name})]
+	}
+}
+
+func (p *SQLParser) constraintNameTaken(t *ddlTable) func(string) bool {
+	return func(name string) bool {
+		return p.catalog.constraintNames[qualifiedName(schema.Identifier{Schema: t.table.Schema, Name: name})]
+	}
+}
+
+func (p *SQLParser) parseCreateIndex(unique bool) error {
+	p.acceptKeyword("concurrently")
+	ifNotExists := p.acceptKeyword("if", "not", "exists")
+	var indexName string
+	if !p.isKeyword(p.peek(), "on") {
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		indexName = name
+	}
+	if err := p.expectKeyword("on"); err != nil {
+		return err
+	}
+	p.acceptKeyword("only")
+	tableName, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	t, ok := p.catalog.getTable(tableName)
+	if !ok {
+		return xerrors.Errorf("table %q not found", qualifiedName(tableName))
+	}
+	method := "btree"
+	if p.acceptKeyword("using") {
+		if method, err = p.ident(); err != nil {
+			return err
+		}
+	}
+
+	var (
+		columns     []string
+		elements    []string
+		expressions bool
+	)
+	if err := p.parseList(func() error {
+		from := p.pos
+		switch {
+		case p.isOp("("):
+			expressions = true
+			if _, _, err := p.skipParens(); err != nil {
+				return err
+			}
+		case p.peekN(1).kind == tokenOp && p.peekN(1).value == "(":
+			// вызов функции
+			expressions = true
+			p.next()
+			if _, _, err := p.skipParens(); err != nil {
+				return err
+			}
+		default:
+			col, err := p.ident()
+			if err != nil {
+				return err
+			}
+			columns = append(columns, col)
+		}
+		for !p.isOp(",") && !p.isOp(")") && !p.isStatementEnd() {
+			p.next()
+		}
+		elements = append(elements, p.tokensText(from, p.pos))
+		return nil
+	}); err != nil {
+		return err
+	}
+	if p.acceptKeyword("include") {
+		if _, _, err := p.skipParens(); err != nil {
+			return err
+		}
+	}
+	nullsNotDistinct := p.parseNullsDistinct()
+	// WITH и TABLESPACE не влияют на схему, условие частичного индекса сохраняется в определении
+	var predicate string
+	for !p.isStatementEnd() {
+		if p.acceptKeyword("where") {
+			from := p.pos
+			p.skipStatement()
+			predicate = p.tokensText(from, p.pos)
+			break
+		}
+		if p.isOp("(") {
+			if _, _, err := p.skipParens(); err != nil {
+				return err
+			}
+			continue
+		}
+		p.next()
+	}
+
+	if indexName == "" {
+		colNames := strings.Join(columns, "_")
+		if colNames == "" {
+			colNames = "expr"
+		}
+		label := "idx"
+		if unique {
+			label = "key"
+		}
+		indexName = chooseName(t.table.Table, colNames, label, p.relNameTaken(t))
+	}
+	if ifNotExists && p.relNameTaken(t)(indexName) {
+		return nil
+	}
+
+	def := "CREATE INDEX"
+	if unique {
+		def = "CREATE UNIQUE INDEX"
+	}
+	def = fmt.Sprintf("%s %s ON %s USING %s (%s)",
+		def, quoteIdent(indexName), p.tableName(t), method, strings.Join(elements, ", "))
+	if nullsNotDistinct {
+		def += " NULLS NOT DISTINCT"
+	}
+	if predicate != "" {
+		def += " WHERE (" + predicate + ")"
+	}
+	return p.addIndex(t, &ddlIndex{
+		idx: query.Index{
+			IndexName:          indexName,
+			IsUnique:           unique,
+			IsNullsNotDistinct: nullsNotDistinct,
+			IndexDefinition:    def,
+		},
+		columns:     columns,
+		expressions: expressions,
+	})
+}
+
+func (p *SQLParser) parseAlterTable() error {
+	ifExists := p.acceptKeyword("if", "exists")
+	p.acceptKeyword("only")
+	name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	p.acceptOp("*")
+	t, ok := p.catalog.getTable(name)
+	if !ok {
+		if ifExists {
+			p.skipStatement()
+			return nil
+		}
+		return xerrors.Errorf("table %q not found", qualifiedName(name))
+	}
+
+	for {
+		if err := p.parseAlterTableAction(t); err != nil {
+			return xerrors.Errorf("table %q: %w", qualifiedName(name), err)
+		}
+		if !p.acceptOp(",") {
+			return nil
+		}
+	}
+}
+
+func (p *SQLParser) parseAlterTableAction(t *ddlTable) error {
+	switch {
+	case p.acceptKeyword("add"):
+		if p.isTableConstraintStart() {
+			return p.parseTableConstraint(t)
+		}
+		p.acceptKeyword("column")
+		if p.acceptKeyword("if", "not", "exists") {
+			if col, ok := t.column(p.peek().value); ok {
+				p.log.Debug("column already exists", zap.String("column", col.ColumnName))
+				p.skipAction()
+				return nil
+			}
+		}
+		return p.parseColumnDef(t)
+	case p.acceptKeyword("drop", "constraint"):
+		ifExists := p.acceptKeyword("if", "exists")
+		conName, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if !t.dropConstraint(conName) && !ifExists {
+			return xerrors.Errorf("constraint %q not found", conName)
+		}
+		p.skipAction()
+		return nil
+	case p.acceptKeyword("drop"):
+		p.acceptKeyword("column")
+		ifExists := p.acceptKeyword("if", "exists")
+		colName, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if !t.dropColumn(colName) && !ifExists {
+			return xerrors.Errorf("column %q not found", colName)
+		}
+		p.skipAction()
+		return nil
+	case p.acceptKeyword("disable", "trigger"):
+		return p.setTriggerDisabled(t, true)
+	case p.acceptKeyword("enable", "trigger"),
+		p.acceptKeyword("enable", "replica", "trigger"),
+		p.acceptKeyword("enable", "always", "trigger"):
+		return p.setTriggerDisabled(t, false)
+	case p.acceptKeyword("alter"):
+		p.acceptKeyword("column")
+		colName, err := p.ident()
+		if err != nil {
+			return err
+		}
+		col, ok := t.column(colName)
+		if !ok {
+			return xerrors.Errorf("column %q not found", colName)
+		}
+		return p.parseAlterColumn(t, col)
+	}
+	p.log.Debug("skip alter table action", zap.String("action", p.peek().value))
+	p.skipAction()
+	return nil
+}
+
+// setTriggerDisabled включает или выключает триггер таблицы. ALL и USER относятся ко всем триггерам.
+func (p *SQLParser) setTriggerDisabled(t *ddlTable, disabled bool) error {
+	if p.acceptKeyword("all") || p.acceptKeyword("user") {
+		for idx := range t.triggers {
+			t.triggers[idx].IsDisabled = disabled
+		}
+		return nil
+	}
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	idx := t.triggerIndex(name)
+	if idx < 0 {
+		return xerrors.Errorf("trigger %q not found", name)
+	}
+	t.triggers[idx].IsDisabled = disabled
+	return nil
+}
+
+func (p *SQLParser) parseAlterColumn(t *ddlTable, col *query.Column) error {
+	switch {
+	case p.acceptKeyword("set", "not", "null"):
+		col.IsNullable = true
+	case p.acceptKeyword("drop", "not", "null"):
+		col.IsNullable = false
+	case p.acceptKeyword("set", "default"):
+		expr, err := p.columnExpr()
+		if err != nil {
+			return err
+		}
+		col.HasDefault = true
+		col.DefaultExpr = sql.NullString{String: expr, Valid: true}
+	case p.acceptKeyword("drop", "default"):
+		col.HasDefault = false
+		col.DefaultExpr = sql.NullString{}
+	case p.acceptKeyword("set", "data", "type"), p.acceptKeyword("type"):
+		ct, err := p.parseType()
+		if err != nil {
+			return err
+		}
+		newCol := p.newColumn(col.ColumnName, ct)
+		col.TypeOID = newCol.TypeOID
+		col.ArrayDims = newCol.ArrayDims
+		col.IsNumeric = newCol.IsNumeric
+		col.CharacterMaxLength = newCol.CharacterMaxLength
+		col.NumericPriecision = newCol.NumericPriecision
+		col.NumericScale = newCol.NumericScale
+		p.skipAction()
+	case p.acceptKeyword("add", "generated"):
+		if err := p.parseGenerated(t, col); err != nil {
+			return err
+		}
+		if col.Identity == "" {
+			return xerrors.Errorf("column %q: expected identity", col.ColumnName)
+		}
+	case p.acceptKeyword("drop", "identity"):
+		col.Identity = ""
+		col.SequenceSchema = sql.NullString{}
+		col.SequenceName = sql.NullString{}
+		p.skipAction()
+	default:
+		p.log.Debug("skip alter column action", zap.String("action", p.peek().value))
+		p.skipAction()
+	}
+	return nil
+}
+
+func (p *SQLParser) parseCreateType() error {
+	name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	if _, ok := p.catalog.lookupType(name); ok {
+		return xerrors.Errorf("type %q already exists", qualifiedName(name))
+	}
+	typ := query.Type{
+		TypeSchema: name.Schema,
+		TypeName:   name.Name,
+	}
+
+	switch {
+	case p.acceptKeyword("as", "enum"):
+		var values []string
+		if err := p.parseList(func() error {
+			if p.isOp(")") {
+				return nil
+			}
+			tok := p.next()
+			if tok.kind != tokenString {
+				return p.errorf("expected enum label, got %q", tok.value)
+			}
+			values = append(values, tok.value)
+			return nil
+		}); err != nil {
+			return err
+		}
+		typ.TypeType = "e"
+		typ.TypeOID = p.catalog.newOID()
+		p.catalog.enums[typ.TypeOID] = query.Enum{TypeOID: typ.TypeOID, Values: values}
+	case p.acceptKeyword("as", "range"):
+		var subtype query.Type
+		if err := p.parseList(func() error {
+			param, err := p.ident()
+			if err != nil {
+				return err
+			}
+			if err := p.expectOp("="); err != nil {
+				return err
+			}
+			if param != "subtype" {
+				for !p.isOp(",") && !p.isOp(")") && !p.isStatementEnd() {
+					p.next()
+				}
+				return nil
+			}
+			ct, err := p.parseType()
+			if err != nil {
+				return err
+			}
+			subtype = ct.typ
+			return nil
+		}); err != nil {
+			return err
+		}
+		if subtype.TypeOID == 0 {
+			return xerrors.Errorf("range type %q subtype is not specified", qualifiedName(name))
+		}
+		typ.TypeType = "r"
+		typ.TypeOID = p.catalog.newOID()
+		typ.RangeElementTypeOID = validInt32(subtype.TypeOID)
+	case p.acceptKeyword("as"):
+		// поля составного типа хранятся как колонки отдельного отношения
+		relOID := p.catalog.newOID()
+		var attrs []query.Column
+		if err := p.parseList(func() error {
+			if p.isOp(")") {
+				return nil
+			}
+			attrName, err := p.ident()
+			if err != nil {
+				return err
+			}
+			ct, err := p.parseType()
+			if err != nil {
+				return err
+			}
+			attr := p.newColumn(attrName, ct)
+			attr.TableOID = relOID
+			attr.ColumnNum = len(attrs) + 1
+			attrs = append(attrs, attr)
+			if p.acceptKeyword("collate") {
+				_, err := p.qualifiedName()
+				return err
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		typ.TypeType = "c"
+		typ.TypeOID = p.catalog.newOID()
+		typ.CompositeRelationOID = validInt32(relOID)
+		p.catalog.composites[relOID] = attrs
+	default:
+		p.log.Warn("unsupported type definition, skip it", zap.String("type", qualifiedName(name)))
+		p.skipStatement()
+		return nil
+	}
+
+	p.catalog.addType(typ)
+	return nil
+}
+
+func (p *SQLParser) parseCreateDomain() error {
+	name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	if _, ok := p.catalog.lookupType(name); ok {
+		return xerrors.Errorf("type %q already exists", qualifiedName(name))
+	}
+	p.acceptKeyword("as")
+	ct, err := p.parseType()
+	if err != nil {
+		return err
+	}
+
+	base := p.catalog.baseType(ct.typ)
+	typmods := ct.typmods
+	if ct.typ.TypeType == "d" {
+		typmods = domainTypmods(ct.typ)
+	}
+	charLen, precision, scale := typeModifiers(base, typmods)
+	typ := query.Type{
+		TypeOID:                p.catalog.newOID(),
+		TypeSchema:             name.Schema,
+		TypeName:               name.Name,
+		TypeType:               "d",
+		DomainTypeOID:          validInt32(ct.typ.TypeOID),
+		DomainCharacterMaxSize: charLen,
+		DomainIsNumeric:        base.TypeSchema == pgCatalog && base.TypeName == "numeric",
+		DomainNumericPrecision: precision,
+		DomainNumericScale:     scale,
+		DomainArrayDims:        ct.arrayDims,
+	}
+
+	for !p.isStatementEnd() {
+		switch {
+		case p.acceptKeyword("constraint"):
+			if _, err := p.ident(); err != nil {
+				return err
+			}
+		case p.acceptKeyword("not", "null"):
+			typ.DomainIsNotNullable = true
+		case p.acceptKeyword("null"):
+			typ.DomainIsNotNullable = false
+		case p.acceptKeyword("collate"):
+			if _, err := p.qualifiedName(); err != nil {
+				return err
+			}
+		case p.acceptKeyword("default"):
+			if _, err := p.columnExpr(); err != nil {
+				return err
+			}
+		case p.acceptKeyword("check"):
+			// TODO сохранять ограничения домена
+			if _, _, err := p.skipParens(); err != nil {
+				return err
+			}
+		case p.acceptConstraintAttributes():
+		default:
+			return p.errorf("unexpected %q in domain definition", p.peek().value)
+		}
+	}
+
+	p.catalog.addType(typ)
+	return nil
+}
+
+func (p *SQLParser) parseCreateSequence() error {
+	ifNotExists := p.acceptKeyword("if", "not", "exists")
+	name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	if _, ok := p.catalog.sequences[qualifiedName(name)]; ok {
+		if ifNotExists {
+			p.skipStatement()
+			return nil
+		}
+		return xerrors.Errorf("sequence %q already exists", qualifiedName(name))
+	}
+
+	seq := &ddlSequence{name: name}
+	if err := p.parseSequenceOptions(seq, p.isStatementEnd); err != nil {
+		return xerrors.Errorf("sequence %q: %w", qualifiedName(name), err)
+	}
+	p.catalog.addSequence(seq)
+	return nil
+}
+
+// parseCreateFunction сохраняет текст функции, чтобы добавить его к триггерам, которые ее вызывают.
+func (p *SQLParser) parseCreateFunction() error {
+	name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	p.skipStatement()
+	p.catalog.functions[qualifiedName(name)] = p.src[p.toks[p.stmt].start:p.toks[p.pos-1].end]
+	return nil
+}
+
+// parseCreateTrigger разбирает CREATE TRIGGER. Тип триггера кодируется так же, как pg_trigger.tgtype.
+func (p *SQLParser) parseCreateTrigger() error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	trigger := query.Trigger{TriggerOID: p.catalog.newOID(), TriggerName: name}
+	switch {
+	case p.acceptKeyword("before"):
+		trigger.TriggerType |= pgTriggerTypeBefore
+	case p.acceptKeyword("instead", "of"):
+		trigger.TriggerType |= pgTriggerTypeInstead
+	case p.acceptKeyword("after"):
+	default:
+		return p.errorf("expected BEFORE, AFTER or INSTEAD OF, got %q", p.peek().value)
+	}
+	if err := p.parseTriggerEvents(&trigger); err != nil {
+		return xerrors.Errorf("trigger %q: %w", name, err)
+	}
+
+	if err := p.expectKeyword("on"); err != nil {
+		return err
+	}
+	tableName, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	t, ok := p.catalog.getTable(tableName)
+	if !ok {
+		return xerrors.Errorf("table %q not found", qualifiedName(tableName))
+	}
+
+	for !p.acceptKeyword("execute") {
+		switch {
+		case p.acceptKeyword("for"):
+			p.acceptKeyword("each")
+			if p.acceptKeyword("row") {
+				trigger.TriggerType |= pgTriggerTypeRow
+			} else if err := p.expectKeyword("statement"); err != nil {
+				return err
+			}
+		case p.acceptKeyword("when"):
+			from, to, err := p.skipParens()
+			if err != nil {
+				return err
+			}
+			trigger.Condition = sql.NullString{String: p.tokensText(from, to), Valid: true}
+		case p.isStatementEnd():
+			return p.errorf("expected EXECUTE FUNCTION, got %q", p.peek().value)
+		default:
+			// FROM, DEFERRABLE и REFERENCING не влияют на схему
+			p.next()
+		}
+	}
+	if !p.acceptKeyword("function") {
+		if err := p.expectKeyword("procedure"); err != nil {
+			return err
+		}
+	}
+	fn, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	if _, _, err := p.skipParens(); err != nil {
+		return err
+	}
+	trigger.FunctionSchema = fn.Schema
+	trigger.FunctionName = fn.Name
+	trigger.Definition = p.text(p.toks[p.stmt].start, p.toks[p.pos-1].end)
+	t.addTrigger(trigger)
+	return nil
+}
+
+// parseTriggerEvents разбирает события триггера, разделенные OR.
+func (p *SQLParser) parseTriggerEvents(trigger *query.Trigger) error {
+	for {
+		switch {
+		case p.acceptKeyword("insert"):
+			trigger.TriggerType |= pgTriggerTypeInsert
+		case p.acceptKeyword("delete"):
+			trigger.TriggerType |= pgTriggerTypeDelete
+		case p.acceptKeyword("truncate"):
+			trigger.TriggerType |= pgTriggerTypeTruncate
+		case p.acceptKeyword("update"):
+			trigger.TriggerType |= pgTriggerTypeUpdate
+			if p.acceptKeyword("of") {
+				for {
+					if _, err := p.ident(); err != nil {
+						return err
+					}
+					if !p.acceptOp(",") {
+						break
+					}
+				}
+			}
+		default:
+			return p.errorf("expected trigger event, got %q", p.peek().value)
+		}
+		if !p.acceptKeyword("or") {
+			return nil
+		}
+	}
+}
+
+// parseSequenceOptions разбирает параметры последовательности до тех пор, пока end не вернет true.
+func (p *SQLParser) parseSequenceOptions(seq *ddlSequence, end func() bool) error {
+	for !end() {
+		var err error
+		switch {
+		case p.acceptKeyword("as"):
+			var ct columnType
+			ct, err = p.parseType()
+			seq.typeName = p.catalog.baseType(ct.typ).TypeName
+		case p.acceptKeyword("increment"):
+			p.acceptKeyword("by")
+			seq.increment, err = p.signedInt()
+		case p.acceptKeyword("start"):
+			p.acceptKeyword("with")
+			seq.start, err = p.signedInt()
+		case p.acceptKeyword("minvalue"):
+			seq.minValue, err = p.signedInt()
+		case p.acceptKeyword("maxvalue"):
+			seq.maxValue, err = p.signedInt()
+		case p.acceptKeyword("no", "minvalue"):
+			seq.minValue = sql.NullInt64{}
+		case p.acceptKeyword("no", "maxvalue"):
+			seq.maxValue = sql.NullInt64{}
+		case p.acceptKeyword("cycle"):
+			seq.cycle = true
+		case p.acceptKeyword("no", "cycle"):
+			seq.cycle = false
+		case p.acceptKeyword("cache"):
+			_, err = p.signedInt()
+		case p.acceptKeyword("sequence", "name"):
+			seq.name, err = p.qualifiedName()
+		case p.acceptKeyword("owned", "by"):
+			// владелец последовательности не влияет на ее значения
+			if !p.acceptKeyword("none") {
+				_, err = p.qualifiedName()
+				if err == nil && p.acceptOp(".") {
+					_, err = p.ident()
+				}
+			}
+		default:
+			return p.errorf("unexpected %q in sequence options", p.peek().value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signedInt разбирает целое число со знаком.
+func (p *SQLParser) signedInt() (sql.NullInt64, error) {
+	sign := ""
+	if p.acceptOp("-") {
+		sign = "-"
+	} else {
+		p.acceptOp("+")
+	}
+	tok := p.peek()
+	n, err := strconv.ParseInt(sign+tok.value, 10, 64)
+	if tok.kind != tokenNumber || err != nil {
+		return sql.NullInt64{}, p.errorf("expected integer, got %q", tok.value)
+	}
+	p.next()
+	return sql.NullInt64{Int64: n, Valid: true}, nil
+}
+
+// parseType разбирает имя типа вместе с модификаторами и размерностью массива.
+func (p *SQLParser) parseType() (ct columnType, err error) {
+	tok := p.peek()
+	if tok.kind != tokenIdent && tok.kind != tokenQuotedIdent {
+		return ct, p.errorf("expected type name, got %q", tok.value)
+	}
+
+	var name schema.Identifier
+	switch {
+	case p.peekN(1).kind == tokenOp && p.peekN(1).value == ".":
+		name, err = p.qualifiedName()
+		if err != nil {
+			return ct, err
+		}
+	case tok.kind == tokenQuotedIdent:
+		p.next()
+		name = schema.Identifier{Schema: defaultSchema, Name: tok.value}
+		if tok.value == "char" {
+			name.Schema = pgCatalog
+		}
+	default:
+		p.next()
+		typeName := p.multiwordTypeName(tok.value)
+		ct.serial = serialTypes[typeName]
+		if alias, ok := builtinTypeAliases[typeName]; ok {
+			typeName = alias
+		}
+		name = schema.Identifier{Schema: pgCatalog, Name: typeName}
+		if _, ok := builtinTypesByName[typeName]; !ok {
+			name.Schema = defaultSchema
+		}
+	}
+
+	if p.isOp("(") {
+		if err := p.parseList(func() error {
+			tok := p.next()
+			n, err := strconv.Atoi(tok.value)
+			if err != nil {
+				return p.errorf("invalid type modifier %q", tok.value)
+			}
+			ct.typmods = append(ct.typmods, n)
+			return nil
+		}); err != nil {
+			return ct, err
+		}
+	}
+	if name.Schema == pgCatalog {
+		name.Name = typeNameSuffix(name.Name, ct.typmods)
+	}
+
+	typ, ok := p.catalog.lookupType(name)
+	if !ok {
+		return ct, xerrors.Errorf("type %q does not exist", qualifiedName(name))
+	}
+
+	for {
+		switch {
+		case p.acceptOp("["):
+			if p.peek().kind == tokenNumber {
+				p.next()
+			}
+			if err := p.expectOp("]"); err != nil {
+				return ct, err
+			}
+			ct.arrayDims++
+		case p.acceptKeyword("array"):
+			ct.arrayDims++
+			if p.acceptOp("[") {
+				if p.peek().kind == tokenNumber {
+					p.next()
+				}
+				if err := p.expectOp("]"); err != nil {
+					return ct, err
+				}
+			}
+		default:
+			if ct.arrayDims > 0 {
+				typ = p.catalog.arrayType(typ)
+			}
+			ct.typ = typ
+			return ct, nil
+		}
+	}
+}
+
+// multiwordTypeName дочитывает имена типов из нескольких слов, например DOUBLE PRECISION.
+func (p *SQLParser) multiwordTypeName(first string) string {
+	switch first {
+	case "double":
+		if p.acceptKeyword("precision") {
+			return "double precision"
+		}
+	case "character", "char":
+		if p.acceptKeyword("varying") {
+			return "character varying"
+		}
+	case "bit":
+		if p.acceptKeyword("varying") {
+			return "bit varying"
+		}
+	case "timestamp", "time":
+		if p.isOp("(") {
+			// точность указывается до часового пояса
+			if _, _, err := p.skipParens(); err != nil {
+				return first
+			}
+		}
+		switch {
+		case p.acceptKeyword("with", "time", "zone"):
+			return first + " with time zone"
+		case p.acceptKeyword("without", "time", "zone"):
+			return first + " without time zone"
+		}
+	case "interval":
+		for p.isKeyword(p.peek(), "year", "month", "day", "hour", "minute", "second", "to") {
+			p.next()
+		}
+	}
+	return first
+}
+
+// typeNameSuffix уточняет тип по модификаторам, например FLOAT(10) это float4.
+func typeNameSuffix(name string, typmods []int) string {
+	if name == "float8" && len(typmods) == 1 && typmods[0] <= 24 {
+		return "float4"
+	}
+	return name
+}
+
+// parseList разбирает список элементов в скобках, разделенных запятыми.
+func (p *SQLParser) parseList(elem func() error) error {
+	if err := p.expectOp("("); err != nil {
+		return err
+	}
+	for {
+		if err := elem(); err != nil {
+			return err
+		}
+		if p.acceptOp(",") {
+			continue
+		}
+		return p.expectOp(")")
+	}
+}
+
+// identList разбирает список идентификаторов в скобках.
+func (p *SQLParser) identList() ([]string, error) {
+	var res []string
+	err := p.parseList(func() error {
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		res = append(res, name)
+		return nil
+	})
+	return res, err
+}
+
+// skipParens пропускает выражение в скобках и возвращает границы лексем внутри скобок.
+func (p *SQLParser) skipParens() (from, to int, err error) {
+	if err := p.expectOp("("); err != nil {
+		return 0, 0, err
+	}
+	from = p.pos
+	for depth := 1; ; {
+		tok := p.next()
+		switch {
+		case tok.kind == tokenEOF:
+			return 0, 0, p.errorf("unbalanced parentheses")
+		case tok.kind != tokenOp:
+		case tok.value == "(":
+			depth++
+		case tok.value == ")":
+			depth--
+			if depth == 0 {
+				return from, p.pos - 1, nil
+			}
+		}
+	}
+}
+
+// skipStatement пропускает лексемы до конца выражения.
+func (p *SQLParser) skipStatement() {
+	for !p.isStatementEnd() {
+		if p.isOp("(") {
+			if _, _, err := p.skipParens(); err != nil {
+				return
+			}
+			continue
+		}
+		p.next()
+	}
+}
+
+// skipAction пропускает лексемы до следующего действия ALTER TABLE.
+func (p *SQLParser) skipAction() {
+	for !p.isOp(",") && !p.isStatementEnd() {
+		if p.isOp("(") {
+			if _, _, err := p.skipParens(); err != nil {
+				return
+			}
+			continue
+		}
+		p.next()
+	}
+}
+
+func (p *SQLParser) isStatementEnd() bool {
+	return p.isOp(";") || p.peek().kind == tokenEOF
+}
+
+func (p *SQLParser) statementHead() string {
+	from := p.peek().start
+	to := from
+	for i := p.pos; i < len(p.toks) && i < p.pos+3; i++ {
+		if p.toks[i].kind == tokenEOF || p.toks[i].value == ";" {
+			break
+		}
+		to = p.toks[i].end
+	}
+	return p.text(from, to)
+}
+
+func (p *SQLParser) peek() token { return p.peekN(0) }
+
+func (p *SQLParser) peekN(n int) token {
+	if p.pos+n >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.pos+n]
+}
+
+func (p *SQLParser) next() token {
+	tok := p.peek()
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *SQLParser) isKeyword(tok token, keywords ...string) bool {
+	if tok.kind != tokenIdent {
+		return false
+	}
+	for _, kw := range keywords {
+		if tok.value == kw {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptKeyword пропускает последовательность ключевых слов, если все они идут подряд.
+func (p *SQLParser) acceptKeyword(keywords ...string) bool {
+	for idx, kw := range keywords {
+		if !p.isKeyword(p.peekN(idx), kw) {
+			return false
+		}
+	}
+	p.pos += len(keywords)
+	return true
+}
+
+func (p *SQLParser) expectKeyword(keywords ...string) error {
+	if !p.acceptKeyword(keywords...) {
+		return p.errorf("expected %s, got %q", strings.ToUpper(strings.Join(keywords, " ")), p.peek().value)
+	}
+	return nil
+}
+
+func (p *SQLParser) isOp(op string) bool {
+	tok := p.peek()
+	return tok.kind == tokenOp && tok.value == op
+}
+
+func (p *SQLParser) acceptOp(op string) bool {
+	if p.isOp(op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *SQLParser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.errorf("expected %q, got %q", op, p.peek().value)
+	}
+	return nil
+}
+
+func (p *SQLParser) errorf(format string, args ...any) error {
+	return posError(p.src, p.peek().start, format, args...)
+}
+
+func (p *SQLParser) ident() (string, error) {
+	tok := p.peek()
+	if tok.kind != tokenIdent && tok.kind != tokenQuotedIdent {
+		return "", p.errorf("expected identifier, got %q", tok.value)
+	}
+	p.next()
+	return tok.value, nil
+}
+
+// qualifiedName разбирает имя объекта со схемой. Если схема не указана, то используется public.
+func (p *SQLParser) qualifiedName() (schema.Identifier, error) {
+	name, err := p.ident()
+	if err != nil {
+		return schema.Identifier{}, err
+	}
+	if !p.acceptOp(".") {
+		return schema.Identifier{Schema: defaultSchema, Name: name}, nil
+	}
+	objName, err := p.ident()
+	if err != nil {
+		return schema.Identifier{}, err
+	}
+	return schema.Identifier{Schema: name, Name: objName}, nil
+}
+
+// tokensText возвращает текст лексем from..to, где лексемы разделены одним пробелом.
+func (p *SQLParser) tokensText(from, to int) string {
+	var sb strings.Builder
+	for idx := from; idx < to; idx++ {
+		tok := p.toks[idx]
+		if idx > from && needSpace(p.toks[idx-1], tok) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(p.src[tok.start:tok.end])
+	}
+	return sb.String()
+}
+
+func needSpace(prev, cur token) bool {
+	if prev.kind == tokenOp && (prev.value == "(" || prev.value == "[" || prev.value == "::" || prev.value == ".") {
+		return false
+	}
+	if cur.kind == tokenOp && (cur.value == ")" || cur.value == "]" || cur.value == "," ||
+		cur.value == "::" || cur.value == "." || cur.value == "[") {
+		return false
+	}
+	if cur.kind == tokenOp && cur.value == "(" && prev.kind == tokenIdent {
+		// перед скобками вызова функции пробел не нужен
+		return isReservedBeforeParen(prev)
+	}
+	return true
+}
+
+// isReservedBeforeParen проверяет ключевые слова, после которых перед скобкой ставится пробел.
+func isReservedBeforeParen(tok token) bool {
+	switch tok.value {
+	case "and", "or", "not", "in", "check", "exists", "any", "all":
+		return tok.kind == tokenIdent
+	}
+	return false
+}
+
+func (p *SQLParser) text(from, to int) string {
+	return strings.Join(strings.Fields(p.src[from:to]), " ")
+}
+
+func (p *SQLParser) tableName(t *ddlTable) string {
+	return p.identifierText(schema.Identifier{Schema: t.table.Schema, Name: t.table.Table})
+}
+
+func (p *SQLParser) identifierText(name schema.Identifier) string {
+	return quoteIdent(name.Schema) + "." + quoteIdent(name.Name)
+}
+
+// quoteIdent экранирует идентификатор, только если это необходимо.
+func quoteIdent(name string) string {
+	for idx, r := range name {
+		if !(r == '_' || 'a' <= r && r <= 'z' || idx > 0 && '0' <= r && r <= '9') {
+			return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		}
+	}
+	return name
+}
+
+func quoteIdents(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, quoteIdent(name))
+	}
+	return strings.Join(quoted, ", ")
+}
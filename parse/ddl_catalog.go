@@ -0,0 +1,590 @@
+package parse
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/parse/query"
+	"github.com/Feresey/mtest/schema"
+)
+
+const (
+	// первый OID, который PostgreSQL выдает пользовательским объектам
+	firstNormalObjectID = 16384
+	// максимальная длина имени объекта
+	maxIdentifierLength = 63
+	defaultSchema       = "public"
+)
+
+// ddlCatalog хранит объекты, созданные SQL скриптом, в том же виде, в котором их возвращают запросы к базе.
+type ddlCatalog struct {
+	nextOID int
+
+	types     map[int]query.Type
+	typeNames map[string]int
+	enums     map[int]query.Enum
+	// поля составных типов по OID отношения типа
+	composites map[int][]query.Column
+
+	tables     map[string]*ddlTable
+	tableOrder []string
+	// имена отношений (таблиц и индексов) в схеме, нужны для выбора имен по умолчанию
+	relNames map[string]bool
+	// имена CHECK и FOREIGN KEY ограничений в схеме
+	constraintNames map[string]bool
+	// последовательности, созданные CREATE SEQUENCE, SERIAL и IDENTITY колонками
+	sequences map[string]*ddlSequence
+	// тексты CREATE FUNCTION по имени функции
+	functions map[string]string
+}
+
+type ddlTable struct {
+	table      query.Table
+	columns    []query.Column
+	nextColNum int
+
+	constraints []*ddlConstraint
+	indexes     []*ddlIndex
+	triggers    []query.Trigger
+}
+
+type ddlConstraint struct {
+	con     query.Constraint
+	columns []string
+	// для FOREIGN KEY
+	refTable   schema.Identifier
+	refColumns []string
+	// индекс, на котором основано ограничение
+	index *ddlIndex
+}
+
+// ddlSequence описывает последовательность. Незаданные параметры вычисляются при разрешении.
+type ddlSequence struct {
+	name schema.Identifier
+	// тип значений последовательности, по умолчанию int8
+	typeName string
+
+	start     sql.NullInt64
+	increment sql.NullInt64
+	minValue  sql.NullInt64
+	maxValue  sql.NullInt64
+	cycle     bool
+}
+
+type ddlIndex struct {
+	idx     query.Index
+	columns []string
+	// ключ индекса содержит выражения
+	expressions bool
+	// ограничение, которое создало индекс
+	constraint *ddlConstraint
+}
+
+func newDDLCatalog() *ddlCatalog {
+	return &ddlCatalog{
+		nextOID:         firstNormalObjectID,
+		types:           make(map[int]query.Type),
+		typeNames:       make(map[string]int),
+		enums:           make(map[int]query.Enum),
+		composites:      make(map[int][]query.Column),
+		tables:          make(map[string]*ddlTable),
+		relNames:        make(map[string]bool),
+		constraintNames: make(map[string]bool),
+		sequences:       make(map[string]*ddlSequence),
+		functions:       make(map[string]string),
+	}
+}
+
+func (c *ddlCatalog) newOID() int {
+	oid := c.nextOID
+	c.nextOID++
+	return oid
+}
+
+// lookupType возвращает тип по имени. Встроенные типы добавляются в каталог при первом обращении.
+func (c *ddlCatalog) lookupType(name schema.Identifier) (query.Type, bool) {
+	if oid, ok := c.typeNames[qualifiedName(name)]; ok {
+		return c.types[oid], true
+	}
+	if name.Schema != pgCatalog {
+		return query.Type{}, false
+	}
+	builtin, ok := builtinTypesByName[name.Name]
+	if !ok {
+		return query.Type{}, false
+	}
+	typ := query.Type{
+		TypeOID:    builtin.oid,
+		TypeSchema: pgCatalog,
+		TypeName:   builtin.name,
+		TypeType:   builtin.typType,
+	}
+	if builtin.rangeElem != "" {
+		elem, _ := c.lookupType(schema.Identifier{Schema: pgCatalog, Name: builtin.rangeElem})
+		typ.RangeElementTypeOID = validInt32(elem.TypeOID)
+	}
+	c.addType(typ)
+	return typ, true
+}
+
+func (c *ddlCatalog) addType(typ query.Type) {
+	c.types[typ.TypeOID] = typ
+	c.typeNames[qualifiedName(schema.Identifier{Schema: typ.TypeSchema, Name: typ.TypeName})] = typ.TypeOID
+}
+
+// arrayType возвращает тип массива элементов указанного типа, создавая его при необходимости.
+func (c *ddlCatalog) arrayType(elem query.Type) query.Type {
+	name := schema.Identifier{Schema: elem.TypeSchema, Name: "_" + elem.TypeName}
+	if typ, ok := c.lookupType(name); ok {
+		return typ
+	}
+	oid, ok := 0, false
+	if elem.TypeSchema == pgCatalog {
+		var builtin builtinType
+		builtin, ok = builtinTypesByName[elem.TypeName]
+		oid = builtin.arrayOID
+	}
+	if !ok {
+		oid = c.newOID()
+	}
+	typ := query.Type{
+		TypeOID:     oid,
+		TypeSchema:  elem.TypeSchema,
+		TypeName:    name.Name,
+		TypeType:    "b",
+		IsArray:     true,
+		ElemTypeOID: validInt32(elem.TypeOID),
+	}
+	c.addType(typ)
+	return typ
+}
+
+// baseType возвращает базовый тип домена.
+func (c *ddlCatalog) baseType(typ query.Type) query.Type {
+	for typ.TypeType == "d" {
+		typ = c.types[int(typ.DomainTypeOID.Int32)]
+	}
+	return typ
+}
+
+func (c *ddlCatalog) getTable(name schema.Identifier) (*ddlTable, bool) {
+	t, ok := c.tables[qualifiedName(name)]
+	return t, ok
+}
+
+func (c *ddlCatalog) addTable(t *ddlTable) {
+	name := qualifiedName(schema.Identifier{Schema: t.table.Schema, Name: t.table.Table})
+	c.tables[name] = t
+	c.tableOrder = append(c.tableOrder, name)
+	c.relNames[name] = true
+}
+
+func (c *ddlCatalog) addSequence(seq *ddlSequence) {
+	name := qualifiedName(seq.name)
+	c.sequences[name] = seq
+	c.relNames[name] = true
+}
+
+// nextvalRe находит имя последовательности в значении по умолчанию, например nextval('users_id_seq'::regclass).
+var nextvalRe = regexp.MustCompile(`(?i)^nextval\(\s*'((?:[^']|'')+)'(?:\s*::\s*regclass)?\s*\)$`)
+
+// columnSequence возвращает последовательность IDENTITY колонки
+// или последовательность, из которой берется значение колонки по умолчанию.
+func (c *ddlCatalog) columnSequence(col query.Column) (*ddlSequence, bool) {
+	name := schema.Identifier{Schema: col.SequenceSchema.String, Name: col.SequenceName.String}
+	if !col.SequenceName.Valid {
+		m := nextvalRe.FindStringSubmatch(strings.TrimSpace(col.DefaultExpr.String))
+		if m == nil {
+			return nil, false
+		}
+		var ok bool
+		name, ok = regclassName(strings.ReplaceAll(m[1], "''", "'"))
+		if !ok {
+			return nil, false
+		}
+	}
+	seq, ok := c.sequences[qualifiedName(name)]
+	return seq, ok
+}
+
+// regclassName разбирает имя отношения, записанное текстом, например public."Users_id_seq".
+func regclassName(text string) (schema.Identifier, bool) {
+	toks, err := tokenize(text)
+	if err != nil {
+		return schema.Identifier{}, false
+	}
+	p := &SQLParser{src: text, toks: toks}
+	name, err := p.qualifiedName()
+	return name, err == nil && p.peek().kind == tokenEOF
+}
+
+// resolve заполняет незаданные параметры последовательности так же, как это делает PostgreSQL:
+// границы зависят от типа и знака шага, а начальное значение равно границе со стороны шага.
+func (s *ddlSequence) resolve(col *query.Column) {
+	bounds, ok := sequenceTypeRanges[s.typeName]
+	if !ok {
+		bounds = sequenceTypeRanges["int8"]
+	}
+	increment := int64(1)
+	if s.increment.Valid {
+		increment = s.increment.Int64
+	}
+	minValue, maxValue := int64(1), bounds[1]
+	if increment < 0 {
+		minValue, maxValue = bounds[0], -1
+	}
+	if s.minValue.Valid {
+		minValue = s.minValue.Int64
+	}
+	if s.maxValue.Valid {
+		maxValue = s.maxValue.Int64
+	}
+	start := minValue
+	if increment < 0 {
+		start = maxValue
+	}
+	if s.start.Valid {
+		start = s.start.Int64
+	}
+
+	col.SequenceSchema = sql.NullString{String: s.name.Schema, Valid: true}
+	col.SequenceName = sql.NullString{String: s.name.Name, Valid: true}
+	col.SequenceStart = sql.NullInt64{Int64: start, Valid: true}
+	col.SequenceIncrement = sql.NullInt64{Int64: increment, Valid: true}
+	col.SequenceMin = sql.NullInt64{Int64: minValue, Valid: true}
+	col.SequenceMax = sql.NullInt64{Int64: maxValue, Valid: true}
+	col.SequenceCycle = sql.NullBool{Bool: s.cycle, Valid: true}
+}
+
+func (t *ddlTable) column(name string) (*query.Column, bool) {
+	for idx := range t.columns {
+		if t.columns[idx].ColumnName == name {
+			return &t.columns[idx], true
+		}
+	}
+	return nil, false
+}
+
+func (t *ddlTable) addColumn(col query.Column) {
+	t.nextColNum++
+	col.TableOID = t.table.OID
+	col.ColumnNum = t.nextColNum
+	t.columns = append(t.columns, col)
+}
+
+// dropColumn удаляет колонку вместе с ограничениями и индексами, в которые она входит.
+func (t *ddlTable) dropColumn(name string) bool {
+	idx := slices.IndexFunc(t.columns, func(col query.Column) bool { return col.ColumnName == name })
+	if idx < 0 {
+		return false
+	}
+	t.columns = slices.Delete(t.columns, idx, idx+1)
+	t.constraints = deleteFunc(t.constraints, func(c *ddlConstraint) bool {
+		return slices.Contains(c.columns, name)
+	})
+	t.indexes = deleteFunc(t.indexes, func(i *ddlIndex) bool {
+		return slices.Contains(i.columns, name)
+	})
+	return true
+}
+
+func (t *ddlTable) dropConstraint(name string) bool {
+	idx := slices.IndexFunc(t.constraints, func(c *ddlConstraint) bool { return c.con.ConstraintName == name })
+	if idx < 0 {
+		return false
+	}
+	if index := t.constraints[idx].index; index != nil {
+		t.indexes = deleteFunc(t.indexes, func(i *ddlIndex) bool { return i == index })
+	}
+	t.constraints = slices.Delete(t.constraints, idx, idx+1)
+	return true
+}
+
+// addTrigger добавляет триггер. Триггер с тем же именем заменяется, как в CREATE OR REPLACE TRIGGER.
+func (t *ddlTable) addTrigger(trigger query.Trigger) {
+	trigger.TableOID = t.table.OID
+	if idx := t.triggerIndex(trigger.TriggerName); idx >= 0 {
+		t.triggers[idx] = trigger
+		return
+	}
+	t.triggers = append(t.triggers, trigger)
+}
+
+func (t *ddlTable) triggerIndex(name string) int {
+	return slices.IndexFunc(t.triggers, func(trigger query.Trigger) bool { return trigger.TriggerName == name })
+}
+
+// chooseName выбирает свободное имя объекта так же, как это делает PostgreSQL:
+// <table>_<columns>_<label>, а при совпадении к метке добавляется номер.
+func chooseName(table, columns, label string, taken func(string) bool) string {
+	for pass := 0; ; pass++ {
+		modlabel := label
+		if pass > 0 {
+			modlabel = fmt.Sprintf("%s%d", label, pass)
+		}
+		name := makeObjectName(table, columns, modlabel)
+		if !taken(name) {
+			return name
+		}
+	}
+}
+
+// makeObjectName собирает имя объекта, укорачивая самую длинную часть, если имя слишком длинное.
+func makeObjectName(name1, name2, label string) string {
+	overhead := len(label) + 1
+	if name2 != "" {
+		overhead++
+	}
+	for len(name1)+len(name2)+overhead > maxIdentifierLength {
+		if len(name1) > len(name2) {
+			name1 = name1[:len(name1)-1]
+		} else {
+			name2 = name2[:len(name2)-1]
+		}
+	}
+	parts := []string{name1}
+	if name2 != "" {
+		parts = append(parts, name2)
+	}
+	return strings.Join(append(parts, label), "_")
+}
+
+// buildParseSchema переводит каталог в формат, который получается при разборе схемы из базы данных.
+// Учитываются только таблицы, подходящие под шаблоны, и типы их колонок.
+func (c *ddlCatalog) buildParseSchema(patterns []Pattern) (parseSchema, error) {
+	ps := newParseSchema()
+
+	matchers, err := patternMatchers(patterns)
+	if err != nil {
+		return ps, err
+	}
+
+	usedTypes := make(map[int]bool)
+	for _, name := range c.tableOrder {
+		t, ok := c.tables[name]
+		if !ok || !matchers.match(t.table) {
+			continue
+		}
+
+		// колонки первичного ключа всегда NOT NULL
+		pkColumns := make(map[string]bool)
+		for _, dc := range t.constraints {
+			if dc.con.ConstraintType == "p" {
+				for _, colName := range dc.columns {
+					pkColumns[colName] = true
+				}
+			}
+		}
+
+		columns := make(map[int]query.Column, len(t.columns))
+		for _, col := range t.columns {
+			if pkColumns[col.ColumnName] {
+				col.IsNullable = true
+			}
+			if seq, ok := c.columnSequence(col); ok {
+				seq.resolve(&col)
+			}
+			columns[col.ColumnNum] = col
+			c.markUsedTypes(col.TypeOID, usedTypes)
+		}
+		triggers := slices.Clone(t.triggers)
+		for idx := range triggers {
+			fn := schema.Identifier{Schema: triggers[idx].FunctionSchema, Name: triggers[idx].FunctionName}
+			triggers[idx].FunctionSource = c.functions[qualifiedName(fn)]
+		}
+		ps.tables[t.table.OID] = parseTable{table: t.table, columns: columns, triggers: triggers}
+
+		for _, dc := range t.constraints {
+			con, err := c.resolveConstraint(t, dc)
+			if err != nil {
+				return ps, xerrors.Errorf("table %q, constraint %q: %w",
+					qualifiedName(schema.Identifier{Schema: t.table.Schema, Name: t.table.Table}),
+					dc.con.ConstraintName, err)
+			}
+			ps.constraints[con.ConstraintOID] = con
+		}
+		for _, di := range t.indexes {
+			idx := di.idx
+			idx.TableOID = t.table.OID
+			for _, colName := range di.columns {
+				col, ok := t.column(colName)
+				if !ok {
+					return ps, xerrors.Errorf("index %q: column %q not found", idx.IndexName, colName)
+				}
+				idx.Columns = append(idx.Columns, col.ColumnNum)
+			}
+			if di.expressions {
+				// номер 0 означает выражение, как в pg_index.indkey
+				idx.Columns = append(idx.Columns, 0)
+			}
+			// колонки INCLUDE не сохраняются, поэтому все колонки ключевые
+			idx.KeyColumnsCount = len(idx.Columns)
+			if di.constraint != nil {
+				idx.ConstraintOID = validInt32(di.constraint.con.ConstraintOID)
+			}
+			ps.indexes[idx.IndexOID] = idx
+		}
+	}
+
+	oids := maps.Keys(usedTypes)
+	slices.Sort(oids)
+	for _, oid := range oids {
+		typ := c.types[oid]
+		ps.types[oid] = typ
+		switch typ.TypeType {
+		case "e":
+			ps.enumList = append(ps.enumList, oid)
+			ps.enums[oid] = c.enums[oid]
+		case "c":
+			relOID := int(typ.CompositeRelationOID.Int32)
+			attrs := make(map[int]query.Column, len(c.composites[relOID]))
+			for _, attr := range c.composites[relOID] {
+				attrs[attr.ColumnNum] = attr
+			}
+			ps.composites[relOID] = attrs
+		}
+	}
+	return ps, nil
+}
+
+func (c *ddlCatalog) markUsedTypes(oid int, used map[int]bool) {
+	if used[oid] {
+		return
+	}
+	used[oid] = true
+	typ := c.types[oid]
+	for _, ref := range []sql.NullInt32{typ.ElemTypeOID, typ.DomainTypeOID, typ.RangeElementTypeOID} {
+		if ref.Valid {
+			c.markUsedTypes(int(ref.Int32), used)
+		}
+	}
+	for _, attr := range c.composites[int(typ.CompositeRelationOID.Int32)] {
+		c.markUsedTypes(attr.TypeOID, used)
+	}
+}
+
+func (c *ddlCatalog) resolveConstraint(t *ddlTable, dc *ddlConstraint) (query.Constraint, error) {
+	con := dc.con
+	con.TableOID = t.table.OID
+	for _, colName := range dc.columns {
+		col, ok := t.column(colName)
+		if !ok {
+			return con, xerrors.Errorf("column %q not found", colName)
+		}
+		con.Colnums = append(con.Colnums, col.ColumnNum)
+	}
+	if con.ConstraintType != "f" {
+		return con, nil
+	}
+
+	reftable, ok := c.getTable(dc.refTable)
+	if !ok {
+		return con, xerrors.Errorf("referenced table %q not found", qualifiedName(dc.refTable))
+	}
+	refColumns := dc.refColumns
+	if len(refColumns) == 0 {
+		// без списка колонок внешний ключ ссылается на первичный ключ
+		for _, refcon := range reftable.constraints {
+			if refcon.con.ConstraintType == "p" {
+				refColumns = refcon.columns
+			}
+		}
+		if len(refColumns) == 0 {
+			return con, xerrors.Errorf("referenced table %q has no primary key", qualifiedName(dc.refTable))
+		}
+	}
+	if len(refColumns) != len(dc.columns) {
+		return con, xerrors.Errorf("number of referencing and referenced columns must match")
+	}
+	for _, colName := range refColumns {
+		col, ok := reftable.column(colName)
+		if !ok {
+			return con, xerrors.Errorf("referenced column %q not found", colName)
+		}
+		con.ForeignColnums = append(con.ForeignColnums, col.ColumnNum)
+	}
+	con.ForeignTableOID = validInt32(reftable.table.OID)
+	return con, nil
+}
+
+type likeMatchers []struct{ schema, table *regexp.Regexp }
+
+// patternMatchers переводит шаблоны LIKE в регулярные выражения.
+func patternMatchers(patterns []Pattern) (likeMatchers, error) {
+	res := make(likeMatchers, 0, len(patterns))
+	for _, p := range patterns {
+		schemaRe, err := likeToRegexp(p.Schema)
+		if err != nil {
+			return nil, xerrors.Errorf("schema pattern %q: %w", p.Schema, err)
+		}
+		tableRe, err := likeToRegexp(p.Tables)
+		if err != nil {
+			return nil, xerrors.Errorf("tables pattern %q: %w", p.Tables, err)
+		}
+		res = append(res, struct{ schema, table *regexp.Regexp }{schemaRe, tableRe})
+	}
+	return res, nil
+}
+
+// match проверяет, что таблица подходит под один из шаблонов. Без шаблонов подходят все таблицы.
+func (m likeMatchers) match(table query.Table) bool {
+	if len(m) == 0 {
+		return true
+	}
+	for _, p := range m {
+		if p.schema.MatchString(table.Schema) && p.table.MatchString(table.Table) {
+			return true
+		}
+	}
+	return false
+}
+
+func likeToRegexp(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = "%"
+	}
+	var sb strings.Builder
+	sb.WriteByte('^')
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteByte('.')
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteByte('$')
+	return regexp.Compile(sb.String())
+}
+
+func qualifiedName(name schema.Identifier) string {
+	return name.Schema + "." + name.Name
+}
+
+func validInt32(v int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(v), Valid: true}
+}
+
+// deleteFunc удаляет из слайса элементы, для которых del возвращает true.
+func deleteFunc[T any](s []T, del func(T) bool) []T {
+	res := s[:0]
+	for _, v := range s {
+		if !del(v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,232 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/xerrors"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// идентификатор или ключевое слово без кавычек. Значение приведено к нижнему регистру.
+	tokenIdent
+	// идентификатор в двойных кавычках
+	tokenQuotedIdent
+	// строковая константа, в том числе E'...' и $tag$...$tag$
+	tokenString
+	tokenNumber
+	// знаки пунктуации и операторы
+	tokenOp
+)
+
+// token описывает лексему SQL скрипта.
+type token struct {
+	kind tokenKind
+	// значение лексемы. Для идентификаторов без кавычек приведено к нижнему регистру
+	value string
+	// границы лексемы в исходном тексте
+	start, end int
+}
+
+// posError возвращает ошибку с указанием строки и позиции в исходном тексте.
+func posError(src string, pos int, format string, args ...any) error {
+	line := strings.Count(src[:pos], "\n") + 1
+	col := pos - strings.LastIndexByte(src[:pos], '\n')
+	return xerrors.Errorf("line %d, column %d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+// tokenize разбивает SQL скрипт на лексемы, пропуская комментарии.
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	for pos := 0; pos < len(src); {
+		r, size := utf8.DecodeRuneInString(src[pos:])
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+		case strings.HasPrefix(src[pos:], "--"):
+			end := strings.IndexByte(src[pos:], '\n')
+			if end < 0 {
+				end = len(src) - pos
+			}
+			pos += end
+		case strings.HasPrefix(src[pos:], "/*"):
+			end, err := skipBlockComment(src, pos)
+			if err != nil {
+				return nil, err
+			}
+			pos = end
+		case (r == 'e' || r == 'E') && pos+1 < len(src) && src[pos+1] == '\'':
+			tok, err := scanString(src, pos+1, true)
+			if err != nil {
+				return nil, err
+			}
+			tok.start = pos
+			toks = append(toks, tok)
+			pos = tok.end
+		case r == '\'':
+			tok, err := scanString(src, pos, false)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			pos = tok.end
+		case r == '"':
+			tok, err := scanQuotedIdent(src, pos)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			pos = tok.end
+		case r == '$' && isDollarQuoteStart(src[pos:]):
+			tok, err := scanDollarString(src, pos)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			pos = tok.end
+		case r == '_' || unicode.IsLetter(r):
+			end := pos
+			for end < len(src) {
+				r, size := utf8.DecodeRuneInString(src[end:])
+				if r != '_' && r != '$' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				end += size
+			}
+			toks = append(toks, token{
+				kind:  tokenIdent,
+				value: strings.ToLower(src[pos:end]),
+				start: pos,
+				end:   end,
+			})
+			pos = end
+		case unicode.IsDigit(r) || (r == '.' && pos+1 < len(src) && isDigit(src[pos+1])):
+			end := pos
+			for end < len(src) && (isDigit(src[end]) || src[end] == '.' ||
+				src[end] == 'e' || src[end] == 'E' ||
+				((src[end] == '+' || src[end] == '-') && (src[end-1] == 'e' || src[end-1] == 'E'))) {
+				end++
+			}
+			toks = append(toks, token{kind: tokenNumber, value: src[pos:end], start: pos, end: end})
+			pos = end
+		default:
+			end := scanOperator(src, pos)
+			toks = append(toks, token{kind: tokenOp, value: src[pos:end], start: pos, end: end})
+			pos = end
+		}
+	}
+	toks = append(toks, token{kind: tokenEOF, start: len(src), end: len(src)})
+	return toks, nil
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func skipBlockComment(src string, pos int) (int, error) {
+	depth := 0
+	for i := pos; i+1 < len(src); i++ {
+		switch src[i : i+2] {
+		case "/*":
+			depth++
+			i++
+		case "*/":
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return 0, posError(src, pos, "unterminated comment")
+}
+
+// scanString читает строку в одинарных кавычках, начиная с позиции кавычки.
+func scanString(src string, pos int, escapes bool) (token, error) {
+	var sb strings.Builder
+	for i := pos + 1; i < len(src); i++ {
+		switch {
+		case escapes && src[i] == '\\' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			default:
+				sb.WriteByte(src[i])
+			}
+		case src[i] != '\'':
+			sb.WriteByte(src[i])
+		case i+1 < len(src) && src[i+1] == '\'':
+			sb.WriteByte('\'')
+			i++
+		default:
+			return token{kind: tokenString, value: sb.String(), start: pos, end: i + 1}, nil
+		}
+	}
+	return token{}, posError(src, pos, "unterminated string literal")
+}
+
+func scanQuotedIdent(src string, pos int) (token, error) {
+	var sb strings.Builder
+	for i := pos + 1; i < len(src); i++ {
+		switch {
+		case src[i] != '"':
+			sb.WriteByte(src[i])
+		case i+1 < len(src) && src[i+1] == '"':
+			sb.WriteByte('"')
+			i++
+		default:
+			return token{kind: tokenQuotedIdent, value: sb.String(), start: pos, end: i + 1}, nil
+		}
+	}
+	return token{}, posError(src, pos, "unterminated quoted identifier")
+}
+
+// isDollarQuoteStart проверяет, что строка начинается с $tag$ или $$.
+func isDollarQuoteStart(s string) bool {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return true
+		}
+		if c != '_' && !unicode.IsLetter(rune(c)) && !(i > 1 && isDigit(c)) {
+			return false
+		}
+	}
+	return false
+}
+
+func scanDollarString(src string, pos int) (token, error) {
+	tagEnd := strings.IndexByte(src[pos+1:], '$') + pos + 2
+	tag := src[pos:tagEnd]
+	end := strings.Index(src[tagEnd:], tag)
+	if end < 0 {
+		return token{}, posError(src, pos, "unterminated dollar-quoted string")
+	}
+	return token{
+		kind:  tokenString,
+		value: src[tagEnd : tagEnd+end],
+		start: pos,
+		end:   tagEnd + end + len(tag),
+	}, nil
+}
+
+// операторы из нескольких символов, которые нужно различать при разборе DDL.
+var multiCharOps = []string{"::", "<>", "!=", "<=", ">=", "&&", "||", "=>", ":="}
+
+func scanOperator(src string, pos int) int {
+	for _, op := range multiCharOps {
+		if strings.HasPrefix(src[pos:], op) {
+			return pos + len(op)
+		}
+	}
+	_, size := utf8.DecodeRuneInString(src[pos:])
+	return pos + size
+}
@@ -0,0 +1,300 @@
+package parse
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestSQLParserLoadSchema(t *testing.T) {
+	r := require.New(t)
+
+	f, err := os.Open("../schema/testdata/a-bit-of-everything.sql")
+	r.NoError(err)
+	t.Cleanup(func() { f.Close() })
+
+	s, err := NewSQLParser(zap.NewNop()).LoadSchema(f, Config{})
+	r.NoError(err)
+
+	r.ElementsMatch([]string{
+		"test.circles", "test.roles", "test.users", "test.products",
+		"test.orders", "test.employers", "test.departments",
+	}, maps.Keys(s.Tables))
+
+	users := s.Tables["test.users"]
+	id := users.Columns["id"]
+	r.Equal(1, id.ColNum)
+	r.Equal("int4", id.Type.String())
+	r.True(id.Attributes.NotNullable)
+	r.Equal("nextval('test.users_id_seq'::regclass)", id.Attributes.Default)
+	r.Equal(&schema.Sequence{
+		Name:      schema.Identifier{Schema: "test", Name: "users_id_seq"},
+		Start:     1,
+		Increment: 1,
+		MinValue:  1,
+		MaxValue:  2147483647,
+	}, id.Attributes.Sequence)
+
+	name := users.Columns["name"]
+	r.Equal(schema.DataTypeDomain, name.Type.Type)
+	r.Equal("varchar", name.Type.ElemType.String())
+	r.Equal(100, name.Attributes.CharMaxLength)
+	r.True(name.Attributes.NotNullable)
+
+	r.Equal(schema.DataTypeEnum, users.Columns["status"].Type.Type)
+	r.Equal([]string{"active", "inactive"}, users.Columns["status"].Type.EnumValues)
+	fullName := users.Columns["full_name"].Type
+	r.Equal(schema.DataTypeComposite, fullName.Type)
+	r.Len(fullName.Attributes, 2)
+	for idx, attrName := range []string{"first_name", "last_name"} {
+		attr := fullName.Attributes[idx]
+		r.Equal(attrName, attr.Name)
+		r.Equal(idx+1, attr.ColNum)
+		r.Equal("test.name_domain", attr.Type.String())
+		r.Equal(100, attr.Attributes.CharMaxLength)
+	}
+	r.Equal(schema.DataTypeRange, users.Columns["price_range"].Type.Type)
+
+	r.Equal([]string{"id"}, users.PrimaryKey.Columns)
+	r.Equal("users_pkey", users.PrimaryKey.Index.Name)
+	r.Equal(schema.ConstraintTypeCheck, users.Constraints["users_age_check"].Type)
+	r.Equal([]string{"age", "status"}, users.Constraints["users_check"].Columns)
+	r.Equal(schema.ConstraintTypeUnique, users.Constraints["users_email_key"].Type)
+
+	fk := users.ForeignKeys["user_role_composite_fk"]
+	r.Equal("test.roles", fk.ReferenceTable)
+	r.Equal([]string{"role_id", "role_name"}, fk.Constraint.Columns)
+	r.Equal([]string{"id", "name"}, fk.ReferenceColumns)
+	r.Contains(s.Tables["test.roles"].ReferencedBy, "test.users")
+
+	orders := s.Tables["test.orders"]
+	r.Equal("test.products", orders.ForeignKeys["orders_product_id_fkey"].ReferenceTable)
+	r.Equal([]string{"id"}, orders.ForeignKeys["orders_user_id_fkey"].ReferenceColumns)
+	unique := orders.Indexes["orders_user_product_unique"]
+	r.True(unique.IsUnique)
+	r.True(unique.IsNullsNotDistinct)
+	r.Equal([]string{"user_id"}, orders.Indexes["test_orders_user_id_idx"].Columns)
+	r.False(orders.Indexes["test_orders_user_id_idx"].IsUnique)
+
+	prices := s.Tables["test.products"].Columns["prices_2"]
+	r.Equal(schema.DataTypeArray, prices.Type.Type)
+	r.Equal("int4", prices.Type.ElemType.String())
+	r.Equal(2, prices.Attributes.ArrayDims)
+
+	total := s.Tables["test.employers"].Columns["total_salary"]
+	r.True(total.Attributes.IsGenerated)
+	r.Equal("salary + (salary * bonus_percent / 100)", total.Attributes.Default)
+
+	r.Equal(schema.ConstraintTypeExclusion, s.Tables["test.circles"].Constraints["circles_c_excl"].Type)
+
+	_, err = s.NewGraph().TopologicalSort()
+	r.NoError(err)
+}
+
+func TestSQLParserAlterTable(t *testing.T) {
+	r := require.New(t)
+
+	// так выглядит вывод pg_dump: ограничения добавляются после создания всех таблиц
+	const script = `
+SET search_path = '';
+CREATE TABLE public.items (
+    id bigint NOT NULL,
+    owner_id integer,
+    title character varying(20),
+    price numeric(10,2) DEFAULT 0.0,
+    tmp text
+);
+CREATE TABLE public.owners (
+    id integer NOT NULL
+);
+CREATE TABLE other.ignored (id integer);
+ALTER TABLE public.items OWNER TO postgres;
+ALTER TABLE ONLY public.items
+    ADD CONSTRAINT items_pkey PRIMARY KEY (id),
+    ALTER COLUMN title SET NOT NULL;
+ALTER TABLE ONLY public.owners ADD CONSTRAINT owners_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY public.items ADD FOREIGN KEY (owner_id) REFERENCES public.owners;
+ALTER TABLE public.items DROP COLUMN tmp;
+CREATE UNIQUE INDEX ON public.items USING btree (lower(title));
+CREATE UNIQUE INDEX items_owner_key ON public.items (owner_id) WITH (fillfactor = 90) WHERE price > 0;
+CREATE UNIQUE INDEX items_title_key ON public.items (title) INCLUDE (price);
+CREATE UNIQUE INDEX items_owner_title_key ON public.items (owner_id, lower(title));
+`
+	s, err := NewSQLParser(zap.NewNop()).LoadSchema(strings.NewReader(script), Config{
+		Patterns: []Pattern{{Schema: "public"}},
+	})
+	r.NoError(err)
+	r.ElementsMatch([]string{"public.items", "public.owners"}, maps.Keys(s.Tables))
+
+	items := s.Tables["public.items"]
+	r.ElementsMatch([]string{"id", "owner_id", "title", "price"}, maps.Keys(items.Columns))
+	r.Equal("int8", items.Columns["id"].Type.String())
+	r.True(items.Columns["title"].Attributes.NotNullable)
+	r.Equal(20, items.Columns["title"].Attributes.CharMaxLength)
+	price := items.Columns["price"].Attributes
+	r.True(price.IsNumeric)
+	r.Equal(10, price.NumericPrecision)
+	r.Equal(2, price.NumericScale)
+	r.Equal("0.0", price.Default)
+
+	r.Equal("items_pkey", items.PrimaryKey.Name)
+	fk := items.ForeignKeys["items_owner_id_fkey"]
+	r.Equal("public.owners", fk.ReferenceTable)
+	r.Equal([]string{"id"}, fk.ReferenceColumns)
+	r.Contains(s.Tables["public.owners"].ReferencedBy, "public.items")
+
+	r.Contains(items.Indexes, "items_expr_key")
+	r.Empty(items.Indexes["items_expr_key"].Columns)
+	r.True(items.Indexes["items_expr_key"].HasExpressions)
+	r.Equal("CREATE UNIQUE INDEX items_owner_key ON public.items USING btree (owner_id) WHERE (price > 0)",
+		items.Indexes["items_owner_key"].Definition)
+	// колонки INCLUDE не входят в ключ индекса, как и при загрузке из базы данных
+	r.Equal([]string{"title"}, items.Indexes["items_title_key"].Columns)
+	r.False(items.Indexes["items_title_key"].HasExpressions)
+	r.Equal([]string{"owner_id"}, items.Indexes["items_owner_title_key"].Columns)
+	r.True(items.Indexes["items_owner_title_key"].HasExpressions)
+}
+
+func TestSQLParserSequences(t *testing.T) {
+	r := require.New(t)
+
+	const script = `
+CREATE SEQUENCE public.orders_id_seq AS integer START WITH 100 INCREMENT BY -1 MINVALUE 10 NO MAXVALUE CACHE 1;
+CREATE TABLE public.orders (
+    id integer DEFAULT nextval('public.orders_id_seq'::regclass) NOT NULL,
+    num bigint GENERATED BY DEFAULT AS IDENTITY (START WITH 5 MAXVALUE 1000 CYCLE),
+    small smallserial,
+    code integer NOT NULL
+);
+ALTER TABLE public.orders ALTER COLUMN code ADD GENERATED ALWAYS AS IDENTITY (
+    SEQUENCE NAME public.orders_code_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1
+);
+ALTER SEQUENCE public.orders_id_seq OWNED BY public.orders.id;
+`
+	s, err := NewSQLParser(zap.NewNop()).LoadSchema(strings.NewReader(script), Config{})
+	r.NoError(err)
+	orders := s.Tables["public.orders"]
+
+	id := orders.Columns["id"].Attributes
+	r.Empty(id.Identity)
+	r.Equal(&schema.Sequence{
+		Name:      schema.Identifier{Schema: "public", Name: "orders_id_seq"},
+		Start:     100,
+		Increment: -1,
+		MinValue:  10,
+		MaxValue:  -1,
+	}, id.Sequence)
+
+	num := orders.Columns["num"].Attributes
+	r.Equal(schema.IdentityByDefault, num.Identity)
+	r.False(num.IsGeneratedAlways())
+	r.True(num.NotNullable)
+	r.Equal(&schema.Sequence{
+		Name:      schema.Identifier{Schema: "public", Name: "orders_num_seq"},
+		Start:     5,
+		Increment: 1,
+		MinValue:  1,
+		MaxValue:  1000,
+		Cycle:     true,
+	}, num.Sequence)
+
+	small := orders.Columns["small"].Attributes
+	r.Equal("nextval('orders_small_seq'::regclass)", small.Default)
+	r.Equal(int64(32767), small.Sequence.MaxValue)
+
+	code := orders.Columns["code"].Attributes
+	r.True(code.IsGeneratedAlways())
+	r.Equal("public.orders_code_seq", code.Sequence.String())
+	r.Equal(int64(2147483647), code.Sequence.MaxValue)
+}
+
+func TestSQLParserTriggers(t *testing.T) {
+	r := require.New(t)
+
+	const script = `
+CREATE TABLE public.orders (id integer, total numeric);
+CREATE FUNCTION public.orders_audit() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    -- сохраняем время изменения
+    RETURN NEW;
+END
+$$;
+CREATE TRIGGER orders_audit BEFORE INSERT OR UPDATE OF total ON public.orders
+    FOR EACH ROW WHEN (NEW.total > 0) EXECUTE FUNCTION public.orders_audit();
+CREATE TRIGGER orders_truncate AFTER TRUNCATE ON public.orders EXECUTE PROCEDURE orders_audit();
+ALTER TABLE public.orders DISABLE TRIGGER orders_truncate;
+`
+	s, err := NewSQLParser(zap.NewNop()).LoadSchema(strings.NewReader(script), Config{})
+	r.NoError(err)
+	triggers := s.Tables["public.orders"].Triggers
+	r.Len(triggers, 2)
+
+	audit := triggers["orders_audit"]
+	r.Equal(schema.TriggerTimingBefore, audit.Timing)
+	r.Equal([]schema.TriggerEvent{schema.TriggerEventInsert, schema.TriggerEventUpdate}, audit.Events)
+	r.True(audit.ForEachRow)
+	r.Equal("NEW.total > 0", audit.Condition)
+	r.Equal("public.orders_audit", audit.Function.String())
+	r.Contains(audit.FunctionSource, "-- сохраняем время изменения\n")
+	r.True(strings.HasPrefix(audit.Definition, "CREATE TRIGGER orders_audit BEFORE INSERT"))
+	r.True(audit.FiresOn(schema.TriggerEventInsert))
+
+	truncate := triggers["orders_truncate"]
+	r.Equal(schema.TriggerTimingAfter, truncate.Timing)
+	r.Equal([]schema.TriggerEvent{schema.TriggerEventTruncate}, truncate.Events)
+	r.False(truncate.ForEachRow)
+	r.True(truncate.Disabled)
+	r.Equal(audit.FunctionSource, truncate.FunctionSource)
+	r.False(truncate.FiresOn(schema.TriggerEventTruncate))
+
+	r.Equal([]string{audit.FunctionSource}, s.TriggerFunctions())
+}
+
+func TestSQLParserErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{
+			name:   "unknown type",
+			script: "CREATE TABLE t (\n  id unknown_type\n);",
+			want:   `type "public.unknown_type" does not exist`,
+		},
+		{
+			name:   "syntax",
+			script: "CREATE TABLE t (\n  id integer PRIMARY\n);",
+			want:   `line 2, column 14: unexpected "primary" in column definition`,
+		},
+		{
+			name:   "unknown fk table",
+			script: "CREATE TABLE t (id integer REFERENCES missing(id));",
+			want:   `referenced table "public.missing" not found`,
+		},
+		{
+			name:   "unterminated string",
+			script: "CREATE TYPE e AS ENUM ('a);",
+			want:   `line 1, column 24: unterminated string literal`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSQLParser(zap.NewNop()).LoadSchema(strings.NewReader(tt.script), Config{})
+			require.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package parse
+
+import "math"
+
+// builtinType описывает встроенный тип pg_catalog.
+type builtinType struct {
+	oid      int
+	arrayOID int
+	name     string
+	// typtype из pg_type
+	typType string
+	// тип элементов диапазона
+	rangeElem string
+	// точность числовых типов из information_schema
+	precision int
+}
+
+const pgCatalog = "pg_catalog"
+
+// builtinTypes содержит встроенные типы, которые чаще всего используются в колонках таблиц.
+// OID совпадают с OID типов в PostgreSQL.
+var builtinTypes = []builtinType{
+	{oid: 16, arrayOID: 1000, name: "bool", typType: "b"},
+	{oid: 17, arrayOID: 1001, name: "bytea", typType: "b"},
+	{oid: 18, arrayOID: 1002, name: "char", typType: "b"},
+	{oid: 20, arrayOID: 1016, name: "int8", typType: "b", precision: 64},
+	{oid: 21, arrayOID: 1005, name: "int2", typType: "b", precision: 16},
+	{oid: 23, arrayOID: 1007, name: "int4", typType: "b", precision: 32},
+	{oid: 25, arrayOID: 1009, name: "text", typType: "b"},
+	{oid: 26, arrayOID: 1028, name: "oid", typType: "b"},
+	{oid: 114, arrayOID: 199, name: "json", typType: "b"},
+	{oid: 142, arrayOID: 143, name: "xml", typType: "b"},
+	{oid: 600, arrayOID: 1017, name: "point", typType: "b"},
+	{oid: 601, arrayOID: 1018, name: "lseg", typType: "b"},
+	{oid: 602, arrayOID: 1019, name: "path", typType: "b"},
+	{oid: 603, arrayOID: 1020, name: "box", typType: "b"},
+	{oid: 604, arrayOID: 1027, name: "polygon", typType: "b"},
+	{oid: 628, arrayOID: 629, name: "line", typType: "b"},
+	{oid: 650, arrayOID: 651, name: "cidr", typType: "b"},
+	{oid: 700, arrayOID: 1021, name: "float4", typType: "b", precision: 24},
+	{oid: 701, arrayOID: 1022, name: "float8", typType: "b", precision: 53},
+	{oid: 718, arrayOID: 719, name: "circle", typType: "b"},
+	{oid: 774, arrayOID: 775, name: "macaddr8", typType: "b"},
+	{oid: 790, arrayOID: 791, name: "money", typType: "b"},
+	{oid: 829, arrayOID: 1040, name: "macaddr", typType: "b"},
+	{oid: 869, arrayOID: 1041, name: "inet", typType: "b"},
+	{oid: 1042, arrayOID: 1014, name: "bpchar", typType: "b"},
+	{oid: 1043, arrayOID: 1015, name: "varchar", typType: "b"},
+	{oid: 1082, arrayOID: 1182, name: "date", typType: "b"},
+	{oid: 1083, arrayOID: 1183, name: "time", typType: "b"},
+	{oid: 1114, arrayOID: 1115, name: "timestamp", typType: "b"},
+	{oid: 1184, arrayOID: 1185, name: "timestamptz", typType: "b"},
+	{oid: 1186, arrayOID: 1187, name: "interval", typType: "b"},
+	{oid: 1266, arrayOID: 1270, name: "timetz", typType: "b"},
+	{oid: 1560, arrayOID: 1561, name: "bit", typType: "b"},
+	{oid: 1562, arrayOID: 1563, name: "varbit", typType: "b"},
+	{oid: 1700, arrayOID: 1231, name: "numeric", typType: "b"},
+	{oid: 2950, arrayOID: 2951, name: "uuid", typType: "b"},
+	{oid: 3614, arrayOID: 3643, name: "tsvector", typType: "b"},
+	{oid: 3802, arrayOID: 3807, name: "jsonb", typType: "b"},
+	{oid: 3904, arrayOID: 3905, name: "int4range", typType: "r", rangeElem: "int4"},
+	{oid: 3906, arrayOID: 3907, name: "numrange", typType: "r", rangeElem: "numeric"},
+	{oid: 3908, arrayOID: 3909, name: "tsrange", typType: "r", rangeElem: "timestamp"},
+	{oid: 3910, arrayOID: 3911, name: "tstzrange", typType: "r", rangeElem: "timestamptz"},
+	{oid: 3912, arrayOID: 3913, name: "daterange", typType: "r", rangeElem: "date"},
+	{oid: 3926, arrayOID: 3927, name: "int8range", typType: "r", rangeElem: "int8"},
+}
+
+// builtinTypeAliases переводит SQL имена типов в имена pg_catalog.
+var builtinTypeAliases = map[string]string{
+	"integer":                     "int4",
+	"int":                         "int4",
+	"smallint":                    "int2",
+	"bigint":                      "int8",
+	"serial":                      "int4",
+	"serial4":                     "int4",
+	"smallserial":                 "int2",
+	"serial2":                     "int2",
+	"bigserial":                   "int8",
+	"serial8":                     "int8",
+	"real":                        "float4",
+	"double precision":            "float8",
+	"float":                       "float8",
+	"decimal":                     "numeric",
+	"boolean":                     "bool",
+	"character varying":           "varchar",
+	"char varying":                "varchar",
+	"character":                   "bpchar",
+	"char":                        "bpchar",
+	"bit varying":                 "varbit",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+	"time without time zone":      "time",
+	"time with time zone":         "timetz",
+}
+
+// sequenceTypeRanges - диапазоны значений типов, допустимых для последовательностей.
+var sequenceTypeRanges = map[string][2]int64{
+	"int2": {math.MinInt16, math.MaxInt16},
+	"int4": {math.MinInt32, math.MaxInt32},
+	"int8": {math.MinInt64, math.MaxInt64},
+}
+
+// serialTypes - псевдотипы, которые создают последовательность для значения по умолчанию.
+var serialTypes = map[string]bool{
+	"serial":      true,
+	"serial4":     true,
+	"smallserial": true,
+	"serial2":     true,
+	"bigserial":   true,
+	"serial8":     true,
+}
+
+var builtinTypesByName = func() map[string]builtinType {
+	res := make(map[string]builtinType, len(builtinTypes))
+	for _, typ := range builtinTypes {
+		res[typ.name] = typ
+	}
+	return res
+}()
@@ -247,6 +247,62 @@ func (_c *MockQueries_Indexes_Call) RunAndReturn(run func(context.Context, query
 	return _c
 }
 
+// Partitions provides a mock function with given fields: ctx, exec, tables
+func (_m *MockQueries) Partitions(ctx context.Context, exec query.Executor, tables []int) ([]query.Partition, error) {
+	ret := _m.Called(ctx, exec, tables)
+
+	var r0 []query.Partition
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, query.Executor, []int) ([]query.Partition, error)); ok {
+		return rf(ctx, exec, tables)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, query.Executor, []int) []query.Partition); ok {
+		r0 = rf(ctx, exec, tables)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]query.Partition)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, query.Executor, []int) error); ok {
+		r1 = rf(ctx, exec, tables)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockQueries_Partitions_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Partitions'
+type MockQueries_Partitions_Call struct {
+	*mock.Call
+}
+
+// Partitions is a helper method to define mock.On call
+//   - ctx context.Context
+//   - exec query.Executor
+//   - tables []int
+func (_e *MockQueries_Expecter) Partitions(ctx interface{}, exec interface{}, tables interface{}) *MockQueries_Partitions_Call {
+	return &MockQueries_Partitions_Call{Call: _e.mock.On("Partitions", ctx, exec, tables)}
+}
+
+func (_c *MockQueries_Partitions_Call) Run(run func(ctx context.Context, exec query.Executor, tables []int)) *MockQueries_Partitions_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(query.Executor), args[2].([]int))
+	})
+	return _c
+}
+
+func (_c *MockQueries_Partitions_Call) Return(_a0 []query.Partition, _a1 error) *MockQueries_Partitions_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockQueries_Partitions_Call) RunAndReturn(run func(context.Context, query.Executor, []int) ([]query.Partition, error)) *MockQueries_Partitions_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Tables provides a mock function with given fields: ctx, exec, patterns
 func (_m *MockQueries) Tables(ctx context.Context, exec query.Executor, patterns []query.TablesPattern) ([]query.Table, error) {
 	ret := _m.Called(ctx, exec, patterns)
@@ -303,6 +359,62 @@ func (_c *MockQueries_Tables_Call) RunAndReturn(run func(context.Context, query.
 	return _c
 }
 
+// Triggers provides a mock function with given fields: ctx, exec, tables
+func (_m *MockQueries) Triggers(ctx context.Context, exec query.Executor, tables []int) ([]query.Trigger, error) {
+	ret := _m.Called(ctx, exec, tables)
+
+	var r0 []query.Trigger
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, query.Executor, []int) ([]query.Trigger, error)); ok {
+		return rf(ctx, exec, tables)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, query.Executor, []int) []query.Trigger); ok {
+		r0 = rf(ctx, exec, tables)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]query.Trigger)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, query.Executor, []int) error); ok {
+		r1 = rf(ctx, exec, tables)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockQueries_Triggers_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Triggers'
+type MockQueries_Triggers_Call struct {
+	*mock.Call
+}
+
+// Triggers is a helper method to define mock.On call
+//   - ctx context.Context
+//   - exec query.Executor
+//   - tables []int
+func (_e *MockQueries_Expecter) Triggers(ctx interface{}, exec interface{}, tables interface{}) *MockQueries_Triggers_Call {
+	return &MockQueries_Triggers_Call{Call: _e.mock.On("Triggers", ctx, exec, tables)}
+}
+
+func (_c *MockQueries_Triggers_Call) Run(run func(ctx context.Context, exec query.Executor, tables []int)) *MockQueries_Triggers_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(query.Executor), args[2].([]int))
+	})
+	return _c
+}
+
+func (_c *MockQueries_Triggers_Call) Return(_a0 []query.Trigger, _a1 error) *MockQueries_Triggers_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockQueries_Triggers_Call) RunAndReturn(run func(context.Context, query.Executor, []int) ([]query.Trigger, error)) *MockQueries_Triggers_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Types provides a mock function with given fields: ctx, exec, types
 func (_m *MockQueries) Types(ctx context.Context, exec query.Executor, types []int) ([]query.Type, error) {
 	ret := _m.Called(ctx, exec, types)
@@ -359,6 +471,118 @@ func (_c *MockQueries_Types_Call) RunAndReturn(run func(context.Context, query.E
 	return _c
 }
 
+// ViewDependencies provides a mock function with given fields: ctx, exec, views
+func (_m *MockQueries) ViewDependencies(ctx context.Context, exec query.Executor, views []int) ([]query.ViewDependency, error) {
+	ret := _m.Called(ctx, exec, views)
+
+	var r0 []query.ViewDependency
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, query.Executor, []int) ([]query.ViewDependency, error)); ok {
+		return rf(ctx, exec, views)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, query.Executor, []int) []query.ViewDependency); ok {
+		r0 = rf(ctx, exec, views)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]query.ViewDependency)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, query.Executor, []int) error); ok {
+		r1 = rf(ctx, exec, views)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockQueries_ViewDependencies_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ViewDependencies'
+type MockQueries_ViewDependencies_Call struct {
+	*mock.Call
+}
+
+// ViewDependencies is a helper method to define mock.On call
+//   - ctx context.Context
+//   - exec query.Executor
+//   - views []int
+func (_e *MockQueries_Expecter) ViewDependencies(ctx interface{}, exec interface{}, views interface{}) *MockQueries_ViewDependencies_Call {
+	return &MockQueries_ViewDependencies_Call{Call: _e.mock.On("ViewDependencies", ctx, exec, views)}
+}
+
+func (_c *MockQueries_ViewDependencies_Call) Run(run func(ctx context.Context, exec query.Executor, views []int)) *MockQueries_ViewDependencies_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(query.Executor), args[2].([]int))
+	})
+	return _c
+}
+
+func (_c *MockQueries_ViewDependencies_Call) Return(_a0 []query.ViewDependency, _a1 error) *MockQueries_ViewDependencies_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockQueries_ViewDependencies_Call) RunAndReturn(run func(context.Context, query.Executor, []int) ([]query.ViewDependency, error)) *MockQueries_ViewDependencies_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Views provides a mock function with given fields: ctx, exec, patterns
+func (_m *MockQueries) Views(ctx context.Context, exec query.Executor, patterns []query.TablesPattern) ([]query.View, error) {
+	ret := _m.Called(ctx, exec, patterns)
+
+	var r0 []query.View
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, query.Executor, []query.TablesPattern) ([]query.View, error)); ok {
+		return rf(ctx, exec, patterns)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, query.Executor, []query.TablesPattern) []query.View); ok {
+		r0 = rf(ctx, exec, patterns)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]query.View)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, query.Executor, []query.TablesPattern) error); ok {
+		r1 = rf(ctx, exec, patterns)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockQueries_Views_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Views'
+type MockQueries_Views_Call struct {
+	*mock.Call
+}
+
+// Views is a helper method to define mock.On call
+//   - ctx context.Context
+//   - exec query.Executor
+//   - patterns []query.TablesPattern
+func (_e *MockQueries_Expecter) Views(ctx interface{}, exec interface{}, patterns interface{}) *MockQueries_Views_Call {
+	return &MockQueries_Views_Call{Call: _e.mock.On("Views", ctx, exec, patterns)}
+}
+
+func (_c *MockQueries_Views_Call) Run(run func(ctx context.Context, exec query.Executor, patterns []query.TablesPattern)) *MockQueries_Views_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(query.Executor), args[2].([]query.TablesPattern))
+	})
+	return _c
+}
+
+func (_c *MockQueries_Views_Call) Return(_a0 []query.View, _a1 error) *MockQueries_Views_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockQueries_Views_Call) RunAndReturn(run func(context.Context, query.Executor, []query.TablesPattern) ([]query.View, error)) *MockQueries_Views_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 type mockConstructorTestingTNewMockQueries interface {
 	mock.TestingT
 	Cleanup(func())
@@ -29,11 +29,20 @@ func TestParse(t *testing.T) {
 	type typeQuery struct {
 		types    []int
 		typesRet []query.Type
+		// поля составных типов
+		composites colQuery
 	}
 	type enumsQuery struct {
 		enums    []int
 		enumsRet []query.Enum
 	}
+	type viewsQuery struct {
+		views        []query.View
+		columns      []query.Column
+		dependencies []query.ViewDependency
+		// ожидаемые зависимости представлений
+		dependsOn map[string]map[string][]string
+	}
 	tests := []*struct {
 		name        string
 		tables      []query.Table
@@ -42,20 +51,39 @@ func TestParse(t *testing.T) {
 		indexes     indQuery
 		types       []typeQuery
 		enums       enumsQuery
+		views       viewsQuery
+		partitions  []query.Partition
+		triggers    []query.Trigger
 	}{
 		{
 			name: "simple",
 			tables: []query.Table{
 				{Table: "table1", OID: 1},
-				{Table: "table2", OID: 2},
+				{
+					Table: "table2", OID: 2,
+					PartitionStrategy: sql.NullString{String: "r", Valid: true},
+					PartitionColumns:  []int{1},
+					PartitionKeyDef:   sql.NullString{String: "RANGE (col1)", Valid: true},
+				},
 			},
 			columns: colQuery{
 				tables: []int{1, 2},
 				columns: []query.Column{
-					{TableOID: 1, ColumnNum: 1, ColumnName: "col1", TypeOID: 12},
+					{
+						TableOID: 1, ColumnNum: 1, ColumnName: "col1", TypeOID: 12,
+						Identity:          "a",
+						SequenceSchema:    sql.NullString{String: "public", Valid: true},
+						SequenceName:      sql.NullString{String: "table1_col1_seq", Valid: true},
+						SequenceStart:     sql.NullInt64{Int64: 1, Valid: true},
+						SequenceIncrement: sql.NullInt64{Int64: 1, Valid: true},
+						SequenceMin:       sql.NullInt64{Int64: 1, Valid: true},
+						SequenceMax:       sql.NullInt64{Int64: 2147483647, Valid: true},
+						SequenceCycle:     sql.NullBool{Bool: false, Valid: true},
+					},
 					{TableOID: 1, ColumnNum: 2, ColumnName: "col2", TypeOID: 13},
 					{TableOID: 1, ColumnNum: 3, ColumnName: "col3", TypeOID: 14, CharacterMaxLength: sql.NullInt32{Int32: 255, Valid: true}},
 					{TableOID: 1, ColumnNum: 4, ColumnName: "col4", TypeOID: 15},
+					{TableOID: 1, ColumnNum: 5, ColumnName: "col5", TypeOID: 17},
 
 					{TableOID: 2, ColumnNum: 1, ColumnName: "col1", TypeOID: 12},
 				},
@@ -74,7 +102,7 @@ func TestParse(t *testing.T) {
 			},
 			types: []typeQuery{
 				{
-					types: []int{12, 13, 14, 15},
+					types: []int{12, 13, 14, 15, 17},
 					typesRet: []query.Type{
 						{TypeOID: 12, TypeName: "base_type", TypeType: "b"},
 						{TypeOID: 13, TypeName: "custom_enum", TypeType: "e"},
@@ -86,12 +114,24 @@ func TestParse(t *testing.T) {
 							TypeOID: 15, TypeName: "custom_domain2", TypeType: "d",
 							DomainTypeOID: sql.NullInt32{Int32: 12, Valid: true},
 						},
+						{
+							TypeOID: 17, TypeName: "custom_composite", TypeType: "c",
+							CompositeRelationOID: sql.NullInt32{Int32: 30, Valid: true},
+						},
+					},
+					composites: colQuery{
+						tables: []int{30},
+						columns: []query.Column{
+							{TableOID: 30, ColumnNum: 2, ColumnName: "second", TypeOID: 18},
+							{TableOID: 30, ColumnNum: 1, ColumnName: "first", TypeOID: 14},
+						},
 					},
 				},
 				{
-					types: []int{16},
+					types: []int{16, 18},
 					typesRet: []query.Type{
 						{TypeOID: 16, TypeName: "custom_domain_elem", TypeType: "b"},
+						{TypeOID: 18, TypeName: "int4", TypeSchema: "pg_catalog", TypeType: "b"},
 					},
 				},
 			},
@@ -101,10 +141,25 @@ func TestParse(t *testing.T) {
 				indexes: []query.Index{
 					{
 						TableOID: 1, IndexOID: 4, IndexName: "idx",
-						ConstraintOID: sql.NullInt32{Int32: int32(23), Valid: true},
-						Columns:       []int{1},
-						IsUnique:      true,
-						IsPrimary:     true,
+						ConstraintOID:   sql.NullInt32{Int32: int32(23), Valid: true},
+						Columns:         []int{1},
+						KeyColumnsCount: 1,
+						IsUnique:        true,
+						IsPrimary:       true,
+					},
+					{
+						TableOID: 1, IndexOID: 10, IndexName: "table1_col2_key",
+						Columns:         []int{2, 3},
+						KeyColumnsCount: 1,
+						IsUnique:        true,
+						IndexDefinition: "CREATE UNIQUE INDEX table1_col2_key ON public.table1 USING btree (col2) INCLUDE (col3)",
+					},
+					{
+						TableOID: 1, IndexOID: 11, IndexName: "table1_col2_expr_key",
+						Columns:         []int{2, 0},
+						KeyColumnsCount: 2,
+						IsUnique:        true,
+						IndexDefinition: "CREATE UNIQUE INDEX table1_col2_expr_key ON public.table1 USING btree (col2, lower(col3))",
 					},
 				},
 			},
@@ -112,6 +167,42 @@ func TestParse(t *testing.T) {
 				enums:    []int{13},
 				enumsRet: []query.Enum{{TypeOID: 13, Values: []string{"val1", "val2"}}},
 			},
+			partitions: []query.Partition{
+				{TableOID: 2, PartitionOID: 8, Partition: "table2_default", Bound: "DEFAULT", IsDefault: true},
+				{TableOID: 2, PartitionOID: 7, Partition: "table2_1", Bound: "FOR VALUES FROM (1) TO (10)"},
+			},
+			triggers: []query.Trigger{
+				{
+					TableOID: 1, TriggerOID: 9, TriggerName: "table1_audit",
+					// BEFORE INSERT OR UPDATE FOR EACH ROW
+					TriggerType:    1 | 2 | 4 | 16,
+					Condition:      sql.NullString{String: "new.col1 > 0", Valid: true},
+					FunctionSchema: "public",
+					FunctionName:   "audit",
+					FunctionSource: "CREATE OR REPLACE FUNCTION public.audit() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN RETURN NEW; END $$",
+				},
+			},
+			views: viewsQuery{
+				views: []query.View{
+					{OID: 5, Schema: "public", View: "view1", Kind: "v", Definition: "SELECT col1, col2 FROM table1"},
+					{OID: 6, Schema: "public", View: "view2", Kind: "m", Definition: "SELECT col1 FROM view1"},
+				},
+				columns: []query.Column{
+					{TableOID: 5, ColumnNum: 1, ColumnName: "col1", TypeOID: 12},
+					{TableOID: 5, ColumnNum: 2, ColumnName: "col2", TypeOID: 13},
+					{TableOID: 6, ColumnNum: 1, ColumnName: "col1", TypeOID: 12},
+				},
+				dependencies: []query.ViewDependency{
+					{ViewOID: 5, Schema: "public", Relation: "table1"},
+					{ViewOID: 5, Schema: "public", Relation: "table1", Column: sql.NullString{String: "col1", Valid: true}},
+					{ViewOID: 5, Schema: "public", Relation: "table1", Column: sql.NullString{String: "col2", Valid: true}},
+					{ViewOID: 6, Schema: "public", Relation: "view1", Column: sql.NullString{String: "col1", Valid: true}},
+				},
+				dependsOn: map[string]map[string][]string{
+					"public.view1": {"public.table1": {"col1", "col2"}},
+					"public.view2": {"public.view1": {"col1"}},
+				},
+			},
 		},
 	}
 
@@ -129,19 +220,109 @@ func TestParse(t *testing.T) {
 			expect := q.EXPECT()
 			expect.Tables(anyCtx, anyExec, mock.Anything).Return(tt.tables, nil)
 			expect.Columns(anyCtx, anyExec, tt.columns.tables).Return(tt.columns.columns, nil)
+			if len(tt.partitions) != 0 {
+				expect.Partitions(anyCtx, anyExec, []int{2}).Return(tt.partitions, nil)
+			}
 			expect.Constraints(anyCtx, anyExec, tt.constraints.tables).Return(tt.constraints.constraints, nil)
 			expect.Indexes(anyCtx, anyExec, tt.indexes.tables, tt.indexes.constraints).Return(tt.indexes.indexes, nil)
+			expect.Triggers(anyCtx, anyExec, tt.columns.tables).Return(tt.triggers, nil)
 			for _, typQ := range tt.types {
 				q.EXPECT().Types(anyCtx, anyExec, typQ.types).Return(typQ.typesRet, nil)
+				if len(typQ.composites.tables) != 0 {
+					expect.Columns(anyCtx, anyExec, typQ.composites.tables).Return(typQ.composites.columns, nil)
+				}
 			}
 			q.EXPECT().Enums(anyCtx, anyExec, tt.enums.enums).Return(tt.enums.enumsRet, nil)
+			expect.Views(anyCtx, anyExec, mock.Anything).Return(tt.views.views, nil)
+			if len(tt.views.views) != 0 {
+				viewOIDs := make([]int, 0, len(tt.views.views))
+				for _, view := range tt.views.views {
+					viewOIDs = append(viewOIDs, view.OID)
+				}
+				expect.Columns(anyCtx, anyExec, viewOIDs).Return(tt.views.columns, nil)
+				expect.ViewDependencies(anyCtx, anyExec, viewOIDs).Return(tt.views.dependencies, nil)
+			}
 
 			p := NewParser(nil, log.Named(tt.name))
 			p.q = q
 
 			schema, err := p.LoadSchema(context.Background(), Config{})
-			_ = schema
 			r.NoError(err)
+			for _, table := range schema.Tables {
+				for _, fk := range table.ForeignKeys {
+					r.Contains(schema.Tables, fk.ReferenceTable)
+					r.Contains(schema.Tables[fk.ReferenceTable].ReferencedBy, table.String())
+				}
+			}
+			// внешний ключ ссылается на таблицу ForeignTableOID, а не на свою таблицу
+			fk := schema.Tables[".table1"].ForeignKeys["fk"]
+			r.Equal(".table2", fk.ReferenceTable)
+			r.Equal([]string{"col1"}, fk.ReferenceColumns)
+			r.Contains(schema.Tables[".table2"].ReferencedBy, ".table1")
+			// колонки INCLUDE не входят в ключ уникального индекса
+			r.Equal([]string{"col2"}, schema.Tables[".table1"].Indexes["table1_col2_key"].Columns)
+			r.False(schema.Tables[".table1"].Indexes["table1_col2_key"].HasExpressions)
+			// выражения не попадают в колонки, но индекс помечается как содержащий их
+			exprIndex := schema.Tables[".table1"].Indexes["table1_col2_expr_key"]
+			r.Equal([]string{"col2"}, exprIndex.Columns)
+			r.True(exprIndex.HasExpressions)
+			r.NotNil(schema.Tables[".table1"].PrimaryKey)
+			r.Equal("pk", schema.Tables[".table1"].PrimaryKey.Name)
+			if len(tt.partitions) != 0 {
+				partitioning := schema.Tables[".table2"].Partitioning
+				r.NotNil(partitioning)
+				r.Equal([]string{"col1"}, partitioning.Columns)
+				r.Len(partitioning.Partitions, len(tt.partitions))
+				// партиции отсортированы по именам
+				r.Equal("table2_1", partitioning.Partitions[0].Name.Name)
+				r.Equal("FOR VALUES FROM (1) TO (10)", partitioning.Partitions[0].Bound)
+				r.True(partitioning.HasDefault())
+			}
+			if table, ok := schema.Tables[".table1"]; ok {
+				attrs := table.Columns["col1"].Attributes
+				r.True(attrs.IsGeneratedAlways())
+				r.NotNil(attrs.Sequence)
+				r.Equal("public.table1_col1_seq", attrs.Sequence.String())
+				r.Equal(int64(2147483647), attrs.Sequence.MaxValue)
+				r.Nil(table.Columns["col2"].Attributes.Sequence)
+			}
+			for _, trigger := range tt.triggers {
+				var table string
+				for _, dbtable := range tt.tables {
+					if dbtable.OID == trigger.TableOID {
+						table = "." + dbtable.Table
+					}
+				}
+				r.Contains(schema.Tables[table].Triggers, trigger.TriggerName)
+			}
+			if trigger, ok := schema.Tables[".table1"].Triggers["table1_audit"]; ok {
+				r.EqualValues("BEFORE", trigger.Timing)
+				r.Len(trigger.Events, 2)
+				r.EqualValues("INSERT", trigger.Events[0])
+				r.EqualValues("UPDATE", trigger.Events[1])
+				r.True(trigger.ForEachRow)
+				r.Equal("new.col1 > 0", trigger.Condition)
+				r.Equal("public.audit", trigger.Function.String())
+				r.True(trigger.FiresOn("INSERT"))
+				r.False(trigger.FiresOn("DELETE"))
+				r.Len(schema.TriggerFunctions(), 1)
+			}
+			if composite, ok := schema.Types[".custom_composite"]; ok {
+				r.Len(composite.Attributes, 2)
+				r.Equal("first", composite.Attributes[0].Name)
+				r.Equal("custom_domain1", composite.Attributes[0].Type.TypeName.Name)
+				r.Equal("second", composite.Attributes[1].Name)
+				r.Equal("int4", composite.Attributes[1].Type.String())
+			}
+			r.Len(schema.Views, len(tt.views.views))
+			for name, dependsOn := range tt.views.dependsOn {
+				r.Contains(schema.Views, name)
+				r.Equal(dependsOn, schema.Views[name].DependsOn)
+			}
+			if view, ok := schema.Views["public.view2"]; ok {
+				r.True(view.Materialized)
+				r.Contains(view.Columns, "col1")
+			}
 		})
 	}
 }
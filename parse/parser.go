@@ -30,6 +30,10 @@ type Queries interface {
 	Types(ctx context.Context, exec query.Executor, types []int) ([]query.Type, error)
 	Indexes(ctx context.Context, exec query.Executor, tables []int, constraints []int) ([]query.Index, error)
 	Enums(ctx context.Context, exec query.Executor, enums []int) ([]query.Enum, error)
+	Partitions(ctx context.Context, exec query.Executor, tables []int) ([]query.Partition, error)
+	Views(ctx context.Context, exec query.Executor, patterns []query.TablesPattern) ([]query.View, error)
+	ViewDependencies(ctx context.Context, exec query.Executor, views []int) ([]query.ViewDependency, error)
+	Triggers(ctx context.Context, exec query.Executor, tables []int) ([]query.Trigger, error)
 }
 
 type Parser struct {
@@ -68,12 +72,21 @@ func (p *Parser) LoadSchema(ctx context.Context, conf Config) (*schema.Schema, e
 	if err := p.loadTablesColumns(ctx, tableOIDs); err != nil {
 		return nil, xerrors.Errorf("load tables columns: %w", err)
 	}
+	if err := p.loadPartitions(ctx); err != nil {
+		return nil, xerrors.Errorf("load partitions: %w", err)
+	}
 	if err := p.loadConstraints(ctx, tableOIDs); err != nil {
 		return nil, xerrors.Errorf("load constraints: %w", err)
 	}
 	if err := p.loadIndexes(ctx, tableOIDs); err != nil {
 		return nil, xerrors.Errorf("load indexes: %w", err)
 	}
+	if err := p.loadTriggers(ctx, tableOIDs); err != nil {
+		return nil, xerrors.Errorf("load triggers: %w", err)
+	}
+	if err := p.loadViews(ctx, patterns); err != nil {
+		return nil, xerrors.Errorf("load views: %w", err)
+	}
 	if err := p.loadTypes(ctx); err != nil {
 		return nil, xerrors.Errorf("load types: %w", err)
 	}
@@ -129,6 +142,37 @@ func (p *Parser) loadTablesColumns(
 	return nil
 }
 
+// loadPartitions загружает партиции партиционированных таблиц.
+func (p *Parser) loadPartitions(ctx context.Context) error {
+	var tableOIDs []int
+	for oid, table := range p.schema.tables {
+		if table.table.PartitionStrategy.Valid {
+			tableOIDs = append(tableOIDs, oid)
+		}
+	}
+	if len(tableOIDs) == 0 {
+		return nil
+	}
+	slices.Sort(tableOIDs)
+
+	partitions, err := p.q.Partitions(ctx, p.conn, tableOIDs)
+	if err != nil {
+		p.log.Error("failed to query partitions", zap.Error(err))
+		return err
+	}
+	p.log.Debug("loaded partitions", zap.Int("n", len(partitions)), zap.Ints("tables", tableOIDs))
+
+	for _, partition := range partitions {
+		table, ok := p.schema.tables[partition.TableOID]
+		if !ok {
+			return xerrors.Errorf("table with oid %d not found", partition.TableOID)
+		}
+		table.partitions = append(table.partitions, partition)
+		p.schema.tables[partition.TableOID] = table
+	}
+	return nil
+}
+
 // loadConstraints загружает ограничения для всех найденных таблиц.
 func (p *Parser) loadConstraints(
 	ctx context.Context,
@@ -172,6 +216,83 @@ func (p *Parser) loadIndexes(
 	return nil
 }
 
+// loadTriggers загружает триггеры таблиц вместе с функциями триггеров.
+func (p *Parser) loadTriggers(
+	ctx context.Context,
+	tableOIDs []int,
+) error {
+	triggers, err := p.q.Triggers(ctx, p.conn, tableOIDs)
+	if err != nil {
+		p.log.Error("failed to query tables triggers", zap.Error(err))
+		return err
+	}
+	p.log.Debug("loaded triggers", zap.Int("n", len(triggers)))
+
+	for _, trigger := range triggers {
+		table, ok := p.schema.tables[trigger.TableOID]
+		if !ok {
+			return xerrors.Errorf("table with oid %d not found", trigger.TableOID)
+		}
+		table.triggers = append(table.triggers, trigger)
+		p.schema.tables[trigger.TableOID] = table
+	}
+	return nil
+}
+
+// loadViews загружает представления, их колонки и зависимости от других отношений.
+func (p *Parser) loadViews(
+	ctx context.Context,
+	patterns []query.TablesPattern,
+) error {
+	views, err := p.q.Views(ctx, p.conn, patterns)
+	if err != nil {
+		p.log.Error("failed to query views", zap.Error(err))
+		return err
+	}
+	if len(views) == 0 {
+		return nil
+	}
+	p.log.Debug("loaded views", zap.Reflect("views", views))
+
+	for _, dbview := range views {
+		p.schema.views[dbview.OID] = parseView{
+			view:    dbview,
+			columns: make(map[int]query.Column),
+		}
+	}
+	viewOIDs := maps.Keys(p.schema.views)
+	slices.Sort(viewOIDs)
+
+	columns, err := p.q.Columns(ctx, p.conn, viewOIDs)
+	if err != nil {
+		p.log.Error("failed to query views columns", zap.Error(err))
+		return err
+	}
+	for _, col := range columns {
+		view, ok := p.schema.views[col.TableOID]
+		if !ok {
+			return xerrors.Errorf("view with oid %d not found", col.TableOID)
+		}
+		view.columns[col.ColumnNum] = col
+	}
+
+	deps, err := p.q.ViewDependencies(ctx, p.conn, viewOIDs)
+	if err != nil {
+		p.log.Error("failed to query views dependencies", zap.Error(err))
+		return err
+	}
+	p.log.Debug("loaded views dependencies", zap.Int("n", len(deps)))
+	for _, dep := range deps {
+		view, ok := p.schema.views[dep.ViewOID]
+		if !ok {
+			return xerrors.Errorf("view with oid %d not found", dep.ViewOID)
+		}
+		view.dependencies = append(view.dependencies, dep)
+		p.schema.views[dep.ViewOID] = view
+	}
+	return nil
+}
+
 func (p *Parser) loadTypes(ctx context.Context) error {
 	typeSet := mapset.NewThreadUnsafeSet[int]()
 	for _, table := range p.schema.tables {
@@ -179,6 +300,11 @@ func (p *Parser) loadTypes(ctx context.Context) error {
 			typeSet.Add(col.TypeOID)
 		}
 	}
+	for _, view := range p.schema.views {
+		for _, col := range view.columns {
+			typeSet.Add(col.TypeOID)
+		}
+	}
 
 	for typeSet.Cardinality() != 0 {
 		types := typeSet.ToSlice()
@@ -192,6 +318,9 @@ func (p *Parser) loadTypes(ctx context.Context) error {
 
 		typeSet.Clear()
 		p.loadTypesByOIDs(dbtypes, typeSet)
+		if err := p.loadCompositeAttributes(ctx, dbtypes, typeSet); err != nil {
+			return xerrors.Errorf("load composite types attributes: %w", err)
+		}
 	}
 
 	return nil
@@ -232,6 +361,44 @@ func (p *Parser) loadTypesByOIDs(
 	}
 }
 
+// loadCompositeAttributes загружает поля составных типов как колонки отношений этих типов.
+// Еще не загруженные типы полей добавляются в typeSet.
+func (p *Parser) loadCompositeAttributes(
+	ctx context.Context,
+	types []query.Type,
+	typeSet mapset.Set[int],
+) error {
+	var relOIDs []int
+	for _, typ := range types {
+		if typ.TypeType == "c" && typ.CompositeRelationOID.Valid {
+			relOIDs = append(relOIDs, int(typ.CompositeRelationOID.Int32))
+		}
+	}
+	if len(relOIDs) == 0 {
+		return nil
+	}
+	slices.Sort(relOIDs)
+
+	columns, err := p.q.Columns(ctx, p.conn, relOIDs)
+	if err != nil {
+		return err
+	}
+	p.log.Debug("loaded composite types attributes", zap.Int("n", len(columns)))
+
+	for _, col := range columns {
+		attrs, ok := p.schema.composites[col.TableOID]
+		if !ok {
+			attrs = make(map[int]query.Column)
+			p.schema.composites[col.TableOID] = attrs
+		}
+		attrs[col.ColumnNum] = col
+		if _, ok := p.schema.types[col.TypeOID]; !ok {
+			typeSet.Add(col.TypeOID)
+		}
+	}
+	return nil
+}
+
 func (p *Parser) loadEnums(ctx context.Context) error {
 	enums, err := p.q.Enums(ctx, p.conn, p.schema.enumList)
 	if err != nil {
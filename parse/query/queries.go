@@ -16,6 +16,12 @@ type Table struct {
 	OID    int
 	Schema string
 	Table  string
+	// Значение pg_partitioned_table.partstrat: r - range, l - list, h - hash.
+	// Пустое, если таблица не партиционирована.
+	PartitionStrategy sql.NullString
+	// Номера колонок ключа партиционирования, 0 означает выражение
+	PartitionColumns []int
+	PartitionKeyDef  sql.NullString
 }
 
 type TablesPattern struct {
@@ -44,13 +50,38 @@ func (Queries) Tables(ctx context.Context, exec Executor, p []TablesPattern) ([]
 SELECT
 	c.oid::INT AS table_oid,
 	ns.nspname AS schema_name,
-	c.relname AS table_name
+	c.relname AS table_name,
+	pt.partstrat::TEXT AS partition_strategy,
+	pt.partattrs::INT2[]::INT[] AS partition_columns,
+	pg_get_partkeydef(c.oid) AS partition_key_def
 FROM
 	pg_class c
 	JOIN pg_namespace ns ON ns.oid = c.relnamespace
+	LEFT JOIN pg_partitioned_table pt ON pt.partrelid = c.oid
 WHERE
-	c.relkind = 'r'`
+	c.relkind IN ('r', 'p')
+	-- partitions are loaded with their parent tables
+	AND NOT c.relispartition`
 
+	where, args := patternsCondition(p)
+	return QueryAll(
+		ctx, exec,
+		func(scan pgx.Rows, v *Table) error {
+			return scan.Scan(
+				&v.OID,
+				&v.Schema,
+				&v.Table,
+				&v.PartitionStrategy,
+				&v.PartitionColumns,
+				&v.PartitionKeyDef,
+			)
+		},
+		fmt.Sprintf("%s AND (%s) ORDER BY c.oid ASC", queryTablesSQL, where),
+		args...)
+}
+
+// patternsCondition возвращает условие выборки отношений по шаблонам схем и имен.
+func patternsCondition(p []TablesPattern) (string, []any) {
 	var qb queryBuiler
 
 	for _, pattern := range p {
@@ -65,22 +96,156 @@ WHERE
 
 		qb.Append(schema, args...)
 	}
+	return strings.Join(qb.queries, " OR "), qb.args
+}
+
+//go:embed sql/partitions.sql
+var queryPartitionsSQL string
+
+type Partition struct {
+	TableOID     int
+	PartitionOID int
+	Schema       string
+	Partition    string
+	// Результат pg_get_expr(relpartbound)
+	Bound         string
+	IsDefault     bool
+	IsPartitioned bool
+}
+
+func (Queries) Partitions(
+	ctx context.Context, exec Executor,
+	tableOIDs []int,
+) ([]Partition, error) {
+	return QueryAll(
+		ctx, exec,
+		func(scan pgx.Rows, v *Partition) error {
+			return scan.Scan(
+				&v.TableOID,
+				&v.PartitionOID,
+				&v.Schema,
+				&v.Partition,
+				&v.Bound,
+				&v.IsDefault,
+				&v.IsPartitioned,
+			)
+		},
+		queryPartitionsSQL,
+		tableOIDs,
+	)
+}
+
+//go:embed sql/triggers.sql
+var queryTriggersSQL string
+
+type Trigger struct {
+	TableOID    int
+	TriggerOID  int
+	TriggerName string
+	// Битовая маска pg_trigger.tgtype: уровень, момент срабатывания и события
+	TriggerType int
+	IsDisabled  bool
+	Condition   sql.NullString
+	// Функция триггера
+	FunctionSchema string
+	FunctionName   string
+	FunctionSource string
+	Definition     string
+}
 
+func (Queries) Triggers(
+	ctx context.Context, exec Executor,
+	tableOIDs []int,
+) ([]Trigger, error) {
 	return QueryAll(
 		ctx, exec,
-		func(scan pgx.Rows, v *Table) error {
+		func(scan pgx.Rows, v *Trigger) error {
+			return scan.Scan(
+				&v.TableOID,
+				&v.TriggerOID,
+				&v.TriggerName,
+				&v.TriggerType,
+				&v.IsDisabled,
+				&v.Condition,
+				&v.FunctionSchema,
+				&v.FunctionName,
+				&v.FunctionSource,
+				&v.Definition,
+			)
+		},
+		queryTriggersSQL,
+		tableOIDs,
+	)
+}
+
+type View struct {
+	OID    int
+	Schema string
+	View   string
+	// Значение pg_class.relkind: v - представление, m - материализованное представление
+	Kind       string
+	Definition string
+}
+
+func (Queries) Views(ctx context.Context, exec Executor, p []TablesPattern) ([]View, error) {
+	const queryViewsSQL = `-- list views
+SELECT
+	c.oid::INT AS view_oid,
+	ns.nspname AS schema_name,
+	c.relname AS view_name,
+	c.relkind::TEXT AS view_kind,
+	pg_get_viewdef(c.oid) AS view_definition
+FROM
+	pg_class c
+	JOIN pg_namespace ns ON ns.oid = c.relnamespace
+WHERE
+	c.relkind IN ('v', 'm')`
+
+	where, args := patternsCondition(p)
+	return QueryAll(
+		ctx, exec,
+		func(scan pgx.Rows, v *View) error {
 			return scan.Scan(
 				&v.OID,
 				&v.Schema,
-				&v.Table,
+				&v.View,
+				&v.Kind,
+				&v.Definition,
+			)
+		},
+		fmt.Sprintf("%s AND (%s) ORDER BY c.oid ASC", queryViewsSQL, where),
+		args...)
+}
+
+//go:embed sql/view_dependencies.sql
+var queryViewDependenciesSQL string
+
+// ViewDependency описывает зависимость представления от отношения или его колонки.
+type ViewDependency struct {
+	ViewOID  int
+	Schema   string
+	Relation string
+	// Пустое, если представление зависит от отношения целиком
+	Column sql.NullString
+}
+
+func (Queries) ViewDependencies(
+	ctx context.Context, exec Executor,
+	viewOIDs []int,
+) ([]ViewDependency, error) {
+	return QueryAll(
+		ctx, exec,
+		func(scan pgx.Rows, v *ViewDependency) error {
+			return scan.Scan(
+				&v.ViewOID,
+				&v.Schema,
+				&v.Relation,
+				&v.Column,
 			)
 		},
-		fmt.Sprintf(
-			"%s AND (%s) ORDER BY c.oid ASC",
-			queryTablesSQL,
-			strings.Join(qb.queries, " OR "),
-		),
-		qb.args...)
+		queryViewDependenciesSQL,
+		viewOIDs,
+	)
 }
 
 //go:embed sql/columns.sql
@@ -100,6 +265,16 @@ type Column struct {
 	IsNumeric          bool
 	NumericPriecision  sql.NullInt32
 	NumericScale       sql.NullInt32
+	// Значение pg_attribute.attidentity: a - ALWAYS, d - BY DEFAULT, пустое для обычных колонок
+	Identity string
+	// Последовательность колонки: IDENTITY, SERIAL или nextval в значении по умолчанию
+	SequenceSchema    sql.NullString
+	SequenceName      sql.NullString
+	SequenceStart     sql.NullInt64
+	SequenceIncrement sql.NullInt64
+	SequenceMin       sql.NullInt64
+	SequenceMax       sql.NullInt64
+	SequenceCycle     sql.NullBool
 }
 
 func (Queries) Columns(
@@ -123,6 +298,14 @@ func (Queries) Columns(
 				&v.IsNumeric,
 				&v.NumericPriecision,
 				&v.NumericScale,
+				&v.Identity,
+				&v.SequenceSchema,
+				&v.SequenceName,
+				&v.SequenceStart,
+				&v.SequenceIncrement,
+				&v.SequenceMin,
+				&v.SequenceMax,
+				&v.SequenceCycle,
 			)
 		},
 		queryColumnsSQL, tableOIDs)
@@ -186,6 +369,8 @@ type Type struct {
 	DomainNumericScale     sql.NullInt32
 	DomainArrayDims        int
 	RangeElementTypeOID    sql.NullInt32
+	// Отношение, в котором хранятся поля составного типа
+	CompositeRelationOID sql.NullInt32
 }
 
 func (Queries) Types(
@@ -210,6 +395,7 @@ func (Queries) Types(
 				&v.DomainNumericScale,
 				&v.DomainArrayDims,
 				&v.RangeElementTypeOID,
+				&v.CompositeRelationOID,
 			)
 		},
 		querySelectTypesSQL, typeOIDs)
@@ -228,7 +414,9 @@ type Index struct {
 	IsPrimary          bool
 	IsNullsNotDistinct bool
 	Columns            []int
-	IndexDefinition    string
+	// Количество ключевых колонок в начале Columns, остальные добавлены через INCLUDE
+	KeyColumnsCount int
+	IndexDefinition string
 }
 
 func (Queries) Indexes(
@@ -250,6 +438,7 @@ func (Queries) Indexes(
 				&v.IsPrimary,
 				&v.IsNullsNotDistinct,
 				&v.Columns,
+				&v.KeyColumnsCount,
 				&v.IndexDefinition,
 			)
 		},
@@ -41,12 +41,48 @@ SELECT
 			elem_t.oid,
 			a.atttypmod
 		)
-	)::INT AS numeric_scale
+	)::INT AS numeric_scale,
+	a.attidentity::TEXT AS identity,
+	-- sequence
+	seq_ns.nspname    AS sequence_schema,
+	seq_c.relname     AS sequence_name,
+	seq.seqstart      AS sequence_start,
+	seq.seqincrement  AS sequence_increment,
+	seq.seqmin        AS sequence_min,
+	seq.seqmax        AS sequence_max,
+	seq.seqcycle      AS sequence_cycle
 FROM
 	pg_attribute a
 	JOIN pg_type t ON a.atttypid = t.oid
 	LEFT JOIN pg_attrdef ad ON a.attrelid = ad.adrelid AND a.attnum = ad.adnum
 	LEFT JOIN pg_type elem_t ON elem_t.oid = t.typelem
+	LEFT JOIN LATERAL (
+		SELECT s.*
+		FROM (
+			-- sequence used in the default expression, e.g. nextval('seq'::regclass)
+			SELECT d.refobjid AS seq_oid, 0 AS priority
+			FROM pg_depend d
+			WHERE
+				d.classid = 'pg_attrdef'::REGCLASS
+				AND d.objid = ad.oid
+				AND d.refclassid = 'pg_class'::REGCLASS
+			UNION ALL
+			-- identity sequence or sequence owned by the column (serial)
+			SELECT d.objid, 1
+			FROM pg_depend d
+			WHERE
+				d.classid = 'pg_class'::REGCLASS
+				AND d.refclassid = 'pg_class'::REGCLASS
+				AND d.refobjid = a.attrelid
+				AND d.refobjsubid = a.attnum
+				AND d.deptype IN ('a', 'i')
+		) deps
+		JOIN pg_sequence s ON s.seqrelid = deps.seq_oid
+		ORDER BY deps.priority
+		LIMIT 1
+	) seq ON TRUE
+	LEFT JOIN pg_class seq_c ON seq_c.oid = seq.seqrelid
+	LEFT JOIN pg_namespace seq_ns ON seq_ns.oid = seq_c.relnamespace
 WHERE
 	attnum > 0
 	AND attisdropped = False
@@ -12,6 +12,7 @@ SELECT
     i.indisprimary AS is_primary,
     i.indnullsnotdistinct AS is_nulls_not_distinct,
     COALESCE(i.indkey, '{}'::INT[]) AS index_colnums,
+    i.indnkeyatts::INT AS index_key_colnums_count,
     pg_get_indexdef(ci.oid) AS index_def
 FROM
     pg_index i
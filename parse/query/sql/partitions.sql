@@ -0,0 +1,19 @@
+-- partitions
+SELECT
+	i.inhparent::INT AS table_oid,
+	c.oid::INT       AS partition_oid,
+	ns.nspname       AS schema_name,
+	c.relname        AS partition_name,
+	pg_get_expr(c.relpartbound, c.oid) AS partition_bound,
+	pg_get_expr(c.relpartbound, c.oid) = 'DEFAULT' AS is_default,
+	c.relkind = 'p'  AS is_partitioned
+FROM
+	pg_inherits i
+	JOIN pg_class c ON c.oid = i.inhrelid
+	JOIN pg_namespace ns ON ns.oid = c.relnamespace
+WHERE
+	c.relispartition
+	AND i.inhparent = ANY($1)
+ORDER BY
+	i.inhparent,
+	c.relname;
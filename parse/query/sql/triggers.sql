@@ -0,0 +1,23 @@
+-- triggers
+SELECT
+	t.tgrelid::INT    AS table_oid,
+	t.oid::INT        AS trigger_oid,
+	t.tgname          AS trigger_name,
+	t.tgtype::INT     AS trigger_type,
+	t.tgenabled = 'D' AS is_disabled,
+	-- pg_get_expr can not deparse OLD and NEW references of the condition
+	substring(pg_get_triggerdef(t.oid) FROM ' WHEN \((.*)\) EXECUTE ') AS trigger_condition,
+	pn.nspname        AS function_schema,
+	p.proname         AS function_name,
+	pg_get_functiondef(p.oid) AS function_source,
+	pg_get_triggerdef(t.oid)  AS trigger_definition
+FROM
+	pg_trigger t
+	JOIN pg_proc p ON p.oid = t.tgfoid
+	JOIN pg_namespace pn ON pn.oid = p.pronamespace
+WHERE
+	NOT t.tgisinternal
+	AND t.tgrelid = ANY($1)
+ORDER BY
+	t.tgrelid,
+	t.tgname;
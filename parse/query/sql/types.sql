@@ -15,7 +15,9 @@ SELECT
 	information_schema._pg_numeric_scale(dt.oid, t.typtypmod)::INT AS domain_scale,
 	t.typndims AS domain_array_dims,
 	-- range types
-	rng.rngsubtype::INT AS range_element_type_oid
+	rng.rngsubtype::INT AS range_element_type_oid,
+	-- composite types, attributes are loaded as columns of the relation
+	NULLIF(t.typrelid, 0)::INT AS composite_relation_oid
 FROM
 	pg_type t
 	LEFT JOIN pg_type  et  ON et.oid =   t.typelem
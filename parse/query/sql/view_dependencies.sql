@@ -0,0 +1,22 @@
+-- view dependencies
+SELECT DISTINCT
+	r.ev_class::INT AS view_oid,
+	ns.nspname      AS schema_name,
+	c.relname       AS relation_name,
+	a.attname       AS column_name
+FROM
+	pg_rewrite r
+	JOIN pg_depend d ON d.classid = 'pg_rewrite'::REGCLASS AND d.objid = r.oid
+	JOIN pg_class c ON c.oid = d.refobjid
+	JOIN pg_namespace ns ON ns.oid = c.relnamespace
+	LEFT JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+WHERE
+	d.refclassid = 'pg_class'::REGCLASS
+	-- the view rule depends on the view itself
+	AND d.refobjid <> r.ev_class
+	AND r.ev_class = ANY($1)
+ORDER BY
+	view_oid,
+	schema_name,
+	relation_name,
+	column_name;
@@ -7,6 +7,8 @@ import (
 	"github.com/Feresey/mtest/parse/query"
 	"github.com/Feresey/mtest/schema"
 	mapset "github.com/deckarep/golang-set/v2"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 	"golang.org/x/xerrors"
 )
 
@@ -20,6 +22,30 @@ var pgConstraintType = map[string]schema.ConstraintType{
 	"x": schema.ConstraintTypeExclusion,
 }
 
+// Перевод значений колонки pg_partitioned_table.partstrat.
+var pgPartitionStrategy = map[string]schema.PartitionStrategy{
+	"r": schema.PartitionStrategyRange,
+	"l": schema.PartitionStrategyList,
+	"h": schema.PartitionStrategyHash,
+}
+
+// Перевод значений колонки pg_attribute.attidentity.
+var pgIdentity = map[string]schema.IdentityKind{
+	"a": schema.IdentityAlways,
+	"d": schema.IdentityByDefault,
+}
+
+// Биты колонки pg_trigger.tgtype.
+const (
+	pgTriggerTypeRow      = 1 << 0
+	pgTriggerTypeBefore   = 1 << 1
+	pgTriggerTypeInsert   = 1 << 2
+	pgTriggerTypeDelete   = 1 << 3
+	pgTriggerTypeUpdate   = 1 << 4
+	pgTriggerTypeTruncate = 1 << 5
+	pgTriggerTypeInstead  = 1 << 6
+)
+
 // Перевод значений колонки pg_type.typtype.
 var pgTypType = map[string]schema.DataType{
 	"b": schema.DataTypeBase,
@@ -37,8 +63,11 @@ type parseSchema struct {
 
 	enumList []int
 	enums    map[int]query.Enum
+	// поля составных типов по OID отношения типа
+	composites map[int]map[int]query.Column
 
 	tables map[int]parseTable
+	views  map[int]parseView
 
 	constraints      map[int]query.Constraint
 	constraintsByOID map[int]*schema.Constraint
@@ -53,7 +82,10 @@ func newParseSchema() parseSchema {
 		enumList: nil,
 		enums:    make(map[int]query.Enum),
 
+		composites: make(map[int]map[int]query.Column),
+
 		tables: make(map[int]parseTable),
+		views:  make(map[int]parseView),
 
 		constraints:      make(map[int]query.Constraint),
 		constraintsByOID: make(map[int]*schema.Constraint),
@@ -62,14 +94,23 @@ func newParseSchema() parseSchema {
 }
 
 type parseTable struct {
-	table   query.Table
-	columns map[int]query.Column
+	table      query.Table
+	columns    map[int]query.Column
+	partitions []query.Partition
+	triggers   []query.Trigger
+}
+
+type parseView struct {
+	view         query.View
+	columns      map[int]query.Column
+	dependencies []query.ViewDependency
 }
 
 func (ps *parseSchema) convertToSchema() (*schema.Schema, error) {
 	s := &schema.Schema{
 		Types:  make(map[string]*schema.DBType),
 		Tables: make(map[string]schema.Table),
+		Views:  make(map[string]schema.View),
 	}
 
 	if err := ps.convertTypes(s); err != nil {
@@ -84,6 +125,9 @@ func (ps *parseSchema) convertToSchema() (*schema.Schema, error) {
 	if err := ps.convertIndexes(s); err != nil {
 		return nil, xerrors.Errorf("convert indexes: %w", err)
 	}
+	if err := ps.convertViews(s); err != nil {
+		return nil, xerrors.Errorf("convert views: %w", err)
+	}
 	return s, nil
 }
 
@@ -148,6 +192,7 @@ func (ps *parseSchema) fillType(dbtype *query.Type) (*schema.DBType, error) {
 		elemType         *schema.DBType
 		enumValues       []string
 		domainAttributes *schema.DomainAttributes
+		attributes       []schema.Column
 	)
 
 	switch typType {
@@ -176,7 +221,12 @@ func (ps *parseSchema) fillType(dbtype *query.Type) (*schema.DBType, error) {
 	case schema.DataTypeMultiRange:
 	// TODO add multirange type
 	case schema.DataTypeComposite:
-	// TODO add composite type
+		attrs := make(map[string]schema.Column)
+		if err := ps.convertColumns(ps.composites[int(dbtype.CompositeRelationOID.Int32)], attrs); err != nil {
+			return nil, xerrors.Errorf("get composite attributes: %w", err)
+		}
+		attributes = maps.Values(attrs)
+		slices.SortFunc(attributes, func(a, b schema.Column) bool { return a.ColNum < b.ColNum })
 	case schema.DataTypeDomain:
 		elem, ok := ps.typesByOID[int(dbtype.DomainTypeOID.Int32)]
 		if !ok {
@@ -205,6 +255,7 @@ func (ps *parseSchema) fillType(dbtype *query.Type) (*schema.DBType, error) {
 		ElemType:         elemType,
 		DomainAttributes: domainAttributes,
 		EnumValues:       enumValues,
+		Attributes:       attributes,
 	}, nil
 }
 
@@ -224,36 +275,189 @@ func (ps *parseSchema) convertTables(s *schema.Schema) error {
 			Indexes:      make(map[string]schema.Index),
 		}
 
-		for _, col := range table.columns {
-			typ, ok := ps.typesByOID[col.TypeOID]
-			if !ok {
-				return xerrors.Errorf("get column type for table %q: %w", t, getTypeError(col.TypeOID))
+		if err := ps.convertColumns(table.columns, t.Columns); err != nil {
+			return xerrors.Errorf("convert columns of table %q: %w", t, err)
+		}
+		if table.table.PartitionStrategy.Valid {
+			partitioning, err := ps.convertPartitioning(&table)
+			if err != nil {
+				return xerrors.Errorf("convert partitioning of table %q: %w", t, err)
 			}
+			t.Partitioning = partitioning
+		}
+		if len(table.triggers) != 0 {
+			t.Triggers = make(map[string]schema.Trigger, len(table.triggers))
+			for _, trigger := range table.triggers {
+				t.Triggers[trigger.TriggerName] = convertTrigger(trigger)
+			}
+		}
+
+		s.Tables[t.String()] = t
+	}
+
+	return nil
+}
+
+func convertTrigger(trigger query.Trigger) schema.Trigger {
+	timing := schema.TriggerTimingAfter
+	switch {
+	case trigger.TriggerType&pgTriggerTypeBefore != 0:
+		timing = schema.TriggerTimingBefore
+	case trigger.TriggerType&pgTriggerTypeInstead != 0:
+		timing = schema.TriggerTimingInsteadOf
+	}
+
+	var events []schema.TriggerEvent
+	for _, event := range []struct {
+		bit   int
+		event schema.TriggerEvent
+	}{
+		{pgTriggerTypeInsert, schema.TriggerEventInsert},
+		{pgTriggerTypeUpdate, schema.TriggerEventUpdate},
+		{pgTriggerTypeDelete, schema.TriggerEventDelete},
+		{pgTriggerTypeTruncate, schema.TriggerEventTruncate},
+	} {
+		if trigger.TriggerType&event.bit != 0 {
+			events = append(events, event.event)
+		}
+	}
+
+	return schema.Trigger{
+		Name:       trigger.TriggerName,
+		Timing:     timing,
+		Events:     events,
+		ForEachRow: trigger.TriggerType&pgTriggerTypeRow != 0,
+		Condition:  trigger.Condition.String,
+		Function: schema.Identifier{
+			Schema: trigger.FunctionSchema,
+			Name:   trigger.FunctionName,
+		},
+		FunctionSource: trigger.FunctionSource,
+		Definition:     trigger.Definition,
+		Disabled:       trigger.IsDisabled,
+	}
+}
+
+func (ps *parseSchema) convertColumns(columns map[int]query.Column, res map[string]schema.Column) error {
+	for _, col := range columns {
+		typ, ok := ps.typesByOID[col.TypeOID]
+		if !ok {
+			return xerrors.Errorf("get column %q type: %w", col.ColumnName, getTypeError(col.TypeOID))
+		}
 
-			t.Columns[col.ColumnName] = schema.Column{
-				ColNum: col.ColumnNum,
-				Name:   col.ColumnName,
-				Type:   typ,
-				Attributes: schema.ColumnAttributes{
-					HasDefault:  col.HasDefault,
-					IsGenerated: col.IsGenerated,
-					Default:     col.DefaultExpr.String,
-					DomainAttributes: schema.DomainAttributes{
-						NotNullable:      col.IsNullable,
-						HasCharMaxLength: col.CharacterMaxLength.Valid,
-						CharMaxLength:    int(col.CharacterMaxLength.Int32),
-						ArrayDims:        col.ArrayDims,
-						IsNumeric:        col.IsNumeric,
-						NumericPrecision: int(col.NumericPriecision.Int32),
-						NumericScale:     int(col.NumericScale.Int32),
-					},
+		var seq *schema.Sequence
+		if col.SequenceName.Valid {
+			seq = &schema.Sequence{
+				Name: schema.Identifier{
+					Schema: col.SequenceSchema.String,
+					Name:   col.SequenceName.String,
 				},
+				Start:     col.SequenceStart.Int64,
+				Increment: col.SequenceIncrement.Int64,
+				MinValue:  col.SequenceMin.Int64,
+				MaxValue:  col.SequenceMax.Int64,
+				Cycle:     col.SequenceCycle.Bool,
 			}
 		}
 
-		s.Tables[t.String()] = t
+		res[col.ColumnName] = schema.Column{
+			ColNum: col.ColumnNum,
+			Name:   col.ColumnName,
+			Type:   typ,
+			Attributes: schema.ColumnAttributes{
+				HasDefault:  col.HasDefault,
+				IsGenerated: col.IsGenerated,
+				Default:     col.DefaultExpr.String,
+				Identity:    pgIdentity[col.Identity],
+				Sequence:    seq,
+				DomainAttributes: schema.DomainAttributes{
+					NotNullable:      col.IsNullable,
+					HasCharMaxLength: col.CharacterMaxLength.Valid,
+					CharMaxLength:    int(col.CharacterMaxLength.Int32),
+					ArrayDims:        col.ArrayDims,
+					IsNumeric:        col.IsNumeric,
+					NumericPrecision: int(col.NumericPriecision.Int32),
+					NumericScale:     int(col.NumericScale.Int32),
+				},
+			},
+		}
+	}
+	return nil
+}
+
+func (ps *parseSchema) convertPartitioning(table *parseTable) (*schema.Partitioning, error) {
+	strategy, ok := pgPartitionStrategy[table.table.PartitionStrategy.String]
+	if !ok {
+		return nil, xerrors.Errorf("unsupported partition strategy: %q", table.table.PartitionStrategy.String)
 	}
 
+	// номер 0 означает выражение вместо колонки
+	columns := make([]string, 0, len(table.table.PartitionColumns))
+	for _, colnum := range table.table.PartitionColumns {
+		if colnum == 0 {
+			columns = append(columns, "")
+			continue
+		}
+		col, ok := table.columns[colnum]
+		if !ok {
+			return nil, xerrors.Errorf("partition key column %d not found in table %d", colnum, table.table.OID)
+		}
+		columns = append(columns, col.ColumnName)
+	}
+
+	p := &schema.Partitioning{
+		Strategy:   strategy,
+		Columns:    columns,
+		Definition: table.table.PartitionKeyDef.String,
+		Partitions: make([]schema.Partition, 0, len(table.partitions)),
+	}
+	for _, partition := range table.partitions {
+		p.Partitions = append(p.Partitions, schema.Partition{
+			Name: schema.Identifier{
+				OID:    partition.PartitionOID,
+				Schema: partition.Schema,
+				Name:   partition.Partition,
+			},
+			Bound:         partition.Bound,
+			IsDefault:     partition.IsDefault,
+			IsPartitioned: partition.IsPartitioned,
+		})
+	}
+	slices.SortFunc(p.Partitions, func(a, b schema.Partition) bool { return a.Name.String() < b.Name.String() })
+	return p, nil
+}
+
+// convertViews переводит представления. Зависимости хранятся по именам отношений,
+// поэтому представление может зависеть и от таблиц, не попавших в схему.
+func (ps *parseSchema) convertViews(s *schema.Schema) error {
+	for _, dbview := range ps.views {
+		v := schema.View{
+			Name: schema.Identifier{
+				OID:    dbview.view.OID,
+				Schema: dbview.view.Schema,
+				Name:   dbview.view.View,
+			},
+			Materialized: dbview.view.Kind == "m",
+			Definition:   dbview.view.Definition,
+			Columns:      make(map[string]schema.Column),
+			DependsOn:    make(map[string][]string),
+		}
+
+		if err := ps.convertColumns(dbview.columns, v.Columns); err != nil {
+			return xerrors.Errorf("convert columns of view %q: %w", v, err)
+		}
+
+		for _, dep := range dbview.dependencies {
+			relation := schema.Identifier{Schema: dep.Schema, Name: dep.Relation}.String()
+			columns := v.DependsOn[relation]
+			if dep.Column.Valid && !slices.Contains(columns, dep.Column.String) {
+				columns = append(columns, dep.Column.String)
+			}
+			v.DependsOn[relation] = columns
+		}
+
+		s.Views[v.String()] = v
+	}
 	return nil
 }
 
@@ -304,8 +508,10 @@ func (ps *parseSchema) convertConstraints(s *schema.Schema) error {
 		case schema.ConstraintTypePK:
 			// PRIMARY KEY либо один либо нет его
 			table.PrimaryKey = c
+			// table - копия, остальные поля таблицы - общие мапы
+			s.Tables[table.String()] = table
 		case schema.ConstraintTypeFK:
-			dbreftable, reftable, err := ps.getTable(s, dbconstraint.TableOID)
+			dbreftable, reftable, err := ps.getTable(s, int(dbconstraint.ForeignTableOID.Int32))
 			if err != nil {
 				return xerrors.Errorf("get ref table for table %q fk constraint %q: %w", table, c, err)
 			}
@@ -320,6 +526,7 @@ func (ps *parseSchema) convertConstraints(s *schema.Schema) error {
 				ReferenceTable:   reftable.String(),
 				ReferenceColumns: refcols,
 			}
+			reftable.ReferencedBy[table.String()] = c
 		}
 	}
 
@@ -332,7 +539,22 @@ func (ps *parseSchema) convertIndexes(s *schema.Schema) error {
 		if err != nil {
 			return xerrors.Errorf("get table for index %q: %w", dbindex.IndexName, err)
 		}
-		cols, err := ps.checkTableColumns(dbindex.Columns, &dbtable)
+		// колонки INCLUDE не входят в ключ индекса и не влияют на уникальность
+		keyColnums := dbindex.Columns
+		if dbindex.KeyColumnsCount < len(keyColnums) {
+			keyColnums = keyColnums[:dbindex.KeyColumnsCount]
+		}
+		// номер 0 означает выражение вместо колонки
+		colnums := make([]int, 0, len(keyColnums))
+		hasExpressions := false
+		for _, colnum := range keyColnums {
+			if colnum == 0 {
+				hasExpressions = true
+				continue
+			}
+			colnums = append(colnums, colnum)
+		}
+		cols, err := ps.checkTableColumns(colnums, &dbtable)
 		if err != nil {
 			return xerrors.Errorf("check table %q columns for index %q: %w",
 				table, dbindex.IndexName, err)
@@ -342,6 +564,7 @@ func (ps *parseSchema) convertIndexes(s *schema.Schema) error {
 			OID:                dbindex.IndexOID,
 			Name:               dbindex.IndexName,
 			Columns:            cols,
+			HasExpressions:     hasExpressions,
 			Definition:         dbindex.IndexDefinition,
 			IsUnique:           dbindex.IsUnique,
 			IsPrimary:          dbindex.IsPrimary,
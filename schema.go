@@ -19,6 +19,28 @@ import (
 
 type SchemaLoaderFlags struct {
 	dumpPath *cli.StringFlag
+	fromSQL  *cli.StringFlag
+}
+
+func newFromSQLFlag() *cli.StringFlag {
+	return &cli.StringFlag{
+		Name:      "from-sql",
+		Usage:     "--from-sql schema.sql parse schema from sql script instead of database",
+		TakesFile: true,
+		Action: func(ctx *cli.Context, fpath string) error {
+			if fpath == stdinFileName {
+				return nil
+			}
+			fileInfo, err := os.Stat(fpath)
+			if err != nil {
+				return xerrors.Errorf("sql script %q does not exist", fpath)
+			}
+			if fileInfo.IsDir() {
+				return xerrors.Errorf("%q is a directory, expected file", fpath)
+			}
+			return nil
+		},
+	}
 }
 
 func NewSchemaLoaderFlags() SchemaLoaderFlags {
@@ -41,6 +63,7 @@ func NewSchemaLoaderFlags() SchemaLoaderFlags {
 				return xerrors.Errorf("file %s exists but is not readable", fpath)
 			},
 		},
+		fromSQL: newFromSQLFlag(),
 	}
 }
 
@@ -67,7 +90,7 @@ func NewSchemaLoader(
 const stdinFileName = "-"
 
 func (p *SchemaLoader) Init(ctx *cli.Context, flags flags, sflags SchemaLoaderFlags) error {
-	if sflags.dumpPath.Get(ctx) == "" {
+	if sflags.dumpPath.Get(ctx) == "" && sflags.fromSQL.Get(ctx) == "" {
 		conn, err := p.connectDB(ctx, flags.debug.Get(ctx))
 		if err != nil {
 			return cli.Exit(err, 3)
@@ -77,6 +100,19 @@ func (p *SchemaLoader) Init(ctx *cli.Context, flags flags, sflags SchemaLoaderFl
 	return nil
 }
 
+// Connect возвращает соединение с базой данных, создавая его при необходимости.
+func (p *SchemaLoader) Connect(ctx *cli.Context, flags flags) (*pgx.Conn, error) {
+	if p.conn != nil {
+		return p.conn, nil
+	}
+	conn, err := p.connectDB(ctx, flags.debug.Get(ctx))
+	if err != nil {
+		return nil, err
+	}
+	p.conn = conn
+	return conn, nil
+}
+
 func (p *SchemaLoader) Cleanup(ctx *cli.Context) error {
 	if p.conn == nil {
 		return nil
@@ -94,13 +130,16 @@ func (p *SchemaLoader) GetSchema(
 	if filename := sflags.dumpPath.Get(ctx); filename != "" {
 		return p.getSchemaFromFile(filename)
 	}
+	if filename := sflags.fromSQL.Get(ctx); filename != "" {
+		return p.loadSchemaFromSQL(filename)
+	}
 	p.log.Info("schema dump path is not specified")
 	return p.parseDB(ctx)
 }
 
-func (p *SchemaLoader) getSchemaFromFile(filename string) (s *schema.Schema, err error) {
-	p.log.Debug("load schema from file", zap.String("filename", filename))
-	defer p.log.Info("schema loaded", zap.Error(err), zap.String("filename", filename))
+func (b *BaseCommand) getSchemaFromFile(filename string) (s *schema.Schema, err error) {
+	b.log.Debug("load schema from file", zap.String("filename", filename))
+	defer b.log.Info("schema loaded", zap.Error(err), zap.String("filename", filename))
 	var in io.Reader
 	if filename == stdinFileName {
 		in = os.Stdin
@@ -136,3 +175,24 @@ func (p *SchemaLoader) parseDB(ctx *cli.Context) (s *schema.Schema, err error) {
 
 	return s, nil
 }
+
+// loadSchemaFromSQL разбирает схему из SQL скрипта без подключения к базе данных.
+func (b *BaseCommand) loadSchemaFromSQL(filename string) (s *schema.Schema, err error) {
+	b.log.Debug("parse schema from sql script", zap.String("filename", filename))
+	defer b.log.Info("schema parsed", zap.Error(err), zap.String("filename", filename))
+	var in io.Reader = os.Stdin
+	if filename != stdinFileName {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, xerrors.Errorf("open sql script: %w", err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	s, err = parse.NewSQLParser(b.log).LoadSchema(in, b.cnf.Parser)
+	if err != nil {
+		return nil, xerrors.Errorf("parse sql script: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,466 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+)
+
+// ChangeKind описывает вид изменения объекта схемы.
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
+
+// ObjectKind описывает вид изменённого объекта схемы.
+type ObjectKind string
+
+const (
+	ObjectTable        ObjectKind = "table"
+	ObjectColumn       ObjectKind = "column"
+	ObjectType         ObjectKind = "type"
+	ObjectEnumValue    ObjectKind = "enum value"
+	ObjectConstraint   ObjectKind = "constraint"
+	ObjectIndex        ObjectKind = "index"
+	ObjectView         ObjectKind = "view"
+	ObjectTrigger      ObjectKind = "trigger"
+	ObjectPartitionKey ObjectKind = "partition key"
+	ObjectPartition    ObjectKind = "partition"
+)
+
+// Change описывает одно изменение между двумя схемами.
+type Change struct {
+	Kind   ChangeKind `json:"kind"`
+	Object ObjectKind `json:"object"`
+	// Таблица или тип, которому принадлежит объект. Пустое для таблиц и типов.
+	Parent string `json:"parent,omitempty"`
+	// Имя объекта
+	Name string `json:"name"`
+	// Описание объекта до изменения. Пустое для добавленных объектов.
+	Old string `json:"old,omitempty"`
+	// Описание объекта после изменения. Пустое для удалённых объектов.
+	New string `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	name := c.Name
+	if c.Parent != "" {
+		name = c.Parent + "." + c.Name
+	}
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s %s: %s", c.Object, name, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s %s: %s", c.Object, name, c.Old)
+	default:
+		return fmt.Sprintf("~ %s %s: %s -> %s", c.Object, name, c.Old, c.New)
+	}
+}
+
+// Diff содержит изменения между двумя схемами.
+type Diff struct {
+	Changes []Change `json:"changes"`
+}
+
+// Empty возвращает true, если схемы не отличаются.
+func (d *Diff) Empty() bool { return len(d.Changes) == 0 }
+
+// WriteText выводит изменения в текстовом виде, по одному на строку.
+func (d *Diff) WriteText(w io.Writer) error {
+	for _, c := range d.Changes {
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return xerrors.Errorf("write change: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteJSON выводит изменения в формате JSON.
+func (d *Diff) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		return xerrors.Errorf("encode diff: %w", err)
+	}
+	return nil
+}
+
+// Compare сравнивает две схемы.
+// OID объектов не сравниваются, потому что они различаются между базами данных.
+// Встроенные типы из pg_catalog не сравниваются, изменения колонок отражают их использование.
+func Compare(from, to *Schema) *Diff {
+	d := &Diff{Changes: []Change{}}
+	d.compareTypes(from.Types, to.Types)
+	d.compareTables(from.Tables, to.Tables)
+	d.compareViews(from.Views, to.Views)
+	return d
+}
+
+func (d *Diff) add(c Change) { d.Changes = append(d.Changes, c) }
+
+func (d *Diff) compareTypes(from, to map[string]*DBType) {
+	userTypes := func(types map[string]*DBType) map[string]*DBType {
+		res := make(map[string]*DBType, len(types))
+		for name, typ := range types {
+			if typ.TypeName.Schema != "pg_catalog" {
+				res[name] = typ
+			}
+		}
+		return res
+	}
+	from, to = userTypes(from), userTypes(to)
+
+	for _, name := range unionKeys(from, to) {
+		oldType, inOld := from[name]
+		newType, inNew := to[name]
+		switch {
+		case !inNew:
+			d.add(Change{Kind: ChangeRemoved, Object: ObjectType, Name: name, Old: typeDefinition(oldType)})
+		case !inOld:
+			d.add(Change{Kind: ChangeAdded, Object: ObjectType, Name: name, New: typeDefinition(newType)})
+		default:
+			// значения перечислений сравниваются по отдельности
+			oldBase, newBase := *oldType, *newType
+			oldBase.EnumValues, newBase.EnumValues = nil, nil
+			if oldDef, newDef := typeDefinition(&oldBase), typeDefinition(&newBase); oldDef != newDef {
+				d.add(Change{
+					Kind: ChangeChanged, Object: ObjectType, Name: name,
+					Old: typeDefinition(oldType), New: typeDefinition(newType),
+				})
+			}
+			d.compareEnumValues(name, oldType.EnumValues, newType.EnumValues)
+		}
+	}
+}
+
+// compareEnumValues сравнивает значения перечисления с учетом порядка,
+// потому что порядок значений определяет их сортировку и сравнение.
+func (d *Diff) compareEnumValues(typeName string, from, to []string) {
+	for _, value := range from {
+		if !slices.Contains(to, value) {
+			d.add(Change{Kind: ChangeRemoved, Object: ObjectEnumValue, Parent: typeName, Name: value, Old: quote(value)})
+		}
+	}
+	for idx, value := range to {
+		if !slices.Contains(from, value) {
+			def := quote(value)
+			// значение добавлено не в конец, как ALTER TYPE ... ADD VALUE ... BEFORE
+			if idx+1 < len(to) {
+				def += " BEFORE " + quote(to[idx+1])
+			}
+			d.add(Change{Kind: ChangeAdded, Object: ObjectEnumValue, Parent: typeName, Name: value, New: def})
+		}
+	}
+
+	common := func(values, other []string) []string {
+		var res []string
+		for _, value := range values {
+			if slices.Contains(other, value) {
+				res = append(res, quote(value))
+			}
+		}
+		return res
+	}
+	if oldOrder, newOrder := common(from, to), common(to, from); !slices.Equal(oldOrder, newOrder) {
+		d.add(Change{
+			Kind: ChangeChanged, Object: ObjectEnumValue, Parent: typeName, Name: "order",
+			Old: strings.Join(oldOrder, ", "), New: strings.Join(newOrder, ", "),
+		})
+	}
+}
+
+func (d *Diff) compareTables(from, to map[string]Table) {
+	for _, name := range unionKeys(from, to) {
+		oldTable, inOld := from[name]
+		newTable, inNew := to[name]
+		switch {
+		case !inNew:
+			d.add(Change{Kind: ChangeRemoved, Object: ObjectTable, Name: name, Old: tableDefinition(oldTable)})
+		case !inOld:
+			d.add(Change{Kind: ChangeAdded, Object: ObjectTable, Name: name, New: tableDefinition(newTable)})
+		default:
+			d.compareColumns(name, oldTable.Columns, newTable.Columns)
+			d.compareConstraints(name, oldTable.Constraints, newTable.Constraints)
+			d.compareIndexes(name, oldTable.Indexes, newTable.Indexes)
+			d.comparePartitioning(name, oldTable.Partitioning, newTable.Partitioning)
+			d.compareTriggers(name, oldTable.Triggers, newTable.Triggers)
+		}
+	}
+}
+
+func (d *Diff) compareColumns(table string, from, to map[string]Column) {
+	for _, name := range unionKeys(from, to) {
+		oldCol, inOld := from[name]
+		newCol, inNew := to[name]
+		oldDef, newDef := columnDefinition(oldCol), columnDefinition(newCol)
+		switch {
+		case !inNew:
+			d.add(Change{Kind: ChangeRemoved, Object: ObjectColumn, Parent: table, Name: name, Old: oldDef})
+		case !inOld:
+			d.add(Change{Kind: ChangeAdded, Object: ObjectColumn, Parent: table, Name: name, New: newDef})
+		case oldDef != newDef:
+			d.add(Change{Kind: ChangeChanged, Object: ObjectColumn, Parent: table, Name: name, Old: oldDef, New: newDef})
+		}
+	}
+}
+
+func (d *Diff) compareConstraints(table string, from, to map[string]*Constraint) {
+	for _, name := range unionKeys(from, to) {
+		oldCon, inOld := from[name]
+		newCon, inNew := to[name]
+		switch {
+		case !inNew:
+			d.add(Change{Kind: ChangeRemoved, Object: ObjectConstraint, Parent: table, Name: name, Old: constraintDefinition(oldCon)})
+		case !inOld:
+			d.add(Change{Kind: ChangeAdded, Object: ObjectConstraint, Parent: table, Name: name, New: constraintDefinition(newCon)})
+		default:
+			if oldDef, newDef := constraintDefinition(oldCon), constraintDefinition(newCon); oldDef != newDef {
+				d.add(Change{Kind: ChangeChanged, Object: ObjectConstraint, Parent: table, Name: name, Old: oldDef, New: newDef})
+			}
+		}
+	}
+}
+
+func (d *Diff) compareIndexes(table string, from, to map[string]Index) {
+	for _, name := range unionKeys(from, to) {
+		oldIndex, inOld := from[name]
+		newIndex, inNew := to[name]
+		switch {
+		case !inNew:
+			d.add(Change{Kind: ChangeRemoved, Object: ObjectIndex, Parent: table, Name: name, Old: oldIndex.Definition})
+		case !inOld:
+			d.add(Change{Kind: ChangeAdded, Object: ObjectIndex, Parent: table, Name: name, New: newIndex.Definition})
+		case oldIndex.Definition != newIndex.Definition:
+			d.add(Change{Kind: ChangeChanged, Object: ObjectIndex, Parent: table, Name: name, Old: oldIndex.Definition, New: newIndex.Definition})
+		}
+	}
+}
+
+// comparePartitioning сравнивает ключи партиционирования таблицы и ее партиции.
+func (d *Diff) comparePartitioning(table string, from, to *Partitioning) {
+	oldKey, newKey := partitionKeyDefinition(from), partitionKeyDefinition(to)
+	switch {
+	case newKey == "" && oldKey != "":
+		d.add(Change{Kind: ChangeRemoved, Object: ObjectPartitionKey, Name: table, Old: oldKey})
+	case oldKey == "" && newKey != "":
+		d.add(Change{Kind: ChangeAdded, Object: ObjectPartitionKey, Name: table, New: newKey})
+	case oldKey != newKey:
+		d.add(Change{Kind: ChangeChanged, Object: ObjectPartitionKey, Name: table, Old: oldKey, New: newKey})
+	}
+
+	partitions := func(p *Partitioning) map[string]Partition {
+		res := make(map[string]Partition)
+		if p != nil {
+			for _, partition := range p.Partitions {
+				res[partition.String()] = partition
+			}
+		}
+		return res
+	}
+	oldParts, newParts := partitions(from), partitions(to)
+	for _, name := range unionKeys(oldParts, newParts) {
+		oldPart, inOld := oldParts[name]
+		newPart, inNew := newParts[name]
+		oldDef, newDef := partitionDefinition(oldPart), partitionDefinition(newPart)
+		switch {
+		case !inNew:
+			d.add(Change{Kind: ChangeRemoved, Object: ObjectPartition, Parent: table, Name: name, Old: oldDef})
+		case !inOld:
+			d.add(Change{Kind: ChangeAdded, Object: ObjectPartition, Parent: table, Name: name, New: newDef})
+		case oldDef != newDef:
+			d.add(Change{Kind: ChangeChanged, Object: ObjectPartition, Parent: table, Name: name, Old: oldDef, New: newDef})
+		}
+	}
+}
+
+func (d *Diff) compareTriggers(table string, from, to map[string]Trigger) {
+	for _, name := range unionKeys(from, to) {
+		oldTrigger, inOld := from[name]
+		newTrigger, inNew := to[name]
+		oldDef, newDef := triggerDefinition(oldTrigger), triggerDefinition(newTrigger)
+		switch {
+		case !inNew:
+			d.add(Change{Kind: ChangeRemoved, Object: ObjectTrigger, Parent: table, Name: name, Old: oldDef})
+		case !inOld:
+			d.add(Change{Kind: ChangeAdded, Object: ObjectTrigger, Parent: table, Name: name, New: newDef})
+		case oldDef != newDef:
+			d.add(Change{Kind: ChangeChanged, Object: ObjectTrigger, Parent: table, Name: name, Old: oldDef, New: newDef})
+		}
+	}
+}
+
+func (d *Diff) compareViews(from, to map[string]View) {
+	for _, name := range unionKeys(from, to) {
+		oldView, inOld := from[name]
+		newView, inNew := to[name]
+		oldDef, newDef := viewDefinition(oldView), viewDefinition(newView)
+		switch {
+		case !inNew:
+			d.add(Change{Kind: ChangeRemoved, Object: ObjectView, Name: name, Old: oldDef})
+		case !inOld:
+			d.add(Change{Kind: ChangeAdded, Object: ObjectView, Name: name, New: newDef})
+		case oldDef != newDef:
+			d.add(Change{Kind: ChangeChanged, Object: ObjectView, Name: name, Old: oldDef, New: newDef})
+		}
+	}
+}
+
+// unionKeys возвращает отсортированные ключи обеих мап.
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := maps.Keys(a)
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func quote(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+
+func typeDefinition(typ *DBType) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(typ.Type.String()))
+	switch typ.Type {
+	case DataTypeEnum:
+		values := make([]string, 0, len(typ.EnumValues))
+		for _, value := range typ.EnumValues {
+			values = append(values, quote(value))
+		}
+		sb.WriteString(" (" + strings.Join(values, ", ") + ")")
+	case DataTypeDomain, DataTypeArray, DataTypeRange, DataTypeMultiRange:
+		if typ.ElemType != nil {
+			sb.WriteString(" of " + typ.ElemType.String())
+		}
+	case DataTypeComposite:
+		attrs := make([]string, 0, len(typ.Attributes))
+		for _, attr := range typ.Attributes {
+			attrs = append(attrs, attr.Name+" "+columnDefinition(attr))
+		}
+		sb.WriteString(" (" + strings.Join(attrs, ", ") + ")")
+	}
+	if attrs := typ.DomainAttributes; attrs != nil {
+		sb.WriteString(domainAttributesDefinition(*attrs))
+	}
+	return sb.String()
+}
+
+func domainAttributesDefinition(attrs DomainAttributes) string {
+	var sb strings.Builder
+	switch {
+	case attrs.HasCharMaxLength:
+		fmt.Fprintf(&sb, "(%d)", attrs.CharMaxLength)
+	case attrs.IsNumeric && attrs.NumericPrecision != 0:
+		fmt.Fprintf(&sb, "(%d,%d)", attrs.NumericPrecision, attrs.NumericScale)
+	}
+	sb.WriteString(strings.Repeat("[]", attrs.ArrayDims))
+	if attrs.NotNullable {
+		sb.WriteString(" NOT NULL")
+	}
+	return sb.String()
+}
+
+func tableDefinition(table Table) string {
+	cols := make([]Column, 0, len(table.Columns))
+	for _, col := range table.Columns {
+		cols = append(cols, col)
+	}
+	slices.SortFunc(cols, func(a, b Column) bool { return a.ColNum < b.ColNum })
+
+	defs := make([]string, 0, len(cols))
+	for _, col := range cols {
+		defs = append(defs, col.Name+" "+columnDefinition(col))
+	}
+	def := "(" + strings.Join(defs, ", ") + ")"
+	if key := partitionKeyDefinition(table.Partitioning); key != "" {
+		def += " PARTITION BY " + key
+	}
+	if len(table.Triggers) != 0 {
+		triggers := maps.Keys(table.Triggers)
+		slices.Sort(triggers)
+		def += " TRIGGERS (" + strings.Join(triggers, ", ") + ")"
+	}
+	return def
+}
+
+// partitionKeyDefinition возвращает ключ партиционирования или пустую строку, если таблица не партиционирована.
+func partitionKeyDefinition(p *Partitioning) string {
+	switch {
+	case p == nil:
+		return ""
+	case p.Definition != "":
+		return p.Definition
+	}
+	return fmt.Sprintf("%s (%s)", strings.ToUpper(string(p.Strategy)), strings.Join(p.Columns, ", "))
+}
+
+func partitionDefinition(p Partition) string {
+	if p.IsPartitioned {
+		return p.Bound + " PARTITIONED"
+	}
+	return p.Bound
+}
+
+// triggerDefinition описывает триггер без имени таблицы, чтобы не зависеть от формата pg_get_triggerdef.
+func triggerDefinition(t Trigger) string {
+	events := make([]string, 0, len(t.Events))
+	for _, event := range t.Events {
+		events = append(events, string(event))
+	}
+	def := string(t.Timing) + " " + strings.Join(events, " OR ")
+	if t.ForEachRow {
+		def += " FOR EACH ROW"
+	} else {
+		def += " FOR EACH STATEMENT"
+	}
+	if t.Condition != "" {
+		def += " WHEN (" + t.Condition + ")"
+	}
+	def += " EXECUTE FUNCTION " + t.Function.String()
+	if t.FunctionSource != "" {
+		// изменение тела функции меняет поведение триггера
+		def += " AS " + strings.Join(strings.Fields(t.FunctionSource), " ")
+	}
+	if t.Disabled {
+		def += " DISABLED"
+	}
+	return def
+}
+
+func columnDefinition(col Column) string {
+	if col.Type == nil {
+		return ""
+	}
+	attrs := col.Attributes
+	def := col.Type.String() + domainAttributesDefinition(attrs.DomainAttributes)
+	switch {
+	case attrs.IsGenerated:
+		def += " GENERATED ALWAYS AS (" + attrs.Default + ") STORED"
+	case attrs.HasDefault || attrs.Default != "":
+		def += " DEFAULT " + attrs.Default
+	case attrs.Identity != "":
+		def += " GENERATED " + strings.ToUpper(string(attrs.Identity)) + " AS IDENTITY"
+	}
+	return def
+}
+
+func viewDefinition(view View) string {
+	// pg_get_viewdef форматирует запрос переносами строк
+	return strings.ToLower(view.Kind()) + " AS " + strings.Join(strings.Fields(view.Definition), " ")
+}
+
+func constraintDefinition(c *Constraint) string {
+	if c.Definition != "" {
+		return c.Definition
+	}
+	return fmt.Sprintf("%s (%s)", c.Type, strings.Join(c.Columns, ", "))
+}
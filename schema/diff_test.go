@@ -0,0 +1,236 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	r := require.New(t)
+
+	int4 := &DBType{TypeName: Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"}, Type: DataTypeBase}
+	varchar := &DBType{TypeName: Identifier{OID: 1043, Schema: "pg_catalog", Name: "varchar"}, Type: DataTypeBase}
+	oldStatus := &DBType{
+		TypeName:   Identifier{OID: 16385, Schema: "public", Name: "status"},
+		Type:       DataTypeEnum,
+		EnumValues: []string{"active", "deleted"},
+	}
+	newStatus := &DBType{
+		// OID различается между базами данных и не должен учитываться
+		TypeName:   Identifier{OID: 20000, Schema: "public", Name: "status"},
+		Type:       DataTypeEnum,
+		EnumValues: []string{"active", "archived"},
+	}
+	oldPriority := &DBType{
+		TypeName:   Identifier{OID: 16386, Schema: "public", Name: "priority"},
+		Type:       DataTypeEnum,
+		EnumValues: []string{"low", "high"},
+	}
+	newPriority := &DBType{
+		TypeName:   Identifier{OID: 20001, Schema: "public", Name: "priority"},
+		Type:       DataTypeEnum,
+		EnumValues: []string{"high", "medium", "low"},
+	}
+	oldAddress := &DBType{
+		TypeName: Identifier{OID: 16390, Schema: "public", Name: "address"},
+		Type:     DataTypeComposite,
+		Attributes: []Column{
+			{ColNum: 1, Name: "city", Type: varchar, Attributes: ColumnAttributes{DomainAttributes: DomainAttributes{HasCharMaxLength: true, CharMaxLength: 50}}},
+		},
+	}
+	newAddress := &DBType{
+		TypeName: Identifier{OID: 20005, Schema: "public", Name: "address"},
+		Type:     DataTypeComposite,
+		Attributes: []Column{
+			{ColNum: 1, Name: "city", Type: varchar, Attributes: ColumnAttributes{DomainAttributes: DomainAttributes{HasCharMaxLength: true, CharMaxLength: 100}}},
+			{ColNum: 2, Name: "zip", Type: int4},
+		},
+	}
+
+	audit := Trigger{
+		Name: "users_audit", Timing: TriggerTimingAfter, Events: []TriggerEvent{TriggerEventInsert, TriggerEventUpdate},
+		Function: Identifier{Schema: "public", Name: "audit"},
+	}
+	oldCheck := Trigger{
+		Name: "users_check", Timing: TriggerTimingBefore, Events: []TriggerEvent{TriggerEventInsert}, ForEachRow: true,
+		Function:       Identifier{Schema: "public", Name: "check_user"},
+		FunctionSource: "BEGIN\n  RETURN new;\nEND",
+	}
+	newCheck := oldCheck
+	newCheck.Condition = "new.id > 0"
+	newCheck.FunctionSource = "BEGIN RETURN NULL; END"
+	notify := Trigger{
+		Name: "users_notify", Timing: TriggerTimingAfter, Events: []TriggerEvent{TriggerEventDelete}, ForEachRow: true,
+		Function: Identifier{Schema: "public", Name: "notify"}, Disabled: true,
+	}
+	metricsColumns := map[string]Column{
+		"id":      {ColNum: 1, Name: "id", Type: int4},
+		"created": {ColNum: 2, Name: "created", Type: int4},
+	}
+
+	from := &Schema{
+		Types: map[string]*DBType{"int4": int4, "varchar": varchar, "public.status": oldStatus, "public.address": oldAddress, "public.priority": oldPriority},
+		Tables: map[string]Table{
+			"public.users": {
+				Name: Identifier{OID: 1, Schema: "public", Name: "users"},
+				Columns: map[string]Column{
+					"id":     {ColNum: 1, Name: "id", Type: int4, Attributes: ColumnAttributes{DomainAttributes: DomainAttributes{NotNullable: true}}},
+					"name":   {ColNum: 2, Name: "name", Type: varchar, Attributes: ColumnAttributes{DomainAttributes: DomainAttributes{HasCharMaxLength: true, CharMaxLength: 50}}},
+					"status": {ColNum: 3, Name: "status", Type: oldStatus},
+					"legacy": {ColNum: 4, Name: "legacy", Type: int4},
+				},
+				Constraints: map[string]*Constraint{
+					"users_pkey": {Name: "users_pkey", Type: ConstraintTypePK, Definition: "PRIMARY KEY (id)", Columns: []string{"id"}},
+				},
+				Indexes: map[string]Index{
+					"users_pkey":     {Name: "users_pkey", Definition: "CREATE UNIQUE INDEX users_pkey ON public.users USING btree (id)"},
+					"users_name_idx": {Name: "users_name_idx", Definition: "CREATE INDEX users_name_idx ON public.users USING btree (name)"},
+				},
+				Triggers: map[string]Trigger{audit.Name: audit, oldCheck.Name: oldCheck},
+			},
+			"public.logs": {
+				Name:         Identifier{OID: 2, Schema: "public", Name: "logs"},
+				Columns:      map[string]Column{"id": {ColNum: 1, Name: "id", Type: int4}},
+				Partitioning: &Partitioning{Strategy: PartitionStrategyHash, Columns: []string{"id"}},
+				Triggers:     map[string]Trigger{audit.Name: audit},
+			},
+			"public.metrics": {
+				Name:    Identifier{OID: 6, Schema: "public", Name: "metrics"},
+				Columns: metricsColumns,
+				Partitioning: &Partitioning{
+					Strategy: PartitionStrategyRange, Columns: []string{"id"}, Definition: "RANGE (id)",
+					Partitions: []Partition{
+						{Name: Identifier{Schema: "public", Name: "metrics_1"}, Bound: "FOR VALUES FROM (1) TO (10)"},
+						{Name: Identifier{Schema: "public", Name: "metrics_2"}, Bound: "FOR VALUES FROM (10) TO (20)"},
+					},
+				},
+			},
+		},
+		Views: map[string]View{
+			"public.active_users": {
+				Name:       Identifier{OID: 4, Schema: "public", Name: "active_users"},
+				Definition: " SELECT users.id,\n    users.name\n   FROM users;",
+			},
+			"public.users_legacy": {
+				Name:         Identifier{OID: 5, Schema: "public", Name: "users_legacy"},
+				Materialized: true,
+				Definition:   " SELECT users.legacy\n   FROM users;",
+			},
+		},
+	}
+	to := &Schema{
+		Types: map[string]*DBType{"int4": int4, "varchar": varchar, "public.status": newStatus, "public.address": newAddress, "public.priority": newPriority},
+		Tables: map[string]Table{
+			"public.users": {
+				Name: Identifier{OID: 100, Schema: "public", Name: "users"},
+				Columns: map[string]Column{
+					"id":     {ColNum: 1, Name: "id", Type: int4, Attributes: ColumnAttributes{DomainAttributes: DomainAttributes{NotNullable: true}}},
+					"name":   {ColNum: 2, Name: "name", Type: varchar, Attributes: ColumnAttributes{DomainAttributes: DomainAttributes{HasCharMaxLength: true, CharMaxLength: 100}}},
+					"status": {ColNum: 3, Name: "status", Type: newStatus},
+					"age":    {ColNum: 5, Name: "age", Type: int4, Attributes: ColumnAttributes{HasDefault: true, Default: "18"}},
+				},
+				Constraints: map[string]*Constraint{
+					"users_pkey":      {Name: "users_pkey", Type: ConstraintTypePK, Definition: "PRIMARY KEY (id)", Columns: []string{"id"}},
+					"users_age_check": {Name: "users_age_check", Type: ConstraintTypeCheck, Definition: "CHECK ((age >= 18))", Columns: []string{"age"}},
+				},
+				Indexes: map[string]Index{
+					"users_pkey":     {Name: "users_pkey", Definition: "CREATE UNIQUE INDEX users_pkey ON public.users USING btree (id)"},
+					"users_name_idx": {Name: "users_name_idx", Definition: "CREATE INDEX users_name_idx ON public.users USING btree (lower((name)::text))"},
+				},
+				Triggers: map[string]Trigger{newCheck.Name: newCheck, notify.Name: notify},
+			},
+			"public.metrics": {
+				Name:    Identifier{OID: 106, Schema: "public", Name: "metrics"},
+				Columns: metricsColumns,
+				Partitioning: &Partitioning{
+					Strategy: PartitionStrategyRange, Columns: []string{"id", "created"}, Definition: "RANGE (id, created)",
+					Partitions: []Partition{
+						{Name: Identifier{Schema: "public", Name: "metrics_1"}, Bound: "FOR VALUES FROM (1, 1) TO (10, 1)"},
+						{Name: Identifier{Schema: "public", Name: "metrics_default"}, Bound: "DEFAULT", IsDefault: true, IsPartitioned: true},
+					},
+				},
+			},
+			"public.events": {
+				Name:    Identifier{OID: 3, Schema: "public", Name: "events"},
+				Columns: map[string]Column{"id": {ColNum: 1, Name: "id", Type: int4}},
+			},
+		},
+		Views: map[string]View{
+			"public.active_users": {
+				// переносы строк в определении не считаются изменением
+				Name:       Identifier{OID: 104, Schema: "public", Name: "active_users"},
+				Definition: " SELECT users.id, users.name FROM users;",
+			},
+		},
+	}
+
+	d := Compare(from, to)
+	r.Equal([]Change{
+		{
+			Kind: ChangeChanged, Object: ObjectType, Name: "public.address",
+			Old: "composite (city varchar(50))", New: "composite (city varchar(100), zip int4)",
+		},
+		{Kind: ChangeAdded, Object: ObjectEnumValue, Parent: "public.priority", Name: "medium", New: "'medium' BEFORE 'low'"},
+		{Kind: ChangeChanged, Object: ObjectEnumValue, Parent: "public.priority", Name: "order", Old: "'low', 'high'", New: "'high', 'low'"},
+		{Kind: ChangeRemoved, Object: ObjectEnumValue, Parent: "public.status", Name: "deleted", Old: "'deleted'"},
+		{Kind: ChangeAdded, Object: ObjectEnumValue, Parent: "public.status", Name: "archived", New: "'archived'"},
+		{Kind: ChangeAdded, Object: ObjectTable, Name: "public.events", New: "(id int4)"},
+		{Kind: ChangeRemoved, Object: ObjectTable, Name: "public.logs", Old: "(id int4) PARTITION BY HASH (id) TRIGGERS (users_audit)"},
+		{Kind: ChangeChanged, Object: ObjectPartitionKey, Name: "public.metrics", Old: "RANGE (id)", New: "RANGE (id, created)"},
+		{
+			Kind: ChangeChanged, Object: ObjectPartition, Parent: "public.metrics", Name: "public.metrics_1",
+			Old: "FOR VALUES FROM (1) TO (10)", New: "FOR VALUES FROM (1, 1) TO (10, 1)",
+		},
+		{Kind: ChangeRemoved, Object: ObjectPartition, Parent: "public.metrics", Name: "public.metrics_2", Old: "FOR VALUES FROM (10) TO (20)"},
+		{Kind: ChangeAdded, Object: ObjectPartition, Parent: "public.metrics", Name: "public.metrics_default", New: "DEFAULT PARTITIONED"},
+		{Kind: ChangeAdded, Object: ObjectColumn, Parent: "public.users", Name: "age", New: "int4 DEFAULT 18"},
+		{Kind: ChangeRemoved, Object: ObjectColumn, Parent: "public.users", Name: "legacy", Old: "int4"},
+		{Kind: ChangeChanged, Object: ObjectColumn, Parent: "public.users", Name: "name", Old: "varchar(50)", New: "varchar(100)"},
+		{Kind: ChangeAdded, Object: ObjectConstraint, Parent: "public.users", Name: "users_age_check", New: "CHECK ((age >= 18))"},
+		{
+			Kind: ChangeChanged, Object: ObjectIndex, Parent: "public.users", Name: "users_name_idx",
+			Old: "CREATE INDEX users_name_idx ON public.users USING btree (name)",
+			New: "CREATE INDEX users_name_idx ON public.users USING btree (lower((name)::text))",
+		},
+		{
+			Kind: ChangeRemoved, Object: ObjectTrigger, Parent: "public.users", Name: "users_audit",
+			Old: "AFTER INSERT OR UPDATE FOR EACH STATEMENT EXECUTE FUNCTION public.audit",
+		},
+		{
+			Kind: ChangeChanged, Object: ObjectTrigger, Parent: "public.users", Name: "users_check",
+			Old: "BEFORE INSERT FOR EACH ROW EXECUTE FUNCTION public.check_user AS BEGIN RETURN new; END",
+			New: "BEFORE INSERT FOR EACH ROW WHEN (new.id > 0) EXECUTE FUNCTION public.check_user AS BEGIN RETURN NULL; END",
+		},
+		{
+			Kind: ChangeAdded, Object: ObjectTrigger, Parent: "public.users", Name: "users_notify",
+			New: "AFTER DELETE FOR EACH ROW EXECUTE FUNCTION public.notify DISABLED",
+		},
+		{Kind: ChangeRemoved, Object: ObjectView, Name: "public.users_legacy", Old: "materialized view AS SELECT users.legacy FROM users;"},
+	}, d.Changes)
+
+	var text bytes.Buffer
+	r.NoError(d.WriteText(&text))
+	r.Contains(text.String(), "~ column public.users.name: varchar(50) -> varchar(100)\n")
+	r.Contains(text.String(), "~ partition key public.metrics: RANGE (id) -> RANGE (id, created)\n")
+
+	var buf bytes.Buffer
+	r.NoError(d.WriteJSON(&buf))
+	var decoded Diff
+	r.NoError(json.Unmarshal(buf.Bytes(), &decoded))
+	r.Equal(d, &decoded)
+
+	r.True(Compare(from, from).Empty())
+
+	// форматирование тела функции триггера не считается изменением
+	reformatted := oldCheck
+	reformatted.FunctionSource = "BEGIN RETURN new; END"
+	withTrigger := func(trigger Trigger) *Schema {
+		return &Schema{Tables: map[string]Table{"public.t": {
+			Name: Identifier{Schema: "public", Name: "t"}, Triggers: map[string]Trigger{trigger.Name: trigger},
+		}}}
+	}
+	r.True(Compare(withTrigger(oldCheck), withTrigger(reformatted)).Empty())
+}
@@ -26,12 +26,16 @@ func (s *Schema) Dump(w io.Writer, tplName TemplateName) error {
 	case DumpSchemaTemplate:
 		data = s
 	case DumpGrapthTemplate:
+		graph, err := s.NewAcyclicGraph()
+		if err != nil {
+			return xerrors.Errorf("build tables graph: %w", err)
+		}
 		data = struct {
 			Schema *Schema
 			Graph  *Graph
 		}{
 			Schema: s,
-			Graph:  s.NewGraph(),
+			Graph:  graph,
 		}
 	default:
 		return xerrors.Errorf("undefined template name: %s", tplName)
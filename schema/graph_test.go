@@ -152,3 +152,270 @@ func TestTopologicalSort(t *testing.T) {
 		})
 	}
 }
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   map[string][]string
+		want    [][]string
+		wantErr error
+	}{
+		{
+			name:  "no tables",
+			graph: nil,
+			want:  nil,
+		},
+		{
+			name: "independent",
+			graph: map[string][]string{
+				"2": nil,
+				"1": nil,
+			},
+			want: [][]string{{"1", "2"}},
+		},
+		{
+			name: "diamond",
+			graph: map[string][]string{
+				"1": {"2", "3"},
+				"2": {"4"},
+				"3": {"4"},
+				"4": nil,
+				"5": {"5", "3"}, // ссылка сама на себя не считается зависимостью
+			},
+			want: [][]string{{"1", "5"}, {"2", "3"}, {"4"}},
+		},
+		{
+			name: "longest path",
+			graph: map[string][]string{
+				"1": {"2", "3"},
+				"2": {"3"},
+				"3": nil,
+			},
+			want: [][]string{{"1"}, {"2"}, {"3"}},
+		},
+		{
+			name: "cycle",
+			graph: map[string][]string{
+				"1": {"2"},
+				"2": {"1"},
+				"3": nil,
+			},
+			wantErr: ErrCycle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Graph{Graph: tt.graph}
+			levels, err := g.Levels()
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, levels)
+		})
+	}
+}
+
+func cycleTestSchema(headNotNull, deferrable bool) *Schema {
+	int4 := &DBType{TypeName: Identifier{Schema: "pg_catalog", Name: "int4"}, Type: DataTypeBase}
+	notNull := ColumnAttributes{DomainAttributes: DomainAttributes{NotNullable: true}}
+	headAttr := ColumnAttributes{}
+	if headNotNull {
+		headAttr = notNull
+	}
+
+	deptFK := &Constraint{
+		Name: "users_department_id_fkey", Type: ConstraintTypeFK, Columns: []string{"department_id"},
+		Definition: "FOREIGN KEY (department_id) REFERENCES test.departments(id)",
+	}
+	headFK := &Constraint{
+		Name: "departments_head_id_fkey", Type: ConstraintTypeFK, Columns: []string{"head_id"},
+		Definition: "FOREIGN KEY (head_id) REFERENCES test.users(id)",
+	}
+	if deferrable {
+		headFK.Definition += " DEFERRABLE INITIALLY DEFERRED"
+	}
+	users := Table{
+		Name: Identifier{Schema: "test", Name: "users"},
+		Columns: map[string]Column{
+			"id":            {ColNum: 1, Name: "id", Type: int4, Attributes: notNull},
+			"department_id": {ColNum: 2, Name: "department_id", Type: int4, Attributes: notNull},
+		},
+		ForeignKeys: map[string]ForeignKey{
+			deptFK.Name: {Constraint: deptFK, ReferenceTable: "test.departments", ReferenceColumns: []string{"id"}},
+		},
+		ReferencedBy: map[string]*Constraint{"test.departments": headFK},
+	}
+	departments := Table{
+		Name: Identifier{Schema: "test", Name: "departments"},
+		Columns: map[string]Column{
+			"id":      {ColNum: 1, Name: "id", Type: int4, Attributes: notNull},
+			"head_id": {ColNum: 2, Name: "head_id", Type: int4, Attributes: headAttr},
+		},
+		ForeignKeys: map[string]ForeignKey{
+			headFK.Name: {Constraint: headFK, ReferenceTable: "test.users", ReferenceColumns: []string{"id"}},
+		},
+		ReferencedBy: map[string]*Constraint{"test.users": deptFK},
+	}
+	return &Schema{Tables: map[string]Table{users.String(): users, departments.String(): departments}}
+}
+
+func TestNewAcyclicGraph(t *testing.T) {
+	tests := []struct {
+		name           string
+		headNotNull    bool
+		deferrable     bool
+		wantDeferrable bool
+		wantErr        bool
+	}{
+		{name: "nullable"},
+		{name: "nullable and deferrable", deferrable: true},
+		{name: "deferrable", headNotNull: true, deferrable: true, wantDeferrable: true},
+		{name: "not breakable", headNotNull: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			s := cycleTestSchema(tt.headNotNull, tt.deferrable)
+			r.Equal([][]string{{"test.departments", "test.users"}}, s.NewGraph().StronglyConnectedComponents())
+
+			g, err := s.NewAcyclicGraph()
+			if tt.wantErr {
+				r.ErrorIs(err, ErrCycle)
+				r.ErrorContains(err, "test.departments, test.users")
+				return
+			}
+			r.NoError(err)
+			r.Len(g.Deferred, 1)
+			edge := g.Deferred[0]
+			r.Equal("test.departments -> test.users (departments_head_id_fkey)", edge.String())
+			r.Equal(tt.wantDeferrable || tt.deferrable, edge.Deferrable())
+
+			order, err := g.TopologicalSort()
+			r.NoError(err)
+			r.Equal([]string{"test.departments", "test.users"}, order)
+		})
+	}
+}
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	g := &Graph{Graph: map[string][]string{
+		"1": {"2"},
+		"2": {"3", "1"},
+		"3": {"3"},
+		"4": {"5"},
+		"5": {"6"},
+		"6": {"4", "1"},
+	}}
+	require.Equal(t, [][]string{{"1", "2"}, {"4", "5", "6"}}, g.StronglyConnectedComponents())
+}
+
+func TestClosure(t *testing.T) {
+	fk := func(ref string) ForeignKey {
+		return ForeignKey{Constraint: &Constraint{Type: ConstraintTypeFK}, ReferenceTable: ref}
+	}
+	table := func(name string, fks ...string) Table {
+		t := Table{
+			Name:         Identifier{Schema: "shop", Name: name},
+			ForeignKeys:  make(map[string]ForeignKey),
+			ReferencedBy: make(map[string]*Constraint),
+		}
+		for _, ref := range fks {
+			t.ForeignKeys[name+"_"+ref+"_fkey"] = fk("shop." + ref)
+		}
+		return t
+	}
+	s := &Schema{Tables: make(map[string]Table)}
+	for _, t := range []Table{
+		table("users"),
+		table("products"),
+		table("orders", "users"),
+		table("order_items", "orders", "products"),
+		table("reviews", "users", "products"),
+		table("audit"),
+	} {
+		s.Tables[t.String()] = t
+	}
+	for _, t := range s.Tables {
+		for _, fk := range t.ForeignKeys {
+			s.Tables[fk.ReferenceTable].ReferencedBy[t.String()] = fk.Constraint
+		}
+	}
+
+	tests := []struct {
+		name             string
+		tables           []string
+		withReferencedBy bool
+		want             []string
+	}{
+		{
+			name:   "dependencies",
+			tables: []string{"shop.order_items"},
+			want:   []string{"shop.order_items", "shop.orders", "shop.products", "shop.users"},
+		},
+		{
+			name:   "no dependencies",
+			tables: []string{"shop.audit", "shop.unknown"},
+			want:   []string{"shop.audit"},
+		},
+		{
+			name:             "referenced by",
+			tables:           []string{"shop.orders"},
+			withReferencedBy: true,
+			want:             []string{"shop.order_items", "shop.orders", "shop.products", "shop.users"},
+		},
+		{
+			name:             "referenced by transitively",
+			tables:           []string{"shop.users"},
+			withReferencedBy: true,
+			want: []string{
+				"shop.order_items", "shop.orders", "shop.products", "shop.reviews", "shop.users",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, s.Closure(tt.tables, tt.withReferencedBy))
+		})
+	}
+}
+
+func TestViewOrder(t *testing.T) {
+	r := require.New(t)
+	view := func(name string, deps ...string) View {
+		v := View{Name: Identifier{Schema: "shop", Name: name}, DependsOn: make(map[string][]string)}
+		for _, dep := range deps {
+			v.DependsOn["shop."+dep] = nil
+		}
+		return v
+	}
+	s := &Schema{
+		Tables: map[string]Table{
+			"shop.users":  {Name: Identifier{Schema: "shop", Name: "users"}},
+			"shop.orders": {Name: Identifier{Schema: "shop", Name: "orders"}},
+		},
+		Views: make(map[string]View),
+	}
+	for _, v := range []View{
+		view("a_report", "user_orders"),
+		view("user_orders", "users", "orders"),
+		view("b_users", "users"),
+	} {
+		s.Views[v.String()] = v
+	}
+
+	var names []string
+	for _, v := range s.ViewOrder() {
+		names = append(names, v.String())
+	}
+	r.Equal([]string{"shop.user_orders", "shop.a_report", "shop.b_users"}, names)
+
+	g := s.NewGraph()
+	r.Equal([]string{"shop.orders", "shop.users"}, g.Views["shop.user_orders"])
+	// представления не участвуют в порядке заполнения таблиц
+	order, err := g.TopologicalSort()
+	r.NoError(err)
+	r.Equal([]string{"shop.orders", "shop.users"}, order)
+}
@@ -3,6 +3,11 @@ package schema
 import (
 	"errors"
 	"sort"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
 )
 
 var ErrCycle = errors.New("graph contains a cycle")
@@ -10,6 +15,40 @@ var ErrCycle = errors.New("graph contains a cycle")
 type Graph struct {
 	// map[текущая_таблица][таблицы_для_которых_текущая_это_внешняя]внешняя_таблица
 	Graph map[string][]string
+	// Связи, удаленные из графа для разрыва циклов
+	Deferred []DeferredEdge
+	// map[представление]отношения_от_которых_оно_зависит
+	// Представления не участвуют в порядке заполнения таблиц.
+	Views map[string][]string
+}
+
+// DeferredEdge описывает связь, удаленную из графа для разрыва цикла внешних ключей.
+// Записи таблицы Table вставляются раньше записей ReferenceTable, поэтому внешние ключи
+// либо вставляются с NULL и обновляются после вставки ReferenceTable,
+// либо их проверка откладывается до конца транзакции.
+type DeferredEdge struct {
+	Table          string
+	ReferenceTable string
+	// Внешние ключи таблицы Table на таблицу ReferenceTable
+	ForeignKeys []ForeignKey
+}
+
+func (e DeferredEdge) String() string {
+	names := make([]string, 0, len(e.ForeignKeys))
+	for _, fk := range e.ForeignKeys {
+		names = append(names, fk.String())
+	}
+	return e.Table + " -> " + e.ReferenceTable + " (" + strings.Join(names, ", ") + ")"
+}
+
+// Deferrable проверяет, что проверку всех внешних ключей связи можно отложить до конца транзакции.
+func (e DeferredEdge) Deferrable() bool {
+	for _, fk := range e.ForeignKeys {
+		if !fk.Constraint.IsDeferrable() {
+			return false
+		}
+	}
+	return true
 }
 
 func (s *Schema) NewGraph() *Graph {
@@ -21,8 +60,15 @@ func (s *Schema) NewGraph() *Graph {
 		}
 		graph[table.String()] = refs
 	}
+	views := make(map[string][]string, len(s.Views))
+	for _, view := range s.Views {
+		deps := maps.Keys(view.DependsOn)
+		sort.Strings(deps)
+		views[view.String()] = deps
+	}
 	return &Graph{
 		Graph: graph,
+		Views: views,
 	}
 }
 
@@ -94,3 +140,269 @@ func (g *Graph) TopologicalSort() ([]string, error) {
 	}
 	return result, nil
 }
+
+// Levels разбивает таблицы на уровни. Таблицы одного уровня не зависят друг от друга,
+// а только от таблиц предыдущих уровней, поэтому их можно заполнять одновременно.
+// Таблицы внутри уровня отсортированы.
+func (g *Graph) Levels() ([][]string, error) {
+	inDegrees := g.GetDepth()
+
+	var level []string
+	for node := range g.Graph {
+		if inDegrees[node] == 0 {
+			level = append(level, node)
+		}
+	}
+
+	var (
+		levels [][]string
+		total  int
+	)
+	for len(level) > 0 {
+		sort.Strings(level)
+		levels = append(levels, level)
+		total += len(level)
+
+		var next []string
+		for _, node := range level {
+			for _, neighbor := range g.Graph[node] {
+				if neighbor == node {
+					continue
+				}
+				inDegrees[neighbor]--
+				if inDegrees[neighbor] == 0 {
+					next = append(next, neighbor)
+				}
+			}
+		}
+		level = next
+	}
+
+	if total != len(g.Graph) {
+		return nil, ErrCycle
+	}
+	return levels, nil
+}
+
+// NewAcyclicGraph возвращает граф зависимостей таблиц без циклов.
+// В каждой компоненте сильной связности удаляются связи, пока циклы не исчезнут.
+// Удаляется только связь, все внешние ключи которой допускают NULL хотя бы в одной колонке
+// или могут быть отложены (DEFERRABLE). Ключи, допускающие NULL, предпочтительнее.
+// Если такой связи в цикле нет, то возвращается ErrCycle.
+func (s *Schema) NewAcyclicGraph() (*Graph, error) {
+	g := s.NewGraph()
+	for {
+		components := g.StronglyConnectedComponents()
+		if len(components) == 0 {
+			return g, nil
+		}
+		for _, component := range components {
+			edge, ok := s.chooseDeferredEdge(g, component)
+			if !ok {
+				return nil, xerrors.Errorf(
+					"%w: tables %s, no foreign key is nullable or deferrable",
+					ErrCycle, strings.Join(component, ", "))
+			}
+			refs := g.Graph[edge.ReferenceTable]
+			if idx := slices.Index(refs, edge.Table); idx >= 0 {
+				g.Graph[edge.ReferenceTable] = slices.Delete(slices.Clone(refs), idx, idx+1)
+			}
+			g.Deferred = append(g.Deferred, edge)
+		}
+	}
+}
+
+// chooseDeferredEdge выбирает связь внутри компоненты сильной связности, которую можно удалить.
+func (s *Schema) chooseDeferredEdge(g *Graph, component []string) (DeferredEdge, bool) {
+	var (
+		best      DeferredEdge
+		bestScore = -1
+	)
+	// компонента и связи отсортированы, поэтому выбор детерминирован
+	for _, parent := range component {
+		refs := slices.Clone(g.Graph[parent])
+		sort.Strings(refs)
+		for _, child := range refs {
+			if child == parent || !slices.Contains(component, child) {
+				continue
+			}
+			edge := DeferredEdge{Table: child, ReferenceTable: parent}
+			fkNames := maps.Keys(s.Tables[child].ForeignKeys)
+			sort.Strings(fkNames)
+			for _, fkName := range fkNames {
+				if fk := s.Tables[child].ForeignKeys[fkName]; fk.ReferenceTable == parent {
+					edge.ForeignKeys = append(edge.ForeignKeys, fk)
+				}
+			}
+			score := s.deferredEdgeScore(edge)
+			if score > bestScore {
+				best, bestScore = edge, score
+			}
+		}
+	}
+	return best, bestScore > 0
+}
+
+// deferredEdgeScore оценивает, насколько удобно разорвать цикл на связи.
+// 0 - связь удалить нельзя, 2 - все ключи допускают NULL, 1 - все ключи могут быть отложены.
+func (s *Schema) deferredEdgeScore(edge DeferredEdge) int {
+	if len(edge.ForeignKeys) == 0 {
+		return 0
+	}
+	table := s.Tables[edge.Table]
+	nullable := true
+	for _, fk := range edge.ForeignKeys {
+		if !slices.ContainsFunc(fk.Constraint.Columns, func(col string) bool {
+			c, ok := table.Columns[col]
+			return ok && !c.IsNotNull()
+		}) {
+			nullable = false
+			break
+		}
+	}
+	switch {
+	case nullable:
+		return 2
+	case edge.Deferrable():
+		return 1
+	}
+	return 0
+}
+
+// StronglyConnectedComponents возвращает компоненты сильной связности графа, содержащие цикл.
+// Ссылка таблицы на себя циклом не считается.
+// Таблицы в компонентах и сами компоненты отсортированы.
+func (g *Graph) StronglyConnectedComponents() [][]string {
+	nodes := maps.Keys(g.Graph)
+	sort.Strings(nodes)
+
+	// алгоритм Тарьяна
+	var (
+		index      = make(map[string]int, len(nodes))
+		lowLink    = make(map[string]int, len(nodes))
+		onStack    = make(map[string]bool, len(nodes))
+		stack      []string
+		components [][]string
+		connect    func(node string)
+	)
+	connect = func(node string) {
+		index[node] = len(index)
+		lowLink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+
+		refs := slices.Clone(g.Graph[node])
+		sort.Strings(refs)
+		for _, ref := range refs {
+			if _, ok := index[ref]; !ok {
+				connect(ref)
+				if lowLink[ref] < lowLink[node] {
+					lowLink[node] = lowLink[ref]
+				}
+			} else if onStack[ref] && index[ref] < lowLink[node] {
+				lowLink[node] = index[ref]
+			}
+		}
+
+		if lowLink[node] != index[node] {
+			return
+		}
+		var component []string
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == node {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+	for _, node := range nodes {
+		if _, ok := index[node]; !ok {
+			connect(node)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	return components
+}
+
+// ViewOrder возвращает представления в порядке создания: представление идет после всех
+// представлений, от которых оно зависит. Независимые представления отсортированы по имени.
+func (s *Schema) ViewOrder() []View {
+	names := maps.Keys(s.Views)
+	sort.Strings(names)
+
+	order := make([]View, 0, len(names))
+	visited := make(map[string]bool, len(names))
+	var visit func(name string)
+	visit = func(name string) {
+		view, ok := s.Views[name]
+		if !ok || visited[name] {
+			return
+		}
+		// представления не могут зависеть друг от друга циклически
+		visited[name] = true
+		deps := maps.Keys(view.DependsOn)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			visit(dep)
+		}
+		order = append(order, view)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return order
+}
+
+// Closure возвращает наименьшее множество таблиц, содержащее указанные таблицы и все таблицы,
+// на которые они ссылаются внешними ключами, в том числе транзитивно. Таких таблиц достаточно,
+// чтобы заполнить указанные таблицы корректными данными.
+// Если withReferencedBy, то в множество добавляются и все таблицы, ссылающиеся на указанные,
+// вместе с таблицами, на которые ссылаются они сами.
+// Результат отсортирован, неизвестные таблицы пропускаются.
+func (s *Schema) Closure(tables []string, withReferencedBy bool) []string {
+	closure := make(map[string]struct{}, len(tables))
+	var queue []string
+	add := func(name string) {
+		if _, ok := s.Tables[name]; !ok {
+			return
+		}
+		if _, ok := closure[name]; ok {
+			return
+		}
+		closure[name] = struct{}{}
+		queue = append(queue, name)
+	}
+
+	for _, name := range tables {
+		add(name)
+	}
+	if withReferencedBy {
+		// сначала собираются все дочерние таблицы, т.к. их родители тоже должны попасть в множество
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			for child := range s.Tables[name].ReferencedBy {
+				add(child)
+			}
+		}
+		queue = maps.Keys(closure)
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, fk := range s.Tables[name].ForeignKeys {
+			add(fk.ReferenceTable)
+		}
+	}
+
+	res := maps.Keys(closure)
+	sort.Strings(res)
+	return res
+}
@@ -28,6 +28,13 @@ func (t *DBType) ToLua(l *lua.LState) *lua.LTable {
 	if t.DomainAttributes != nil {
 		typ.RawSetString("attrs", t.DomainAttributes.ToLua(l))
 	}
+	if t.Type == DataTypeComposite {
+		attributes := l.NewTable()
+		for idx := range t.Attributes {
+			attributes.Append(t.Attributes[idx].ToLua(l))
+		}
+		typ.RawSetString("attributes", attributes)
+	}
 
 	return typ
 }
@@ -64,6 +71,12 @@ func (t *Table) ToLua(l *lua.LState) *lua.LTable {
 		constraints.RawSetString(constraint.String(), constraint.ToLua(l))
 	}
 
+	triggers := l.NewTable()
+	table.RawSetString("triggers", triggers)
+	for _, trigger := range t.Triggers {
+		triggers.RawSetString(trigger.String(), trigger.ToLua(l))
+	}
+
 	return table
 }
 
@@ -78,6 +91,25 @@ func (c *Constraint) ToLua(l *lua.LState) *lua.LTable {
 	return lc
 }
 
+func (t *Trigger) ToLua(l *lua.LState) *lua.LTable {
+	lt := l.NewTable()
+	lt.RawSetString("timing", lua.LString(t.Timing))
+	events := l.NewTable()
+	for _, event := range t.Events {
+		events.Append(lua.LString(event))
+	}
+	lt.RawSetString("events", events)
+	lt.RawSetString("for_each_row", lua.LBool(t.ForEachRow))
+	if t.Condition != "" {
+		lt.RawSetString("condition", lua.LString(t.Condition))
+	}
+	lt.RawSetString("function", lua.LString(t.Function.String()))
+	lt.RawSetString("function_source", lua.LString(t.FunctionSource))
+	lt.RawSetString("definition", lua.LString(t.Definition))
+	lt.RawSetString("disabled", lua.LBool(t.Disabled))
+	return lt
+}
+
 func (i *Index) ToLua(l *lua.LState) *lua.LTable {
 	li := l.NewTable()
 	li.RawSetString("definition", lua.LString(i.Definition))
@@ -85,6 +117,7 @@ func (i *Index) ToLua(l *lua.LState) *lua.LTable {
 	li.RawSetString("is_primary", lua.LBool(i.IsPrimary))
 	li.RawSetString("is_nulls_not_distinct", lua.LBool(i.IsNullsNotDistinct))
 	li.RawSetString("columns", luaList(l, i.Columns))
+	li.RawSetString("has_expressions", lua.LBool(i.HasExpressions))
 	return li
 }
 
@@ -121,6 +154,9 @@ func (a *ColumnAttributes) ToLua(l *lua.LState) *lua.LTable {
 			la.RawSetString("default", lua.LString(a.Default))
 		}
 	}
+	if a.Identity != "" {
+		la.RawSetString("identity", lua.LString(a.Identity))
+	}
 	a.DomainAttributes.ToLuaTable(la)
 	return la
 }
@@ -1,5 +1,10 @@
 package schema
 
+import (
+	"sort"
+	"strings"
+)
+
 // Identifier описывает имя элемента.
 type Identifier struct {
 	// Row identifier
@@ -21,6 +26,7 @@ func (i Identifier) String() string {
 type Schema struct {
 	Types  map[string]*DBType `json:"types"`
 	Tables map[string]Table   `json:"tables"`
+	Views  map[string]View    `json:"views,omitempty"`
 }
 
 // Table описывает таблицу базы данных.
@@ -41,11 +47,164 @@ type Table struct {
 	Constraints map[string]*Constraint `json:"constraints,omitempty"`
 	// Список всех INDEX-ов текущей таблицы
 	Indexes map[string]Index `json:"indexes,omitempty"`
+
+	// Партиционирование таблицы (nil, если таблица не партиционирована)
+	Partitioning *Partitioning `json:"partitioning,omitempty"`
+	// Триггеры таблицы, где ключ - имя триггера
+	Triggers map[string]Trigger `json:"triggers,omitempty"`
 }
 
 func (t Table) String() string { return t.Name.String() }
 func (t Table) GetOID() int    { return t.Name.OID }
 
+// PartitionStrategy описывает способ партиционирования таблицы.
+type PartitionStrategy string
+
+const (
+	PartitionStrategyRange PartitionStrategy = "range"
+	PartitionStrategyList  PartitionStrategy = "list"
+	PartitionStrategyHash  PartitionStrategy = "hash"
+)
+
+// Partitioning описывает ключ партиционирования и партиции таблицы.
+// Записи вставляются в саму таблицу, а PostgreSQL распределяет их по партициям.
+type Partitioning struct {
+	Strategy PartitionStrategy `json:"strategy"`
+	// Колонки ключа партиционирования. Для выражений в ключе указывается пустая строка.
+	Columns []string `json:"columns"`
+	// Результат функции pg_get_partkeydef
+	Definition string `json:"definition"`
+	// Партиции в порядке их имен
+	Partitions []Partition `json:"partitions,omitempty"`
+}
+
+// HasDefault проверяет, что у таблицы есть DEFAULT партиция.
+func (p *Partitioning) HasDefault() bool {
+	for _, partition := range p.Partitions {
+		if partition.IsDefault {
+			return true
+		}
+	}
+	return false
+}
+
+// Partition описывает партицию таблицы.
+type Partition struct {
+	// имя партиции
+	Name Identifier `json:"name"`
+	// Границы партиции, например FOR VALUES FROM (1) TO (10) или DEFAULT
+	Bound string `json:"bound"`
+	// DEFAULT партиция, в которую попадают значения, не подходящие остальным партициям
+	IsDefault bool `json:"is_default,omitempty"`
+	// Партиция сама партиционирована
+	IsPartitioned bool `json:"is_partitioned,omitempty"`
+}
+
+func (p Partition) String() string { return p.Name.String() }
+
+// TriggerTiming описывает момент срабатывания триггера.
+type TriggerTiming string
+
+const (
+	TriggerTimingBefore    TriggerTiming = "BEFORE"
+	TriggerTimingAfter     TriggerTiming = "AFTER"
+	TriggerTimingInsteadOf TriggerTiming = "INSTEAD OF"
+)
+
+// TriggerEvent описывает событие, на которое срабатывает триггер.
+type TriggerEvent string
+
+const (
+	TriggerEventInsert   TriggerEvent = "INSERT"
+	TriggerEventUpdate   TriggerEvent = "UPDATE"
+	TriggerEventDelete   TriggerEvent = "DELETE"
+	TriggerEventTruncate TriggerEvent = "TRUNCATE"
+)
+
+// Trigger описывает триггер таблицы.
+type Trigger struct {
+	// имя триггера
+	Name   string         `json:"name"`
+	Timing TriggerTiming  `json:"timing"`
+	Events []TriggerEvent `json:"events"`
+	// Триггер вызывается для каждой строки (FOR EACH ROW), а не один раз для запроса
+	ForEachRow bool `json:"for_each_row,omitempty"`
+	// Условие WHEN, пустое, если условия нет
+	Condition string `json:"condition,omitempty"`
+	// Функция, которую вызывает триггер
+	Function Identifier `json:"function"`
+	// Определение функции: результат pg_get_functiondef или CREATE FUNCTION из SQL скрипта
+	FunctionSource string `json:"function_source,omitempty"`
+	// Результат функции pg_get_triggerdef или CREATE TRIGGER из SQL скрипта
+	Definition string `json:"definition"`
+	// Триггер выключен с помощью ALTER TABLE ... DISABLE TRIGGER
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+func (t Trigger) String() string { return t.Name }
+
+// FiresOn проверяет, что включенный триггер срабатывает на событие.
+func (t Trigger) FiresOn(event TriggerEvent) bool {
+	if t.Disabled {
+		return false
+	}
+	for _, e := range t.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// TriggerFunctions возвращает определения функций триггеров всех таблиц без повторов в порядке имен функций.
+func (s *Schema) TriggerFunctions() []string {
+	sources := make(map[string]string)
+	for _, table := range s.Tables {
+		for _, trigger := range table.Triggers {
+			if trigger.FunctionSource != "" {
+				sources[trigger.Function.String()] = trigger.FunctionSource
+			}
+		}
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		res = append(res, sources[name])
+	}
+	return res
+}
+
+// View описывает представление или материализованное представление.
+type View struct {
+	// имя представления
+	Name Identifier `json:"name"`
+	// Материализованное представление
+	Materialized bool `json:"materialized,omitempty"`
+	// Результат функции pg_get_viewdef
+	Definition string `json:"definition"`
+	// мапа колонок, где ключ - имя колонки
+	Columns map[string]Column `json:"columns,omitempty"`
+	// Таблицы и представления, от которых зависит представление, и используемые колонки.
+	// Список колонок пуст, если представление зависит от отношения целиком.
+	DependsOn map[string][]string `json:"depends_on,omitempty"`
+}
+
+func (v View) String() string { return v.Name.String() }
+func (v View) GetOID() int    { return v.Name.OID }
+
+// Kind возвращает вид представления для SQL: VIEW или MATERIALIZED VIEW.
+func (v View) Kind() string {
+	if v.Materialized {
+		return "MATERIALIZED VIEW"
+	}
+	return "VIEW"
+}
+
 // ForeignKey описывает внешнюю связь
 // В PostgreSQL FK может ссылаться на PRIMARY KEY CONSTRAINT, UNIQUE CONSTRAINT, UNIQUE INDEX.
 type ForeignKey struct {
@@ -74,6 +233,19 @@ type Column struct {
 
 func (c *Column) String() string { return c.Name }
 
+// IsNotNull проверяет, что колонка не допускает NULL, в том числе из-за домена.
+func (c Column) IsNotNull() bool {
+	if c.Attributes.NotNullable {
+		return true
+	}
+	for typ := c.Type; typ != nil && typ.Type == DataTypeDomain; typ = typ.ElemType {
+		if typ.DomainAttributes != nil && typ.DomainAttributes.NotNullable {
+			return true
+		}
+	}
+	return false
+}
+
 //go:generate enumer -type DataType -trimprefix DataType -json
 type DataType int
 
@@ -95,6 +267,8 @@ type DBType struct {
 	ElemType         *DBType           `json:"elem_type,omitempty"`
 	EnumValues       []string          `json:"enum_values,omitempty"`
 	DomainAttributes *DomainAttributes `json:"domain_attributes,omitempty"`
+	// Поля составного типа в порядке объявления
+	Attributes []Column `json:"attributes,omitempty"`
 }
 
 func (t *DBType) String() string    { return t.TypeName.String() }
@@ -122,8 +296,40 @@ type ColumnAttributes struct {
 	IsGenerated bool `json:"is_generated,omitempty"`
 	// Дефолтное значение
 	Default string `json:"default,omitempty"`
+	// Способ генерации значений IDENTITY колонки, пустой для обычных колонок
+	Identity IdentityKind `json:"identity,omitempty"`
+	// Последовательность, из которой берутся значения колонки: IDENTITY, SERIAL или DEFAULT nextval(...)
+	Sequence *Sequence `json:"sequence,omitempty"`
+}
+
+// IsGeneratedAlways проверяет, что значение колонки всегда вычисляет база данных:
+// это GENERATED ALWAYS AS (...) STORED и GENERATED ALWAYS AS IDENTITY колонки.
+func (a ColumnAttributes) IsGeneratedAlways() bool {
+	return a.IsGenerated || a.Identity == IdentityAlways
+}
+
+// IdentityKind описывает IDENTITY колонку.
+type IdentityKind string
+
+const (
+	// GENERATED ALWAYS AS IDENTITY, значение можно указать только с OVERRIDING SYSTEM VALUE
+	IdentityAlways IdentityKind = "always"
+	// GENERATED BY DEFAULT AS IDENTITY, значение из последовательности используется, если оно не указано
+	IdentityByDefault IdentityKind = "by default"
+)
+
+// Sequence описывает последовательность.
+type Sequence struct {
+	Name      Identifier `json:"name"`
+	Start     int64      `json:"start"`
+	Increment int64      `json:"increment"`
+	MinValue  int64      `json:"min_value"`
+	MaxValue  int64      `json:"max_value"`
+	Cycle     bool       `json:"cycle,omitempty"`
 }
 
+func (s *Sequence) String() string { return s.Name.String() }
+
 //go:generate enumer -type ConstraintType -trimprefix ConstraintType -json
 type ConstraintType int
 
@@ -159,12 +365,20 @@ type Constraint struct {
 func (c Constraint) String() string { return c.Name }
 func (c Constraint) GetOID() int    { return c.OID }
 
+// IsDeferrable проверяет, что проверку ограничения можно отложить до конца транзакции.
+func (c Constraint) IsDeferrable() bool {
+	def := strings.ToUpper(c.Definition)
+	return strings.Contains(def, " DEFERRABLE") && !strings.Contains(def, " NOT DEFERRABLE")
+}
+
 type Index struct {
 	OID int `json:"oid"`
 	// Имя индекса
 	Name string `json:"name"`
 	// Колонки, которые затрагивает индекс
 	Columns []string `json:"columns"`
+	// Ключ индекса содержит выражения, которых нет в Columns
+	HasExpressions bool `json:"has_expressions,omitempty"`
 	// Определение индекса
 	Definition string `json:"definition"`
 
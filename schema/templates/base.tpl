@@ -20,6 +20,9 @@
     {{- else}} DEFAULT {{.Default}}
     {{- end}}
   {{- end}}
+  {{- if .Identity}} GENERATED {{.Identity | toString | upper}} AS IDENTITY
+    {{- with .Sequence}} (START WITH {{.Start}} INCREMENT BY {{.Increment}} MINVALUE {{.MinValue}} MAXVALUE {{.MaxValue}}{{if .Cycle}} CYCLE{{end}}){{end}}
+  {{- end}}
 {{- end}}
 
 {{- define "coltype"}}
@@ -32,15 +32,37 @@ class {{$table.Name}} {
 }
 {{/* range tables */}}
 {{- end}}
+{{- /* range views */}}
+{{- range $view := $.Schema.ViewOrder}}
+class {{$view.Name}} <<{{$view.Kind | lower}}>> {
+  {{- /* range columns */}}
+  {{- range $view.Columns}}
+  {{.Name}}: {{template "smalltype" .}}
+  {{- /* range columns */}}
+  {{- end}}
+}
+{{/* range views */}}
+{{- end}}
 
 {{- $degrees := ($.Graph.GetDepth)}}
 {{- range $rel_from := ($.Graph.TopologicalSort)}}
 {{- $relations := index $.Graph.Graph .}}
-{{- range $rel_to, $table := $relations}}
+{{- range $rel_to := $relations}}
 {{index $.Schema.Tables $rel_from}}
 {{- " "}}{{- repeat (index $degrees $rel_to) "-" -}}{{"{ "}}
 {{- index $.Schema.Tables $rel_to}}
 {{- end}}
 {{- end}}
+{{- /* связи, удаленные для разрыва циклов */}}
+{{- range $.Graph.Deferred}}
+{{index $.Schema.Tables .ReferenceTable}} ..{ {{index $.Schema.Tables .Table}} : deferred
+{{- range .ForeignKeys}} {{.}}{{end}}
+{{- end}}
+{{- /* зависимости представлений */}}
+{{- range $view, $deps := $.Graph.Views}}
+{{- range $deps}}
+{{$view}} ..> {{.}} : depends
+{{- end}}
+{{- end}}
 
 @enduml
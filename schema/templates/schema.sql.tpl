@@ -27,12 +27,36 @@ CREATE TABLE {{$table.Name}} (
     {{- end}}
 {{- /* range constraints */}}
 {{- end}}
-);
+){{with $table.Partitioning}} PARTITION BY {{.Definition}}{{end}};
 {{- /* range indexes */}}
 {{- range $table.Indexes }}
 {{.Definition}};
 {{- /* range indexes */}}
 {{- end}}
+{{- /* range partitions */}}
+{{- with $table.Partitioning}}{{range .Partitions}}
+CREATE TABLE {{.Name}} PARTITION OF {{$table.Name}} {{.Bound}};
+{{- /* range partitions */}}
+{{- end}}{{end}}
 {{/* range tables */}}{{end}}
 
+{{- /* range trigger functions */}}
+{{- range $source := $.TriggerFunctions }}
+{{$source | trim | trimSuffix ";"}};
+{{/* range trigger functions */}}{{end}}
+
+{{- /* range triggers */}}
+{{- range $table := $.Tables }}{{range $table.Triggers }}
+{{.Definition}};
+{{- if .Disabled}}
+ALTER TABLE {{$table.Name}} DISABLE TRIGGER {{.Name}};
+{{- end}}
+{{/* range triggers */}}{{end}}{{end}}
+
+{{- /* range views */}}
+{{- range $view := $.ViewOrder }}
+CREATE {{$view.Kind}} {{$view.Name}} AS
+{{$view.Definition | trim | trimSuffix ";"}};
+{{/* range views */}}{{end}}
+
 {{- template "types.tpl" .Types}}
\ No newline at end of file
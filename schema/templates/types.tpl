@@ -16,7 +16,12 @@ CREATE TYPE {{.TypeName}} {{.Type.String | upper}}
     {{$value | quote}}{{if eq $vallen (add $idx 1)}}{{else}},{{end}}
     {{- end }}
 )
-{{- else if eq . "Composite"}} ({{/*TODO*/}})
+{{- else if eq . "Composite"}} (
+    {{- $attrlen := len $type.Attributes}}
+    {{- range $idx, $attr := $type.Attributes }}
+    {{$attr.Name}} {{template "coltype" $attr}}{{if eq $attrlen (add $idx 1)}}{{else}},{{end}}
+    {{- end }}
+)
 {{- else if eq . "Domain" -}}
     {{- with $type.ElemType}}{{" " -}}
         {{.TypeName}}
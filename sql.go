@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/insert"
+	"github.com/Feresey/mtest/schema"
+)
+
+// SQLInsertConverter сохраняет записи в виде SQL скрипта из INSERT запросов.
+type SQLInsertConverter struct{}
+
+// WriteRecords записывает записи таблиц в порядке order. Весь скрипт выполняется в одной транзакции.
+func (w *SQLInsertConverter) WriteRecords(
+	out io.Writer,
+	order []schema.Table,
+	records map[string]generate.Records,
+) error {
+	bw := bufio.NewWriter(out)
+
+	fmt.Fprintln(bw, "BEGIN;")
+	writeDeferConstraints(bw, order)
+	for _, table := range order {
+		tableRecords, ok := records[table.String()]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(bw, "\n-- %s\n", table)
+		for _, record := range tableRecords.Records {
+			fmt.Fprintf(bw, "%s;\n", insert.Query(table, record))
+		}
+	}
+	writeDeferredUpdates(bw, order, records)
+	writeSequenceUpdates(bw, order)
+	fmt.Fprintln(bw, "\nCOMMIT;")
+
+	return bw.Flush()
+}
+
+// SQLCopyConverter сохраняет записи в виде SQL скрипта из блоков COPY ... FROM stdin.
+type SQLCopyConverter struct{}
+
+// WriteRecords записывает записи таблиц в порядке order. Весь скрипт выполняется в одной транзакции.
+func (w *SQLCopyConverter) WriteRecords(
+	out io.Writer,
+	order []schema.Table,
+	records map[string]generate.Records,
+) error {
+	bw := bufio.NewWriter(out)
+
+	fmt.Fprintln(bw, "BEGIN;")
+	writeDeferConstraints(bw, order)
+	for _, table := range order {
+		tableRecords, ok := records[table.String()]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(bw, "\n-- %s\n", table)
+		for _, group := range insert.GroupByColumns(tableRecords.Records) {
+			// COPY без колонок недопустим, такие записи вставляются со значениями по умолчанию
+			if len(group[0].Columns) == 0 {
+				for _, record := range group {
+					fmt.Fprintf(bw, "%s;\n", insert.Query(table, record))
+				}
+				continue
+			}
+			fmt.Fprintf(bw, "%s;\n", insert.CopyQuery(table, group[0].Columns))
+			if err := insert.WriteCopyData(bw, group); err != nil {
+				return xerrors.Errorf("write records of table %q: %w", table, err)
+			}
+			fmt.Fprintln(bw, `\.`)
+		}
+	}
+	writeDeferredUpdates(bw, order, records)
+	writeSequenceUpdates(bw, order)
+	fmt.Fprintln(bw, "\nCOMMIT;")
+
+	return bw.Flush()
+}
+
+// writeDeferConstraints откладывает проверку внешних ключей, разрывающих циклы между таблицами.
+func writeDeferConstraints(w io.Writer, order []schema.Table) {
+	if deferred := insert.DeferredConstraints(order); len(deferred) != 0 {
+		fmt.Fprintf(w, "%s;\n", insert.SetConstraintsQuery(deferred, "DEFERRED"))
+	}
+}
+
+// writeDeferredUpdates записывает обновления внешних ключей, которые вставлены как NULL для разрыва циклов.
+func writeDeferredUpdates(w io.Writer, order []schema.Table, records map[string]generate.Records) {
+	for _, table := range order {
+		header := false
+		for _, record := range records[table.String()].Records {
+			if record.Deferred == nil {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(w, "\n-- %s deferred foreign keys\n", table)
+				header = true
+			}
+			fmt.Fprintf(w, "%s;\n", insert.UpdateQuery(table, record))
+		}
+	}
+}
+
+// writeSequenceUpdates записывает запросы, сдвигающие последовательности колонок за вставленные значения.
+func writeSequenceUpdates(w io.Writer, order []schema.Table) {
+	queries := insert.SequenceQueries(order)
+	if len(queries) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\n-- sequences")
+	for _, query := range queries {
+		fmt.Fprintf(w, "%s;\n", query)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/schema"
+)
+
+func TestSQLInsertConverter(t *testing.T) {
+	r := require.New(t)
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	text := &schema.DBType{
+		TypeName: schema.Identifier{OID: 25, Schema: "pg_catalog", Name: "text"},
+		Type:     schema.DataTypeBase,
+	}
+	users := schema.Table{
+		Name: schema.Identifier{OID: 1, Schema: "test", Name: "users"},
+		Columns: map[string]schema.Column{
+			"id":        {ColNum: 1, Name: "id", Type: int4},
+			"Full Name": {ColNum: 2, Name: "Full Name", Type: text},
+		},
+	}
+	userFK := &schema.Constraint{Name: "orders_user_fkey", Type: schema.ConstraintTypeFK, Columns: []string{"user"}}
+	orders := schema.Table{
+		Name: schema.Identifier{OID: 2, Schema: "Test", Name: "order\"s"},
+		Columns: map[string]schema.Column{
+			"id":   {ColNum: 1, Name: "id", Type: int4},
+			"user": {ColNum: 2, Name: "user", Type: int4},
+		},
+		ForeignKeys: map[string]schema.ForeignKey{
+			userFK.Name: {Constraint: userFK, ReferenceTable: users.String(), ReferenceColumns: []string{"id"}},
+		},
+	}
+	empty := schema.Table{Name: schema.Identifier{OID: 3, Schema: "test", Name: "empty"}}
+
+	// записи дочерней таблицы идут в map раньше, но выводятся в порядке order
+	records := map[string]generate.Records{
+		orders.String(): {Records: []generate.Record{
+			{Columns: []string{"id", "user"}, Values: []string{"10", "1"}},
+			{Columns: []string{"id", "user"}, Values: []string{"11", "NULL"}},
+			{},
+		}},
+		users.String(): {Records: []generate.Record{
+			{Columns: []string{"Full Name", "id"}, Values: []string{"'O''Brien'", "1"}},
+			{Columns: []string{"Full Name", "id"}, Values: []string{"NULL", "2"}},
+		}},
+	}
+
+	var buf bytes.Buffer
+	var conv SQLInsertConverter
+	r.NoError(conv.WriteRecords(&buf, []schema.Table{users, empty, orders}, records))
+
+	golden, err := os.ReadFile("testdata/records.sql")
+	r.NoError(err)
+	r.Equal(string(golden), buf.String())
+}
+
+func TestSQLCopyConverterEmptyColumns(t *testing.T) {
+	r := require.New(t)
+	int4 := &schema.DBType{
+		TypeName: schema.Identifier{OID: 23, Schema: "pg_catalog", Name: "int4"},
+		Type:     schema.DataTypeBase,
+	}
+	table := schema.Table{
+		Name:    schema.Identifier{OID: 1, Schema: "test", Name: "items"},
+		Columns: map[string]schema.Column{"id": {ColNum: 1, Name: "id", Type: int4}},
+	}
+	// записи без колонок, например когда все колонки генерируются базой данных
+	records := map[string]generate.Records{
+		table.String(): {Records: []generate.Record{
+			{}, {},
+			{Columns: []string{"id"}, Values: []string{"1"}},
+		}},
+	}
+
+	var buf bytes.Buffer
+	var conv SQLCopyConverter
+	r.NoError(conv.WriteRecords(&buf, []schema.Table{table}, records))
+	r.Equal("BEGIN;\n\n-- test.items\n"+
+		"INSERT INTO \"test\".\"items\" DEFAULT VALUES;\n"+
+		"INSERT INTO \"test\".\"items\" DEFAULT VALUES;\n"+
+		"COPY \"test\".\"items\" (\"id\") FROM STDIN;\n1\n\\.\n"+
+		"\nCOMMIT;\n", buf.String())
+}
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- test.users
+INSERT INTO "test"."users" ("Full Name", "id") VALUES ('O''Brien', 1);
+INSERT INTO "test"."users" ("Full Name", "id") VALUES (NULL, 2);
+
+-- Test.order"s
+INSERT INTO "Test"."order""s" ("id", "user") VALUES (10, 1);
+INSERT INTO "Test"."order""s" ("id", "user") VALUES (11, NULL);
+INSERT INTO "Test"."order""s" DEFAULT VALUES;
+
+COMMIT;
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/xerrors"
+
+	"github.com/Feresey/mtest/db"
+	"github.com/Feresey/mtest/generate"
+	"github.com/Feresey/mtest/insert"
+	"github.com/Feresey/mtest/schema"
+)
+
+// GenerateVolume генерирует записи в режиме объема и сохраняет их по мере генерации.
+// Если включена вставка, то записи одновременно загружаются в базу данных.
+func (p *GenerateCommand) GenerateVolume(
+	ctx *cli.Context,
+	gen *generate.Generator,
+	partial generate.PartialRecords,
+	format DumpFormat,
+	conf insert.Config,
+) error {
+	dumpdir, err := p.cnf.Dump.OutputDir(p.flags.outputPath.Get(ctx))
+	if err != nil {
+		return err
+	}
+	dump, err := newStreamDumper(p.log, gen.Order(), format, dumpdir)
+	if err != nil {
+		return err
+	}
+
+	generateRecords := func(w generate.RecordWriter) error {
+		warnings, err := gen.GenerateVolume(partial, w)
+		if len(warnings) > 0 {
+			p.log.Warn("generate records", zap.Errors("warnings", warnings))
+		}
+		return err
+	}
+
+	if !conf.Enabled {
+		err := generateRecords(dump)
+		return errors.Join(err, dump.Close(err))
+	}
+	if conf.Parallel > 1 {
+		p.log.Warn("volume records are inserted in one transaction, parallel insert is ignored")
+	}
+	conn, err := p.schemaLoader.Connect(ctx, p.flags.flags)
+	if err != nil {
+		return errors.Join(cli.Exit(err, 3), dump.Close(err))
+	}
+	err = insert.NewInserter(conn, p.log).Stream(ctx.Context, gen.Order(), conf,
+		func(w generate.RecordWriter) error {
+			return generateRecords(teeWriter{dump, w})
+		})
+	err = errors.Join(err, dump.Close(err))
+	if err != nil {
+		var pErr db.Error
+		if errors.As(err, &pErr) {
+			p.log.Error(pErr.Pretty())
+		}
+		return xerrors.Errorf("insert records: %w", err)
+	}
+	return nil
+}
+
+// teeWriter передает записи во все writer по очереди.
+type teeWriter []generate.RecordWriter
+
+func (t teeWriter) BeginTable(table schema.Table) error {
+	for _, w := range t {
+		if err := w.BeginTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t teeWriter) WriteRecord(table schema.Table, record generate.Record) error {
+	for _, w := range t {
+		if err := w.WriteRecord(table, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t teeWriter) EndTable(table schema.Table) error {
+	for _, w := range t {
+		if err := w.EndTable(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamDumper сохраняет записи в указанном формате по мере генерации.
+// Для CSV и JSON каждая таблица сохраняется в отдельный файл, для SQL - все таблицы в один скрипт.
+type streamDumper struct {
+	log     *zap.Logger
+	format  DumpFormat
+	dumpdir string
+	order   []schema.Table
+
+	// файл текущей таблицы или SQL скрипта
+	out io.WriteCloser
+	bw  *bufio.Writer
+	// количество записей текущей таблицы
+	count int
+
+	csv     *csv.Writer
+	json    *json.Encoder
+	columns []string
+}
+
+func newStreamDumper(
+	log *zap.Logger,
+	order []schema.Table,
+	format DumpFormat,
+	dumpdir string,
+) (*streamDumper, error) {
+	d := &streamDumper{log: log, format: format, dumpdir: dumpdir, order: order}
+	switch format {
+	case DumpFormatSQLInsert, DumpFormatSQLCopy:
+		if err := d.open(recordsDumpName + sqlExt); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(d.bw, "BEGIN;")
+		writeDeferConstraints(d.bw, order)
+	case DumpFormatCSV, DumpFormatJSON:
+	default:
+		return nil, xerrors.Errorf("dump format %q is not supported", format)
+	}
+	return d, nil
+}
+
+func (d *streamDumper) open(fileName string) error {
+	d.out = nopCloser{os.Stdout}
+	if d.dumpdir != "" {
+		file, err := os.Create(filepath.Join(d.dumpdir, fileName))
+		if err != nil {
+			return xerrors.Errorf("create output file for records: %w", err)
+		}
+		d.out = file
+	}
+	d.bw = bufio.NewWriter(d.out)
+	return nil
+}
+
+func (d *streamDumper) close() error {
+	err := errors.Join(d.bw.Flush(), d.out.Close())
+	d.out, d.bw = nil, nil
+	return err
+}
+
+func (d *streamDumper) BeginTable(table schema.Table) error {
+	d.count = 0
+	d.columns = nil
+	switch d.format {
+	case DumpFormatCSV:
+		if err := d.open(table.String() + csvExt); err != nil {
+			return err
+		}
+		d.csv = csv.NewWriter(d.bw)
+		header := maps.Keys(table.Columns)
+		sort.Strings(header)
+		return d.csv.Write(header)
+	case DumpFormatJSON:
+		if err := d.open(table.String() + ndjsonExt); err != nil {
+			return err
+		}
+		d.json = json.NewEncoder(d.bw)
+	default:
+		fmt.Fprintf(d.bw, "\n-- %s\n", table)
+	}
+	return nil
+}
+
+func (d *streamDumper) WriteRecord(table schema.Table, record generate.Record) error {
+	d.count++
+	switch d.format {
+	case DumpFormatCSV:
+		var conv CSVConverter
+		return d.csv.Write(sortByKey(conv.partialToFullMap(record, table.Columns)))
+	case DumpFormatJSON:
+		var conv JSONConverter
+		obj, err := conv.recordToMap(record)
+		if err != nil {
+			return xerrors.Errorf("record %d: %w", d.count, err)
+		}
+		return d.json.Encode(obj)
+	case DumpFormatSQLCopy:
+		if len(record.Columns) != 0 {
+			if !slices.Equal(d.columns, record.Columns) {
+				d.endCopy()
+				d.columns = slices.Clone(record.Columns)
+				fmt.Fprintf(d.bw, "%s;\n", insert.CopyQuery(table, record.Columns))
+			}
+			return insert.WriteCopyRecord(d.bw, record)
+		}
+		d.endCopy()
+	}
+	_, err := fmt.Fprintf(d.bw, "%s;\n", insert.Query(table, record))
+	return err
+}
+
+// endCopy завершает блок данных COPY.
+func (d *streamDumper) endCopy() {
+	if d.columns != nil {
+		fmt.Fprintln(d.bw, `\.`)
+		d.columns = nil
+	}
+}
+
+func (d *streamDumper) EndTable(table schema.Table) error {
+	switch d.format {
+	case DumpFormatCSV:
+		d.csv.Flush()
+		if err := errors.Join(d.csv.Error(), d.close()); err != nil {
+			return xerrors.Errorf("dump records of table %q: %w", table, err)
+		}
+	case DumpFormatJSON:
+		if err := d.close(); err != nil {
+			return xerrors.Errorf("dump records of table %q: %w", table, err)
+		}
+	case DumpFormatSQLCopy:
+		d.endCopy()
+	}
+	d.log.Info("dumped records", zap.Stringer("table", table), zap.Int("records", d.count))
+	return nil
+}
+
+// Close завершает SQL скрипт и закрывает открытый файл.
+// Если генерация завершилась ошибкой genErr, то записана только часть записей
+// и скрипт завершается ROLLBACK вместо COMMIT.
+func (d *streamDumper) Close(genErr error) error {
+	if d.out == nil {
+		return nil
+	}
+	if d.format == DumpFormatSQLInsert || d.format == DumpFormatSQLCopy {
+		d.endCopy()
+		if genErr != nil {
+			fmt.Fprintln(d.bw, "\nROLLBACK;")
+		} else {
+			writeSequenceUpdates(d.bw, d.order)
+			fmt.Fprintln(d.bw, "\nCOMMIT;")
+		}
+	}
+	return d.close()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }